@@ -0,0 +1,62 @@
+// Command whi relays captures from a Webhook Inspector token to a local receiver.
+//
+//	whi -server https://inspector.example.com -credential whk_... -target http://localhost:3000/webhooks
+//
+// Each capture is replayed against -target and the local response is reported
+// back, so it shows up with the capture under /logs/{id}/deliveries.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"webhook-inspector/internal/relay"
+)
+
+func main() {
+	server := flag.String("server", envOr("WHI_SERVER", "http://localhost:8080"), "Webhook Inspector base URL")
+	credential := flag.String("credential", os.Getenv("WHI_CREDENTIAL"), "bearer credential of a bin")
+	token := flag.String("token", os.Getenv("WHI_TOKEN"), "webhook_token cookie value, used when no credential is given")
+	target := flag.String("target", "", "local URL to replay captures against, e.g. http://localhost:3000/webhooks")
+	since := flag.Duration("since", 0, "also replay captures received within this window before connecting, e.g. 15m")
+	flag.Parse()
+
+	if *target == "" || (*credential == "" && *token == "") {
+		fmt.Fprintln(os.Stderr, "usage: whi -target <url> (-credential <whk_...> | -token <token>) [-server <url>] [-since <duration>]")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	client := &relay.Client{
+		Server:     *server,
+		Credential: *credential,
+		Token:      *token,
+		Target:     *target,
+	}
+	if *since > 0 {
+		client.Since = time.Now().Add(-*since)
+	} else {
+		client.Since = time.Now()
+	}
+
+	log.Printf("Relaying captures from %s to %s", *server, *target)
+	if err := client.Run(ctx); err != nil && err != context.Canceled {
+		log.Fatal(err)
+	}
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,355 @@
+// Package baseline freezes captures of one provider event as a golden recording and
+// reports how later captures of that event drift from it: fields added or removed,
+// types changed and enum values never seen before.
+package baseline
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"webhook-inspector/internal/models"
+)
+
+// Drift kinds
+const (
+	KindAddedField   = "added_field"
+	KindRemovedField = "removed_field"
+	KindTypeChanged  = "type_changed"
+	KindNewEnumValue = "new_enum_value"
+)
+
+// A string field is treated as an enum when it took at most this many distinct values
+// and at least one of them repeated
+const maxEnumValues = 20
+
+// Capture IDs kept per report finding
+const maxFindingIDs = 10
+
+var validName = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// ValidateName checks a baseline name is usable in a URL path
+func ValidateName(name string) error {
+	if !validName.MatchString(name) {
+		return errors.New("name must be 1-64 letters, digits, '.', '_' or '-'")
+	}
+	return nil
+}
+
+// Freeze learns a baseline from captures that all share one provider and event type
+func Freeze(name string, captures []models.WebhookPayload, now time.Time) (models.Baseline, error) {
+	if err := ValidateName(name); err != nil {
+		return models.Baseline{}, err
+	}
+	if len(captures) == 0 {
+		return models.Baseline{}, errors.New("no captures selected")
+	}
+
+	b := models.Baseline{
+		Name:      name,
+		Provider:  captures[0].Provider,
+		EventType: captures[0].EventType,
+		CreatedAt: now.UTC(),
+		Fields:    make(map[string]models.FieldShape),
+	}
+	seen := make(map[string]int)
+	values := make(map[string]map[string]int)
+	for _, c := range captures {
+		if c.Provider != b.Provider || c.EventType != b.EventType {
+			return models.Baseline{}, fmt.Errorf("captures must share one event type, got %s and %s", eventName(b.Provider, b.EventType), eventName(c.Provider, c.EventType))
+		}
+		doc, ok := decode(c.Body)
+		if !ok {
+			return models.Baseline{}, fmt.Errorf("capture %s has no JSON or form body", c.ID)
+		}
+		b.CaptureIDs = append(b.CaptureIDs, c.ID)
+
+		for path, f := range observe(doc) {
+			seen[path]++
+			shape := b.Fields[path]
+			for _, t := range f.types {
+				shape.Types = addSorted(shape.Types, t)
+			}
+			b.Fields[path] = shape
+			if values[path] == nil {
+				values[path] = make(map[string]int)
+			}
+			for _, v := range f.strings {
+				values[path][v]++
+			}
+		}
+	}
+
+	for path, shape := range b.Fields {
+		if path == "" {
+			shape.Required = true
+		} else {
+			shape.Required = seen[path] == seen[parent(path)]
+		}
+		if vals := values[path]; len(vals) > 0 && len(vals) <= maxEnumValues && repeats(vals) {
+			for v := range vals {
+				shape.Enum = append(shape.Enum, v)
+			}
+			sort.Strings(shape.Enum)
+		}
+		b.Fields[path] = shape
+	}
+	return b, nil
+}
+
+func repeats(counts map[string]int) bool {
+	for _, n := range counts {
+		if n > 1 {
+			return true
+		}
+	}
+	return false
+}
+
+func eventName(provider, eventType string) string {
+	if provider == "" && eventType == "" {
+		return "unclassified"
+	}
+	return strings.Trim(provider+"/"+eventType, "/")
+}
+
+// Applies reports whether a capture should be checked against a baseline: it has the
+// same provider and event type and isn't one of the baseline's own captures
+func Applies(b models.Baseline, p models.WebhookPayload) bool {
+	if p.Provider != b.Provider || p.EventType != b.EventType {
+		return false
+	}
+	for _, id := range b.CaptureIDs {
+		if id == p.ID {
+			return false
+		}
+	}
+	return true
+}
+
+// Check lists how a capture's body departs from a baseline, sorted by path
+func Check(b models.Baseline, p models.WebhookPayload) []models.Drift {
+	doc, ok := decode(p.Body)
+	if !ok {
+		return []models.Drift{{Baseline: b.Name, Kind: KindTypeChanged, Path: "", Expected: strings.Join(b.Fields[""].Types, "|"), Actual: "text"}}
+	}
+	fields := observe(doc)
+
+	var drift []models.Drift
+	add := func(kind, path, expected, actual string) {
+		drift = append(drift, models.Drift{Baseline: b.Name, Kind: kind, Path: path, Expected: expected, Actual: actual})
+	}
+	for path, f := range fields {
+		shape, known := b.Fields[path]
+		if !known {
+			// Only the outermost new field is reported, not everything inside it
+			if path == "" || b.Fields[parent(path)].Types != nil {
+				add(KindAddedField, path, "", strings.Join(f.types, "|"))
+			}
+			continue
+		}
+		for _, t := range f.types {
+			if !contains(shape.Types, t) {
+				add(KindTypeChanged, path, strings.Join(shape.Types, "|"), t)
+			}
+		}
+		if len(shape.Enum) > 0 {
+			for _, v := range f.strings {
+				if !contains(shape.Enum, v) {
+					add(KindNewEnumValue, path, "", v)
+				}
+			}
+		}
+	}
+	for path, shape := range b.Fields {
+		if !shape.Required || path == "" || strings.HasSuffix(path, "/*") {
+			// Array items are only missing when the array is empty, which isn't drift
+			continue
+		}
+		if _, present := fields[path]; present {
+			continue
+		}
+		// A missing or retyped parent is reported on its own
+		if container, ok := fields[parent(path)]; ok && (contains(container.types, "object") || contains(container.types, "array")) {
+			add(KindRemovedField, path, strings.Join(shape.Types, "|"), "")
+		}
+	}
+
+	sort.Slice(drift, func(i, j int) bool {
+		if drift[i].Path != drift[j].Path {
+			return drift[i].Path < drift[j].Path
+		}
+		if drift[i].Kind != drift[j].Kind {
+			return drift[i].Kind < drift[j].Kind
+		}
+		return drift[i].Actual < drift[j].Actual
+	})
+	return drift
+}
+
+// CheckAll runs every baseline that applies to the capture
+func CheckAll(baselines []models.Baseline, p models.WebhookPayload) []models.Drift {
+	var drift []models.Drift
+	for _, b := range baselines {
+		if Applies(b, p) {
+			drift = append(drift, Check(b, p)...)
+		}
+	}
+	return drift
+}
+
+// Report summarises drift across the captures checked against a baseline
+type Report struct {
+	Baseline string    `json:"baseline"`
+	Checked  int       `json:"checked"`
+	Drifted  int       `json:"drifted"`
+	Findings []Finding `json:"findings"`
+}
+
+// Finding is one kind of drift and the captures showing it
+type Finding struct {
+	models.Drift
+	Count     int       `json:"count"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	// The most recent captures showing it
+	CaptureIDs []string `json:"capture_ids"`
+}
+
+// NewReport checks every applicable capture, given oldest first, against a baseline.
+// Findings are ordered by path.
+func NewReport(b models.Baseline, captures []models.WebhookPayload) Report {
+	report := Report{Baseline: b.Name, Findings: []Finding{}}
+	index := make(map[models.Drift]int)
+	for _, c := range captures {
+		if !Applies(b, c) {
+			continue
+		}
+		report.Checked++
+		drift := Check(b, c)
+		if len(drift) > 0 {
+			report.Drifted++
+		}
+		for _, d := range drift {
+			d.Baseline = ""
+			i, ok := index[d]
+			if !ok {
+				i = len(report.Findings)
+				index[d] = i
+				report.Findings = append(report.Findings, Finding{Drift: d, FirstSeen: c.Timestamp})
+			}
+			f := &report.Findings[i]
+			f.Count++
+			f.LastSeen = c.Timestamp
+			f.CaptureIDs = append(f.CaptureIDs, c.ID)
+			if len(f.CaptureIDs) > maxFindingIDs {
+				f.CaptureIDs = f.CaptureIDs[1:]
+			}
+		}
+	}
+	sort.SliceStable(report.Findings, func(i, j int) bool {
+		return report.Findings[i].Path < report.Findings[j].Path
+	})
+	return report
+}
+
+// What one capture holds at a path
+type field struct {
+	types   []string
+	strings []string
+}
+
+// Collect every path in a document, with * for array indexes
+func observe(doc interface{}) map[string]*field {
+	fields := make(map[string]*field)
+	var walk func(node interface{}, path string)
+	walk = func(node interface{}, path string) {
+		f := fields[path]
+		if f == nil {
+			f = &field{}
+			fields[path] = f
+		}
+		f.types = addSorted(f.types, typeOf(node))
+
+		switch v := node.(type) {
+		case map[string]interface{}:
+			for k, child := range v {
+				walk(child, path+"/"+escape(k))
+			}
+		case []interface{}:
+			for _, child := range v {
+				walk(child, path+"/*")
+			}
+		case string:
+			f.strings = addSorted(f.strings, v)
+		}
+	}
+	walk(doc, "")
+	return fields
+}
+
+func typeOf(v interface{}) string {
+	switch v.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case json.Number:
+		return "number"
+	case bool:
+		return "boolean"
+	default:
+		return "null"
+	}
+}
+
+func parent(path string) string {
+	return path[:strings.LastIndex(path, "/")]
+}
+
+func escape(key string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+}
+
+func contains(list []string, s string) bool {
+	i := sort.SearchStrings(list, s)
+	return i < len(list) && list[i] == s
+}
+
+func addSorted(list []string, s string) []string {
+	i := sort.SearchStrings(list, s)
+	if i < len(list) && list[i] == s {
+		return list
+	}
+	list = append(list, "")
+	copy(list[i+1:], list[i:])
+	list[i] = s
+	return list
+}
+
+// Decode a JSON body, or a form body as a map of first values like rules do
+func decode(body string) (interface{}, bool) {
+	dec := json.NewDecoder(strings.NewReader(body))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err == nil {
+		return doc, true
+	}
+
+	form, err := url.ParseQuery(body)
+	if err != nil || len(form) == 0 {
+		return nil, false
+	}
+	fields := make(map[string]interface{}, len(form))
+	for k := range form {
+		fields[k] = form.Get(k)
+	}
+	return fields, true
+}
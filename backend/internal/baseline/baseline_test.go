@@ -0,0 +1,104 @@
+package baseline
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"webhook-inspector/internal/models"
+)
+
+func charge(id, body string) models.WebhookPayload {
+	return models.WebhookPayload{ID: id, Provider: "stripe", EventType: "charge.succeeded", Body: body}
+}
+
+func frozen(t *testing.T) models.Baseline {
+	t.Helper()
+	b, err := Freeze("charges", []models.WebhookPayload{
+		charge("1", `{"id":"ch_1","status":"paid","amount":100,"meta":{"a":1},"items":[{"sku":"x"}],"note":null}`),
+		charge("2", `{"id":"ch_2","status":"paid","amount":250,"meta":{"a":2},"items":[]}`),
+		charge("3", `{"id":"ch_3","status":"pending","amount":300,"meta":{"a":3},"items":[{"sku":"y"}]}`),
+	}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestFreeze(t *testing.T) {
+	b := frozen(t)
+	if len(b.CaptureIDs) != 3 || b.Provider != "stripe" || b.EventType != "charge.succeeded" {
+		t.Errorf("baseline = %+v", b)
+	}
+	status, id, note := b.Fields["/status"], b.Fields["/id"], b.Fields["/note"]
+	if !status.Required || strings.Join(status.Enum, ",") != "paid,pending" {
+		t.Errorf("/status = %+v", status)
+	}
+	// Every ID differs, so it isn't an enum
+	if id.Enum != nil || note.Required || note.Types[0] != "null" {
+		t.Errorf("/id = %+v, /note = %+v", id, note)
+	}
+	if f := b.Fields["/items/*/sku"]; !f.Required || f.Types[0] != "string" {
+		t.Errorf("/items/*/sku = %+v", f)
+	}
+
+	if _, err := Freeze("mixed", []models.WebhookPayload{charge("1", "{}"), {ID: "2", Provider: "github", Body: "{}"}}, time.Now()); err == nil {
+		t.Error("expected mixed event types to be rejected")
+	}
+	if _, err := Freeze("bad name", []models.WebhookPayload{charge("1", "{}")}, time.Now()); err == nil {
+		t.Error("expected invalid name to be rejected")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	b := frozen(t)
+
+	drift := Check(b, charge("4", `{"id":"ch_4","status":"refunded","amount":"300","meta":{"a":4,"extra":{"deep":true}},"items":[{"sku":"z"}],"note":"hi"}`))
+	var got []string
+	for _, d := range drift {
+		got = append(got, fmt.Sprintf("%s %s %s>%s", d.Kind, d.Path, d.Expected, d.Actual))
+	}
+	want := []string{
+		"type_changed /amount number>string",
+		"added_field /meta/extra >object",
+		"type_changed /note null>string",
+		"new_enum_value /status >refunded",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("drift =\n%s", strings.Join(got, "\n"))
+	}
+
+	// A missing object is reported once, not field by field
+	drift = Check(b, charge("5", `{"id":"ch_5","status":"paid","amount":1,"items":[]}`))
+	if len(drift) != 1 || drift[0].Kind != KindRemovedField || drift[0].Path != "/meta" || drift[0].Baseline != "charges" {
+		t.Errorf("drift = %+v", drift)
+	}
+
+	if len(CheckAll([]models.Baseline{b}, charge("1", `{}`))) != 0 {
+		t.Error("a baseline's own capture was checked")
+	}
+	if len(CheckAll([]models.Baseline{b}, models.WebhookPayload{ID: "6", Provider: "github", Body: `{}`})) != 0 {
+		t.Error("a capture of another event type was checked")
+	}
+}
+
+func TestNewReport(t *testing.T) {
+	b := frozen(t)
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	var captures []models.WebhookPayload
+	for i, status := range []string{"paid", "refunded", "refunded"} {
+		c := charge(fmt.Sprint(10+i), `{"id":"x","status":"`+status+`","amount":1,"meta":{"a":1},"items":[]}`)
+		c.Timestamp = base.Add(time.Duration(i) * time.Minute)
+		captures = append(captures, c)
+	}
+
+	report := NewReport(b, captures)
+	if report.Checked != 3 || report.Drifted != 2 || len(report.Findings) != 1 {
+		t.Fatalf("report = %+v", report)
+	}
+	f := report.Findings[0]
+	if f.Count != 2 || f.Actual != "refunded" || !f.FirstSeen.Equal(base.Add(time.Minute)) || strings.Join(f.CaptureIDs, ",") != "11,12" {
+		t.Errorf("finding = %+v", f)
+	}
+}
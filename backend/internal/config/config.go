@@ -16,8 +16,105 @@ var (
 	WebhookDataTTL   = getEnvDuration("WEBHOOK_DATA_TTL", 24*time.Hour)
 	RateLimitTTL     = getEnvDuration("RATE_LIMIT_TTL", 24*time.Hour)
 	SessionCookieTTL = getEnvInt("SESSION_COOKIE_TTL", 86400*3) // 3 days in seconds
+
+	// Ephemeral bins created via POST /bins
+	DefaultBinTTL       = getEnvDuration("DEFAULT_BIN_TTL", 10*time.Minute)
+	AnonymousMaxBinTTL  = getEnvDuration("ANONYMOUS_MAX_BIN_TTL", time.Hour)
+	PrivilegedMaxBinTTL = getEnvDuration("PRIVILEGED_MAX_BIN_TTL", 7*24*time.Hour)
+	BinSweepInterval    = getEnvDuration("BIN_SWEEP_INTERVAL", time.Minute)
+	// Bins one client IP may create per BinCreateWindow
+	BinCreateLimit  = getEnvInt("BIN_CREATE_LIMIT", 30)
+	BinCreateWindow = getEnvDuration("BIN_CREATE_WINDOW", time.Hour)
+
+	// Forwarding of captures to per-endpoint targets
+	ForwardWorkers     = getEnvInt("FORWARD_WORKERS", 4)
+	ForwardMaxAttempts = getEnvInt("FORWARD_MAX_ATTEMPTS", 5)
+	ForwardTimeout     = getEnvDuration("FORWARD_TIMEOUT", 10*time.Second)
+	ForwardBaseBackoff = getEnvDuration("FORWARD_BASE_BACKOFF", 2*time.Second)
+	ForwardMaxBackoff  = getEnvDuration("FORWARD_MAX_BACKOFF", 5*time.Minute)
+	ForwardMaxTargets  = getEnvInt("FORWARD_MAX_TARGETS", 5)
+	ResponseBodyLimit  = getEnvInt("RESPONSE_BODY_LIMIT", 64*1024)
+
+	// Forwards, replays, simulations and notifications may reach loopback, private and
+	// link-local addresses only when this is "true"
+	OutboundAllowPrivate = os.Getenv("OUTBOUND_ALLOW_PRIVATE") == "true"
+
+	// Bulk replay limits
+	ReplayMaxConcurrency = getEnvInt("REPLAY_MAX_CONCURRENCY", 16)
+	ReplayMaxRate        = float64(getEnvInt("REPLAY_MAX_RATE", 100))
+
+	// Bulk replays a token may have running at once, across every instance
+	ReplayMaxBatchesPerToken = getEnvInt("REPLAY_MAX_BATCHES_PER_TOKEN", 3)
+
+	// Fan-out sinks; SINKS is a JSON array of instance-wide sinks applied to every token
+	Sinks           = os.Getenv("SINKS")
+	SinkWorkers     = getEnvInt("SINK_WORKERS", 2)
+	SinkMaxAttempts = getEnvInt("SINK_MAX_ATTEMPTS", 10)
+	SinkMaxPerToken = getEnvInt("SINK_MAX_PER_TOKEN", 5)
+	SinkFileDir     = os.Getenv("SINK_FILE_DIR") // per-endpoint file sinks are disabled when empty
+	NATSURL         = os.Getenv("NATS_URL")
+
+	// Size at which an endpoint file sink stops accepting captures
+	SinkFileMaxBytes = int64(getEnvInt("SINK_FILE_MAX_BYTES", 100<<20))
+
+	// Per-endpoint rules; pinned captures outlive WEBHOOK_DATA_TTL (0 keeps them forever)
+	RulesMaxPerToken = getEnvInt("RULES_MAX_PER_TOKEN", 20)
+	PinnedCaptureTTL = getEnvDuration("PINNED_CAPTURE_TTL", 30*24*time.Hour)
+
+	// Longest retention an endpoint may choose instead of WEBHOOK_DATA_TTL
+	AnonymousMaxRetention  = getEnvDuration("ANONYMOUS_MAX_RETENTION", 24*time.Hour)
+	PrivilegedMaxRetention = getEnvDuration("PRIVILEGED_MAX_RETENTION", 30*24*time.Hour)
+
+	// Most captures a token may keep pinned, by rules or by hand
+	AnonymousPinQuota  = getEnvInt("ANONYMOUS_PIN_QUOTA", 20)
+	PrivilegedPinQuota = getEnvInt("PRIVILEGED_PIN_QUOTA", 200)
+
+	// Most captures a token may keep; imports are checked against it instead of the rate limit
+	AnonymousStorageQuota  = getEnvInt("ANONYMOUS_STORAGE_QUOTA", 1000)
+	PrivilegedStorageQuota = getEnvInt("PRIVILEGED_STORAGE_QUOTA", 10000)
+	ImportMaxBytes         = int64(getEnvInt("IMPORT_MAX_BYTES", 10<<20))
+
+	// Read-only share links. SHARE_SIGNING_KEY signs their URLs and must be the same on
+	// every instance, so it is required with the Redis store; the memory store uses a
+	// random key when it is empty, so links die with the process.
+	ShareSigningKey       = os.Getenv("SHARE_SIGNING_KEY")
+	DefaultShareTTL       = getEnvDuration("DEFAULT_SHARE_TTL", 24*time.Hour)
+	AnonymousMaxShareTTL  = getEnvDuration("ANONYMOUS_MAX_SHARE_TTL", 24*time.Hour)
+	PrivilegedMaxShareTTL = getEnvDuration("PRIVILEGED_MAX_SHARE_TTL", 30*24*time.Hour)
+	ShareMaxPerToken      = getEnvInt("SHARE_MAX_PER_TOKEN", 50)
+
+	// Wrong share link passwords allowed per link and per client IP before they are
+	// locked out, until SHARE_PASSWORD_LOCKOUT passes without another failure
+	SharePasswordMaxFailures = getEnvInt("SHARE_PASSWORD_MAX_FAILURES", 10)
+	SharePasswordLockout     = getEnvDuration("SHARE_PASSWORD_LOCKOUT", 15*time.Minute)
+
+	// Simulations per token that may be waiting on a retry at once
+	SimulateMaxPending = getEnvInt("SIMULATE_MAX_PENDING", 20)
+
+	// Largest page GET /logs will return
+	LogsMaxPageSize = getEnvInt("LOGS_MAX_PAGE_SIZE", 500)
+
+	// Most search index terms kept per capture; larger captures are always scanned
+	SearchMaxTerms = getEnvInt("SEARCH_MAX_TERMS", 2000)
+
+	// Storage backend: "redis" (default) or "memory" for a single process without Redis,
+	// which disables forwarding, replays, simulations, sinks and the relay
+	StoreBackend = getEnv("STORE", "redis")
+	// How often the in-memory store drops expired entries
+	MemorySweepInterval = getEnvDuration("MEMORY_SWEEP_INTERVAL", time.Minute)
+
+	// Public URL used when handing out ingest links; derived from the request when empty
+	PublicBaseURL = os.Getenv("PUBLIC_BASE_URL")
 )
 
+// Helper function to get environment variable with default
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
 // Helper function to get environment variable as int with default
 func getEnvInt(key string, defaultValue int) int {
 	if value := os.Getenv(key); value != "" {
@@ -0,0 +1,296 @@
+// Package diff compares two captures: JSON bodies path by path, other bodies as a
+// unified text diff, and headers by name.
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"webhook-inspector/internal/jsonpatch"
+	"webhook-inspector/internal/models"
+)
+
+// Change operations
+const (
+	OpAdded   = "added"
+	OpRemoved = "removed"
+	OpChanged = "changed"
+)
+
+// Body formats
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// Options tune what counts as a difference
+type Options struct {
+	// JSON pointers to skip; a segment may be a glob such as *_at, and ** matches any
+	// number of segments (e.g. /**/id)
+	Ignore []string
+	// Header names to skip, case-insensitively
+	IgnoreHeaders []string
+}
+
+// Change is one differing JSON path or header; Old and New are JSON values
+type Change struct {
+	Op   string          `json:"op"`
+	Path string          `json:"path"`
+	Old  json.RawMessage `json:"old,omitempty"`
+	New  json.RawMessage `json:"new,omitempty"`
+}
+
+// Result is the difference from capture A to capture B
+type Result struct {
+	Equal   bool     `json:"equal"`
+	Body    BodyDiff `json:"body"`
+	Headers []Change `json:"headers"`
+}
+
+// BodyDiff holds path changes for JSON bodies, or a unified diff for anything else
+type BodyDiff struct {
+	Format  string   `json:"format"`
+	Changes []Change `json:"changes,omitempty"`
+	Unified string   `json:"unified,omitempty"`
+}
+
+// Compare diffs two captures
+func Compare(a, b models.WebhookPayload, opts Options) (*Result, error) {
+	ignore := make([][]string, 0, len(opts.Ignore))
+	for _, pattern := range opts.Ignore {
+		tokens, err := jsonpatch.ParsePointer(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("ignore %q: %w", pattern, err)
+		}
+		for _, tok := range tokens {
+			if _, err := path.Match(tok, ""); err != nil {
+				return nil, fmt.Errorf("ignore %q: bad pattern %q", pattern, tok)
+			}
+		}
+		ignore = append(ignore, tokens)
+	}
+
+	res := &Result{Headers: compareHeaders(a.Headers, b.Headers, opts.IgnoreHeaders)}
+
+	docA, okA := decode(a.Body)
+	docB, okB := decode(b.Body)
+	if okA && okB {
+		res.Body.Format = FormatJSON
+		d := differ{ignore: ignore}
+		d.compare(nil, docA, docB)
+		res.Body.Changes = d.changes
+		res.Equal = len(d.changes) == 0
+	} else {
+		res.Body.Format = FormatText
+		res.Body.Unified = Unified(a.Body, b.Body, "a/"+a.ID, "b/"+b.ID)
+		res.Equal = res.Body.Unified == ""
+	}
+	res.Equal = res.Equal && len(res.Headers) == 0
+	return res, nil
+}
+
+func decode(body string) (interface{}, bool) {
+	dec := json.NewDecoder(strings.NewReader(body))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil || dec.More() {
+		return nil, false
+	}
+	return doc, true
+}
+
+type differ struct {
+	ignore  [][]string
+	changes []Change
+}
+
+func (d *differ) add(op string, tokens []string, before, after interface{}) {
+	c := Change{Op: op, Path: pointer(tokens)}
+	if op != OpAdded {
+		c.Old, _ = json.Marshal(before)
+	}
+	if op != OpRemoved {
+		c.New, _ = json.Marshal(after)
+	}
+	d.changes = append(d.changes, c)
+}
+
+func (d *differ) ignored(tokens []string) bool {
+	for _, pattern := range d.ignore {
+		if matchPath(pattern, tokens) {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *differ) compare(tokens []string, a, b interface{}) {
+	if d.ignored(tokens) {
+		return
+	}
+
+	switch av := a.(type) {
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok {
+			d.add(OpChanged, tokens, a, b)
+			return
+		}
+		keys := make([]string, 0, len(av)+len(bv))
+		for k := range av {
+			keys = append(keys, k)
+		}
+		for k := range bv {
+			if _, seen := av[k]; !seen {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			child := append(tokens[:len(tokens):len(tokens)], k)
+			va, inA := av[k]
+			vb, inB := bv[k]
+			switch {
+			case !inB:
+				if !d.ignored(child) {
+					d.add(OpRemoved, child, va, nil)
+				}
+			case !inA:
+				if !d.ignored(child) {
+					d.add(OpAdded, child, nil, vb)
+				}
+			default:
+				d.compare(child, va, vb)
+			}
+		}
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok {
+			d.add(OpChanged, tokens, a, b)
+			return
+		}
+		for i := 0; i < max(len(av), len(bv)); i++ {
+			child := append(tokens[:len(tokens):len(tokens)], strconv.Itoa(i))
+			switch {
+			case i >= len(bv):
+				if !d.ignored(child) {
+					d.add(OpRemoved, child, av[i], nil)
+				}
+			case i >= len(av):
+				if !d.ignored(child) {
+					d.add(OpAdded, child, nil, bv[i])
+				}
+			default:
+				d.compare(child, av[i], bv[i])
+			}
+		}
+	default:
+		if !scalarEqual(a, b) {
+			d.add(OpChanged, tokens, a, b)
+		}
+	}
+}
+
+// 1 and 1.0 are the same number
+func scalarEqual(a, b interface{}) bool {
+	na, okA := a.(json.Number)
+	nb, okB := b.(json.Number)
+	if okA && okB {
+		fa, errA := na.Float64()
+		fb, errB := nb.Float64()
+		if errA == nil && errB == nil {
+			return fa == fb
+		}
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// Match tokens against a pattern whose segments are globs; ** spans any number of them
+func matchPath(pattern, tokens []string) bool {
+	if len(pattern) == 0 {
+		return len(tokens) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(tokens); i++ {
+			if matchPath(pattern[1:], tokens[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(tokens) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], tokens[0]); !ok {
+		return false
+	}
+	return matchPath(pattern[1:], tokens[1:])
+}
+
+func pointer(tokens []string) string {
+	var b strings.Builder
+	for _, tok := range tokens {
+		b.WriteString("/")
+		b.WriteString(strings.ReplaceAll(strings.ReplaceAll(tok, "~", "~0"), "/", "~1"))
+	}
+	return b.String()
+}
+
+func compareHeaders(a, b map[string][]string, ignore []string) []Change {
+	skip := make(map[string]bool, len(ignore))
+	for _, name := range ignore {
+		skip[http.CanonicalHeaderKey(name)] = true
+	}
+	canonical := func(h map[string][]string) map[string][]string {
+		out := make(map[string][]string, len(h))
+		for name, values := range h {
+			if key := http.CanonicalHeaderKey(name); !skip[key] {
+				out[key] = append(out[key], values...)
+			}
+		}
+		return out
+	}
+	ha, hb := canonical(a), canonical(b)
+
+	names := make([]string, 0, len(ha)+len(hb))
+	for name := range ha {
+		names = append(names, name)
+	}
+	for name := range hb {
+		if _, seen := ha[name]; !seen {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	changes := []Change{}
+	for _, name := range names {
+		va, inA := ha[name]
+		vb, inB := hb[name]
+		c := Change{Path: name}
+		switch {
+		case !inB:
+			c.Op = OpRemoved
+		case !inA:
+			c.Op = OpAdded
+		case !reflect.DeepEqual(va, vb):
+			c.Op = OpChanged
+		default:
+			continue
+		}
+		if inA {
+			c.Old, _ = json.Marshal(va)
+		}
+		if inB {
+			c.New, _ = json.Marshal(vb)
+		}
+		changes = append(changes, c)
+	}
+	return changes
+}
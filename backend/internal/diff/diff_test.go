@@ -0,0 +1,92 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+
+	"webhook-inspector/internal/models"
+)
+
+func capture(id, body string, headers map[string][]string) models.WebhookPayload {
+	return models.WebhookPayload{ID: id, Body: body, Headers: headers}
+}
+
+func TestCompareJSON(t *testing.T) {
+	a := capture("a", `{"id":"evt_1","amount":1.0,"items":[{"id":1,"sku":"x"}],"gone":true,"created_at":1}`, nil)
+	b := capture("b", `{"id":"evt_2","amount":1,"items":[{"id":2,"sku":"y"},{"id":3}],"new":null,"created_at":2}`, nil)
+
+	res, err := Compare(a, b, Options{Ignore: []string{"/**/id", "/*_at"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range res.Body.Changes {
+		got = append(got, c.Op+" "+c.Path+" "+string(c.Old)+" "+string(c.New))
+	}
+	want := []string{
+		`removed /gone true `,
+		`changed /items/0/sku "x" "y"`,
+		`added /items/1  {"id":3}`,
+		`added /new  null`,
+	}
+	if res.Equal || res.Body.Format != FormatJSON || strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("changes =\n%s", strings.Join(got, "\n"))
+	}
+
+	same, _ := Compare(a, a, Options{})
+	if !same.Equal || len(same.Body.Changes) != 0 || same.Headers == nil {
+		t.Errorf("self diff = %+v", same)
+	}
+
+	for _, bad := range []string{"id", "/[x"} {
+		if _, err := Compare(a, b, Options{Ignore: []string{bad}}); err == nil {
+			t.Errorf("ignore %q accepted", bad)
+		}
+	}
+}
+
+func TestCompareHeaders(t *testing.T) {
+	a := capture("a", "", map[string][]string{"X-Id": {"1"}, "content-type": {"text/plain"}, "X-Old": {"v"}})
+	b := capture("b", "", map[string][]string{"x-id": {"2"}, "Content-Type": {"text/plain"}, "X-New": {"v"}})
+
+	res, _ := Compare(a, b, Options{IgnoreHeaders: []string{"x-id"}})
+	if len(res.Headers) != 2 || res.Headers[0].Op != OpAdded || res.Headers[0].Path != "X-New" ||
+		res.Headers[1].Op != OpRemoved || res.Headers[1].Path != "X-Old" || res.Equal {
+		t.Errorf("headers = %+v", res.Headers)
+	}
+}
+
+func TestUnified(t *testing.T) {
+	a := "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\n"
+	b := "one\ntwo\nthree\nfour\nFIVE\nsix\nseven\neight\nnine\nten\neleven\n"
+
+	want := `--- a/x
++++ b/x
+@@ -2,9 +2,10 @@
+ two
+ three
+ four
+-five
++FIVE
+ six
+ seven
+ eight
+ nine
+ ten
++eleven
+`
+	if got := Unified(a, b, "a/x", "b/x"); got != want {
+		t.Errorf("Unified =\n%s", got)
+	}
+	if got := Unified(a, a, "a", "b"); got != "" {
+		t.Errorf("equal texts = %q", got)
+	}
+	if got := Unified("", "x", "a", "b"); !strings.Contains(got, "@@ -0,0 +1,1 @@\n+x\n") {
+		t.Errorf("from empty = %q", got)
+	}
+
+	res, _ := Compare(capture("a", "k=1", nil), capture("b", "k=2", nil), Options{})
+	if res.Body.Format != FormatText || !strings.Contains(res.Body.Unified, "-k=1\n+k=2\n") {
+		t.Errorf("form body = %+v", res.Body)
+	}
+}
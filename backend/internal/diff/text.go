@@ -0,0 +1,149 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Lines of context around each hunk
+const contextLines = 3
+
+// Largest LCS table built; beyond it the differing middle is shown as replaced wholesale
+const maxCells = 4_000_000
+
+type edit struct {
+	kind byte // ' ', '-' or '+'
+	line string
+	a, b int // line indexes in each text before this edit
+}
+
+// Unified returns a unified diff of two texts, or "" when they are equal
+func Unified(a, b, nameA, nameB string) string {
+	if a == b {
+		return ""
+	}
+	edits := lineEdits(splitLines(a), splitLines(b))
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", nameA, nameB)
+	for start := 0; start < len(edits); {
+		// Find the next change and extend the hunk while changes are close together
+		first := start
+		for first < len(edits) && edits[first].kind == ' ' {
+			first++
+		}
+		if first == len(edits) {
+			break
+		}
+		last := first
+		for i := first; i < len(edits); i++ {
+			if edits[i].kind != ' ' {
+				if i-last-1 > 2*contextLines {
+					break
+				}
+				last = i
+			}
+		}
+
+		from := max(start, first-contextLines)
+		to := min(len(edits), last+1+contextLines)
+		writeHunk(&out, edits[from:to])
+		start = to
+	}
+	return out.String()
+}
+
+func writeHunk(out *strings.Builder, hunk []edit) {
+	countA, countB := 0, 0
+	for _, e := range hunk {
+		if e.kind != '+' {
+			countA++
+		}
+		if e.kind != '-' {
+			countB++
+		}
+	}
+	// Empty ranges name the line before them, as diff -u does
+	startA, startB := hunk[0].a+1, hunk[0].b+1
+	if countA == 0 {
+		startA--
+	}
+	if countB == 0 {
+		startB--
+	}
+	fmt.Fprintf(out, "@@ -%d,%d +%d,%d @@\n", startA, countA, startB, countB)
+	for _, e := range hunk {
+		out.WriteByte(e.kind)
+		out.WriteString(e.line)
+		out.WriteByte('\n')
+	}
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// Edit script from a to b via the longest common subsequence of the differing middle
+func lineEdits(a, b []string) []edit {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+
+	var edits []edit
+	for i := 0; i < prefix; i++ {
+		edits = append(edits, edit{' ', a[i], i, i})
+	}
+
+	i, j := 0, 0
+	if len(midA)*len(midB) <= maxCells {
+		// lcs[i][j] is the LCS length of midA[i:] and midB[j:]
+		lcs := make([][]int, len(midA)+1)
+		for i := range lcs {
+			lcs[i] = make([]int, len(midB)+1)
+		}
+		for i := len(midA) - 1; i >= 0; i-- {
+			for j := len(midB) - 1; j >= 0; j-- {
+				if midA[i] == midB[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else {
+					lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+				}
+			}
+		}
+		for i < len(midA) && j < len(midB) {
+			switch {
+			case midA[i] == midB[j]:
+				edits = append(edits, edit{' ', midA[i], prefix + i, prefix + j})
+				i++
+				j++
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				edits = append(edits, edit{'-', midA[i], prefix + i, prefix + j})
+				i++
+			default:
+				edits = append(edits, edit{'+', midB[j], prefix + i, prefix + j})
+				j++
+			}
+		}
+	}
+	for ; i < len(midA); i++ {
+		edits = append(edits, edit{'-', midA[i], prefix + i, prefix + j})
+	}
+	for ; j < len(midB); j++ {
+		edits = append(edits, edit{'+', midB[j], prefix + len(midA), prefix + j})
+	}
+
+	for k := 0; k < suffix; k++ {
+		ia, ib := len(a)-suffix+k, len(b)-suffix+k
+		edits = append(edits, edit{' ', a[ia], ia, ib})
+	}
+	return edits
+}
@@ -0,0 +1,35 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+
+	"webhook-inspector/internal/models"
+	"webhook-inspector/internal/redis"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+func channel(token string) string {
+	return "captures:" + token
+}
+
+// Publish announces a newly stored capture to every live subscriber of the token
+func Publish(ctx context.Context, token string, payload models.WebhookPayload) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return redis.Client.Publish(ctx, channel(token), data).Err()
+}
+
+// Subscribe returns a subscription to the token's new captures; callers must Close it
+func Subscribe(ctx context.Context, token string) (*goredis.PubSub, error) {
+	sub := redis.Client.Subscribe(ctx, channel(token))
+	// Wait for the confirmation so no capture published after this call is missed
+	if _, err := sub.Receive(ctx); err != nil {
+		sub.Close()
+		return nil, err
+	}
+	return sub, nil
+}
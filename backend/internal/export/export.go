@@ -0,0 +1,445 @@
+// Package export writes captures in formats other tools understand: HAR, NDJSON, CSV,
+// Postman collections and curl scripts.
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"webhook-inspector/internal/forward"
+	"webhook-inspector/internal/models"
+)
+
+// Options apply to every format
+type Options struct {
+	// Ingest URL the captures were sent to; request URLs are this plus each capture's sub-path
+	BaseURL string
+}
+
+// Format is an export format
+type Format struct {
+	Name        string
+	ContentType string
+	Extension   string
+	write       func(w io.Writer, captures []models.WebhookPayload, opts Options) error
+}
+
+var formats = map[string]Format{
+	"har":     {"har", "application/json", "har", writeHAR},
+	"ndjson":  {"ndjson", "application/x-ndjson", "ndjson", writeNDJSON},
+	"csv":     {"csv", "text/csv; charset=utf-8", "csv", writeCSV},
+	"postman": {"postman", "application/json", "postman_collection.json", writePostman},
+	"curl":    {"curl", "text/x-shellscript; charset=utf-8", "sh", writeCurl},
+}
+
+// Names lists the supported formats
+func Names() []string {
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Lookup returns the format with the given name
+func Lookup(name string) (Format, bool) {
+	f, ok := formats[name]
+	return f, ok
+}
+
+// Write streams captures to w, one record at a time
+func (f Format) Write(w io.Writer, captures []models.WebhookPayload, opts Options) error {
+	return f.write(w, captures, opts)
+}
+
+// RequestURL is where a capture was sent relative to base: base plus its sub-path and query
+func RequestURL(p models.WebhookPayload, base string) string {
+	u := strings.TrimSuffix(base, "/") + p.Path
+	if p.Query != "" {
+		u += "?" + p.Query
+	}
+	return u
+}
+
+// Headers in a stable order, one entry per value
+func sortedHeaders(headers map[string][]string) [][2]string {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var out [][2]string
+	for _, name := range names {
+		for _, value := range headers[name] {
+			out = append(out, [2]string{name, value})
+		}
+	}
+	return out
+}
+
+// Headers a sender can't meaningfully replay, as for forwards
+func replayable(name string) bool {
+	for _, stripped := range forward.DefaultStripHeaders {
+		if strings.EqualFold(name, stripped) {
+			return false
+		}
+	}
+	return true
+}
+
+func writeNDJSON(w io.Writer, captures []models.WebhookPayload, _ Options) error {
+	enc := json.NewEncoder(w)
+	for _, p := range captures {
+		if err := enc.Encode(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeCSV(w io.Writer, captures []models.WebhookPayload, _ Options) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"id", "timestamp", "method", "path", "query", "provider", "event_type", "tags", "headers", "body"})
+	for _, p := range captures {
+		headers, err := json.Marshal(p.Headers)
+		if err != nil {
+			return err
+		}
+		row := []string{
+			p.ID, p.Timestamp.Format(time.RFC3339Nano), p.Method, p.Path, p.Query,
+			p.Provider, p.EventType, strings.Join(p.Tags, ";"), string(headers), p.Body,
+		}
+		for i, cell := range row {
+			row[i] = csvSafe(cell)
+		}
+		cw.Write(row)
+		// Flush per row so large exports stream
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Spreadsheets run cells starting with these as formulas
+const csvFormulaPrefixes = "=+-@\t\r"
+
+// Quote a sender-controlled cell so spreadsheets show it as text instead of running it
+func csvSafe(cell string) string {
+	if cell != "" && strings.ContainsRune(csvFormulaPrefixes, rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+	Comment         string      `json:"comment,omitempty"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+// HAR 1.2 timings; -1 marks phases that don't apply to an inbound request
+type harTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+func harHeaders(headers map[string][]string) []harNameValue {
+	out := []harNameValue{}
+	for _, h := range sortedHeaders(headers) {
+		out = append(out, harNameValue{Name: h[0], Value: h[1]})
+	}
+	return out
+}
+
+func newHAREntry(p models.WebhookPayload, opts Options) harEntry {
+	contentType := http.Header(p.Headers).Get("Content-Type")
+	req := harRequest{
+		Method:      p.Method,
+		URL:         RequestURL(p, opts.BaseURL),
+		HTTPVersion: "HTTP/1.1",
+		Cookies:     []harNameValue{},
+		Headers:     harHeaders(p.Headers),
+		QueryString: []harNameValue{},
+		HeadersSize: -1,
+		BodySize:    len(p.Body),
+	}
+	if query, err := url.ParseQuery(p.Query); err == nil {
+		for _, name := range sortedKeys(query) {
+			for _, value := range query[name] {
+				req.QueryString = append(req.QueryString, harNameValue{Name: name, Value: value})
+			}
+		}
+	}
+	if p.Body != "" {
+		req.PostData = &harPostData{MimeType: contentType, Text: p.Body}
+	}
+
+	entry := harEntry{
+		StartedDateTime: p.Timestamp.Format(time.RFC3339Nano),
+		Request:         req,
+		Timings:         harTimings{Blocked: -1, DNS: -1, Connect: -1},
+	}
+	if t := p.Timing; t != nil {
+		// The capture is timestamped once its body has been read
+		entry.StartedDateTime = p.Timestamp.Add(-time.Duration(t.ReceiveMs * float64(time.Millisecond))).Format(time.RFC3339Nano)
+		entry.Timings.Send = t.ReceiveMs
+		entry.Timings.Wait = t.HandleMs
+		entry.Time = t.ReceiveMs + t.HandleMs
+	}
+
+	if r := p.Response; r != nil {
+		headers := make(map[string][]string, len(r.Headers))
+		for name, value := range r.Headers {
+			headers[name] = []string{value}
+		}
+		entry.Response = harResponse{
+			Status:      r.Status,
+			StatusText:  http.StatusText(r.Status),
+			HTTPVersion: "HTTP/1.1",
+			Cookies:     []harNameValue{},
+			Headers:     harHeaders(headers),
+			Content:     harContent{Size: len(r.Body), MimeType: r.Headers["Content-Type"], Text: r.Body},
+			HeadersSize: -1,
+			BodySize:    len(r.Body),
+		}
+	} else {
+		entry.Response = harResponse{HTTPVersion: "HTTP/1.1", Cookies: []harNameValue{}, Headers: []harNameValue{}, HeadersSize: -1, BodySize: -1}
+		entry.Comment = "response not recorded"
+	}
+	return entry
+}
+
+func sortedKeys(values url.Values) []string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func writeHAR(w io.Writer, captures []models.WebhookPayload, opts Options) error {
+	if _, err := io.WriteString(w, `{"log":{"version":"1.2","creator":{"name":"webhook-inspector","version":"1.0"},"entries":[`); err != nil {
+		return err
+	}
+	for i, p := range captures {
+		data, err := json.Marshal(newHAREntry(p, opts))
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			data = append([]byte{','}, data...)
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "]}}\n")
+	return err
+}
+
+type postmanItem struct {
+	Name    string         `json:"name"`
+	Request postmanRequest `json:"request"`
+}
+
+type postmanRequest struct {
+	Method string          `json:"method"`
+	Header []postmanHeader `json:"header"`
+	Body   *postmanBody    `json:"body,omitempty"`
+	URL    string          `json:"url"`
+}
+
+type postmanHeader struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type postmanBody struct {
+	Mode string `json:"mode"`
+	Raw  string `json:"raw"`
+}
+
+// Postman collection v2.1; the ingest URL is the baseUrl variable so it can be retargeted
+func writePostman(w io.Writer, captures []models.WebhookPayload, opts Options) error {
+	head := map[string]interface{}{
+		"info": map[string]string{
+			"name":   "Webhook captures",
+			"schema": "https://schema.getpostman.com/json/collection/v2.1.0/collection.json",
+		},
+		"variable": []map[string]string{{"key": "baseUrl", "value": strings.TrimSuffix(opts.BaseURL, "/")}},
+	}
+	data, err := json.Marshal(head)
+	if err != nil {
+		return err
+	}
+	// Reopen the object to stream the items after the header fields
+	data = append(data[:len(data)-1], []byte(`,"item":[`)...)
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+
+	for i, p := range captures {
+		item := postmanItem{
+			Name: fmt.Sprintf("%s %s %s", p.Timestamp.Format(time.RFC3339), p.Method, pathOrRoot(p.Path)),
+			Request: postmanRequest{
+				Method: p.Method,
+				Header: []postmanHeader{},
+				URL:    RequestURL(p, "{{baseUrl}}"),
+			},
+		}
+		for _, h := range sortedHeaders(p.Headers) {
+			if replayable(h[0]) {
+				item.Request.Header = append(item.Request.Header, postmanHeader{Key: h[0], Value: h[1]})
+			}
+		}
+		if p.Body != "" {
+			item.Request.Body = &postmanBody{Mode: "raw", Raw: p.Body}
+		}
+
+		data, err := json.Marshal(item)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			data = append([]byte{','}, data...)
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+	_, err = io.WriteString(w, "]}\n")
+	return err
+}
+
+func pathOrRoot(path string) string {
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
+// Headers curl sends on its own; they are blanked when the capture didn't have them
+var curlDefaults = []string{"Accept", "User-Agent"}
+
+// A shell script with one curl per capture; URL in the environment retargets it
+func writeCurl(w io.Writer, captures []models.WebhookPayload, opts Options) error {
+	header := "#!/bin/sh\n# Webhook captures; set URL to send them somewhere else\n" +
+		"URL=\"${URL:-" + strings.TrimSuffix(opts.BaseURL, "/") + "}\"\n"
+	if _, err := io.WriteString(w, header); err != nil {
+		return err
+	}
+	for _, p := range captures {
+		if _, err := io.WriteString(w, "\n# "+p.ID+" "+p.Timestamp.Format(time.RFC3339)+"\n"+Curl(p, `"$URL"`)+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Curl renders a command that sends the capture's method, headers and body byte for
+// byte to target, a shell word such as a quoted URL or "$URL"
+func Curl(p models.WebhookPayload, target string) string {
+	suffix := p.Path
+	if p.Query != "" {
+		suffix += "?" + p.Query
+	}
+	command := "curl -sS -X " + shellQuote(p.Method) + " " + target
+	if suffix != "" {
+		command += shellQuote(suffix)
+	}
+	lines := []string{command}
+
+	for _, h := range sortedHeaders(p.Headers) {
+		if replayable(h[0]) {
+			lines = append(lines, "-H "+shellQuote(h[0]+": "+h[1]))
+		}
+	}
+	blank := append([]string{}, curlDefaults...)
+	if p.Body != "" {
+		// --data-binary would otherwise add a form content type
+		blank = append(blank, "Content-Type")
+	}
+	for _, name := range blank {
+		if !hasHeader(p.Headers, name) {
+			lines = append(lines, "-H "+shellQuote(name+":"))
+		}
+	}
+	if p.Body != "" {
+		lines = append(lines, "--data-binary "+shellQuote(p.Body))
+	}
+	return strings.Join(lines, " \\\n  ")
+}
+
+func hasHeader(headers map[string][]string, name string) bool {
+	for k := range headers {
+		if strings.EqualFold(k, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Single-quote s for POSIX shells; everything but ' is literal inside single quotes
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
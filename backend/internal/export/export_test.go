@@ -0,0 +1,173 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"webhook-inspector/internal/models"
+)
+
+var captures = []models.WebhookPayload{
+	{
+		ID:        "c1",
+		Method:    "POST",
+		Path:      "/stripe",
+		Query:     "a=1&b=2",
+		Headers:   map[string][]string{"Content-Type": {"application/json"}, "Cookie": {"webhook_token=secret"}, "X-Sig": {"v1"}},
+		Body:      `{"msg":"it's \"quoted\"\nline two"}`,
+		Timestamp: time.Date(2026, 1, 1, 0, 0, 1, 0, time.UTC),
+		Tags:      []string{"billing", "vip"},
+		Response:  &models.CaptureResponse{Status: 202, Headers: map[string]string{"Content-Type": "text/plain"}, Body: "ok"},
+		Timing:    &models.CaptureTiming{ReceiveMs: 2, HandleMs: 3},
+	},
+	{ID: "c2", Method: "GET", Timestamp: time.Date(2026, 1, 1, 0, 0, 2, 0, time.UTC)},
+}
+
+func render(t *testing.T, name string) string {
+	t.Helper()
+	format, ok := Lookup(name)
+	if !ok {
+		t.Fatalf("format %s missing", name)
+	}
+	var buf bytes.Buffer
+	if err := format.Write(&buf, captures, Options{BaseURL: "https://inspector.test/hooks/tok"}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestHAR(t *testing.T) {
+	var har struct {
+		Log struct {
+			Version string
+			Entries []harEntry
+		}
+	}
+	if err := json.Unmarshal([]byte(render(t, "har")), &har); err != nil {
+		t.Fatal(err)
+	}
+	if har.Log.Version != "1.2" || len(har.Log.Entries) != 2 {
+		t.Fatalf("har = %+v", har.Log)
+	}
+
+	e := har.Log.Entries[0]
+	if e.Request.URL != "https://inspector.test/hooks/tok/stripe?a=1&b=2" || len(e.Request.QueryString) != 2 || e.Request.PostData.Text != captures[0].Body {
+		t.Errorf("request = %+v", e.Request)
+	}
+	if e.Response.Status != 202 || e.Response.Content.Text != "ok" || e.Time != 5 || e.Timings.Send != 2 || e.Timings.Wait != 3 {
+		t.Errorf("response/timings = %+v %+v", e.Response, e.Timings)
+	}
+	if e.StartedDateTime != "2026-01-01T00:00:00.998Z" {
+		t.Errorf("started = %s", e.StartedDateTime)
+	}
+	if har.Log.Entries[1].Comment == "" || har.Log.Entries[1].Request.PostData != nil {
+		t.Errorf("capture without a recorded response = %+v", har.Log.Entries[1])
+	}
+}
+
+func TestNDJSONAndCSV(t *testing.T) {
+	lines := strings.Split(strings.TrimSpace(render(t, "ndjson")), "\n")
+	var first models.WebhookPayload
+	if len(lines) != 2 || json.Unmarshal([]byte(lines[0]), &first) != nil || first.Body != captures[0].Body {
+		t.Errorf("ndjson = %q", lines)
+	}
+
+	rows, err := csv.NewReader(strings.NewReader(render(t, "csv"))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 || rows[0][0] != "id" || rows[1][7] != "billing;vip" || rows[1][9] != captures[0].Body {
+		t.Errorf("csv = %q", rows)
+	}
+}
+
+func TestCSVFormulaCells(t *testing.T) {
+	format, _ := Lookup("csv")
+	var buf bytes.Buffer
+	p := models.WebhookPayload{ID: "c3", Method: "POST", Path: "/hook", Tags: []string{"@admin"}, Body: `=HYPERLINK("https://evil.example","x")`}
+	if err := format.Write(&buf, []models.WebhookPayload{p}, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows[1][9] != "'"+p.Body || rows[1][7] != "'@admin" || rows[1][3] != "/hook" {
+		t.Errorf("csv = %q", rows[1])
+	}
+}
+
+func TestPostman(t *testing.T) {
+	var collection struct {
+		Variable []map[string]string
+		Item     []postmanItem
+	}
+	if err := json.Unmarshal([]byte(render(t, "postman")), &collection); err != nil {
+		t.Fatal(err)
+	}
+	if len(collection.Item) != 2 || collection.Variable[0]["value"] != "https://inspector.test/hooks/tok" {
+		t.Fatalf("collection = %+v", collection)
+	}
+	req := collection.Item[0].Request
+	if req.URL != "{{baseUrl}}/stripe?a=1&b=2" || req.Body.Raw != captures[0].Body || len(req.Header) != 2 {
+		t.Errorf("request = %+v", req)
+	}
+}
+
+func TestCurl(t *testing.T) {
+	script := render(t, "curl")
+	if !strings.Contains(script, `URL="${URL:-https://inspector.test/hooks/tok}"`) || strings.Contains(script, "Cookie") {
+		t.Errorf("script = %s", script)
+	}
+
+	cmd := Curl(captures[1], `"$URL"`)
+	if cmd != "curl -sS -X 'GET' \"$URL\" \\\n  -H 'Accept:' \\\n  -H 'User-Agent:'" {
+		t.Errorf("bodyless command = %q", cmd)
+	}
+}
+
+// The shell must hand curl the body byte for byte
+func TestShellQuote(t *testing.T) {
+	body := captures[0].Body + ` $HOME \ ` + "`x`"
+	out, err := exec.Command("sh", "-c", "printf %s "+shellQuote(body)).Output()
+	if err != nil {
+		t.Skip("sh unavailable:", err)
+	}
+	if string(out) != body {
+		t.Errorf("round trip = %q", out)
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	target := RequestURL(captures[0], "https://inspector.test/hooks/tok")
+	for _, lang := range SnippetLanguages() {
+		snippet, ok := Snippet(lang, captures[0], target)
+		if !ok {
+			t.Fatalf("%s missing", lang)
+		}
+		if !strings.Contains(snippet, "https://inspector.test/hooks/tok/stripe?a=1&b=2") || !strings.Contains(snippet, "X-Sig") ||
+			!strings.Contains(snippet, "POST") || strings.Contains(snippet, "Cookie") {
+			t.Errorf("%s snippet = %s", lang, snippet)
+		}
+	}
+	if _, ok := Snippet("cobol", captures[0], target); ok {
+		t.Error("unknown language accepted")
+	}
+}
+
+// Python must read the bytes literal back as the exact body
+func TestPyBytes(t *testing.T) {
+	body := captures[0].Body + "\\ \x00 é\r\t"
+	out, err := exec.Command("python3", "-c", "import sys; sys.stdout.buffer.write("+pyBytes(body)+")").Output()
+	if err != nil {
+		t.Skip("python3 unavailable:", err)
+	}
+	if string(out) != body {
+		t.Errorf("round trip = %q", out)
+	}
+}
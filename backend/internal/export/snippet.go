@@ -0,0 +1,190 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"webhook-inspector/internal/models"
+)
+
+var snippets = map[string]func(p models.WebhookPayload, target string) string{
+	"curl":   curlSnippet,
+	"go":     goSnippet,
+	"httpie": httpieSnippet,
+	"node":   nodeSnippet,
+	"python": pythonSnippet,
+}
+
+// SnippetLanguages lists the languages Snippet supports
+func SnippetLanguages() []string {
+	names := make([]string, 0, len(snippets))
+	for name := range snippets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Snippet renders a standalone program that sends the capture's method, headers and
+// body byte for byte to target, the full URL. Headers that don't survive a replay are
+// left out as for forwards.
+func Snippet(lang string, p models.WebhookPayload, target string) (string, bool) {
+	render, ok := snippets[lang]
+	if !ok {
+		return "", false
+	}
+	return render(p, target), true
+}
+
+// Replayable headers in a stable order, repeated values joined as HTTP allows
+func snippetHeaders(p models.WebhookPayload) [][2]string {
+	var headers [][2]string
+	for _, h := range sortedHeaders(p.Headers) {
+		if !replayable(h[0]) {
+			continue
+		}
+		if n := len(headers); n > 0 && strings.EqualFold(headers[n-1][0], h[0]) {
+			headers[n-1][1] += ", " + h[1]
+			continue
+		}
+		headers = append(headers, h)
+	}
+	return headers
+}
+
+func curlSnippet(p models.WebhookPayload, target string) string {
+	// Curl appends the sub-path and query itself, and target already has them
+	p.Path, p.Query = "", ""
+	return "#!/bin/sh\n" + Curl(p, shellQuote(target)) + "\n"
+}
+
+func httpieSnippet(p models.WebhookPayload, target string) string {
+	args := []string{"http", "--ignore-stdin", shellQuote(p.Method), shellQuote(target)}
+	if p.Body != "" {
+		args = []string{"printf '%s'", shellQuote(p.Body), "|", "http", shellQuote(p.Method), shellQuote(target)}
+	}
+	lines := []string{strings.Join(args, " ")}
+	for _, h := range snippetHeaders(p) {
+		lines = append(lines, shellQuote(h[0]+":"+h[1]))
+	}
+	// An empty value stops HTTPie sending its own default
+	for _, name := range curlDefaults {
+		if !hasHeader(p.Headers, name) {
+			lines = append(lines, shellQuote(name+":"))
+		}
+	}
+	return "#!/bin/sh\n" + strings.Join(lines, " \\\n  ") + "\n"
+}
+
+func goSnippet(p models.WebhookPayload, target string) string {
+	var b strings.Builder
+	b.WriteString("package main\n\nimport (\n\t\"fmt\"\n\t\"io\"\n\t\"net/http\"\n\t\"strings\"\n)\n\nfunc main() {\n")
+	body := strconv.Quote(p.Body)
+	if strconv.CanBackquote(p.Body) {
+		body = "`" + p.Body + "`"
+	}
+	fmt.Fprintf(&b, "\tbody := %s\n", body)
+	fmt.Fprintf(&b, "\treq, err := http.NewRequest(%q, %q, strings.NewReader(body))\n", p.Method, target)
+	b.WriteString("\tif err != nil {\n\t\tpanic(err)\n\t}\n")
+	for _, h := range snippetHeaders(p) {
+		fmt.Fprintf(&b, "\treq.Header.Set(%q, %q)\n", h[0], h[1])
+	}
+	if !hasHeader(p.Headers, "User-Agent") {
+		b.WriteString("\t// The capture had none, so don't send Go's default\n")
+		b.WriteString("\treq.Header.Set(\"User-Agent\", \"\")\n")
+	}
+	b.WriteString(`
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		panic(err)
+	}
+	defer resp.Body.Close()
+	out, _ := io.ReadAll(resp.Body)
+	fmt.Println(resp.Status)
+	fmt.Println(string(out))
+}
+`)
+	return b.String()
+}
+
+func nodeSnippet(p models.WebhookPayload, target string) string {
+	var b strings.Builder
+	b.WriteString("// Node 18 or later\n")
+	fmt.Fprintf(&b, "const body = %s;\n\n", jsString(p.Body))
+	b.WriteString("async function main() {\n")
+	fmt.Fprintf(&b, "  const res = await fetch(%s, {\n", jsString(target))
+	fmt.Fprintf(&b, "    method: %s,\n", jsString(p.Method))
+	b.WriteString("    headers: {\n")
+	for _, h := range snippetHeaders(p) {
+		fmt.Fprintf(&b, "      %s: %s,\n", jsString(h[0]), jsString(h[1]))
+	}
+	b.WriteString("    },\n")
+	if p.Body != "" {
+		b.WriteString("    body,\n")
+	}
+	b.WriteString(`  });
+  console.log(res.status, res.statusText);
+  console.log(await res.text());
+}
+
+main();
+`)
+	return b.String()
+}
+
+func pythonSnippet(p models.WebhookPayload, target string) string {
+	var b strings.Builder
+	b.WriteString("import urllib.error\nimport urllib.request\n\n")
+	fmt.Fprintf(&b, "body = %s\n\n", pyBytes(p.Body))
+	data := "body"
+	if p.Body == "" {
+		data = "None"
+	}
+	fmt.Fprintf(&b, "req = urllib.request.Request(%s, data=%s, method=%s)\n", jsString(target), data, jsString(p.Method))
+	for _, h := range snippetHeaders(p) {
+		fmt.Fprintf(&b, "req.add_header(%s, %s)\n", jsString(h[0]), jsString(h[1]))
+	}
+	b.WriteString(`
+try:
+    resp = urllib.request.urlopen(req)
+except urllib.error.HTTPError as err:
+    resp = err
+print(resp.status, resp.reason)
+print(resp.read().decode("utf-8", "replace"))
+`)
+	return b.String()
+}
+
+// A double-quoted string literal valid in both JavaScript and Python
+func jsString(s string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// A Python bytes literal holding s exactly; printable ASCII stays readable
+func pyBytes(s string) string {
+	var b strings.Builder
+	b.WriteString("b'")
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' || c == '\'':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c == '\n':
+			b.WriteString(`\n`)
+		case c >= 0x20 && c < 0x7f:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, `\x%02x`, c)
+		}
+	}
+	b.WriteString("'")
+	return b.String()
+}
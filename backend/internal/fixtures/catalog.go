@@ -0,0 +1,239 @@
+package fixtures
+
+import (
+	"webhook-inspector/internal/signing"
+)
+
+var catalog = map[string]Fixture{}
+
+func register(f Fixture) {
+	catalog[f.Name] = f
+}
+
+const githubRepository = `{
+    "id": {{num 100000000 999999999}},
+    "name": "webhook-inspector",
+    "full_name": "octocat/webhook-inspector",
+    "private": false,
+    "owner": {"login": "octocat", "id": 583231, "type": "User"},
+    "html_url": "https://github.com/octocat/webhook-inspector",
+    "default_branch": "main"
+  }`
+
+const stripePaymentIntent = `{
+      "id": "{{id "pi_" 24}}",
+      "object": "payment_intent",
+      "amount": {{num 500 50000}},
+      "currency": "usd",
+      "customer": "{{id "cus_" 14}}",
+      "payment_method": "{{id "pm_" 24}}",
+      "created": {{now}},
+      "livemode": false,
+      "metadata": {"order_id": "{{digits 6}}"},`
+
+func init() {
+	githubHeaders := func(event string) map[string]string {
+		return map[string]string{
+			"User-Agent":                             "GitHub-Hookshot/{{hex 7}}",
+			"X-GitHub-Event":                         event,
+			"X-GitHub-Delivery":                      "{{uuid}}",
+			"X-GitHub-Hook-ID":                       "{{num 100000000 999999999}}",
+			"X-GitHub-Hook-Installation-Target-Type": "repository",
+		}
+	}
+
+	register(Fixture{
+		Name:        "github.ping",
+		Provider:    "github",
+		Event:       "ping",
+		Description: "Sent when a webhook is first created",
+		ContentType: "application/json",
+		Sign:        signing.GitHub,
+		Headers:     githubHeaders("ping"),
+		Body: `{
+  "zen": "Keep it logically awesome.",
+  "hook_id": {{num 100000000 999999999}},
+  "hook": {"type": "Repository", "active": true, "events": ["push", "pull_request"], "config": {"content_type": "json", "insecure_ssl": "0"}},
+  "repository": ` + githubRepository + `,
+  "sender": {"login": "octocat", "id": 583231, "type": "User"}
+}`,
+	})
+
+	register(Fixture{
+		Name:        "github.push",
+		Provider:    "github",
+		Event:       "push",
+		Description: "One commit pushed to main",
+		ContentType: "application/json",
+		Sign:        signing.GitHub,
+		Headers:     githubHeaders("push"),
+		Body: `{
+  "ref": "refs/heads/main",
+  "before": "{{hex 40}}",
+  "after": "{{hex 40}}",
+  "created": false,
+  "deleted": false,
+  "forced": false,
+  "compare": "https://github.com/octocat/webhook-inspector/compare/{{hex 12}}...{{hex 12}}",
+  "commits": [
+    {
+      "id": "{{hex 40}}",
+      "message": "Fix webhook handling",
+      "timestamp": "{{iso}}",
+      "author": {"name": "Mona Lisa", "email": "mona@example.com", "username": "octocat"},
+      "added": [],
+      "removed": [],
+      "modified": ["backend/internal/handlers/webhook.go"]
+    }
+  ],
+  "repository": ` + githubRepository + `,
+  "pusher": {"name": "octocat", "email": "mona@example.com"},
+  "sender": {"login": "octocat", "id": 583231, "type": "User"}
+}`,
+	})
+
+	register(Fixture{
+		Name:        "github.pull_request",
+		Provider:    "github",
+		Event:       "pull_request",
+		Description: "A pull request was opened",
+		ContentType: "application/json",
+		Sign:        signing.GitHub,
+		Headers:     githubHeaders("pull_request"),
+		Body: `{
+  "action": "opened",
+  "number": {{num 1 500}},
+  "pull_request": {
+    "id": {{num 1000000000 2000000000}},
+    "title": "Add replay support",
+    "state": "open",
+    "draft": false,
+    "user": {"login": "octocat", "id": 583231},
+    "head": {"ref": "feature/replay", "sha": "{{hex 40}}"},
+    "base": {"ref": "main", "sha": "{{hex 40}}"},
+    "created_at": "{{iso}}",
+    "updated_at": "{{iso}}",
+    "additions": {{num 1 400}},
+    "deletions": {{num 0 100}},
+    "changed_files": {{num 1 20}}
+  },
+  "repository": ` + githubRepository + `,
+  "sender": {"login": "octocat", "id": 583231, "type": "User"}
+}`,
+	})
+
+	stripeEvent := func(event, status, extra string) Fixture {
+		return Fixture{
+			Name:        "stripe." + event,
+			Provider:    "stripe",
+			Event:       event,
+			Description: "PaymentIntent " + status,
+			ContentType: "application/json; charset=utf-8",
+			Sign:        signing.Stripe,
+			Headers: map[string]string{
+				"User-Agent": "Stripe/1.0 (+https://stripe.com/docs/webhooks)",
+			},
+			Body: `{
+  "id": "{{id "evt_" 24}}",
+  "object": "event",
+  "api_version": "2024-06-20",
+  "created": {{now}},
+  "type": "` + event + `",
+  "livemode": false,
+  "pending_webhooks": 1,
+  "request": {"id": "{{id "req_" 14}}", "idempotency_key": "{{uuid}}"},
+  "data": {
+    "object": ` + stripePaymentIntent + `
+      "status": "` + status + `"` + extra + `
+    }
+  }
+}`,
+		}
+	}
+	register(stripeEvent("payment_intent.created", "requires_payment_method", ""))
+	register(stripeEvent("payment_intent.succeeded", "succeeded", `,
+      "amount_received": 2000`))
+	register(stripeEvent("payment_intent.payment_failed", "requires_payment_method", `,
+      "last_payment_error": {"code": "card_declined", "decline_code": "insufficient_funds", "message": "Your card has insufficient funds.", "type": "card_error"}`))
+
+	register(Fixture{
+		Name:        "slack.url_verification",
+		Provider:    "slack",
+		Event:       "url_verification",
+		Description: "Events API handshake; reply with the challenge",
+		ContentType: "application/json",
+		Sign:        signing.Slack,
+		Headers:     map[string]string{"User-Agent": "Slackbot 1.0 (+https://api.slack.com/robots)"},
+		Body: `{
+  "token": "{{id "" 24}}",
+  "challenge": "{{id "" 48}}",
+  "type": "url_verification"
+}`,
+	})
+
+	register(Fixture{
+		Name:        "slack.app_mention",
+		Provider:    "slack",
+		Event:       "app_mention",
+		Description: "Events API callback for a message mentioning the app",
+		ContentType: "application/json",
+		Sign:        signing.Slack,
+		Headers:     map[string]string{"User-Agent": "Slackbot 1.0 (+https://api.slack.com/robots)"},
+		Body: `{
+  "token": "{{id "" 24}}",
+  "team_id": "{{id "T" 10}}",
+  "api_app_id": "{{id "A" 10}}",
+  "event": {
+    "type": "app_mention",
+    "user": "{{id "U" 10}}",
+    "text": "<@U0LAN0Z89> is the deploy done?",
+    "ts": "{{now}}.{{digits 6}}",
+    "channel": "{{id "C" 10}}",
+    "event_ts": "{{now}}.{{digits 6}}"
+  },
+  "type": "event_callback",
+  "event_id": "{{id "Ev" 10}}",
+  "event_time": {{now}}
+}`,
+	})
+
+	register(Fixture{
+		Name:        "shopify.orders_create",
+		Provider:    "shopify",
+		Event:       "orders/create",
+		Description: "A new order was placed",
+		ContentType: "application/json",
+		Sign:        signing.Shopify,
+		Headers: map[string]string{
+			"X-Shopify-Topic":        "orders/create",
+			"X-Shopify-Shop-Domain":  "example-store.myshopify.com",
+			"X-Shopify-API-Version":  "2024-07",
+			"X-Shopify-Webhook-Id":   "{{uuid}}",
+			"X-Shopify-Triggered-At": "{{iso}}",
+		},
+		Body: `{
+  "id": {{num 1000000000000 9999999999999}},
+  "name": "#{{num 1000 9999}}",
+  "email": "customer@example.com",
+  "created_at": "{{iso}}",
+  "currency": "USD",
+  "financial_status": "paid",
+  "total_price": "{{num 10 500}}.00",
+  "line_items": [
+    {"id": {{num 1000000000000 9999999999999}}, "title": "Webhook T-Shirt", "quantity": 1, "price": "25.00", "sku": "WH-TEE-M"}
+  ],
+  "customer": {"id": {{num 1000000000000 9999999999999}}, "first_name": "Ada", "last_name": "Lovelace"}
+}`,
+	})
+
+	register(Fixture{
+		Name:        "twilio.sms_received",
+		Provider:    "twilio",
+		Event:       "sms.received",
+		Description: "Incoming SMS to a Twilio number (form-encoded)",
+		ContentType: "application/x-www-form-urlencoded",
+		Sign:        signing.Twilio,
+		Headers:     map[string]string{"User-Agent": "TwilioProxy/1.1"},
+		Body:        `ToCountry=US&ToState=CA&SmsMessageSid={{id "SM" 32}}&NumMedia=0&ToCity=SAN+FRANCISCO&FromZip=94105&SmsSid={{id "SM" 32}}&FromState=CA&SmsStatus=received&FromCity=SAN+FRANCISCO&Body=Hello+from+Twilio&FromCountry=US&To=%2B1415555{{digits 4}}&ToZip=94105&NumSegments=1&MessageSid={{id "SM" 32}}&AccountSid={{id "AC" 32}}&From=%2B1628555{{digits 4}}&ApiVersion=2010-04-01`,
+	})
+}
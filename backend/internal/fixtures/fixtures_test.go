@@ -0,0 +1,45 @@
+package fixtures
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRender_AllFixturesProduceValidBodies(t *testing.T) {
+	for _, f := range List() {
+		rendered, err := f.Render(time.Now())
+		if err != nil {
+			t.Errorf("%s: render failed: %v", f.Name, err)
+			continue
+		}
+
+		if strings.HasPrefix(f.ContentType, "application/json") {
+			var v interface{}
+			if err := json.Unmarshal([]byte(rendered.Body), &v); err != nil {
+				t.Errorf("%s: body is not valid JSON: %v", f.Name, err)
+			}
+		} else if _, err := url.ParseQuery(rendered.Body); err != nil {
+			t.Errorf("%s: body is not valid form data: %v", f.Name, err)
+		}
+
+		if strings.Contains(rendered.Body, "{{") {
+			t.Errorf("%s: unrendered template field in body", f.Name)
+		}
+	}
+}
+
+func TestRender_FreshValuesEachTime(t *testing.T) {
+	f, ok := Get("stripe.payment_intent.succeeded")
+	if !ok {
+		t.Fatal("expected stripe.payment_intent.succeeded in the catalog")
+	}
+
+	a, _ := f.Render(time.Now())
+	b, _ := f.Render(time.Now())
+	if a.Body == b.Body {
+		t.Error("expected templated IDs to differ between renders")
+	}
+}
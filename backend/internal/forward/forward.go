@@ -0,0 +1,231 @@
+package forward
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"webhook-inspector/internal/config"
+	"webhook-inspector/internal/models"
+	"webhook-inspector/internal/redis"
+	"webhook-inspector/internal/store"
+
+	"github.com/google/uuid"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+const (
+	queueKey = "forward:queue"
+	retryKey = "forward:retry"
+)
+
+// Hop-by-hop headers (RFC 7230 §6.1) plus the headers that only make sense for
+// the original hop. Cookie is included so the inspector's own token never leaks.
+var DefaultStripHeaders = []string{
+	"Connection", "Keep-Alive", "Proxy-Authenticate", "Proxy-Authorization",
+	"Proxy-Connection", "Te", "Trailer", "Transfer-Encoding", "Upgrade",
+	"Host", "Content-Length", "Cookie",
+}
+
+// Job is one capture waiting to be delivered to one target
+type Job struct {
+	ID          string    `json:"id"`
+	Token       string    `json:"token"`
+	CaptureID   string    `json:"capture_id"`
+	TargetID    string    `json:"target_id"`
+	URL         string    `json:"url"`
+	StripHeader []string  `json:"strip_headers,omitempty"`
+	Attempt     int       `json:"attempt"`
+	MaxAttempts int       `json:"max_attempts"`
+	LastError   string    `json:"last_error,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func deliveriesKey(token, captureID string) string {
+	return fmt.Sprintf("deliveries:%s:%s", token, captureID)
+}
+
+func deadLetterKey(token string) string {
+	return "deadletter:" + token
+}
+
+// EnqueueTargets schedules delivery of a stored capture to the given targets
+func EnqueueTargets(ctx context.Context, token, captureID string, targets []models.ForwardTarget) error {
+	if len(targets) == 0 {
+		return nil
+	}
+
+	jobs := make([]interface{}, 0, len(targets))
+	for _, target := range targets {
+		maxAttempts := target.MaxAttempts
+		if maxAttempts <= 0 {
+			maxAttempts = config.ForwardMaxAttempts
+		}
+		job := Job{
+			ID:          uuid.New().String(),
+			Token:       token,
+			CaptureID:   captureID,
+			TargetID:    target.ID,
+			URL:         target.URL,
+			StripHeader: target.StripHeaders,
+			MaxAttempts: maxAttempts,
+			CreatedAt:   time.Now().UTC(),
+		}
+		data, err := json.Marshal(job)
+		if err != nil {
+			return err
+		}
+		jobs = append(jobs, data)
+	}
+
+	return redis.Client.LPush(ctx, queueKey, jobs...).Err()
+}
+
+// Attempts returns every recorded delivery attempt for a capture, oldest first
+func Attempts(ctx context.Context, token, captureID string) ([]models.DeliveryAttempt, error) {
+	values, err := redis.Client.LRange(ctx, deliveriesKey(token, captureID), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	attempts := make([]models.DeliveryAttempt, 0, len(values))
+	for _, v := range values {
+		var attempt models.DeliveryAttempt
+		if err := json.Unmarshal([]byte(v), &attempt); err != nil {
+			continue
+		}
+		attempts = append(attempts, attempt)
+	}
+	return attempts, nil
+}
+
+// RecordAttempt appends an attempt to a capture's delivery history, expiring with the capture
+func RecordAttempt(ctx context.Context, s store.Store, token, captureID string, attempt models.DeliveryAttempt) error {
+	data, err := json.Marshal(attempt)
+	if err != nil {
+		return err
+	}
+
+	key := deliveriesKey(token, captureID)
+	pipe := redis.Client.Pipeline()
+	pipe.RPush(ctx, key, data)
+	if ttl := historyTTL(ctx, s, token, captureID); ttl > 0 {
+		pipe.Expire(ctx, key, ttl)
+	} else {
+		pipe.Persist(ctx, key)
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// How long a capture's delivery history and dead letters are kept: as long as the
+// capture, forever (0) for a pinned one without expiry, or the endpoint's retention
+// when the capture can't be read
+func historyTTL(ctx context.Context, s store.Store, token, captureID string) time.Duration {
+	if ttl, err := s.CaptureTTL(ctx, token, captureID); err == nil {
+		return ttl
+	}
+	cfg, err := s.LoadConfig(ctx, token)
+	if err != nil {
+		return config.WebhookDataTTL
+	}
+	return cfg.Retention.Duration(config.WebhookDataTTL)
+}
+
+// DeadLetters lists deliveries that exhausted their retries
+func DeadLetters(ctx context.Context, token string) ([]Job, error) {
+	values, err := redis.Client.HGetAll(ctx, deadLetterKey(token)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make([]Job, 0, len(values))
+	for _, v := range values {
+		var job Job
+		if err := json.Unmarshal([]byte(v), &job); err != nil {
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// RetryDeadLetter moves a dead-lettered job back onto the queue with a fresh attempt budget
+func RetryDeadLetter(ctx context.Context, token, jobID string) (bool, error) {
+	data, err := redis.Client.HGet(ctx, deadLetterKey(token), jobID).Result()
+	if err == goredis.Nil {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	var job Job
+	if err := json.Unmarshal([]byte(data), &job); err != nil {
+		return false, err
+	}
+	job.Attempt = 0
+	job.LastError = ""
+
+	requeued, err := json.Marshal(job)
+	if err != nil {
+		return false, err
+	}
+
+	pipe := redis.Client.TxPipeline()
+	pipe.HDel(ctx, deadLetterKey(token), jobID)
+	pipe.LPush(ctx, queueKey, requeued)
+	_, err = pipe.Exec(ctx)
+	return err == nil, err
+}
+
+// Purge removes the delivery history of the given captures and the token's dead letters.
+// History expires with its capture, so only live captures need listing.
+func Purge(ctx context.Context, token string, captureIDs []string) error {
+	if err := DeleteHistory(ctx, token, captureIDs); err != nil {
+		return err
+	}
+	return redis.Client.Del(ctx, deadLetterKey(token)).Err()
+}
+
+// DeleteHistory removes the delivery history of the given captures
+func DeleteHistory(ctx context.Context, token string, captureIDs []string) error {
+	if len(captureIDs) == 0 {
+		return nil
+	}
+	keys := make([]string, len(captureIDs))
+	for i, id := range captureIDs {
+		keys[i] = deliveriesKey(token, id)
+	}
+	return redis.Client.Del(ctx, keys...).Err()
+}
+
+// NewRequest builds the outbound copy of a capture, dropping headers that must not be
+// replayed; a nil strip list means DefaultStripHeaders
+func NewRequest(ctx context.Context, payload models.WebhookPayload, url string, strip []string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, payload.Method, url, strings.NewReader(payload.Body))
+	if err != nil {
+		return nil, err
+	}
+
+	if strip == nil {
+		strip = DefaultStripHeaders
+	}
+	stripped := make(map[string]bool, len(strip))
+	for _, name := range strip {
+		stripped[http.CanonicalHeaderKey(name)] = true
+	}
+
+	for name, values := range payload.Headers {
+		if stripped[http.CanonicalHeaderKey(name)] {
+			continue
+		}
+		for _, v := range values {
+			req.Header.Add(name, v)
+		}
+	}
+	return req, nil
+}
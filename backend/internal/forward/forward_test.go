@@ -0,0 +1,72 @@
+package forward
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"webhook-inspector/internal/config"
+	"webhook-inspector/internal/models"
+	"webhook-inspector/internal/store"
+)
+
+func TestNewRequest_StripsDefaultHeaders(t *testing.T) {
+	payload := models.WebhookPayload{
+		Method: "POST",
+		Headers: map[string][]string{
+			"Content-Type": {"application/json"},
+			"Cookie":       {"webhook_token=abc123"},
+			"Connection":   {"keep-alive"},
+		},
+		Body: `{"foo":"bar"}`,
+	}
+
+	req, err := NewRequest(context.Background(), payload, "http://example.com/hook", nil)
+	if err != nil {
+		t.Fatalf("NewRequest failed: %v", err)
+	}
+	if req.Header.Get("Cookie") != "" || req.Header.Get("Connection") != "" {
+		t.Errorf("expected hop-by-hop headers to be stripped, got %v", req.Header)
+	}
+	if req.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("expected Content-Type to be forwarded")
+	}
+
+	req, _ = NewRequest(context.Background(), payload, "http://example.com/hook", []string{})
+	if req.Header.Get("Cookie") == "" {
+		t.Errorf("expected an empty strip list to forward every header")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	if got := Backoff(1); got != config.ForwardBaseBackoff {
+		t.Errorf("expected first backoff %s, got %s", config.ForwardBaseBackoff, got)
+	}
+	if got := Backoff(3); got != 4*config.ForwardBaseBackoff {
+		t.Errorf("expected third backoff %s, got %s", 4*config.ForwardBaseBackoff, got)
+	}
+	if got := Backoff(100); got != config.ForwardMaxBackoff {
+		t.Errorf("expected backoff to cap at %s, got %s", config.ForwardMaxBackoff, got)
+	}
+}
+
+func TestHistoryTTL(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	s.SaveCapture(ctx, "tok", models.WebhookPayload{ID: "short", Timestamp: time.Now()}, time.Hour)
+	s.SaveCapture(ctx, "tok", models.WebhookPayload{ID: "pinned", Timestamp: time.Now(), Pinned: true}, 0)
+
+	if ttl := historyTTL(ctx, s, "tok", "short"); ttl <= 59*time.Minute || ttl > time.Hour {
+		t.Errorf("expected the capture's own TTL, got %s", ttl)
+	}
+	if ttl := historyTTL(ctx, s, "tok", "pinned"); ttl != 0 {
+		t.Errorf("expected a pinned capture's history to be kept, got %s", ttl)
+	}
+	if ttl := historyTTL(ctx, s, "tok", "gone"); ttl != config.WebhookDataTTL {
+		t.Errorf("expected WEBHOOK_DATA_TTL without a retention, got %s", ttl)
+	}
+	s.SaveConfig(ctx, "tok", models.EndpointConfig{Retention: models.Retention{TTL: "3h"}}, 0)
+	if ttl := historyTTL(ctx, s, "tok", "gone"); ttl != 3*time.Hour {
+		t.Errorf("expected the endpoint retention, got %s", ttl)
+	}
+}
@@ -0,0 +1,242 @@
+package forward
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"webhook-inspector/internal/config"
+	"webhook-inspector/internal/models"
+	"webhook-inspector/internal/outbound"
+	"webhook-inspector/internal/redis"
+	"webhook-inspector/internal/store"
+	"webhook-inspector/internal/transform"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// Run starts the delivery workers, reading captures and settings from s, and the retry
+// scheduler; it blocks until ctx is cancelled
+func Run(ctx context.Context, s store.Store) {
+	for i := 0; i < config.ForwardWorkers; i++ {
+		go work(ctx, s)
+	}
+	scheduleRetries(ctx)
+}
+
+func work(ctx context.Context, s store.Store) {
+	for ctx.Err() == nil {
+		result, err := redis.Client.BRPop(ctx, 5*time.Second, queueKey).Result()
+		if err == goredis.Nil {
+			continue
+		}
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("forward: failed to pop job: %v", err)
+				time.Sleep(time.Second)
+			}
+			continue
+		}
+
+		var job Job
+		if err := json.Unmarshal([]byte(result[1]), &job); err != nil {
+			log.Printf("forward: dropping malformed job: %v", err)
+			continue
+		}
+		process(ctx, s, job)
+	}
+}
+
+// Move retries whose backoff has elapsed back onto the queue
+func scheduleRetries(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		due, err := redis.Client.ZRangeByScore(ctx, retryKey, &goredis.ZRangeBy{
+			Min: "-inf",
+			Max: strconv.FormatInt(time.Now().UnixMilli(), 10),
+		}).Result()
+		if err != nil {
+			log.Printf("forward: failed to read retry schedule: %v", err)
+			continue
+		}
+
+		for _, data := range due {
+			// ZREM acts as the claim when several instances share the schedule
+			if removed, err := redis.Client.ZRem(ctx, retryKey, data).Result(); err != nil || removed == 0 {
+				continue
+			}
+			if err := redis.Client.LPush(ctx, queueKey, data).Err(); err != nil {
+				log.Printf("forward: failed to requeue retry: %v", err)
+			}
+		}
+	}
+}
+
+func process(ctx context.Context, s store.Store, job Job) {
+	// Counted before anything can fail so a lasting store error still dead-letters
+	job.Attempt++
+	stored, err := s.GetCapture(ctx, job.Token, job.CaptureID)
+	if err == store.ErrNotFound {
+		// Capture expired or was deleted; nothing left to deliver
+		return
+	}
+	if err != nil {
+		log.Printf("forward: failed to load capture %s: %v", job.CaptureID, err)
+		reschedule(ctx, s, job, err.Error())
+		return
+	}
+	payload := *stored
+
+	// Transforms are read at delivery time so a fixed config applies to retried dead letters
+	cfg, err := s.LoadConfig(ctx, job.Token)
+	if err != nil {
+		log.Printf("forward: failed to load config for token %s: %v", job.Token, err)
+		reschedule(ctx, s, job, err.Error())
+		return
+	}
+
+	payload, err = transform.Apply(ctx, payload, cfg.Transforms)
+	if err != nil {
+		attempt := models.DeliveryAttempt{
+			Kind:      "forward",
+			TargetID:  job.TargetID,
+			URL:       job.URL,
+			Attempt:   job.Attempt,
+			Error:     "transform: " + err.Error(),
+			Timestamp: time.Now().UTC(),
+		}
+		if err := RecordAttempt(ctx, s, job.Token, job.CaptureID, attempt); err != nil {
+			log.Printf("forward: failed to record attempt for capture %s: %v", job.CaptureID, err)
+		}
+		// Retrying won't change the outcome until the config is fixed
+		job.Attempt = job.MaxAttempts
+		reschedule(ctx, s, job, attempt.Error)
+		return
+	}
+
+	attempt := deliver(ctx, payload, job)
+	if err := RecordAttempt(ctx, s, job.Token, job.CaptureID, attempt); err != nil {
+		log.Printf("forward: failed to record attempt for capture %s: %v", job.CaptureID, err)
+	}
+
+	if attempt.Error == "" && attempt.StatusCode >= 200 && attempt.StatusCode < 300 {
+		return
+	}
+
+	reason := attempt.Error
+	if reason == "" {
+		reason = fmt.Sprintf("status %d", attempt.StatusCode)
+	}
+	reschedule(ctx, s, job, reason)
+}
+
+func deliver(ctx context.Context, payload models.WebhookPayload, job Job) models.DeliveryAttempt {
+	attempt := models.DeliveryAttempt{
+		Kind:      "forward",
+		TargetID:  job.TargetID,
+		URL:       job.URL,
+		Attempt:   job.Attempt,
+		Timestamp: time.Now().UTC(),
+	}
+
+	req, err := NewRequest(ctx, payload, job.URL, job.StripHeader)
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	req.Header.Set("X-Webhook-Inspector-Delivery", job.ID)
+
+	return Send(outbound.Client, req, attempt)
+}
+
+// Send performs req and fills in the status, latency and (truncated) response of attempt
+func Send(client *http.Client, req *http.Request, attempt models.DeliveryAttempt) models.DeliveryAttempt {
+	start := time.Now()
+	resp, err := client.Do(req)
+	attempt.LatencyMs = time.Since(start).Milliseconds()
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, int64(config.ResponseBodyLimit)))
+	attempt.StatusCode = resp.StatusCode
+	attempt.ResponseHeaders = resp.Header
+	attempt.ResponseBody = string(body)
+	return attempt
+}
+
+// Retry with exponential backoff, or park the job once its attempts are used up
+func reschedule(ctx context.Context, s store.Store, job Job, reason string) {
+	job.LastError = reason
+
+	data, err := json.Marshal(job)
+	if err != nil {
+		log.Printf("forward: failed to marshal job %s: %v", job.ID, err)
+		return
+	}
+
+	if job.Attempt >= job.MaxAttempts {
+		if err := deadLetter(ctx, s, job, data); err != nil {
+			log.Printf("forward: failed to dead-letter job %s: %v", job.ID, err)
+		}
+		log.Printf("forward: delivery of capture %s to %s dead-lettered after %d attempts", job.CaptureID, job.URL, job.Attempt)
+		return
+	}
+
+	due := time.Now().Add(Backoff(job.Attempt))
+	if err := redis.Client.ZAdd(ctx, retryKey, goredis.Z{Score: float64(due.UnixMilli()), Member: data}).Err(); err != nil {
+		log.Printf("forward: failed to schedule retry for job %s: %v", job.ID, err)
+	}
+}
+
+// Park a job in the token's dead letters, which are kept as long as the longest-lived
+// capture among them and never expire while one of them is pinned
+func deadLetter(ctx context.Context, s store.Store, job Job, data []byte) error {
+	key := deadLetterKey(job.Token)
+	ttl := historyTTL(ctx, s, job.Token, job.CaptureID)
+	// PTTL answers -2 for a missing key and -1 for one without expiry
+	current, err := redis.Client.PTTL(ctx, key).Result()
+	if err != nil {
+		return err
+	}
+
+	pipe := redis.Client.TxPipeline()
+	pipe.HSet(ctx, key, job.ID, data)
+	switch {
+	case ttl == 0:
+		pipe.Persist(ctx, key)
+	case current == -2:
+		pipe.Expire(ctx, key, ttl)
+	case current > 0:
+		pipe.ExpireGT(ctx, key, ttl)
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// Backoff returns the delay before the next try after the given number of attempts
+func Backoff(attempts int) time.Duration {
+	delay := config.ForwardBaseBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= config.ForwardMaxBackoff {
+			return config.ForwardMaxBackoff
+		}
+	}
+	return delay
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"webhook-inspector/internal/baseline"
+	"webhook-inspector/internal/models"
+	"webhook-inspector/internal/query"
+	"webhook-inspector/internal/store"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type baselineRequest struct {
+	Name   string        `json:"name"`
+	IDs    []string      `json:"ids,omitempty"`
+	Filter *query.Filter `json:"filter,omitempty"`
+}
+
+// ListBaselines returns the token's baselines sorted by name
+func ListBaselines(w http.ResponseWriter, r *http.Request) {
+	token, ok := GetToken(w, r)
+	if !ok {
+		return
+	}
+
+	baselines, err := Store.ListBaselines(r.Context(), token)
+	if err != nil {
+		log.Printf("ListBaselines: failed to load baselines for token %s: %v", token, err)
+		http.Error(w, "failed to load baselines", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(baselines)
+}
+
+// CreateBaseline freezes the captures named by ids, or matching filter, as a baseline
+// for their shared provider and event type
+func CreateBaseline(w http.ResponseWriter, r *http.Request) {
+	token, ok := GetToken(w, r)
+	if !ok {
+		return
+	}
+
+	var req baselineRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if err := baseline.ValidateName(req.Name); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Filter != nil {
+		if err := req.Filter.Compile(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if _, err := Store.GetBaseline(r.Context(), token, req.Name); err == nil {
+		http.Error(w, "a baseline with this name already exists", http.StatusConflict)
+		return
+	} else if err != store.ErrNotFound {
+		log.Printf("CreateBaseline: failed to load baseline %s for token %s: %v", req.Name, token, err)
+		http.Error(w, "failed to load baselines", http.StatusInternalServerError)
+		return
+	}
+
+	captures, err := selectCaptures(r.Context(), token, req.IDs, req.Filter)
+	if err != nil {
+		log.Printf("CreateBaseline: failed to load webhooks for token %s: %v", token, err)
+		http.Error(w, "failed to load webhooks", http.StatusInternalServerError)
+		return
+	}
+	if len(captures) == 0 {
+		http.Error(w, "no webhooks match the selection", http.StatusUnprocessableEntity)
+		return
+	}
+
+	b, err := baseline.Freeze(req.Name, captures, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if err := Store.SaveBaseline(r.Context(), token, b, tokenDataTTL(r.Context(), token)); err != nil {
+		log.Printf("CreateBaseline: failed to save baseline %s for token %s: %v", req.Name, token, err)
+		http.Error(w, "failed to save baseline", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(b)
+}
+
+// GetBaseline returns one baseline with the field shapes it learned
+func GetBaseline(w http.ResponseWriter, r *http.Request) {
+	token, ok := GetToken(w, r)
+	if !ok {
+		return
+	}
+
+	b, ok := loadBaseline(w, r, token)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(b)
+}
+
+// DeleteBaseline removes a baseline; captures already flagged keep their drift
+func DeleteBaseline(w http.ResponseWriter, r *http.Request) {
+	token, ok := GetToken(w, r)
+	if !ok {
+		return
+	}
+
+	if _, ok := loadBaseline(w, r, token); !ok {
+		return
+	}
+	name := chi.URLParam(r, "name")
+	if err := Store.DeleteBaseline(r.Context(), token, name); err != nil {
+		log.Printf("DeleteBaseline: failed to delete baseline %s for token %s: %v", name, token, err)
+		http.Error(w, "failed to delete baseline", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("Deleted"))
+}
+
+// GetDriftReport checks every stored capture of the baseline's event type against it
+// and groups what differs
+func GetDriftReport(w http.ResponseWriter, r *http.Request) {
+	token, ok := GetToken(w, r)
+	if !ok {
+		return
+	}
+
+	b, ok := loadBaseline(w, r, token)
+	if !ok {
+		return
+	}
+	// Hold on to only the captures the report checks
+	var captures []models.WebhookPayload
+	err := eachCapture(r.Context(), token, query.Filter{Provider: b.Provider}, func(c models.WebhookPayload) {
+		if baseline.Applies(*b, c) {
+			captures = append(captures, c)
+		}
+	})
+	if err != nil {
+		log.Printf("GetDriftReport: failed to load webhooks for token %s: %v", token, err)
+		http.Error(w, "failed to load webhooks", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSONWithETag(w, r, baseline.NewReport(*b, captures))
+}
+
+// Load the baseline named in the URL, answering 404 or 500 itself
+func loadBaseline(w http.ResponseWriter, r *http.Request, token string) (*models.Baseline, bool) {
+	name := chi.URLParam(r, "name")
+	b, err := Store.GetBaseline(r.Context(), token, name)
+	if err == store.ErrNotFound {
+		http.Error(w, "Baseline not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		log.Printf("loadBaseline: failed to load baseline %s for token %s: %v", name, token, err)
+		http.Error(w, "failed to load baseline", http.StatusInternalServerError)
+		return nil, false
+	}
+	return b, true
+}
@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"webhook-inspector/internal/config"
+	"webhook-inspector/internal/models"
+	"webhook-inspector/internal/store"
+
+	"github.com/google/uuid"
+)
+
+var errBinNotFound = errors.New("bin not found")
+
+type createBinRequest struct {
+	TTL string `json:"ttl"`
+}
+
+type createBinResponse struct {
+	Token      string    `json:"token"`
+	IngestURL  string    `json:"ingest_url"`
+	Credential string    `json:"credential"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	TTLSeconds int       `json:"ttl_seconds"`
+}
+
+// CreateBin creates a throwaway endpoint with a caller-chosen TTL for CI jobs
+func CreateBin(w http.ResponseWriter, r *http.Request) {
+	var req createBinRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	owner := sessionUser(r)
+	maxTTL := config.AnonymousMaxBinTTL
+	if owner != "" {
+		maxTTL = config.PrivilegedMaxBinTTL
+	}
+
+	ttl := config.DefaultBinTTL
+	if req.TTL != "" {
+		parsed, err := time.ParseDuration(req.TTL)
+		if err != nil || parsed < time.Minute {
+			http.Error(w, "ttl must be a duration of at least 1m", http.StatusBadRequest)
+			return
+		}
+		ttl = parsed
+	}
+	if ttl > maxTTL {
+		http.Error(w, fmt.Sprintf("ttl exceeds the maximum of %s for this tier", maxTTL), http.StatusBadRequest)
+		return
+	}
+
+	// Creating bins needs no token, so throttle per client IP instead; only valid
+	// requests count
+	ip := clientIP(r)
+	created, err := Store.IncrementUsage(r.Context(), "bins:ip:"+ip, config.BinCreateWindow)
+	if err != nil {
+		log.Printf("CreateBin: failed to track bins created by %s: %v", ip, err)
+		http.Error(w, "failed to create bin", http.StatusInternalServerError)
+		return
+	}
+	if created > int64(config.BinCreateLimit) {
+		log.Printf("CreateBin: %s blocked after %d bins", ip, created)
+		http.Error(w, "too many bins created from this address; try again later", http.StatusTooManyRequests)
+		return
+	}
+
+	credential, err := newCredential()
+	if err != nil {
+		log.Printf("CreateBin: failed to generate credential: %v", err)
+		http.Error(w, "failed to create bin", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now().UTC()
+	bin := models.Bin{
+		Token:     uuid.New().String(),
+		Owner:     owner,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+	if err := Store.SaveBin(r.Context(), bin, credential); err != nil {
+		log.Printf("CreateBin: failed to store bin %s: %v", bin.Token, err)
+		http.Error(w, "failed to create bin", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Created bin %s expiring at %s", bin.Token, bin.ExpiresAt.Format(time.RFC3339))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(createBinResponse{
+		Token:      bin.Token,
+		IngestURL:  publicBaseURL(r) + "/hooks/" + bin.Token,
+		Credential: credential,
+		ExpiresAt:  bin.ExpiresAt,
+		TTLSeconds: int(ttl.Seconds()),
+	})
+}
+
+// GetBin returns the metadata of the bin owning the bearer credential
+func GetBin(w http.ResponseWriter, r *http.Request) {
+	token, ok := GetToken(w, r)
+	if !ok {
+		return
+	}
+
+	bin, err := lookupBin(r.Context(), token)
+	if err == errBinNotFound {
+		http.Error(w, "Bin not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("GetBin: failed to load bin %s: %v", token, err)
+		http.Error(w, "failed to load bin", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(bin)
+}
+
+// DeleteBin tears a bin down before its TTL runs out
+func DeleteBin(w http.ResponseWriter, r *http.Request) {
+	token, ok := GetToken(w, r)
+	if !ok {
+		return
+	}
+
+	if _, err := lookupBin(r.Context(), token); err == errBinNotFound {
+		http.Error(w, "Bin not found", http.StatusNotFound)
+		return
+	}
+
+	if err := teardownBin(r.Context(), token); err != nil {
+		log.Printf("DeleteBin: failed to tear down bin %s: %v", token, err)
+		http.Error(w, "failed to delete bin", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("Deleted"))
+}
+
+// RunBinJanitor removes the data of expired bins until ctx is cancelled
+func RunBinJanitor(ctx context.Context) {
+	ticker := time.NewTicker(config.BinSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		sweepExpiredBins(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func sweepExpiredBins(ctx context.Context) {
+	tokens, err := Store.ClaimExpiredBins(ctx, time.Now())
+	if err != nil {
+		log.Printf("sweepExpiredBins: failed to list expired bins: %v", err)
+		return
+	}
+
+	for _, token := range tokens {
+		if err := teardownBin(ctx, token); err != nil {
+			log.Printf("sweepExpiredBins: failed to tear down bin %s: %v", token, err)
+			continue
+		}
+		log.Printf("Expired bin %s cleaned up", token)
+	}
+}
+
+// Remove captures, counters, credentials and metadata belonging to a bin
+func teardownBin(ctx context.Context, token string) error {
+	if err := purgeTokenData(ctx, token); err != nil {
+		return err
+	}
+
+	return Store.DeleteBin(ctx, token)
+}
+
+// Load a live bin; expired bins no longer have a metadata key
+func lookupBin(ctx context.Context, token string) (*models.Bin, error) {
+	bin, err := Store.GetBin(ctx, token)
+	if err == store.ErrNotFound {
+		return nil, errBinNotFound
+	}
+	return bin, err
+}
+
+// Address of the connecting client
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Resolve the GitHub username behind the session_token cookie, if any
+func sessionUser(r *http.Request) string {
+	sessionCookie, err := r.Cookie("session_token")
+	if err != nil {
+		return ""
+	}
+	username, err := Store.SessionUser(r.Context(), sessionCookie.Value)
+	if err != nil {
+		return ""
+	}
+	return username
+}
+
+func newCredential() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "whk_" + hex.EncodeToString(buf), nil
+}
+
+// Base URL handed out in ingest links
+func publicBaseURL(r *http.Request) string {
+	if base := config.PublicBaseURL; base != "" {
+		return base
+	}
+	scheme := "http"
+	if isSecureContext(r) {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
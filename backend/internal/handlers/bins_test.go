@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"webhook-inspector/internal/config"
+	"webhook-inspector/internal/models"
+	"webhook-inspector/internal/store"
+)
+
+func createBin(t *testing.T, body string) (*httptest.ResponseRecorder, createBinResponse) {
+	t.Helper()
+	req := httptest.NewRequest("POST", "/bins", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	CreateBin(rr, req)
+
+	var resp createBinResponse
+	if rr.Code == http.StatusCreated {
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return rr, resp
+}
+
+func binRequest(method, token, credential string) *http.Request {
+	req := httptest.NewRequest(method, "/bins/"+token, nil)
+	req = req.WithContext(setURLParam(req.Context(), "token", token))
+	if credential != "" {
+		req.Header.Set("Authorization", "Bearer "+credential)
+	}
+	return req
+}
+
+func TestCreateGetDeleteBin(t *testing.T) {
+	Store = store.NewMemory()
+
+	rr, created := createBin(t, `{"ttl":"5m"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("CreateBin = %d: %s", rr.Code, rr.Body)
+	}
+	if created.TTLSeconds != 300 || !strings.HasSuffix(created.IngestURL, "/hooks/"+created.Token) || !strings.HasPrefix(created.Credential, "whk_") {
+		t.Errorf("unexpected bin: %+v", created)
+	}
+
+	rr = httptest.NewRecorder()
+	GetBin(rr, binRequest("GET", created.Token, created.Credential))
+	var bin models.Bin
+	json.NewDecoder(rr.Body).Decode(&bin)
+	if rr.Code != http.StatusOK || bin.Token != created.Token {
+		t.Fatalf("GetBin = %d %+v", rr.Code, bin)
+	}
+
+	rr = httptest.NewRecorder()
+	GetBin(rr, binRequest("GET", created.Token, "whk_wrong"))
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("GetBin with a wrong credential = %d", rr.Code)
+	}
+
+	// The bin token alone is not a credential
+	req := binRequest("GET", created.Token, "")
+	req.AddCookie(&http.Cookie{Name: "webhook_token", Value: created.Token})
+	rr = httptest.NewRecorder()
+	GetBin(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("GetBin with the bin token as cookie = %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	DeleteBin(rr, binRequest("DELETE", created.Token, created.Credential))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("DeleteBin = %d", rr.Code)
+	}
+	rr = httptest.NewRecorder()
+	GetBin(rr, binRequest("GET", created.Token, created.Credential))
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("GetBin after delete = %d", rr.Code)
+	}
+}
+
+func TestCreateBinLimits(t *testing.T) {
+	Store = store.NewMemory()
+
+	for _, body := range []string{`{"ttl":"30s"}`, `{"ttl":"soon"}`, `{"ttl":"1000h"}`, `{`} {
+		if rr, _ := createBin(t, body); rr.Code != http.StatusBadRequest {
+			t.Errorf("CreateBin(%s) = %d", body, rr.Code)
+		}
+	}
+
+	// Rejected requests don't use up the per-IP quota
+	for i := 0; i < config.BinCreateLimit; i++ {
+		createBin(t, `{"ttl":"soon"}`)
+	}
+	if rr, _ := createBin(t, ""); rr.Code != http.StatusCreated {
+		t.Errorf("expected invalid requests not to count, got %d", rr.Code)
+	}
+
+	Store = store.NewMemory()
+	for i := 0; i < config.BinCreateLimit; i++ {
+		if rr, _ := createBin(t, ""); rr.Code != http.StatusCreated {
+			t.Fatalf("bin %d = %d", i, rr.Code)
+		}
+	}
+	if rr, _ := createBin(t, ""); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("expected bins past the per-IP limit to be refused, got %d", rr.Code)
+	}
+}
+
+func TestExpiredBinsAreTornDown(t *testing.T) {
+	Store = store.NewMemory()
+	ctx := context.Background()
+
+	now := time.Now()
+	expired := models.Bin{Token: "old", CreatedAt: now.Add(-time.Hour), ExpiresAt: now.Add(-time.Minute)}
+	live := models.Bin{Token: "new", CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+	for _, bin := range []models.Bin{expired, live} {
+		if err := Store.SaveBin(ctx, bin, "whk_"+bin.Token); err != nil {
+			t.Fatal(err)
+		}
+		if err := Store.SaveCapture(ctx, bin.Token, models.WebhookPayload{ID: "c1", Timestamp: now}, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// An expired bin stops answering before the janitor gets to it
+	rr := httptest.NewRecorder()
+	GetBin(rr, binRequest("GET", "old", "whk_old"))
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("GetBin on an expired bin = %d", rr.Code)
+	}
+
+	sweepExpiredBins(ctx)
+
+	if ids, _ := Store.CaptureIDs(ctx, "old"); len(ids) != 0 {
+		t.Errorf("expected the expired bin's captures to be deleted, got %v", ids)
+	}
+	if _, err := Store.GetBin(ctx, "old"); err != store.ErrNotFound {
+		t.Errorf("expected the expired bin to be deleted, got %v", err)
+	}
+	if ids, _ := Store.CaptureIDs(ctx, "new"); len(ids) != 1 {
+		t.Errorf("expected the live bin to be kept, got %v", ids)
+	}
+	rr = httptest.NewRecorder()
+	GetBin(rr, binRequest("GET", "new", "whk_new"))
+	if rr.Code != http.StatusOK {
+		t.Errorf("GetBin on the live bin = %d", rr.Code)
+	}
+}
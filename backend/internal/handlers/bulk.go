@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"slices"
+
+	"webhook-inspector/internal/models"
+	"webhook-inspector/internal/query"
+)
+
+// Bulk actions
+const (
+	bulkDelete = "delete"
+	bulkTag    = "tag"
+	bulkUntag  = "untag"
+	bulkRead   = "read"
+	bulkUnread = "unread"
+)
+
+type bulkRequest struct {
+	Action string        `json:"action"`
+	Tags   []string      `json:"tags,omitempty"` // for tag and untag
+	IDs    []string      `json:"ids,omitempty"`
+	Filter *query.Filter `json:"filter,omitempty"`
+}
+
+type bulkResponse struct {
+	Action   string `json:"action"`
+	Matched  int    `json:"matched"`
+	Affected int    `json:"affected"`
+}
+
+// BulkUpdateWebhooks deletes, tags, untags or marks read every capture named by ids or
+// matching filter, in one store transaction
+func BulkUpdateWebhooks(w http.ResponseWriter, r *http.Request) {
+	token, ok := GetToken(w, r)
+	if !ok {
+		return
+	}
+
+	var req bulkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	edit, ok := bulkEdit(w, &req)
+	if !ok {
+		return
+	}
+	// An explicit empty filter is how everything is selected, never by accident
+	if len(req.IDs) == 0 && req.Filter == nil {
+		http.Error(w, "ids or filter is required", http.StatusBadRequest)
+		return
+	}
+	if req.Filter != nil {
+		if err := req.Filter.Compile(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	selected, err := selectCaptures(r.Context(), token, req.IDs, req.Filter)
+	if err != nil {
+		log.Printf("BulkUpdateWebhooks: failed to load webhooks for token %s: %v", token, err)
+		http.Error(w, "failed to load webhooks", http.StatusInternalServerError)
+		return
+	}
+	ids := make([]string, len(selected))
+	for i, c := range selected {
+		ids[i] = c.ID
+	}
+
+	resp := bulkResponse{Action: req.Action, Matched: len(ids)}
+	if req.Action == bulkDelete {
+		resp.Affected, err = deleteCaptures(r.Context(), token, ids)
+	} else {
+		resp.Affected, err = Store.UpdateCaptures(r.Context(), token, ids, edit)
+	}
+	if err != nil {
+		log.Printf("BulkUpdateWebhooks: failed to %s webhooks for token %s: %v", req.Action, token, err)
+		http.Error(w, "failed to update webhooks", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// The change an action makes to one capture, reporting whether anything changed; nil
+// for deletes. Answers 400 itself for unknown actions and bad tags.
+func bulkEdit(w http.ResponseWriter, req *bulkRequest) (func(*models.WebhookPayload) bool, bool) {
+	switch req.Action {
+	case bulkDelete:
+		return nil, true
+	case bulkRead, bulkUnread:
+		read := req.Action == bulkRead
+		return func(p *models.WebhookPayload) bool {
+			changed := p.Read != read
+			p.Read = read
+			return changed
+		}, true
+	case bulkTag, bulkUntag:
+		tags, err := normalizeTags(req.Tags)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return nil, false
+		}
+		if len(tags) == 0 {
+			http.Error(w, req.Action+" needs tags", http.StatusBadRequest)
+			return nil, false
+		}
+		if req.Action == bulkUntag {
+			return func(p *models.WebhookPayload) bool {
+				before := len(p.Tags)
+				p.Tags = slices.DeleteFunc(p.Tags, func(t string) bool { return slices.Contains(tags, t) })
+				return len(p.Tags) != before
+			}, true
+		}
+		// Captures that would go past maxTags are left alone
+		return func(p *models.WebhookPayload) bool {
+			merged := p.Tags
+			for _, t := range tags {
+				if !slices.Contains(merged, t) {
+					merged = append(merged, t)
+				}
+			}
+			if len(merged) == len(p.Tags) || len(merged) > maxTags {
+				return false
+			}
+			p.Tags = merged
+			return true
+		}, true
+	}
+	http.Error(w, "action must be one of delete, tag, untag, read, unread", http.StatusBadRequest)
+	return nil, false
+}
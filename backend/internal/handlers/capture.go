@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+
+	"webhook-inspector/internal/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Full view of one capture: the stored payload, its HTTP wire form and derived details
+type captureDetail struct {
+	models.WebhookPayload
+	Raw      string          `json:"raw"`
+	Metadata captureMetadata `json:"metadata"`
+}
+
+type captureMetadata struct {
+	ContentType string `json:"content_type,omitempty"`
+	BodySize    int    `json:"body_size"`
+	BodyJSON    bool   `json:"body_json"` // body parses as JSON
+	HeaderCount int    `json:"header_count"`
+}
+
+func newCaptureDetail(p models.WebhookPayload) captureDetail {
+	meta := captureMetadata{
+		BodySize: len(p.Body),
+		BodyJSON: json.Valid([]byte(p.Body)),
+	}
+	for _, values := range p.Headers {
+		meta.HeaderCount += len(values)
+	}
+	if mediaType, _, err := mime.ParseMediaType(http.Header(p.Headers).Get("Content-Type")); err == nil {
+		meta.ContentType = mediaType
+	}
+	return captureDetail{WebhookPayload: p, Raw: p.Raw(), Metadata: meta}
+}
+
+// GetWebhook returns one capture with its raw form and derived metadata
+func GetWebhook(w http.ResponseWriter, r *http.Request) {
+	token, ok := GetToken(w, r)
+	if !ok {
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	payload, err := loadCapture(r.Context(), token, id)
+	if err == errCaptureNotFound {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("GetWebhook: failed to load webhook %s for token %s: %v", id, token, err)
+		http.Error(w, "failed to fetch webhook", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSONWithETag(w, r, newCaptureDetail(*payload))
+}
+
+// Write v as JSON tagged with a hash of its encoding, or 304 when If-None-Match already
+// names that hash
+func writeJSONWithETag(w http.ResponseWriter, r *http.Request, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("writeJSONWithETag: failed to encode response: %v", err)
+		http.Error(w, "failed to encode response", http.StatusInternalServerError)
+		return
+	}
+	sum := sha256.Sum256(data)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(append(data, '\n'))
+}
+
+// If-None-Match compares weakly (RFC 9110), so W/ prefixes are ignored
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
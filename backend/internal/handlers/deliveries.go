@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"webhook-inspector/internal/forward"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// GetDeliveries lists every outbound attempt recorded against a capture
+func GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	token, ok := GetToken(w, r)
+	if !ok {
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	attempts, err := forward.Attempts(r.Context(), token, id)
+	if err != nil {
+		log.Printf("GetDeliveries: failed to load attempts for webhook %s: %v", id, err)
+		http.Error(w, "failed to load deliveries", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(attempts)
+}
+
+// GetDeadLetters lists forwards that ran out of retries
+func GetDeadLetters(w http.ResponseWriter, r *http.Request) {
+	token, ok := GetToken(w, r)
+	if !ok {
+		return
+	}
+
+	jobs, err := forward.DeadLetters(r.Context(), token)
+	if err != nil {
+		log.Printf("GetDeadLetters: failed to load dead letters for token %s: %v", token, err)
+		http.Error(w, "failed to load dead letters", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(jobs)
+}
+
+// RetryDeadLetter puts a dead-lettered forward back on the delivery queue
+func RetryDeadLetter(w http.ResponseWriter, r *http.Request) {
+	token, ok := GetToken(w, r)
+	if !ok {
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	found, err := forward.RetryDeadLetter(r.Context(), token, id)
+	if err != nil {
+		log.Printf("RetryDeadLetter: failed to requeue job %s for token %s: %v", id, token, err)
+		http.Error(w, "failed to retry delivery", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "Dead letter not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	w.Write([]byte("Retry scheduled"))
+}
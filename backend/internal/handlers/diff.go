@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"webhook-inspector/internal/diff"
+	"webhook-inspector/internal/models"
+)
+
+// DiffWebhooks compares captures a and b: JSON bodies path by path, anything else as
+// a unified diff, plus their headers. ignore and ignore_headers leave out volatile
+// fields and may be repeated or comma-separated.
+func DiffWebhooks(w http.ResponseWriter, r *http.Request) {
+	token, ok := GetToken(w, r)
+	if !ok {
+		return
+	}
+
+	params := r.URL.Query()
+	idA, idB := params.Get("a"), params.Get("b")
+	if idA == "" || idB == "" {
+		http.Error(w, "a and b are required", http.StatusBadRequest)
+		return
+	}
+
+	var captures [2]models.WebhookPayload
+	for i, id := range []string{idA, idB} {
+		payload, err := loadCapture(r.Context(), token, id)
+		if err == errCaptureNotFound {
+			http.Error(w, "Webhook not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("DiffWebhooks: failed to load webhook %s for token %s: %v", id, token, err)
+			http.Error(w, "failed to fetch webhook", http.StatusInternalServerError)
+			return
+		}
+		captures[i] = *payload
+	}
+
+	opts := diff.Options{Ignore: listParam(params, "ignore"), IgnoreHeaders: listParam(params, "ignore_headers")}
+	res, err := diff.Compare(captures[0], captures[1], opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSONWithETag(w, r, res)
+}
+
+// Values of a parameter given repeatedly, comma-separated, or both
+func listParam(params url.Values, name string) []string {
+	var out []string
+	for _, v := range params[name] {
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				out = append(out, item)
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"webhook-inspector/internal/config"
+	"webhook-inspector/internal/models"
+	"webhook-inspector/internal/outbound"
+	"webhook-inspector/internal/rules"
+	"webhook-inspector/internal/sink"
+	"webhook-inspector/internal/transform"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// GetEndpointConfig returns the per-endpoint settings for the current token
+func GetEndpointConfig(w http.ResponseWriter, r *http.Request) {
+	token, ok := GetToken(w, r)
+	if !ok {
+		return
+	}
+
+	cfg, err := Store.LoadConfig(r.Context(), token)
+	if err != nil {
+		log.Printf("GetEndpointConfig: failed to load config for token %s: %v", token, err)
+		http.Error(w, "failed to load endpoint config", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cfg)
+}
+
+// PutEndpointConfig replaces the per-endpoint settings for the current token
+func PutEndpointConfig(w http.ResponseWriter, r *http.Request) {
+	token, ok := GetToken(w, r)
+	if !ok {
+		return
+	}
+
+	var cfg models.EndpointConfig
+	if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	if err := validateEndpointConfig(&cfg); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateRetention(r.Context(), token, cfg.Retention); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := Store.SaveConfig(r.Context(), token, cfg, tokenDataTTL(r.Context(), token)); err != nil {
+		log.Printf("PutEndpointConfig: failed to save config for token %s: %v", token, err)
+		http.Error(w, "failed to save endpoint config", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cfg)
+}
+
+// GetSinks returns the endpoint's and the instance-wide sinks with their delivery and failure counters
+func GetSinks(w http.ResponseWriter, r *http.Request) {
+	token, ok := GetToken(w, r)
+	if !ok {
+		return
+	}
+
+	stats, err := sink.List(r.Context(), Store, token)
+	if err != nil {
+		log.Printf("GetSinks: failed to load sinks for token %s: %v", token, err)
+		http.Error(w, "failed to load sinks", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+type transformPreviewRequest struct {
+	// Steps to try instead of the saved ones
+	Steps *[]models.TransformStep `json:"steps"`
+}
+
+type transformPreviewResponse struct {
+	Original    models.WebhookPayload  `json:"original"`
+	Transformed *models.WebhookPayload `json:"transformed,omitempty"`
+	Error       string                 `json:"error,omitempty"`
+}
+
+// PreviewTransform shows what a forwarded copy of a stored capture would look like,
+// using the saved transforms or the steps in the request body
+func PreviewTransform(w http.ResponseWriter, r *http.Request) {
+	token, ok := GetToken(w, r)
+	if !ok {
+		return
+	}
+
+	var req transformPreviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	payload, err := loadCapture(r.Context(), token, id)
+	if err == errCaptureNotFound {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("PreviewTransform: failed to load webhook %s for token %s: %v", id, token, err)
+		http.Error(w, "failed to load webhook", http.StatusInternalServerError)
+		return
+	}
+
+	var steps []models.TransformStep
+	if req.Steps != nil {
+		steps = *req.Steps
+		if err := transform.Validate(steps); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	} else {
+		cfg, err := Store.LoadConfig(r.Context(), token)
+		if err != nil {
+			log.Printf("PreviewTransform: failed to load config for token %s: %v", token, err)
+			http.Error(w, "failed to load endpoint config", http.StatusInternalServerError)
+			return
+		}
+		steps = cfg.Transforms
+	}
+
+	resp := transformPreviewResponse{Original: *payload}
+	transformed, err := transform.Apply(r.Context(), *payload, steps)
+	if err != nil {
+		resp.Error = err.Error()
+	} else {
+		resp.Transformed = &transformed
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Check user-supplied settings and fill in generated IDs
+func validateEndpointConfig(cfg *models.EndpointConfig) error {
+	if len(cfg.Forwards) > config.ForwardMaxTargets {
+		return fmt.Errorf("at most %d forwarding targets are allowed", config.ForwardMaxTargets)
+	}
+
+	for i := range cfg.Forwards {
+		target := &cfg.Forwards[i]
+		if err := validateTargetURL(target.URL); err != nil {
+			return fmt.Errorf("forward %d: %v", i, err)
+		}
+		if target.MaxAttempts < 0 || target.MaxAttempts > 20 {
+			return fmt.Errorf("forward %d: max_attempts must be between 0 and 20", i)
+		}
+		if target.ID == "" {
+			target.ID = uuid.New().String()
+		}
+	}
+
+	if err := transform.Validate(cfg.Transforms); err != nil {
+		return fmt.Errorf("transforms: %v", err)
+	}
+
+	if err := rules.Validate(cfg.Rules); err != nil {
+		return err
+	}
+	for i, rule := range cfg.Rules {
+		for j, action := range rule.Actions {
+			if action.URL == "" {
+				continue
+			}
+			if err := validateTargetURL(action.URL); err != nil {
+				return fmt.Errorf("rule %d action %d: %v", i, j, err)
+			}
+		}
+	}
+
+	if len(cfg.Sinks) > config.SinkMaxPerToken {
+		return fmt.Errorf("at most %d sinks are allowed", config.SinkMaxPerToken)
+	}
+	for i := range cfg.Sinks {
+		if err := sink.Validate(&cfg.Sinks[i], sink.ScopeEndpoint); err != nil {
+			return fmt.Errorf("sink %d: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// Targets must be public http(s) URLs; see package outbound
+func validateTargetURL(raw string) error {
+	return outbound.CheckURL(raw)
+}
+
+// How long settings for a token should live: until a bin expires, forever for
+// GitHub-owned tokens, and as long as the session cookie for anonymous ones
+func tokenDataTTL(ctx context.Context, token string) time.Duration {
+	if bin, err := lookupBin(ctx, token); err == nil {
+		return time.Until(bin.ExpiresAt)
+	}
+	if owner, err := Store.TokenOwner(ctx, token); err == nil && owner != "" {
+		return 0
+	}
+	return time.Duration(config.SessionCookieTTL) * time.Second
+}
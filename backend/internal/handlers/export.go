@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+
+	"webhook-inspector/internal/export"
+	"webhook-inspector/internal/query"
+)
+
+// ExportWebhooks streams the captures selected by the /logs parameters in the requested
+// format, oldest first and without paging unless limit is given
+func ExportWebhooks(w http.ResponseWriter, r *http.Request) {
+	token, ok := GetToken(w, r)
+	if !ok {
+		return
+	}
+
+	format, ok := export.Lookup(r.URL.Query().Get("format"))
+	if !ok {
+		http.Error(w, "format must be one of "+strings.Join(export.Names(), ", "), http.StatusBadRequest)
+		return
+	}
+
+	params := r.URL.Query()
+	q, ok := parseLogQuery(w, r, token, params)
+	if !ok {
+		return
+	}
+	if params.Get("limit") == "" {
+		q.Limit = math.MaxInt32
+	}
+	if params.Get("order") == "" {
+		q.Order = query.OrderAsc
+	}
+
+	page, err := Store.QueryCaptures(r.Context(), token, q)
+	if err == query.ErrInvalidCursor {
+		http.Error(w, "invalid cursor", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("ExportWebhooks: failed to query webhooks for token %s: %v", token, err)
+		http.Error(w, "failed to fetch webhooks", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="webhooks.%s"`, format.Extension))
+	opts := export.Options{BaseURL: publicBaseURL(r) + "/hooks/" + token}
+	if err := format.Write(w, page.Items, opts); err != nil {
+		// Headers are already sent, so the client sees a truncated file
+		log.Printf("ExportWebhooks: failed to write %s export for token %s: %v", format.Name, token, err)
+	}
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"webhook-inspector/internal/fixtures"
+	"webhook-inspector/internal/models"
+	"webhook-inspector/internal/replay"
+	"webhook-inspector/internal/signing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type fixtureDetail struct {
+	fixtures.Fixture
+	Sample *fixtures.Rendered `json:"sample"`
+}
+
+type sendFixtureRequest struct {
+	// External URL; empty sends into the caller's own /hooks/{token}
+	URL    string `json:"url"`
+	Secret string `json:"secret"`
+}
+
+type sendFixtureResponse struct {
+	Fixture  string                 `json:"fixture"`
+	URL      string                 `json:"url"`
+	Signed   bool                   `json:"signed"`
+	Request  *fixtures.Rendered     `json:"request"`
+	Response models.DeliveryAttempt `json:"response"`
+}
+
+// ListFixtures returns the catalog of provider sample events
+func ListFixtures(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(fixtures.List())
+}
+
+// GetFixture returns one catalog entry with a freshly rendered, unsigned sample
+func GetFixture(w http.ResponseWriter, r *http.Request) {
+	fixture, ok := fixtures.Get(chi.URLParam(r, "name"))
+	if !ok {
+		http.Error(w, "Fixture not found", http.StatusNotFound)
+		return
+	}
+
+	sample, err := fixture.Render(time.Now())
+	if err != nil {
+		log.Printf("GetFixture: failed to render %s: %v", fixture.Name, err)
+		http.Error(w, "failed to render fixture", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(fixtureDetail{Fixture: fixture, Sample: sample})
+}
+
+// SendFixture renders a catalog event, signs it like the provider would and sends it
+// either into the caller's own endpoint or to an external URL
+func SendFixture(w http.ResponseWriter, r *http.Request) {
+	token, ok := GetToken(w, r)
+	if !ok {
+		return
+	}
+
+	fixture, ok := fixtures.Get(chi.URLParam(r, "name"))
+	if !ok {
+		http.Error(w, "Fixture not found", http.StatusNotFound)
+		return
+	}
+
+	var req sendFixtureRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	target := req.URL
+	if target == "" {
+		target = publicBaseURL(r) + "/hooks/" + token
+	} else if err := validateTargetURL(target); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	rendered, err := fixture.Render(now)
+	if err != nil {
+		log.Printf("SendFixture: failed to render %s: %v", fixture.Name, err)
+		http.Error(w, "failed to render fixture", http.StatusInternalServerError)
+		return
+	}
+
+	secret := req.Secret
+	if secret == "" && fixture.Sign != "" {
+		cfg, err := Store.LoadConfig(r.Context(), token)
+		if err != nil {
+			log.Printf("SendFixture: failed to load config for token %s: %v", token, err)
+			http.Error(w, "failed to load endpoint config", http.StatusInternalServerError)
+			return
+		}
+		secret = signing.SecretFor(cfg.Secrets, fixture.Sign)
+	}
+
+	// Without a secret the event still goes out, just unsigned
+	signed := false
+	if fixture.Sign != "" && secret != "" {
+		msg := signing.Message{ID: "msg_" + uuid.New().String(), Timestamp: now, Body: []byte(rendered.Body), URL: target}
+		if err := signing.Sign(fixture.Sign, secret, rendered.Headers, msg); err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		signed = true
+	}
+
+	var attempt models.DeliveryAttempt
+	if req.URL == "" {
+		attempt = deliverToSelf(r.Context(), token, rendered)
+	} else {
+		payload := models.WebhookPayload{Method: rendered.Method, Headers: rendered.Headers, Body: rendered.Body}
+		attempt = replay.Send(r.Context(), payload, target)
+	}
+	attempt.Kind = "fixture"
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sendFixtureResponse{
+		Fixture:  fixture.Name,
+		URL:      target,
+		Signed:   signed,
+		Request:  rendered,
+		Response: attempt,
+	})
+}
+
+// Run a rendered event through the regular ingest handler in-process
+func deliverToSelf(ctx context.Context, token string, rendered *fixtures.Rendered) models.DeliveryAttempt {
+	attempt := models.DeliveryAttempt{
+		URL:       "/hooks/" + token,
+		Attempt:   1,
+		Timestamp: time.Now().UTC(),
+	}
+
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("token", token)
+	req := httptest.NewRequest(rendered.Method, "/hooks/"+token, strings.NewReader(rendered.Body))
+	req = req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, rctx))
+	req.Header = rendered.Headers.Clone()
+	req.AddCookie(&http.Cookie{Name: "webhook_token", Value: token})
+
+	start := time.Now()
+	rec := httptest.NewRecorder()
+	HandleWebhook(rec, req)
+	attempt.LatencyMs = time.Since(start).Milliseconds()
+	attempt.StatusCode = rec.Code
+	attempt.ResponseHeaders = rec.Header()
+	attempt.ResponseBody = rec.Body.String()
+	return attempt
+}
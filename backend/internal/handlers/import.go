@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"webhook-inspector/internal/config"
+	"webhook-inspector/internal/importer"
+	"webhook-inspector/internal/models"
+
+	"github.com/google/uuid"
+)
+
+type importResponse struct {
+	Imported int      `json:"imported"`
+	IDs      []string `json:"ids"`
+}
+
+// ImportWebhooks loads captures from a HAR or NDJSON request body. They keep their
+// original timestamps and count against the storage quota, not the ingest rate limit.
+func ImportWebhooks(w http.ResponseWriter, r *http.Request) {
+	token, ok := GetToken(w, r)
+	if !ok {
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	captures, err := importer.Parse(format, http.MaxBytesReader(w, r.Body, config.ImportMaxBytes))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, fmt.Sprintf("import is larger than %d bytes", config.ImportMaxBytes), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(captures) == 0 {
+		http.Error(w, "no captures found", http.StatusBadRequest)
+		return
+	}
+
+	stored, err := Store.CountCaptures(r.Context(), token)
+	if err != nil {
+		log.Printf("ImportWebhooks: failed to count webhooks for token %s: %v", token, err)
+		http.Error(w, "failed to check storage quota", http.StatusInternalServerError)
+		return
+	}
+	if quota := storageQuota(r.Context(), token); stored+len(captures) > quota {
+		http.Error(w, fmt.Sprintf("import of %d captures would exceed the storage quota (%d of %d stored)", len(captures), stored, quota), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	cfg, err := Store.LoadConfig(r.Context(), token)
+	if err != nil {
+		log.Printf("ImportWebhooks: failed to load config for token %s: %v", token, err)
+		http.Error(w, "failed to load endpoint config", http.StatusInternalServerError)
+		return
+	}
+
+	// Bins take imported captures with them when they expire
+	dataTTL := retentionTTL(cfg)
+	if bin, err := lookupBin(r.Context(), token); err == nil {
+		remaining := time.Until(bin.ExpiresAt)
+		if remaining <= 0 {
+			http.Error(w, "bin has expired", http.StatusGone)
+			return
+		}
+		dataTTL = min(dataTTL, remaining)
+	}
+
+	now := time.Now().UTC()
+	resp := importResponse{IDs: make([]string, 0, len(captures))}
+	for _, p := range captures {
+		p.Imported = &models.CaptureImport{Format: format, At: now, OriginalID: p.ID}
+		p.ID = uuid.New().String()
+		p.Pinned = false
+		if err := Store.SaveCapture(r.Context(), token, p, dataTTL); err != nil {
+			log.Printf("ImportWebhooks: failed to save webhook for token %s: %v", token, err)
+			http.Error(w, fmt.Sprintf("failed to save webhook after importing %d", resp.Imported), http.StatusInternalServerError)
+			return
+		}
+		resp.Imported++
+		resp.IDs = append(resp.IDs, p.ID)
+	}
+	if _, err := evictOverflow(r.Context(), token, cfg); err != nil {
+		log.Printf("ImportWebhooks: failed to evict webhooks for token %s: %v", token, err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Captures a token may keep; privileged tokens get the larger quota like the rate limit
+func storageQuota(ctx context.Context, token string) int {
+	if owner, err := Store.TokenOwner(ctx, token); err == nil && owner != "" {
+		return config.PrivilegedStorageQuota
+	}
+	return config.AnonymousStorageQuota
+}
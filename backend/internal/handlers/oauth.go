@@ -8,9 +8,7 @@ import (
 	"time"
 	"webhook-inspector/internal/auth"
 	"webhook-inspector/internal/config"
-	"webhook-inspector/internal/redis"
-
-	goredis "github.com/redis/go-redis/v9"
+	"webhook-inspector/internal/store"
 
 	"os"
 
@@ -79,19 +77,19 @@ func GitHubCallback(w http.ResponseWriter, r *http.Request) {
 	// Step 2: Look up or create a webhook token for this user
 	var finalToken string
 
-	existingToken, err := redis.Client.Get(r.Context(), "user:"+ghUser.Login+":webhook_token").Result()
-	if err == goredis.Nil {
+	existingToken, err := Store.UserToken(r.Context(), ghUser.Login)
+	if err == store.ErrNotFound {
 		// If no token, generate a new one
 		finalToken = uuid.New().String()
-		redis.Client.Set(r.Context(), "user:"+ghUser.Login+":webhook_token", finalToken, 0)
-		redis.Client.Set(r.Context(), "token:"+finalToken+":owner", ghUser.Login, 0)
+		Store.SetUserToken(r.Context(), ghUser.Login, finalToken)
+		Store.SetTokenOwner(r.Context(), finalToken, ghUser.Login, 0)
 	} else if err == nil {
 		// If token exists, ensure owner is registered
 		finalToken = existingToken
-		redis.Client.Set(r.Context(), "token:"+finalToken+":owner", ghUser.Login, 0)
+		Store.SetTokenOwner(r.Context(), finalToken, ghUser.Login, 0)
 	} else {
-		log.Printf("GitHubCallback: Redis error getting webhook token for user %s: %v", ghUser.Login, err)
-		http.Error(w, "Redis error", http.StatusInternalServerError)
+		log.Printf("GitHubCallback: store error getting webhook token for user %s: %v", ghUser.Login, err)
+		http.Error(w, "Store error", http.StatusInternalServerError)
 		return
 	}
 
@@ -109,7 +107,7 @@ func GitHubCallback(w http.ResponseWriter, r *http.Request) {
 	// Step 4: Set session_token cookie with proper expiration
 	sessionToken := uuid.New().String()
 	sessionTTL := time.Duration(config.SessionCookieTTL) * time.Second
-	redis.Client.Set(r.Context(), "user:"+sessionToken, ghUser.Login, sessionTTL)
+	Store.CreateSession(r.Context(), sessionToken, ghUser.Login, sessionTTL)
 
 	http.SetCookie(w, &http.Cookie{
 		Name:     "session_token",
@@ -134,7 +132,7 @@ func GetCurrentUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	username, err := redis.Client.Get(r.Context(), "user:"+cookie.Value).Result()
+	username, err := Store.SessionUser(r.Context(), cookie.Value)
 	if err != nil {
 		http.Error(w, "Invalid or expired session", http.StatusUnauthorized)
 		return
@@ -150,6 +148,13 @@ func GetCurrentUser(w http.ResponseWriter, r *http.Request) {
 
 // Logout the user and delete their token
 func Logout(w http.ResponseWriter, r *http.Request) {
+	// Drop the server-side session so the old cookie can't be reused
+	if cookie, err := r.Cookie("session_token"); err == nil {
+		if err := Store.DeleteSession(r.Context(), cookie.Value); err != nil {
+			log.Printf("Logout: failed to delete session: %v", err)
+		}
+	}
+
 	// Clear the cookie
 	http.SetCookie(w, &http.Cookie{
 		Name:     "session_token",
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"webhook-inspector/internal/config"
+	"webhook-inspector/internal/events"
+	"webhook-inspector/internal/forward"
+	"webhook-inspector/internal/models"
+	"webhook-inspector/internal/query"
+	"webhook-inspector/internal/relay"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	relayPingInterval = 30 * time.Second
+	relayWriteTimeout = 10 * time.Second
+)
+
+// The default origin check rejects cross-site browser connections; the CLI sends no Origin
+var relayUpgrader = websocket.Upgrader{}
+
+// RelayConnect streams new captures for the token over a WebSocket so a CLI can
+// replay them against a local receiver. Passing ?since=<RFC3339 time> first
+// sends every stored capture after that point, covering gaps between reconnects.
+func RelayConnect(w http.ResponseWriter, r *http.Request) {
+	token, ok := GetToken(w, r)
+	if !ok {
+		return
+	}
+
+	var since time.Time
+	if raw := r.URL.Query().Get("since"); raw != "" {
+		parsed, err := time.Parse(time.RFC3339Nano, raw)
+		if err != nil {
+			http.Error(w, "since must be an RFC3339 timestamp", http.StatusBadRequest)
+			return
+		}
+		since = parsed
+	}
+
+	// Subscribe before reading history so nothing stored in between is lost
+	sub, err := events.Subscribe(r.Context(), token)
+	if err != nil {
+		log.Printf("RelayConnect: failed to subscribe for token %s: %v", token, err)
+		http.Error(w, "failed to subscribe to captures", http.StatusInternalServerError)
+		return
+	}
+	defer sub.Close()
+
+	conn, err := relayUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already written the error response
+		return
+	}
+	defer conn.Close()
+
+	write := func(msg relay.Message) error {
+		conn.SetWriteDeadline(time.Now().Add(relayWriteTimeout))
+		return conn.WriteJSON(msg)
+	}
+
+	sent := make(map[string]bool)
+	if !since.IsZero() {
+		q := query.Query{Filter: query.Filter{Since: &since}, Limit: config.LogsMaxPageSize, Order: query.OrderAsc}
+		for {
+			page, err := Store.QueryCaptures(r.Context(), token, q)
+			if err != nil {
+				log.Printf("RelayConnect: failed to load captures for token %s: %v", token, err)
+				return
+			}
+			for i := range page.Items {
+				if !page.Items[i].Timestamp.After(since) {
+					continue
+				}
+				if err := write(relay.Message{Type: relay.TypeCapture, Capture: &page.Items[i], Replayed: true}); err != nil {
+					return
+				}
+				sent[page.Items[i].ID] = true
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			q.Cursor = page.NextCursor
+		}
+	}
+	if err := write(relay.Message{Type: relay.TypeSynced}); err != nil {
+		return
+	}
+
+	// Results come back on their own goroutine; gorilla allows one reader and one writer
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			var msg relay.Message
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			if msg.Type != relay.TypeResult || msg.Result == nil || msg.CaptureID == "" {
+				continue
+			}
+			msg.Result.Kind = "relay"
+			if err := forward.RecordAttempt(r.Context(), Store, token, msg.CaptureID, *msg.Result); err != nil {
+				log.Printf("RelayConnect: failed to record result for webhook %s: %v", msg.CaptureID, err)
+			}
+		}
+	}()
+
+	ping := time.NewTicker(relayPingInterval)
+	defer ping.Stop()
+	live := sub.Channel()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(relayWriteTimeout)); err != nil {
+				return
+			}
+		case m, ok := <-live:
+			if !ok {
+				return
+			}
+			var capture models.WebhookPayload
+			if err := json.Unmarshal([]byte(m.Payload), &capture); err != nil {
+				continue
+			}
+			if sent[capture.ID] {
+				delete(sent, capture.ID)
+				continue
+			}
+			if err := write(relay.Message{Type: relay.TypeCapture, Capture: &capture}); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"webhook-inspector/internal/config"
+	"webhook-inspector/internal/forward"
+	"webhook-inspector/internal/models"
+	"webhook-inspector/internal/query"
+	"webhook-inspector/internal/replay"
+	"webhook-inspector/internal/store"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type replayResponse struct {
+	Request  models.WebhookPayload  `json:"request"`
+	Response models.DeliveryAttempt `json:"response"`
+}
+
+// ReplayWebhook re-sends a stored capture to any URL, optionally edited and re-signed
+func ReplayWebhook(w http.ResponseWriter, r *http.Request) {
+	token, ok := GetToken(w, r)
+	if !ok {
+		return
+	}
+
+	var opts replay.Options
+	if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if err := opts.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	payload, err := loadCapture(r.Context(), token, id)
+	if err == errCaptureNotFound {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("ReplayWebhook: failed to load webhook %s for token %s: %v", id, token, err)
+		http.Error(w, "failed to load webhook", http.StatusInternalServerError)
+		return
+	}
+
+	cfg, err := Store.LoadConfig(r.Context(), token)
+	if err != nil {
+		log.Printf("ReplayWebhook: failed to load config for token %s: %v", token, err)
+		http.Error(w, "failed to load endpoint config", http.StatusInternalServerError)
+		return
+	}
+
+	edited, err := replay.Prepare(*payload, opts, cfg.Secrets, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	attempt := replay.Send(r.Context(), edited, opts.URL)
+	if err := forward.RecordAttempt(r.Context(), Store, token, id, attempt); err != nil {
+		log.Printf("ReplayWebhook: failed to record replay of webhook %s: %v", id, err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(replayResponse{Request: edited, Response: attempt})
+}
+
+// StartReplayBatch replays many captures, in original order, against one target in the background
+func StartReplayBatch(w http.ResponseWriter, r *http.Request) {
+	token, ok := GetToken(w, r)
+	if !ok {
+		return
+	}
+
+	var req replay.BatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	captures, err := selectCaptures(r.Context(), token, req.IDs, req.Filter)
+	if err != nil {
+		log.Printf("StartReplayBatch: failed to load webhooks for token %s: %v", token, err)
+		http.Error(w, "failed to load webhooks", http.StatusInternalServerError)
+		return
+	}
+	if len(captures) == 0 {
+		http.Error(w, "no webhooks match the selection", http.StatusUnprocessableEntity)
+		return
+	}
+
+	cfg, err := Store.LoadConfig(r.Context(), token)
+	if err != nil {
+		log.Printf("StartReplayBatch: failed to load config for token %s: %v", token, err)
+		http.Error(w, "failed to load endpoint config", http.StatusInternalServerError)
+		return
+	}
+
+	batch, err := replay.Start(Store, token, req, captures, cfg.Secrets)
+	if err == replay.ErrTooManyBatches {
+		http.Error(w, fmt.Sprintf("replay limit of %d running reached; wait for one to finish or cancel it", config.ReplayMaxBatchesPerToken), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("StartReplayBatch: failed to start batch for token %s: %v", token, err)
+		http.Error(w, "failed to start replay", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(batch)
+}
+
+// Load the captures named by ID, or every one matching the filter, in original order
+func selectCaptures(ctx context.Context, token string, ids []string, filter *query.Filter) ([]models.WebhookPayload, error) {
+	var selected []models.WebhookPayload
+	if len(ids) == 0 {
+		if filter == nil {
+			filter = &query.Filter{}
+		}
+		err := eachCapture(ctx, token, *filter, func(c models.WebhookPayload) {
+			selected = append(selected, c)
+		})
+		return selected, err
+	}
+
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		c, err := Store.GetCapture(ctx, token, id)
+		if err == store.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		selected = append(selected, *c)
+	}
+	sort.Slice(selected, func(i, j int) bool {
+		a := query.Entry{ID: selected[i].ID, Time: selected[i].Timestamp}
+		return a.Before(query.Entry{ID: selected[j].ID, Time: selected[j].Timestamp})
+	})
+	return selected, nil
+}
+
+// Call fn with every capture matching the filter, oldest first, a page at a time so
+// the store only walks the filter's time range
+func eachCapture(ctx context.Context, token string, filter query.Filter, fn func(models.WebhookPayload)) error {
+	q := query.Query{Filter: filter, Limit: config.LogsMaxPageSize, Order: query.OrderAsc}
+	for {
+		page, err := Store.QueryCaptures(ctx, token, q)
+		if err != nil {
+			return err
+		}
+		for _, c := range page.Items {
+			fn(c)
+		}
+		if page.NextCursor == "" {
+			return nil
+		}
+		q.Cursor = page.NextCursor
+	}
+}
+
+// GetReplayBatch returns the progress of a bulk replay
+func GetReplayBatch(w http.ResponseWriter, r *http.Request) {
+	token, ok := GetToken(w, r)
+	if !ok {
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	batch, err := replay.Get(r.Context(), Store, token, id)
+	if err == replay.ErrBatchNotFound {
+		http.Error(w, "Replay not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("GetReplayBatch: failed to load batch %s: %v", id, err)
+		http.Error(w, "failed to load replay", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(batch)
+}
+
+// GetReplayReport returns per-capture status codes and latency for a bulk replay
+func GetReplayReport(w http.ResponseWriter, r *http.Request) {
+	token, ok := GetToken(w, r)
+	if !ok {
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	if _, err := replay.Get(r.Context(), Store, token, id); err == replay.ErrBatchNotFound {
+		http.Error(w, "Replay not found", http.StatusNotFound)
+		return
+	}
+
+	results, err := replay.Report(r.Context(), Store, token, id)
+	if err != nil {
+		log.Printf("GetReplayReport: failed to load report for batch %s: %v", id, err)
+		http.Error(w, "failed to load report", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+// CancelReplayBatch stops dispatching further captures for a running bulk replay
+func CancelReplayBatch(w http.ResponseWriter, r *http.Request) {
+	token, ok := GetToken(w, r)
+	if !ok {
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	cancelled, err := replay.Cancel(r.Context(), Store, token, id)
+	if err != nil {
+		log.Printf("CancelReplayBatch: failed to cancel batch %s: %v", id, err)
+		http.Error(w, "failed to cancel replay", http.StatusInternalServerError)
+		return
+	}
+	if !cancelled {
+		http.Error(w, "Replay not running", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	w.Write([]byte("Cancelling"))
+}
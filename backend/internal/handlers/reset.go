@@ -7,7 +7,11 @@ import (
 	"net/http"
 
 	"webhook-inspector/internal/config"
+	"webhook-inspector/internal/forward"
 	"webhook-inspector/internal/redis"
+	"webhook-inspector/internal/replay"
+	"webhook-inspector/internal/simulate"
+	"webhook-inspector/internal/sink"
 
 	"github.com/google/uuid"
 )
@@ -19,36 +23,22 @@ func ResetToken(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Delete webhook data
-	pattern := fmt.Sprintf("hooks:%s:*", token)
-	keys, err := redis.Client.Keys(context.Background(), pattern).Result()
-	if err != nil {
-		log.Printf("ResetToken: failed to get keys for pattern %s: %v", pattern, err)
+	if err := purgeTokenData(context.Background(), token); err != nil {
+		log.Printf("ResetToken: failed to delete data for token %s: %v", token, err)
 		http.Error(w, "Failed to reset token", http.StatusInternalServerError)
 		return
 	}
-	if len(keys) > 0 {
-		err = redis.Client.Del(context.Background(), keys...).Err()
-		if err != nil {
-			log.Printf("ResetToken: failed to delete keys for token %s: %v", token, err)
-			http.Error(w, "Failed to reset token", http.StatusInternalServerError)
-			return
-		}
-	}
-	err = redis.Client.Del(context.Background(), fmt.Sprintf("rate_limit:%s", token)).Err()
-	if err != nil {
-		log.Printf("ResetToken: failed to delete rate limit key for token %s: %v", token, err)
-	}
 
 	// Check if user is logged in via session_token
 	var newToken string
 	if sessionCookie, err := r.Cookie("session_token"); err == nil {
 		sessionToken := sessionCookie.Value
-		username, err := redis.Client.Get(context.Background(), "user:"+sessionToken).Result()
+		username, err := Store.SessionUser(r.Context(), sessionToken)
 		if err == nil && username != "" {
 			// GitHub user found, so assign user-associated token
 			newToken = uuid.New().String()
-			redis.Client.Set(context.Background(), "user:"+username+":webhook_token", newToken, 0)
-			redis.Client.Set(context.Background(), "token:"+newToken+":owner", username, 0)
+			Store.SetUserToken(r.Context(), username, newToken)
+			Store.SetTokenOwner(r.Context(), newToken, username, 0)
 		}
 	}
 
@@ -72,3 +62,41 @@ func ResetToken(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(`{"success": true, "message": "Token reset complete"}`))
 }
+
+// Delete every capture, its delivery history, simulations, sink streams, the endpoint settings, baselines and the usage counter for a token
+func purgeTokenData(ctx context.Context, token string) error {
+	ids, err := Store.CaptureIDs(ctx, token)
+	if err != nil {
+		return fmt.Errorf("list captures: %w", err)
+	}
+	if err := Store.DeleteCaptures(ctx, token); err != nil {
+		return fmt.Errorf("delete captures: %w", err)
+	}
+	if redis.Enabled() {
+		if err := forward.Purge(ctx, token, ids); err != nil {
+			return fmt.Errorf("delete deliveries: %w", err)
+		}
+		if err := replay.Purge(ctx, Store, token); err != nil {
+			return fmt.Errorf("delete replays: %w", err)
+		}
+		if err := simulate.Purge(ctx, Store, token); err != nil {
+			return fmt.Errorf("delete simulations: %w", err)
+		}
+		if err := sink.Purge(ctx, Store, token); err != nil {
+			return fmt.Errorf("delete sink data: %w", err)
+		}
+	}
+	if err := Store.DeleteConfig(ctx, token); err != nil {
+		return fmt.Errorf("delete endpoint config: %w", err)
+	}
+	if err := Store.DeleteBaselines(ctx, token); err != nil {
+		return fmt.Errorf("delete baselines: %w", err)
+	}
+	if err := Store.DeleteShares(ctx, token); err != nil {
+		return fmt.Errorf("delete share links: %w", err)
+	}
+	if err := Store.ResetUsage(ctx, token); err != nil {
+		log.Printf("purgeTokenData: failed to delete rate limit key for token %s: %v", token, err)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"webhook-inspector/internal/config"
+	"webhook-inspector/internal/forward"
+	"webhook-inspector/internal/models"
+	"webhook-inspector/internal/redis"
+)
+
+// Check an endpoint's retention against its tier's limits
+func validateRetention(ctx context.Context, token string, r models.Retention) error {
+	if r.TTL != "" {
+		ttl, err := time.ParseDuration(r.TTL)
+		if err != nil || ttl < time.Minute {
+			return errors.New("retention ttl must be a duration of at least 1m")
+		}
+		if limit := maxRetention(ctx, token); ttl > limit {
+			return fmt.Errorf("retention ttl exceeds the maximum of %s for this tier", limit)
+		}
+	}
+	if quota := storageQuota(ctx, token); r.MaxCaptures < 0 || r.MaxCaptures > quota {
+		return fmt.Errorf("retention max_captures must be between 0 and %d", quota)
+	}
+	return nil
+}
+
+// Longest retention a token may choose; privileged tokens get the longer one
+func maxRetention(ctx context.Context, token string) time.Duration {
+	if owner, err := Store.TokenOwner(ctx, token); err == nil && owner != "" {
+		return config.PrivilegedMaxRetention
+	}
+	return config.AnonymousMaxRetention
+}
+
+// How long an endpoint keeps new captures
+func retentionTTL(cfg models.EndpointConfig) time.Duration {
+	return cfg.Retention.Duration(config.WebhookDataTTL)
+}
+
+// Delete captures together with their delivery history, returning how many existed
+func deleteCaptures(ctx context.Context, token string, ids []string) (int, error) {
+	deleted, err := Store.DeleteCaptureIDs(ctx, token, ids)
+	if err != nil {
+		return 0, err
+	}
+	if redis.Enabled() {
+		if err := forward.DeleteHistory(ctx, token, ids); err != nil {
+			return deleted, err
+		}
+	}
+	return deleted, nil
+}
+
+// Delete the oldest unpinned captures past the endpoint's max_captures together with
+// their delivery history, returning how many went. Pinned captures don't count.
+func evictOverflow(ctx context.Context, token string, cfg models.EndpointConfig) (int, error) {
+	limit := cfg.Retention.MaxCaptures
+	if limit <= 0 {
+		return 0, nil
+	}
+	ids, err := Store.OldestUnpinned(ctx, token, limit)
+	if err != nil || len(ids) == 0 {
+		return 0, err
+	}
+	return deleteCaptures(ctx, token, ids)
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"webhook-inspector/internal/models"
+	"webhook-inspector/internal/query"
+	"webhook-inspector/internal/schema"
+)
+
+type schemaSummary struct {
+	Provider  string `json:"provider,omitempty"`
+	EventType string `json:"event_type,omitempty"`
+	TypeName  string `json:"type_name"`
+	Samples   int    `json:"samples"`
+}
+
+// ListSchemas lists the event types a schema can be inferred for, with how many
+// captures of each are stored
+func ListSchemas(w http.ResponseWriter, r *http.Request) {
+	token, ok := GetToken(w, r)
+	if !ok {
+		return
+	}
+
+	counts := make(map[[2]string]int)
+	err := eachCapture(r.Context(), token, query.Filter{}, func(c models.WebhookPayload) {
+		counts[[2]string{c.Provider, c.EventType}]++
+	})
+	if err != nil {
+		log.Printf("ListSchemas: failed to load webhooks for token %s: %v", token, err)
+		http.Error(w, "failed to load webhooks", http.StatusInternalServerError)
+		return
+	}
+	summaries := make([]schemaSummary, 0, len(counts))
+	for event, n := range counts {
+		summaries = append(summaries, schemaSummary{
+			Provider:  event[0],
+			EventType: event[1],
+			TypeName:  schema.TypeName(event[0], event[1]),
+			Samples:   n,
+		})
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].Provider != summaries[j].Provider {
+			return summaries[i].Provider < summaries[j].Provider
+		}
+		return summaries[i].EventType < summaries[j].EventType
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summaries)
+}
+
+// InferSchema infers a JSON Schema from every stored capture of one provider and event
+// type and returns it, or the Go, TypeScript or Python types generated from it, as a
+// download
+func InferSchema(w http.ResponseWriter, r *http.Request) {
+	token, ok := GetToken(w, r)
+	if !ok {
+		return
+	}
+
+	params := r.URL.Query()
+	name := params.Get("format")
+	if name == "" {
+		name = "json-schema"
+	}
+	format, ok := schema.Lookup(name)
+	if !ok {
+		http.Error(w, "format must be one of "+strings.Join(schema.Names(), ", "), http.StatusBadRequest)
+		return
+	}
+
+	provider, eventType := params.Get("provider"), params.Get("event_type")
+	var samples []models.WebhookPayload
+	err := eachCapture(r.Context(), token, query.Filter{Provider: provider}, func(c models.WebhookPayload) {
+		if c.Provider == provider && c.EventType == eventType {
+			samples = append(samples, c)
+		}
+	})
+	if err != nil {
+		log.Printf("InferSchema: failed to load webhooks for token %s: %v", token, err)
+		http.Error(w, "failed to load webhooks", http.StatusInternalServerError)
+		return
+	}
+
+	typeName := schema.TypeName(provider, eventType)
+	s, n := schema.Infer(typeName, samples)
+	if n == 0 {
+		http.Error(w, "no JSON or form webhooks of this event type", http.StatusNotFound)
+		return
+	}
+	out, err := format.Render(s)
+	if err != nil {
+		log.Printf("InferSchema: failed to render %s for token %s: %v", format.Name, token, err)
+		http.Error(w, "failed to generate "+format.Name, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, typeName, format.Extension))
+	w.Write(out)
+}
@@ -0,0 +1,363 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"webhook-inspector/internal/config"
+	"webhook-inspector/internal/query"
+	"webhook-inspector/internal/share"
+	"webhook-inspector/internal/store"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	maxRedactHeaders    = 50
+	maxSharePassword    = 256
+	sharePasswordHeader = "X-Share-Password"
+)
+
+type shareRequest struct {
+	CaptureID string        `json:"capture_id,omitempty"`
+	Filter    *query.Filter `json:"filter,omitempty"`
+	TTL       string        `json:"ttl,omitempty"`
+	Password  string        `json:"password,omitempty"`
+	// Omitted means share.DefaultRedactHeaders; [] redacts nothing but Cookie
+	RedactHeaders *[]string `json:"redact_headers,omitempty"`
+}
+
+type shareResponse struct {
+	ID            string        `json:"id"`
+	URL           string        `json:"url"`
+	CaptureID     string        `json:"capture_id,omitempty"`
+	Filter        *query.Filter `json:"filter,omitempty"`
+	RedactHeaders []string      `json:"redact_headers"`
+	Protected     bool          `json:"password_protected"`
+	CreatedAt     time.Time     `json:"created_at"`
+	ExpiresAt     time.Time     `json:"expires_at"`
+}
+
+// Key signing share URLs
+var shareSigningKey = sync.OnceValue(func() []byte {
+	if config.ShareSigningKey != "" {
+		return []byte(config.ShareSigningKey)
+	}
+	// main refuses to start the Redis store without one
+	log.Println("SHARE_SIGNING_KEY is not set; share links will stop working on restart")
+	key := make([]byte, 32)
+	rand.Read(key)
+	return key
+})
+
+// CreateShare hands out a signed, expiring read-only link to one capture or to every
+// capture matching a filter
+func CreateShare(w http.ResponseWriter, r *http.Request) {
+	token, ok := GetToken(w, r)
+	if !ok {
+		return
+	}
+
+	var req shareRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if (req.CaptureID == "") == (req.Filter == nil) {
+		http.Error(w, "exactly one of capture_id and filter is required", http.StatusBadRequest)
+		return
+	}
+	if req.Filter != nil {
+		if err := req.Filter.Compile(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	ttl, err := shareTTL(r.Context(), token, req.TTL)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	redact := share.DefaultRedactHeaders
+	if req.RedactHeaders != nil {
+		if redact, err = normalizeRedactHeaders(*req.RedactHeaders); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if len(req.Password) > maxSharePassword {
+		http.Error(w, fmt.Sprintf("password exceeds %d bytes", maxSharePassword), http.StatusBadRequest)
+		return
+	}
+
+	if req.CaptureID != "" {
+		_, err := loadCapture(r.Context(), token, req.CaptureID)
+		if err == errCaptureNotFound {
+			http.Error(w, "Webhook not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("CreateShare: failed to load webhook %s for token %s: %v", req.CaptureID, token, err)
+			http.Error(w, "failed to create share link", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	links, err := Store.ListShares(r.Context(), token)
+	if err != nil {
+		log.Printf("CreateShare: failed to load share links for token %s: %v", token, err)
+		http.Error(w, "failed to create share link", http.StatusInternalServerError)
+		return
+	}
+	if len(links) >= config.ShareMaxPerToken {
+		http.Error(w, fmt.Sprintf("share link limit of %d reached; revoke one first", config.ShareMaxPerToken), http.StatusConflict)
+		return
+	}
+
+	id, err := share.NewID()
+	if err != nil {
+		log.Printf("CreateShare: failed to generate share ID: %v", err)
+		http.Error(w, "failed to create share link", http.StatusInternalServerError)
+		return
+	}
+	now := time.Now().UTC()
+	link := share.Link{
+		ID:        id,
+		Token:     token,
+		CaptureID: req.CaptureID,
+		Filter:    req.Filter,
+		Redact:    redact,
+		CreatedAt: now,
+		// Signatures cover whole seconds
+		ExpiresAt: now.Add(ttl).Truncate(time.Second),
+	}
+	if req.Password != "" {
+		if link.Password, err = share.HashPassword(req.Password); err != nil {
+			log.Printf("CreateShare: failed to hash password: %v", err)
+			http.Error(w, "failed to create share link", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := Store.SaveShare(r.Context(), link); err != nil {
+		log.Printf("CreateShare: failed to store share link for token %s: %v", token, err)
+		http.Error(w, "failed to create share link", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(newShareResponse(r, link))
+}
+
+// ListShares returns the token's live share links, oldest first
+func ListShares(w http.ResponseWriter, r *http.Request) {
+	token, ok := GetToken(w, r)
+	if !ok {
+		return
+	}
+
+	links, err := Store.ListShares(r.Context(), token)
+	if err != nil {
+		log.Printf("ListShares: failed to load share links for token %s: %v", token, err)
+		http.Error(w, "failed to load share links", http.StatusInternalServerError)
+		return
+	}
+
+	resp := make([]shareResponse, len(links))
+	for i, link := range links {
+		resp[i] = newShareResponse(r, link)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// DeleteShare revokes a share link before it expires
+func DeleteShare(w http.ResponseWriter, r *http.Request) {
+	token, ok := GetToken(w, r)
+	if !ok {
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	err := Store.DeleteShare(r.Context(), token, id)
+	if err == store.ErrNotFound {
+		http.Error(w, "Share link not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("DeleteShare: failed to delete share link %s for token %s: %v", id, token, err)
+		http.Error(w, "failed to delete share link", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("Deleted"))
+}
+
+// GetSharedView serves a share link to whoever holds it, without the token: the
+// redacted capture, or a page of the redacted captures matching the link's filter
+func GetSharedView(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	link, err := Store.GetShare(r.Context(), id)
+	if err != nil && err != store.ErrNotFound {
+		log.Printf("GetSharedView: failed to load share link %s: %v", id, err)
+		http.Error(w, "failed to load share link", http.StatusInternalServerError)
+		return
+	}
+	// Forged, revoked and expired links look the same
+	if err == store.ErrNotFound || !share.Verify(shareSigningKey(), *link, r.URL.Query().Get("sig")) {
+		http.Error(w, "Share link not found or expired", http.StatusNotFound)
+		return
+	}
+	if link.Password != "" && !checkSharePassword(w, r, link) {
+		return
+	}
+
+	if link.CaptureID != "" {
+		payload, err := loadCapture(r.Context(), link.Token, link.CaptureID)
+		if err == errCaptureNotFound {
+			http.Error(w, "Shared webhook no longer exists", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("GetSharedView: failed to load webhook %s for share link %s: %v", link.CaptureID, id, err)
+			http.Error(w, "failed to fetch webhook", http.StatusInternalServerError)
+			return
+		}
+		writeJSONWithETag(w, r, share.Redact(*payload, link.Redact))
+		return
+	}
+
+	// Viewers page through the link's filter but can't widen it
+	params := url.Values{}
+	for _, name := range []string{"limit", "order", "cursor"} {
+		if v := r.URL.Query().Get(name); v != "" {
+			params.Set(name, v)
+		}
+	}
+	q, err := query.Parse(params)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	q.Filter = *link.Filter
+	if err := q.Compile(); err != nil {
+		log.Printf("GetSharedView: failed to compile filter of share link %s: %v", id, err)
+		http.Error(w, "failed to fetch webhooks", http.StatusInternalServerError)
+		return
+	}
+
+	page, err := Store.QueryCaptures(r.Context(), link.Token, q)
+	if err == query.ErrInvalidCursor {
+		http.Error(w, "invalid cursor", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("GetSharedView: failed to query webhooks for share link %s: %v", id, err)
+		http.Error(w, "failed to fetch webhooks", http.StatusInternalServerError)
+		return
+	}
+	for i, p := range page.Items {
+		page.Items[i] = share.Redact(p, link.Redact)
+	}
+	writeJSONWithETag(w, r, page)
+}
+
+// Check the password of a protected link, refusing before hashing anything once the link
+// or the client IP has failed too often
+func checkSharePassword(w http.ResponseWriter, r *http.Request, link *share.Link) bool {
+	password := r.Header.Get(sharePasswordHeader)
+	if password == "" {
+		http.Error(w, "password required in "+sharePasswordHeader, http.StatusUnauthorized)
+		return false
+	}
+
+	counters := []string{"share_password:link:" + link.ID, "share_password:ip:" + clientIP(r)}
+	for _, key := range counters {
+		failures, _, err := Store.Usage(r.Context(), key)
+		if err != nil {
+			log.Printf("GetSharedView: failed to load password failures for %s: %v", key, err)
+			http.Error(w, "failed to check password", http.StatusInternalServerError)
+			return false
+		}
+		if failures >= int64(config.SharePasswordMaxFailures) {
+			http.Error(w, "too many wrong passwords; try again later", http.StatusTooManyRequests)
+			return false
+		}
+	}
+
+	if !share.CheckPassword(link.Password, password) {
+		for _, key := range counters {
+			if _, err := Store.IncrementUsage(r.Context(), key, config.SharePasswordLockout); err != nil {
+				log.Printf("GetSharedView: failed to record password failure for %s: %v", key, err)
+			}
+		}
+		http.Error(w, "wrong password", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// Parse a link's ttl against the token's tier; empty means DefaultShareTTL
+func shareTTL(ctx context.Context, token, raw string) (time.Duration, error) {
+	ttl := config.DefaultShareTTL
+	if raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil || parsed < time.Minute {
+			return 0, errors.New("ttl must be a duration of at least 1m")
+		}
+		ttl = parsed
+	}
+
+	limit := config.AnonymousMaxShareTTL
+	if owner, err := Store.TokenOwner(ctx, token); err == nil && owner != "" {
+		limit = config.PrivilegedMaxShareTTL
+	}
+	if ttl > limit {
+		return 0, fmt.Errorf("ttl exceeds the maximum of %s for this tier", limit)
+	}
+	return ttl, nil
+}
+
+// Canonicalise and dedupe the header names a link redacts
+func normalizeRedactHeaders(names []string) ([]string, error) {
+	if len(names) > maxRedactHeaders {
+		return nil, fmt.Errorf("at most %d redact_headers are allowed", maxRedactHeaders)
+	}
+	seen := make(map[string]bool, len(names))
+	headers := make([]string, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" || strings.ContainsAny(name, " \t:") {
+			return nil, fmt.Errorf("invalid header name %q in redact_headers", name)
+		}
+		name = http.CanonicalHeaderKey(name)
+		if !seen[name] {
+			seen[name] = true
+			headers = append(headers, name)
+		}
+	}
+	return headers, nil
+}
+
+func newShareResponse(r *http.Request, link share.Link) shareResponse {
+	return shareResponse{
+		ID:            link.ID,
+		URL:           publicBaseURL(r) + "/shared/" + link.ID + "?sig=" + share.Sign(shareSigningKey(), link),
+		CaptureID:     link.CaptureID,
+		Filter:        link.Filter,
+		RedactHeaders: link.Redact,
+		Protected:     link.Password != "",
+		CreatedAt:     link.CreatedAt,
+		ExpiresAt:     link.ExpiresAt,
+	}
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"webhook-inspector/internal/config"
+	"webhook-inspector/internal/simulate"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// CreateSimulation signs and delivers an event to an external URL like a provider would
+func CreateSimulation(w http.ResponseWriter, r *http.Request) {
+	token, ok := GetToken(w, r)
+	if !ok {
+		return
+	}
+
+	var req simulate.Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	pending, err := simulate.Pending(r.Context(), Store, token)
+	if err != nil {
+		log.Printf("CreateSimulation: failed to count simulations for token %s: %v", token, err)
+		http.Error(w, "failed to create simulation", http.StatusInternalServerError)
+		return
+	}
+	if pending >= config.SimulateMaxPending {
+		http.Error(w, fmt.Sprintf("simulation limit of %d retrying reached; wait for one to finish", config.SimulateMaxPending), http.StatusConflict)
+		return
+	}
+
+	cfg, err := Store.LoadConfig(r.Context(), token)
+	if err != nil {
+		log.Printf("CreateSimulation: failed to load config for token %s: %v", token, err)
+		http.Error(w, "failed to load endpoint config", http.StatusInternalServerError)
+		return
+	}
+
+	sim, err := simulate.Create(r.Context(), Store, token, req, cfg.Secrets)
+	var invalid *simulate.RequestError
+	if errors.As(err, &invalid) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("CreateSimulation: failed to save simulation for token %s: %v", token, err)
+		http.Error(w, "failed to create simulation", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(sim)
+}
+
+// ListSimulations returns the most recent simulated deliveries, newest first
+func ListSimulations(w http.ResponseWriter, r *http.Request) {
+	token, ok := GetToken(w, r)
+	if !ok {
+		return
+	}
+
+	sims, err := simulate.List(r.Context(), Store, token)
+	if err != nil {
+		log.Printf("ListSimulations: failed to list simulations for token %s: %v", token, err)
+		http.Error(w, "failed to list simulations", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sims)
+}
+
+// GetSimulation returns one simulated delivery with every attempt made so far
+func GetSimulation(w http.ResponseWriter, r *http.Request) {
+	token, ok := GetToken(w, r)
+	if !ok {
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	sim, err := simulate.Get(r.Context(), Store, token, id)
+	if err == simulate.ErrNotFound {
+		http.Error(w, "Simulation not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("GetSimulation: failed to load simulation %s: %v", id, err)
+		http.Error(w, "failed to load simulation", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sim)
+}
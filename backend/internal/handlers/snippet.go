@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"webhook-inspector/internal/export"
+	"webhook-inspector/internal/replay"
+	"webhook-inspector/internal/signing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// GetSnippet renders a program that reproduces a stored capture against url, by default
+// the endpoint it was sent to, optionally re-signed with the endpoint's secret
+func GetSnippet(w http.ResponseWriter, r *http.Request) {
+	token, ok := GetToken(w, r)
+	if !ok {
+		return
+	}
+
+	params := r.URL.Query()
+	lang := params.Get("lang")
+	if lang == "" {
+		lang = "curl"
+	}
+	known := false
+	for _, name := range export.SnippetLanguages() {
+		known = known || name == lang
+	}
+	if !known {
+		http.Error(w, "lang must be one of "+strings.Join(export.SnippetLanguages(), ", "), http.StatusBadRequest)
+		return
+	}
+	target := params.Get("url")
+	if target != "" {
+		u, err := url.Parse(target)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			http.Error(w, "url must be an absolute http(s) URL", http.StatusBadRequest)
+			return
+		}
+	}
+	sign := params.Get("sign")
+	if sign != "" && !signing.Valid(sign) {
+		http.Error(w, "sign must be one of "+strings.Join(signing.Schemes, ", "), http.StatusBadRequest)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	payload, err := loadCapture(r.Context(), token, id)
+	if err == errCaptureNotFound {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("GetSnippet: failed to load webhook %s for token %s: %v", id, token, err)
+		http.Error(w, "failed to load webhook", http.StatusInternalServerError)
+		return
+	}
+	if target == "" {
+		target = export.RequestURL(*payload, publicBaseURL(r)+"/hooks/"+token)
+	}
+
+	request := *payload
+	if sign != "" {
+		cfg, err := Store.LoadConfig(r.Context(), token)
+		if err != nil {
+			log.Printf("GetSnippet: failed to load config for token %s: %v", token, err)
+			http.Error(w, "failed to load endpoint config", http.StatusInternalServerError)
+			return
+		}
+		request, err = replay.Prepare(request, replay.Options{URL: target, Sign: sign}, cfg.Secrets, time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+	}
+
+	snippet, _ := export.Snippet(lang, request, target)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(snippet))
+}
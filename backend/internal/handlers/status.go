@@ -1,14 +1,12 @@
 package handlers
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
 
 	"webhook-inspector/internal/config"
-	"webhook-inspector/internal/redis"
 )
 
 func GetTokenStatus(w http.ResponseWriter, r *http.Request) {
@@ -17,29 +15,16 @@ func GetTokenStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	countKey := fmt.Sprintf("rate_limit:%s", token)
-
-	// Get usage count
-	count, err := redis.Client.Get(context.Background(), countKey).Int()
-	if err != nil && err.Error() != "redis: nil" {
+	// Get usage count and how long until it resets
+	count, ttl, err := Store.Usage(r.Context(), token)
+	if err != nil {
 		log.Printf("GetTokenStatus: failed to fetch usage count for token %s: %v", token, err)
 		http.Error(w, "failed to fetch usage count", http.StatusInternalServerError)
 		return
 	}
-	if err != nil {
-		count = 0
-	}
-
-	// Get TTL
-	ttl, err := redis.Client.TTL(context.Background(), countKey).Result()
-	if err != nil {
-		log.Printf("GetTokenStatus: failed to fetch TTL for token %s: %v", token, err)
-		http.Error(w, "failed to fetch TTL", http.StatusInternalServerError)
-		return
-	}
 
 	// Check if token has a privileged owner
-	owner, err := redis.Client.Get(context.Background(), "token:"+token+":owner").Result()
+	owner, err := Store.TokenOwner(r.Context(), token)
 	isPrivileged := false
 	if err == nil && owner != "" {
 		isPrivileged = true
@@ -47,13 +32,25 @@ func GetTokenStatus(w http.ResponseWriter, r *http.Request) {
 		// Optional: verify that session_token matches owner
 		if sessionCookie, err := r.Cookie("session_token"); err == nil {
 			sessionToken := sessionCookie.Value
-			username, _ := redis.Client.Get(context.Background(), "user:"+sessionToken).Result()
+			username, _ := Store.SessionUser(r.Context(), sessionToken)
 			if username != owner {
 				isPrivileged = false // logged-in user mismatch
 			}
 		}
 	}
 
+	counts, err := Store.CaptureCounts(r.Context(), token)
+	if err != nil {
+		log.Printf("GetTokenStatus: failed to count webhooks for token %s: %v", token, err)
+		http.Error(w, "failed to count webhooks", http.StatusInternalServerError)
+		return
+	}
+	cfg, err := Store.LoadConfig(r.Context(), token)
+	if err != nil {
+		log.Printf("GetTokenStatus: failed to load config for token %s: %v", token, err)
+		http.Error(w, "failed to load endpoint config", http.StatusInternalServerError)
+		return
+	}
 	maxLimit := config.AnonymousRateLimit
 	if isPrivileged {
 		maxLimit = config.PrivilegedRateLimit
@@ -67,6 +64,13 @@ func GetTokenStatus(w http.ResponseWriter, r *http.Request) {
 		"ttl":                fmt.Sprintf("%dh %dm", int(ttl.Hours()), int(ttl.Minutes())%60),
 		"owner":              owner,
 		"privileged":         isPrivileged,
+		"captures_stored":    counts.Total,
+		"storage_quota":      storageQuota(r.Context(), token),
+		"unread":             counts.Unread,
+		"pinned":             counts.Pinned,
+		"pin_quota":          pinQuota(r.Context(), token),
+		"retention":          retentionTTL(cfg).String(),
+		"max_captures":       cfg.Retention.MaxCaptures,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"net/http"
+
+	"webhook-inspector/internal/redis"
+	"webhook-inspector/internal/store"
+)
+
+// Store persists captures, tokens, sessions and settings for every handler; main sets it before serving
+var Store store.Store
+
+// RequireRedis rejects routes backed by the Redis-only delivery workers when running on the in-memory store
+func RequireRedis(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !redis.Enabled() {
+			http.Error(w, "This feature requires Redis", http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -10,16 +10,29 @@ import (
 )
 
 func TestGetToken_CookieOnly(t *testing.T) {
+	token := "6f1c2a0e-8a8d-4c3b-9d5e-2f7a1b3c4d5e"
 	req := httptest.NewRequest("GET", "/", nil)
 	req.AddCookie(&http.Cookie{
 		Name:  "webhook_token",
-		Value: "abc123",
+		Value: token,
 	})
 	rr := httptest.NewRecorder()
 
-	token, ok := GetToken(rr, req)
-	if !ok || token != "abc123" {
-		t.Errorf("expected token 'abc123', got '%s'", token)
+	got, ok := GetToken(rr, req)
+	if !ok || got != token {
+		t.Errorf("expected token '%s', got '%s'", token, got)
+	}
+}
+
+func TestGetToken_RejectsTraversalCookie(t *testing.T) {
+	for _, value := range []string{"../../..", "abc123", "6F1C2A0E-8A8D-4C3B-9D5E-2F7A1B3C4D5E"} {
+		req := httptest.NewRequest("POST", "/reset", nil)
+		req.AddCookie(&http.Cookie{Name: "webhook_token", Value: value})
+		rr := httptest.NewRecorder()
+
+		if _, ok := GetToken(rr, req); ok || rr.Code != http.StatusForbidden {
+			t.Errorf("%q: expected 403, got %d", value, rr.Code)
+		}
 	}
 }
 
@@ -27,7 +40,7 @@ func TestGetToken_URLParamMismatch(t *testing.T) {
 	req := httptest.NewRequest("GET", "/logs/wrongtoken", nil)
 	req.AddCookie(&http.Cookie{
 		Name:  "webhook_token",
-		Value: "abc123",
+		Value: "6f1c2a0e-8a8d-4c3b-9d5e-2f7a1b3c4d5e",
 	})
 	rr := httptest.NewRecorder()
 
@@ -45,3 +58,16 @@ func setURLParam(ctx context.Context, key, val string) context.Context {
 	rctx.URLParams.Add(key, val)
 	return context.WithValue(ctx, chi.RouteCtxKey, rctx)
 }
+
+func TestBearerCredential(t *testing.T) {
+	req := httptest.NewRequest("GET", "/bins/abc123", nil)
+	req.Header.Set("Authorization", "Bearer whk_secret")
+	if got := bearerCredential(req); got != "whk_secret" {
+		t.Errorf("expected 'whk_secret', got '%s'", got)
+	}
+
+	req.Header.Set("Authorization", "Basic dXNlcjpwYXNz")
+	if got := bearerCredential(req); got != "" {
+		t.Errorf("expected no credential for basic auth, got '%s'", got)
+	}
+}
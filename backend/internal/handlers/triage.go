@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"webhook-inspector/internal/config"
+	"webhook-inspector/internal/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Limits on what a team can write on a capture
+const (
+	maxTags      = 20
+	maxTagLength = 64
+	maxNoteBytes = 4096
+)
+
+var errPinQuota = errors.New("pin quota reached")
+
+// Fields of a capture that PATCH /logs/{id} can change; absent fields are left alone
+type captureUpdate struct {
+	Tags   *[]string `json:"tags"`
+	Note   *string   `json:"note"`
+	Pinned *bool     `json:"pinned"`
+	Read   *bool     `json:"read"`
+}
+
+// UpdateWebhook sets a capture's tags, note, pin and read state
+func UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	token, ok := GetToken(w, r)
+	if !ok {
+		return
+	}
+
+	var update captureUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if update.Tags != nil {
+		tags, err := normalizeTags(*update.Tags)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		update.Tags = &tags
+	}
+	if update.Note != nil && len(*update.Note) > maxNoteBytes {
+		http.Error(w, fmt.Sprintf("note must be at most %d bytes", maxNoteBytes), http.StatusBadRequest)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	payload, err := loadCapture(r.Context(), token, id)
+	if err == errCaptureNotFound {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("UpdateWebhook: failed to load webhook %s for token %s: %v", id, token, err)
+		http.Error(w, "failed to load webhook", http.StatusInternalServerError)
+		return
+	}
+
+	wasPinned := payload.Pinned
+	if update.Tags != nil {
+		payload.Tags = *update.Tags
+	}
+	if update.Note != nil {
+		payload.Note = *update.Note
+	}
+	if update.Pinned != nil {
+		payload.Pinned = *update.Pinned
+	}
+	if update.Read != nil {
+		payload.Read = *update.Read
+	}
+
+	err = saveTriaged(r.Context(), token, *payload, wasPinned)
+	if err == errPinQuota {
+		http.Error(w, fmt.Sprintf("pin quota of %d reached; unpin a capture first", pinQuota(r.Context(), token)), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("UpdateWebhook: failed to save webhook %s for token %s: %v", id, token, err)
+		http.Error(w, "failed to save webhook", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(payload)
+}
+
+// Trim, drop duplicates and check the limits of a tag list
+func normalizeTags(tags []string) ([]string, error) {
+	out := []string{}
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || len(tag) > maxTagLength {
+			return nil, fmt.Errorf("tags must be 1-%d characters", maxTagLength)
+		}
+		if !slices.Contains(out, tag) {
+			out = append(out, tag)
+		}
+	}
+	if len(out) > maxTags {
+		return nil, fmt.Errorf("a capture can have at most %d tags", maxTags)
+	}
+	return out, nil
+}
+
+// Store an edited capture. It keeps its expiry unless its pin changed: pinning moves
+// it to PinnedCaptureTTL within the pin quota and unpinning back to the endpoint's
+// retention.
+func saveTriaged(ctx context.Context, token string, p models.WebhookPayload, wasPinned bool) error {
+	ttl, err := Store.CaptureTTL(ctx, token, p.ID)
+	if err != nil {
+		return err
+	}
+	if p.Pinned == wasPinned {
+		return Store.SaveCapture(ctx, token, p, ttl)
+	}
+
+	if p.Pinned {
+		pinned, err := countPinned(ctx, token)
+		if err != nil {
+			return err
+		}
+		if pinned >= pinQuota(ctx, token) {
+			return errPinQuota
+		}
+	}
+	// Bins take every capture, pinned or not, with them when they expire
+	_, err = lookupBin(ctx, token)
+	switch {
+	case err == nil:
+	case err != errBinNotFound:
+		return err
+	case p.Pinned:
+		ttl = longerTTL(ttl, config.PinnedCaptureTTL)
+	default:
+		cfg, err := Store.LoadConfig(ctx, token)
+		if err != nil {
+			return err
+		}
+		ttl = shorterTTL(ttl, retentionTTL(cfg))
+	}
+	return Store.SaveCapture(ctx, token, p, ttl)
+}
+
+// Pick the longer or shorter of two TTLs where zero means no expiry
+func longerTTL(a, b time.Duration) time.Duration {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func shorterTTL(a, b time.Duration) time.Duration {
+	if a == 0 || (b != 0 && b < a) {
+		return b
+	}
+	return a
+}
+
+// Captures a token may keep pinned; privileged tokens get the larger quota
+func pinQuota(ctx context.Context, token string) int {
+	if owner, err := Store.TokenOwner(ctx, token); err == nil && owner != "" {
+		return config.PrivilegedPinQuota
+	}
+	return config.AnonymousPinQuota
+}
+
+func countPinned(ctx context.Context, token string) (int, error) {
+	counts, err := Store.CaptureCounts(ctx, token)
+	return counts.Pinned, err
+}
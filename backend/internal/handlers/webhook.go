@@ -3,23 +3,33 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
-	"sort"
+	"net/url"
 	"strings"
 	"time"
 
+	"webhook-inspector/internal/baseline"
 	"webhook-inspector/internal/config"
+	"webhook-inspector/internal/events"
+	"webhook-inspector/internal/forward"
 	"webhook-inspector/internal/models"
+	"webhook-inspector/internal/provider"
+	"webhook-inspector/internal/query"
 	"webhook-inspector/internal/redis"
+	"webhook-inspector/internal/rules"
+	"webhook-inspector/internal/sink"
+	"webhook-inspector/internal/store"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
-	goredis "github.com/redis/go-redis/v9"
 )
 
+var errCaptureNotFound = errors.New("capture not found")
+
 // Helpers
 func max(a, b int) int {
 	if a > b {
@@ -53,15 +63,28 @@ func sanitizeForLogging(data string) string {
 	return data
 }
 
-// Store webhook in Redis
+// Store an incoming webhook
 func HandleWebhook(w http.ResponseWriter, r *http.Request) {
-	token, ok := GetToken(w, r)
+	start := time.Now()
+	token, bin, ok := getIngestToken(w, r)
 	if !ok {
 		return
 	}
 
+	// Bins cap every key they create at their own expiry
+	rateLimitTTL := config.RateLimitTTL
+	var binRemaining time.Duration
+	if bin != nil {
+		binRemaining = time.Until(bin.ExpiresAt)
+		if binRemaining <= 0 {
+			http.Error(w, "bin has expired", http.StatusGone)
+			return
+		}
+		rateLimitTTL = min(rateLimitTTL, binRemaining)
+	}
+
 	// Check privilege status and set rate limit
-	owner, err := redis.Client.Get(context.Background(), "token:"+token+":owner").Result()
+	owner, err := Store.TokenOwner(r.Context(), token)
 	isPrivileged := (err == nil && owner != "")
 
 	maxRequestsPerToken := config.AnonymousRateLimit
@@ -75,10 +98,16 @@ func HandleWebhook(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "failed to read request body", http.StatusBadRequest)
 		return
 	}
+	received := time.Now()
 
-	// Validate JSON
-	var parsedBody interface{}
-	if err := json.Unmarshal(bodyBytes, &parsedBody); err != nil {
+	// Validate form-encoded bodies (Twilio, Slack commands) as such, everything else as JSON
+	if isFormEncoded(r.Header.Get("Content-Type")) {
+		if _, err := url.ParseQuery(string(bodyBytes)); err != nil {
+			log.Printf("HandleWebhook: invalid form body for token %s: %v", token, err)
+			http.Error(w, "invalid form body", http.StatusBadRequest)
+			return
+		}
+	} else if err := json.Unmarshal(bodyBytes, new(interface{})); err != nil {
 		// Sanitize body data before logging
 		sanitizedBody := sanitizeForLogging(string(bodyBytes))
 		log.Printf("HandleWebhook: invalid JSON body for token %s: %v, body: %s", token, err, sanitizedBody)
@@ -86,9 +115,23 @@ func HandleWebhook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Generate uuid and key for redis storage
+	// Checked before baselines, rules and the pin quota so a blocked sender costs no more lookups
+	usage, err := Store.IncrementUsage(r.Context(), token, rateLimitTTL)
+	if err != nil {
+		log.Printf("HandleWebhook: failed to track usage for token %s: %v", token, err)
+		http.Error(w, "failed to track webhook usage", http.StatusInternalServerError)
+		return
+	}
+
+	count := int(usage)
+
+	if count > maxRequestsPerToken {
+		log.Printf("Token %s blocked (rate limit %d)", token, count)
+		http.Error(w, "rate limit exceeded for this token", http.StatusTooManyRequests)
+		return
+	}
+
 	id := uuid.New().String()
-	key := fmt.Sprintf("hooks:%s:%s", token, id)
 
 	// Map request to webhook data model
 	payload := models.WebhookPayload{
@@ -97,97 +140,219 @@ func HandleWebhook(w http.ResponseWriter, r *http.Request) {
 		Headers:   r.Header,
 		Body:      string(bodyBytes),
 		Timestamp: time.Now().UTC(),
+		Query:     r.URL.RawQuery,
 	}
+	if sub := chi.URLParam(r, "*"); sub != "" {
+		payload.Path = "/" + sub
+	}
+	payload.Provider, payload.EventType = provider.Detect(r.Header, bodyBytes)
 
-	// Format into json data
-	jsonData, err := json.Marshal(payload)
+	baselines, err := Store.ListBaselines(r.Context(), token)
 	if err != nil {
-		log.Printf("HandleWebhook: failed to marshal payload for token %s: %v", token, err)
-		http.Error(w, "failed parse request body into json", http.StatusInternalServerError)
-		return
+		log.Printf("HandleWebhook: failed to load baselines for token %s: %v", token, err)
 	}
+	payload.Drift = baseline.CheckAll(baselines, payload)
 
-	countKey := fmt.Sprintf("rate_limit:%s", token)
-
-	// Use Redis pipeline for atomic increment and TTL setting
-	pipe := redis.Client.Pipeline()
-	incrCmd := pipe.Incr(context.Background(), countKey)
-
-	// Always set TTL to ensure it doesn't get lost
-	pipe.Expire(context.Background(), countKey, config.RateLimitTTL)
-
-	_, err = pipe.Exec(context.Background())
+	// Rules run before the capture is stored so tags, the pin and the audit trail are saved with it
+	cfg, err := Store.LoadConfig(r.Context(), token)
 	if err != nil {
-		log.Printf("HandleWebhook: failed to execute rate limit pipeline for token %s: %v", token, err)
-		http.Error(w, "failed to track webhook usage", http.StatusInternalServerError)
-		return
+		log.Printf("HandleWebhook: failed to load rules for token %s: %v", token, err)
+	}
+	outcome := rules.Evaluate(cfg.Rules, payload)
+	dataTTL := retentionTTL(cfg)
+	if bin != nil {
+		dataTTL = min(dataTTL, binRemaining)
+	}
+	payload.Tags = outcome.Tags
+	payload.Pinned = outcome.Pin
+	payload.Rules = outcome.Fired
+	if payload.Pinned {
+		if pinned, err := countPinned(r.Context(), token); err != nil || pinned >= pinQuota(r.Context(), token) {
+			log.Printf("HandleWebhook: not pinning webhook %s for token %s, %d pinned: %v", id, token, pinned, err)
+			payload.Pinned = false
+		}
+	}
+	// Bins still take their pinned captures with them when they expire
+	if payload.Pinned && bin == nil && (config.PinnedCaptureTTL == 0 || config.PinnedCaptureTTL > dataTTL) {
+		dataTTL = config.PinnedCaptureTTL
 	}
 
-	count := int(incrCmd.Val())
-
-	if count > maxRequestsPerToken {
-		log.Printf("Token %s blocked (rate limit %d)", token, count)
-		http.Error(w, "rate limit exceeded for this token", http.StatusTooManyRequests)
-		return
+	// The response is stored with the capture so exports can show what the sender saw
+	remaining := max(0, maxRequestsPerToken-int(count))
+	resp := ingestResponse(outcome.Response, remaining)
+	payload.Response = &resp
+	payload.Timing = &models.CaptureTiming{
+		ReceiveMs: milliseconds(received.Sub(start)),
+		HandleMs:  milliseconds(time.Since(received)),
 	}
 
-	// Write webhook into redis
-	err = redis.Client.Set(context.Background(), key, jsonData, config.WebhookDataTTL).Err()
-	if err != nil {
+	if err := Store.SaveCapture(r.Context(), token, payload, dataTTL); err != nil {
 		log.Printf("HandleWebhook: failed to save webhook for token %s: %v", token, err)
 		http.Error(w, "failed to save webhook", http.StatusInternalServerError)
 		return
 	}
 
 	fmt.Printf("Saved webhook with ID %s for token %s\n", id, token)
-	remaining := max(0, maxRequestsPerToken-int(count))
-	w.Header().Set("X-RateLimit-Remaining", fmt.Sprintf("%d", remaining))
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("Webhook received"))
+
+	if _, err := evictOverflow(r.Context(), token, cfg); err != nil {
+		log.Printf("HandleWebhook: failed to evict webhooks for token %s: %v", token, err)
+	}
+
+	// Forwarding, notifications, sinks and live events are queued through Redis
+	if redis.Enabled() {
+		dispatch(token, payload, cfg, outcome)
+	}
+
+	for name, value := range resp.Headers {
+		w.Header().Set(name, value)
+	}
+	w.WriteHeader(resp.Status)
+	w.Write([]byte(resp.Body))
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// Hand a stored capture to the delivery workers and live subscribers
+func dispatch(token string, payload models.WebhookPayload, cfg models.EndpointConfig, outcome rules.Outcome) {
+	id := payload.ID
+	targets := cfg.Forwards
+	for _, d := range outcome.Forwards {
+		targets = append(targets, models.ForwardTarget{ID: "rule:" + d.RuleID, URL: d.Action.URL})
+	}
+	if err := forward.EnqueueTargets(context.Background(), token, id, targets); err != nil {
+		log.Printf("HandleWebhook: failed to enqueue forwards for webhook %s: %v", id, err)
+	}
+	for _, d := range outcome.Notifications {
+		go func(d rules.Dispatch) {
+			attempt := rules.Notify(context.Background(), payload, d)
+			if err := forward.RecordAttempt(context.Background(), Store, token, id, attempt); err != nil {
+				log.Printf("HandleWebhook: failed to record notification for webhook %s: %v", id, err)
+			}
+		}(d)
+	}
+	if err := sink.Enqueue(context.Background(), Store, token, payload); err != nil {
+		log.Printf("HandleWebhook: failed to enqueue sinks for webhook %s: %v", id, err)
+	}
+	if err := events.Publish(context.Background(), token, payload); err != nil {
+		log.Printf("HandleWebhook: failed to publish webhook %s: %v", id, err)
+	}
+}
+
+// The response to a sender: the one a rule asked for, or the default acknowledgement.
+// Rule responses are served from our own origin, so they are sandboxed and cannot set
+// cookies (rules reject Set-Cookie).
+func ingestResponse(rule *models.RuleAction, remaining int) models.CaptureResponse {
+	resp := models.CaptureResponse{
+		Status:  http.StatusOK,
+		Headers: map[string]string{"Content-Type": "text/plain; charset=utf-8"},
+		Body:    "Webhook received",
+	}
+	if rule != nil {
+		for name, value := range rule.Headers {
+			resp.Headers[http.CanonicalHeaderKey(name)] = value
+		}
+		resp.Headers["X-Content-Type-Options"] = "nosniff"
+		resp.Headers["Content-Security-Policy"] = "sandbox"
+		resp.Body = rule.Body
+		if rule.Status != 0 {
+			resp.Status = rule.Status
+		}
+	}
+	resp.Headers["X-Ratelimit-Remaining"] = fmt.Sprintf("%d", remaining)
+	return resp
+}
+
+func isFormEncoded(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	return strings.EqualFold(strings.TrimSpace(mediaType), "application/x-www-form-urlencoded")
 }
 
-// Get webhook from Redis
+// List one filtered page of captured webhooks, newest first unless order=asc
 func GetWebhookLogs(w http.ResponseWriter, r *http.Request) {
 	token, ok := GetToken(w, r)
 	if !ok {
 		return
 	}
+	writeLogPage(w, r, token, r.URL.Query())
+}
 
-	pattern := fmt.Sprintf("hooks:%s:*", token)
+// SearchWebhooks answers a full-text and JSON-path search given in q; every /logs
+// parameter except the body substring filter also applies
+func SearchWebhooks(w http.ResponseWriter, r *http.Request) {
+	token, ok := GetToken(w, r)
+	if !ok {
+		return
+	}
 
-	keys, err := redis.Client.Keys(context.Background(), pattern).Result()
-	if err != nil {
-		log.Printf("GetWebhookLogs: failed to fetch keys for token %s: %v", token, err)
-		http.Error(w, "failed to fetch keys", http.StatusInternalServerError)
+	params := r.URL.Query()
+	if params.Get("q") == "" {
+		http.Error(w, "q is required", http.StatusBadRequest)
 		return
 	}
+	params.Set("search", params.Get("q"))
+	params.Del("q")
+	writeLogPage(w, r, token, params)
+}
 
-	var logs []models.WebhookPayload
+func writeLogPage(w http.ResponseWriter, r *http.Request, token string, params url.Values) {
+	q, ok := parseLogQuery(w, r, token, params)
+	if !ok {
+		return
+	}
 
-	// Get logs matching our pattern into logs
-	for _, key := range keys {
-		val, err := redis.Client.Get(context.Background(), key).Result()
-		if err != nil {
-			continue
-		}
+	page, err := Store.QueryCaptures(r.Context(), token, q)
+	if err == query.ErrInvalidCursor {
+		http.Error(w, "invalid cursor", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("writeLogPage: failed to query webhooks for token %s: %v", token, err)
+		http.Error(w, "failed to fetch webhooks", http.StatusInternalServerError)
+		return
+	}
 
-		var parsed models.WebhookPayload
-		if err := json.Unmarshal([]byte(val), &parsed); err != nil {
-			continue // skip invalid entries
-		}
+	writeJSONWithETag(w, r, page)
+}
 
-		logs = append(logs, parsed)
+// Read /logs parameters, resolving after= to a capture; writes the error when it fails
+func parseLogQuery(w http.ResponseWriter, r *http.Request, token string, params url.Values) (query.Query, bool) {
+	q, err := query.Parse(params)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return q, false
 	}
 
-	// Sort by timestamp (oldest first)
-	sort.Slice(logs, func(i, j int) bool {
-		return logs[i].Timestamp.Before(logs[j].Timestamp)
-	})
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(logs)
+	// Incremental mode: captures newer than a known one, oldest first
+	if after := params.Get("after"); after != "" {
+		if params.Get("order") == query.OrderDesc {
+			http.Error(w, "after returns captures oldest first; drop order=desc", http.StatusBadRequest)
+			return q, false
+		}
+		payload, err := loadCapture(r.Context(), token, after)
+		if err == errCaptureNotFound {
+			http.Error(w, "capture given in after no longer exists; fetch without after", http.StatusGone)
+			return q, false
+		}
+		if err != nil {
+			log.Printf("parseLogQuery: failed to load webhook %s for token %s: %v", after, token, err)
+			http.Error(w, "failed to fetch webhooks", http.StatusInternalServerError)
+			return q, false
+		}
+		q.Order = query.OrderAsc
+		q.After = &query.Entry{ID: payload.ID, Time: payload.Timestamp}
+	}
+	return q, true
+}
 
+// Load a single capture by ID
+func loadCapture(ctx context.Context, token, id string) (*models.WebhookPayload, error) {
+	payload, err := Store.GetCapture(ctx, token, id)
+	if err == store.ErrNotFound {
+		return nil, errCaptureNotFound
+	}
+	return payload, err
 }
 
 // Create new session and token for new users
@@ -195,14 +360,14 @@ func CreateSession(w http.ResponseWriter, r *http.Request) {
 	// First, check if user is logged in via session_token
 	if sessionCookie, err := r.Cookie("session_token"); err == nil {
 		sessionToken := sessionCookie.Value
-		username, err := redis.Client.Get(context.Background(), "user:"+sessionToken).Result()
+		username, err := Store.SessionUser(r.Context(), sessionToken)
 		if err == nil && username != "" {
 			// GitHub user: use or create privileged token
-			existingToken, err := redis.Client.Get(context.Background(), "user:"+username+":webhook_token").Result()
-			if err == goredis.Nil {
+			existingToken, err := Store.UserToken(r.Context(), username)
+			if err == store.ErrNotFound {
 				existingToken = uuid.New().String()
-				redis.Client.Set(context.Background(), "user:"+username+":webhook_token", existingToken, 0)
-				redis.Client.Set(context.Background(), "token:"+existingToken+":owner", username, 0)
+				Store.SetUserToken(r.Context(), username, existingToken)
+				Store.SetTokenOwner(r.Context(), existingToken, username, 0)
 			}
 
 			http.SetCookie(w, &http.Cookie{
@@ -238,24 +403,97 @@ func CreateSession(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(fmt.Sprintf("Assigned new anonymous token: %s", newToken)))
 }
 
-// Force the user to use their assigned token
+// Force the user to use their assigned token. Management routes also accept the bearer
+// credential issued with a bin, which is the only way to act on a bin.
 func GetToken(w http.ResponseWriter, r *http.Request) (string, bool) {
-	urlToken := chi.URLParam(r, "token")
+	credential := bearerCredential(r)
+	if credential == "" {
+		token, ok := cookieToken(w, r)
+		if !ok {
+			return "", false
+		}
+		if _, err := lookupBin(r.Context(), token); err != errBinNotFound {
+			if err != nil {
+				log.Printf("GetToken: failed to look up bin %s: %v", token, err)
+				http.Error(w, "failed to check token", http.StatusInternalServerError)
+				return "", false
+			}
+			http.Error(w, "Bins require their bearer credential", http.StatusUnauthorized)
+			return "", false
+		}
+		return token, true
+	}
+
+	// API clients authenticate with the credential issued alongside a bin
+	token, err := Store.CredentialToken(r.Context(), credential)
+	if err != nil {
+		http.Error(w, "Invalid or expired credential", http.StatusUnauthorized)
+		return "", false
+	}
+	if urlToken := chi.URLParam(r, "token"); urlToken != "" && urlToken != token {
+		http.Error(w, "Token mismatch", http.StatusForbidden)
+		return "", false
+	}
+	return token, true
+}
+
+// The webhook_token cookie, which must match the token in the URL if there is one.
+// Tokens end up in keys and file paths, so only the UUIDs the server hands out are accepted.
+func cookieToken(w http.ResponseWriter, r *http.Request) (string, bool) {
 	cookie, err := r.Cookie("webhook_token")
 	if err != nil {
 		http.Error(w, "Missing webhook_token cookie", http.StatusForbidden)
 		return "", false
 	}
-
-	if urlToken != "" && urlToken != cookie.Value {
-		fmt.Printf("urlToken: %s cookie.Value: %s\n", urlToken, cookie.Value)
+	if !validToken(cookie.Value) {
+		http.Error(w, "Invalid webhook_token cookie", http.StatusForbidden)
+		return "", false
+	}
+	if urlToken := chi.URLParam(r, "token"); urlToken != "" && urlToken != cookie.Value {
 		http.Error(w, "Token mismatch", http.StatusForbidden)
 		return "", false
 	}
-
 	return cookie.Value, true
 }
 
+// Senders posting to a bin's ingest URL don't carry a cookie, so the URL token is enough.
+// Their Authorization header belongs to the webhook, never to the inspector.
+func getIngestToken(w http.ResponseWriter, r *http.Request) (string, *models.Bin, bool) {
+	if urlToken := chi.URLParam(r, "token"); urlToken != "" {
+		bin, err := lookupBin(r.Context(), urlToken)
+		if err == nil {
+			return urlToken, bin, true
+		}
+		if err != errBinNotFound {
+			log.Printf("getIngestToken: failed to look up bin %s: %v", urlToken, err)
+		}
+	}
+
+	token, ok := cookieToken(w, r)
+	if !ok {
+		return "", nil, false
+	}
+	bin, err := lookupBin(r.Context(), token)
+	if err != nil {
+		bin = nil
+	}
+	return token, bin, true
+}
+
+// Whether token is a UUID in the canonical form uuid.New produces
+func validToken(token string) bool {
+	id, err := uuid.Parse(token)
+	return err == nil && id.String() == token
+}
+
+func bearerCredential(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	if len(header) > 7 && strings.EqualFold(header[:7], "bearer ") {
+		return strings.TrimSpace(header[7:])
+	}
+	return ""
+}
+
 // Delete individual webhooks
 func DeleteWebhook(w http.ResponseWriter, r *http.Request) {
 	token, ok := GetToken(w, r)
@@ -269,10 +507,7 @@ func DeleteWebhook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	key := fmt.Sprintf("hooks:%s:%s", token, id)
-
-	err := redis.Client.Del(context.Background(), key).Err()
-	if err != nil {
+	if err := Store.DeleteCapture(r.Context(), token, id); err != nil {
 		log.Printf("DeleteWebhook: failed to delete webhook %s for token %s: %v", id, token, err)
 		http.Error(w, "Failed to delete webhook", http.StatusInternalServerError)
 		return
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"webhook-inspector/internal/config"
+	"webhook-inspector/internal/models"
+	"webhook-inspector/internal/store"
+)
+
+// Counts the baseline and rules lookups an ingest makes
+type lookupCounter struct {
+	store.Store
+	lookups int
+}
+
+func (c *lookupCounter) ListBaselines(ctx context.Context, token string) ([]models.Baseline, error) {
+	c.lookups++
+	return c.Store.ListBaselines(ctx, token)
+}
+
+func (c *lookupCounter) LoadConfig(ctx context.Context, token string) (models.EndpointConfig, error) {
+	c.lookups++
+	return c.Store.LoadConfig(ctx, token)
+}
+
+func TestHandleWebhook_RateLimitsBeforeLookups(t *testing.T) {
+	counter := &lookupCounter{Store: store.NewMemory()}
+	Store = counter
+	limit := config.AnonymousRateLimit
+	config.AnonymousRateLimit = 1
+	t.Cleanup(func() { config.AnonymousRateLimit = limit })
+
+	send := func() int {
+		req := httptest.NewRequest("POST", "/hooks", strings.NewReader(`{"ok":true}`))
+		req.AddCookie(&http.Cookie{Name: "webhook_token", Value: "6f1c2a0e-8a8d-4c3b-9d5e-2f7a1b3c4d5e"})
+		rr := httptest.NewRecorder()
+		HandleWebhook(rr, req)
+		return rr.Code
+	}
+
+	if code := send(); code != http.StatusOK {
+		t.Fatalf("first webhook = %d", code)
+	}
+	before := counter.lookups
+	if code := send(); code != http.StatusTooManyRequests {
+		t.Fatalf("expected the second webhook to be rate limited, got %d", code)
+	}
+	if counter.lookups != before {
+		t.Errorf("a rate-limited webhook made %d baseline or rules lookups", counter.lookups-before)
+	}
+}
@@ -0,0 +1,171 @@
+// Package importer reads captures recorded elsewhere: HAR files from browsers and
+// proxies, and NDJSON such as the inspector's own exports.
+package importer
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"webhook-inspector/internal/models"
+	"webhook-inspector/internal/provider"
+)
+
+// Supported formats
+const (
+	FormatHAR    = "har"
+	FormatNDJSON = "ndjson"
+)
+
+// Longest NDJSON line accepted
+const maxLineSize = 16 << 20
+
+// Parse reads captures in the given format. IDs are left for the caller to assign;
+// timestamps, headers and bodies are kept as recorded.
+func Parse(format string, r io.Reader) ([]models.WebhookPayload, error) {
+	var captures []models.WebhookPayload
+	var err error
+	switch format {
+	case FormatHAR:
+		captures, err = parseHAR(r)
+	case FormatNDJSON:
+		captures, err = parseNDJSON(r)
+	default:
+		return nil, fmt.Errorf("format must be %q or %q", FormatHAR, FormatNDJSON)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range captures {
+		if captures[i].Provider == "" {
+			captures[i].Provider, captures[i].EventType = provider.Detect(captures[i].Headers, []byte(captures[i].Body))
+		}
+	}
+	return captures, nil
+}
+
+type harFile struct {
+	Log struct {
+		Entries []harEntry `json:"entries"`
+	} `json:"log"`
+}
+
+type harEntry struct {
+	StartedDateTime string `json:"startedDateTime"`
+	Request         struct {
+		Method   string         `json:"method"`
+		URL      string         `json:"url"`
+		Headers  []harNameValue `json:"headers"`
+		PostData *struct {
+			Text string `json:"text"`
+		} `json:"postData"`
+	} `json:"request"`
+	Response struct {
+		Status  int            `json:"status"`
+		Headers []harNameValue `json:"headers"`
+		Content struct {
+			Text     string `json:"text"`
+			Encoding string `json:"encoding"`
+		} `json:"content"`
+	} `json:"response"`
+	Timings struct {
+		Send float64 `json:"send"`
+		Wait float64 `json:"wait"`
+	} `json:"timings"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+func parseHAR(r io.Reader) ([]models.WebhookPayload, error) {
+	var har harFile
+	if err := json.NewDecoder(r).Decode(&har); err != nil {
+		return nil, fmt.Errorf("invalid HAR: %w", err)
+	}
+
+	captures := make([]models.WebhookPayload, 0, len(har.Log.Entries))
+	for i, e := range har.Log.Entries {
+		at, err := time.Parse(time.RFC3339Nano, e.StartedDateTime)
+		if err != nil {
+			return nil, fmt.Errorf("entry %d: invalid startedDateTime %q", i, e.StartedDateTime)
+		}
+		u, err := url.Parse(e.Request.URL)
+		if err != nil || e.Request.Method == "" {
+			return nil, fmt.Errorf("entry %d: request needs a method and URL", i)
+		}
+
+		p := models.WebhookPayload{
+			Method:    strings.ToUpper(e.Request.Method),
+			Headers:   make(map[string][]string),
+			Timestamp: at.UTC(),
+			Path:      u.EscapedPath(),
+			Query:     u.RawQuery,
+		}
+		for _, h := range e.Request.Headers {
+			// HTTP/2 pseudo-headers (:authority, :path) aren't real headers
+			if !strings.HasPrefix(h.Name, ":") {
+				name := http.CanonicalHeaderKey(h.Name)
+				p.Headers[name] = append(p.Headers[name], h.Value)
+			}
+		}
+		if e.Request.PostData != nil {
+			p.Body = e.Request.PostData.Text
+		}
+
+		if e.Response.Status > 0 {
+			resp := &models.CaptureResponse{Status: e.Response.Status, Headers: map[string]string{}, Body: e.Response.Content.Text}
+			for _, h := range e.Response.Headers {
+				if !strings.HasPrefix(h.Name, ":") {
+					resp.Headers[http.CanonicalHeaderKey(h.Name)] = h.Value
+				}
+			}
+			if e.Response.Content.Encoding == "base64" {
+				if body, err := base64.StdEncoding.DecodeString(resp.Body); err == nil {
+					resp.Body = string(body)
+				}
+			}
+			p.Response = resp
+		}
+		if e.Timings.Send >= 0 && e.Timings.Wait >= 0 && e.Timings.Send+e.Timings.Wait > 0 {
+			p.Timing = &models.CaptureTiming{ReceiveMs: e.Timings.Send, HandleMs: e.Timings.Wait}
+		}
+		captures = append(captures, p)
+	}
+	return captures, nil
+}
+
+func parseNDJSON(r io.Reader) ([]models.WebhookPayload, error) {
+	var captures []models.WebhookPayload
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var p models.WebhookPayload
+		if err := json.Unmarshal([]byte(text), &p); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		if p.Method == "" || p.Timestamp.IsZero() {
+			return nil, fmt.Errorf("line %d: method and timestamp are required", line)
+		}
+		captures = append(captures, p)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("line %d: %v", line+1, err)
+	}
+	return captures, nil
+}
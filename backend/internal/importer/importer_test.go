@@ -0,0 +1,92 @@
+package importer
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"webhook-inspector/internal/export"
+	"webhook-inspector/internal/models"
+)
+
+const har = `{"log":{"version":"1.2","entries":[{
+	"startedDateTime":"2026-01-02T03:04:05.678+01:00",
+	"request":{"method":"post","url":"https://api.example.com/v1/events?x=1",
+		"headers":[{"name":":authority","value":"api.example.com"},{"name":"content-type","value":"application/json"},{"name":"Stripe-Signature","value":"t=1,v1=a"}],
+		"postData":{"mimeType":"application/json","text":"{\"type\":\"charge.succeeded\"}"}},
+	"response":{"status":200,"headers":[{"name":"content-type","value":"text/plain"}],"content":{"text":"b2s=","encoding":"base64"}},
+	"timings":{"blocked":-1,"send":1.5,"wait":20,"receive":2}
+}]}}`
+
+func TestParseHAR(t *testing.T) {
+	captures, err := Parse(FormatHAR, strings.NewReader(har))
+	if err != nil || len(captures) != 1 {
+		t.Fatalf("Parse = %+v, %v", captures, err)
+	}
+	p := captures[0]
+	if p.Method != "POST" || p.Path != "/v1/events" || p.Query != "x=1" || p.Body != `{"type":"charge.succeeded"}` {
+		t.Errorf("request = %+v", p)
+	}
+	if !p.Timestamp.Equal(time.Date(2026, 1, 2, 2, 4, 5, 678e6, time.UTC)) {
+		t.Errorf("timestamp = %s", p.Timestamp)
+	}
+	if _, ok := p.Headers[":authority"]; ok || p.Headers["Content-Type"][0] != "application/json" {
+		t.Errorf("headers = %v", p.Headers)
+	}
+	if p.Response == nil || p.Response.Body != "ok" || p.Timing == nil || p.Timing.HandleMs != 20 {
+		t.Errorf("response/timing = %+v %+v", p.Response, p.Timing)
+	}
+	if p.Provider != "stripe" {
+		t.Errorf("provider = %q", p.Provider)
+	}
+}
+
+func TestParseNDJSON(t *testing.T) {
+	input := `{"id":"a","method":"POST","timestamp":"2026-01-01T00:00:00Z","body":"{}"}` + "\n\n" +
+		`{"id":"b","method":"PUT","timestamp":"2026-01-01T00:00:01Z","body":"{}","tags":["x"]}` + "\n"
+	captures, err := Parse(FormatNDJSON, strings.NewReader(input))
+	if err != nil || len(captures) != 2 || captures[1].ID != "b" || captures[1].Tags[0] != "x" {
+		t.Fatalf("Parse = %+v, %v", captures, err)
+	}
+
+	for _, bad := range []string{`{"method":"POST"}`, "{}\nnot json", `{"timestamp":"2026-01-01T00:00:00Z"}`} {
+		if _, err := Parse(FormatNDJSON, strings.NewReader(bad)); err == nil || !strings.Contains(err.Error(), "line") {
+			t.Errorf("%q: err = %v", bad, err)
+		}
+	}
+	if _, err := Parse("xml", strings.NewReader("")); err == nil {
+		t.Error("expected unknown format to be rejected")
+	}
+}
+
+// What the inspector exports as HAR comes back unchanged
+func TestHARRoundTrip(t *testing.T) {
+	original := models.WebhookPayload{
+		ID:        "c1",
+		Method:    "POST",
+		Path:      "/github",
+		Query:     "a=1",
+		Headers:   map[string][]string{"Content-Type": {"application/json"}, "X-Github-Event": {"push"}},
+		Body:      `{"ref":"main"}`,
+		Timestamp: time.Date(2026, 1, 1, 0, 0, 1, 500, time.UTC),
+		Response:  &models.CaptureResponse{Status: 200, Headers: map[string]string{"Content-Type": "text/plain"}, Body: "Webhook received"},
+	}
+	format, _ := export.Lookup("har")
+	var buf bytes.Buffer
+	format.Write(&buf, []models.WebhookPayload{original}, export.Options{BaseURL: "https://inspector.test/hooks/tok"})
+
+	captures, err := Parse(FormatHAR, &buf)
+	if err != nil || len(captures) != 1 {
+		t.Fatalf("Parse = %v", err)
+	}
+	got := captures[0]
+	if got.Method != original.Method || got.Body != original.Body || got.Query != original.Query ||
+		got.Headers["X-Github-Event"][0] != "push" || !got.Timestamp.Equal(original.Timestamp) || got.Response.Body != "Webhook received" {
+		t.Errorf("round trip = %+v", got)
+	}
+	// The ingest path comes back as part of the URL path
+	if got.Path != "/hooks/tok/github" {
+		t.Errorf("path = %q", got.Path)
+	}
+}
@@ -0,0 +1,44 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestApply(t *testing.T) {
+	doc := []byte(`{"type":"payment_intent.succeeded","data":{"amount":2000,"tags":["a","b"]}}`)
+	ops := []Operation{
+		{Op: "replace", Path: "/type", Value: json.RawMessage(`"payment_intent.payment_failed"`)},
+		{Op: "add", Path: "/data/tags/1", Value: json.RawMessage(`"x"`)},
+		{Op: "remove", Path: "/data/tags/0"},
+		{Op: "copy", From: "/data/amount", Path: "/data/original_amount"},
+		{Op: "move", From: "/data/tags", Path: "/labels"},
+		{Op: "test", Path: "/data/amount", Value: json.RawMessage(`2000`)},
+	}
+
+	got, err := Apply(doc, ops)
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	want := `{"data":{"amount":2000,"original_amount":2000},"labels":["x","b"],"type":"payment_intent.payment_failed"}`
+	if string(got) != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestApply_Errors(t *testing.T) {
+	doc := []byte(`{"a":[1,2]}`)
+	cases := []Operation{
+		{Op: "remove", Path: "/missing"},
+		{Op: "replace", Path: "/a/5", Value: json.RawMessage(`1`)},
+		{Op: "test", Path: "/a/0", Value: json.RawMessage(`2`)},
+		{Op: "move", From: "/a", Path: "/a/0"},
+		{Op: "bogus", Path: "/a"},
+	}
+	for _, op := range cases {
+		if _, err := Apply(doc, []Operation{op}); err == nil {
+			t.Errorf("expected %s %s to fail", op.Op, op.Path)
+		}
+	}
+}
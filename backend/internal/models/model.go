@@ -1,6 +1,9 @@
 package models
 
 import (
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 )
 
@@ -10,4 +13,276 @@ type WebhookPayload struct {
 	Headers   map[string][]string `json:"headers"`
 	Body      string              `json:"body"`
 	Timestamp time.Time           `json:"timestamp"`
+	// Sub-path after /hooks/{token} and the raw query string the sender used
+	Path      string   `json:"path,omitempty"`
+	Query     string   `json:"query,omitempty"`
+	Provider  string   `json:"provider,omitempty"`
+	EventType string   `json:"event_type,omitempty"`
+	Tags      []string `json:"tags,omitempty"`
+	// Pinned captures outlive the data TTL, up to the tier's pin quota
+	Pinned bool `json:"pinned,omitempty"`
+	// Triage state set through PATCH /logs/{id}
+	Note string `json:"note,omitempty"`
+	Read bool   `json:"read,omitempty"`
+	// Rules that fired when the capture arrived
+	Rules []RuleFiring `json:"rules,omitempty"`
+	// What the inspector answered and how long that took; absent on older captures
+	Response *CaptureResponse `json:"response,omitempty"`
+	Timing   *CaptureTiming   `json:"timing,omitempty"`
+	// Set on captures loaded from a file rather than received
+	Imported *CaptureImport `json:"imported,omitempty"`
+	// Ways the body departs from the baselines for its event type, checked on arrival
+	Drift []Drift `json:"drift,omitempty"`
+}
+
+// CaptureImport records where an imported capture came from.
+type CaptureImport struct {
+	Format     string    `json:"format"` // har or ndjson
+	At         time.Time `json:"at"`
+	OriginalID string    `json:"original_id,omitempty"`
+}
+
+// CaptureResponse is the response the sender received.
+type CaptureResponse struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body"`
+}
+
+// CaptureTiming splits the handling of a capture, in milliseconds.
+type CaptureTiming struct {
+	ReceiveMs float64 `json:"receive_ms"` // reading the request body
+	HandleMs  float64 `json:"handle_ms"`  // validation, rules and rate limiting before it was stored
+}
+
+// Bin is an ephemeral endpoint created through the API rather than a cookie session.
+type Bin struct {
+	Token     string    `json:"token"`
+	Owner     string    `json:"owner,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// EndpointConfig holds the per-token settings stored under token:{token}:config.
+type EndpointConfig struct {
+	Forwards []ForwardTarget `json:"forwards"`
+	Secrets  SigningSecrets  `json:"secrets"`
+	Sinks    []SinkConfig    `json:"sinks,omitempty"`
+	// Applied in order to forwarded copies; stored captures are never changed
+	Transforms []TransformStep `json:"transforms,omitempty"`
+	Rules      []Rule          `json:"rules,omitempty"`
+	Retention  Retention       `json:"retention"`
+}
+
+// Retention bounds how long an endpoint keeps new captures and how many it keeps.
+type Retention struct {
+	TTL         string `json:"ttl,omitempty"`          // Go duration; WEBHOOK_DATA_TTL when empty
+	MaxCaptures int    `json:"max_captures,omitempty"` // unpinned captures kept, oldest evicted first; 0 is no cap
+}
+
+// Duration is how long new captures are kept, or fallback when no valid TTL is set
+func (r Retention) Duration(fallback time.Duration) time.Duration {
+	if ttl, err := time.ParseDuration(r.TTL); err == nil {
+		return ttl
+	}
+	return fallback
+}
+
+// Rule runs its actions on every new capture that matches its conditions.
+type Rule struct {
+	ID       string `json:"id"`
+	Name     string `json:"name,omitempty"`
+	Disabled bool   `json:"disabled,omitempty"`
+	// "all" (default) or "any" of the conditions
+	Match      string          `json:"match,omitempty"`
+	Conditions []RuleCondition `json:"conditions"`
+	Actions    []RuleAction    `json:"actions"`
+	// Skip the remaining rules once this one fires
+	Stop bool `json:"stop,omitempty"`
+}
+
+// RuleCondition tests one attribute of a capture.
+type RuleCondition struct {
+	Field string `json:"field"` // method, header, provider, event_type or json
+	Name  string `json:"name,omitempty"`
+	Path  string `json:"path,omitempty"`
+	Op    string `json:"op,omitempty"`
+	Value string `json:"value,omitempty"`
+}
+
+// RuleAction is what a matching rule does.
+type RuleAction struct {
+	Type    string            `json:"type"` // tag, forward, notify, pin or respond
+	Tag     string            `json:"tag,omitempty"`
+	URL     string            `json:"url,omitempty"`
+	Text    string            `json:"text,omitempty"`
+	Status  int               `json:"status,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`
+}
+
+// RuleFiring is the audit record of a rule that matched a capture.
+type RuleFiring struct {
+	RuleID  string   `json:"rule_id"`
+	Name    string   `json:"name,omitempty"`
+	Actions []string `json:"actions"`
+}
+
+// SigningSecrets are the provider secrets used when re-signing requests sent on the endpoint's behalf.
+type SigningSecrets struct {
+	GitHub           string `json:"github,omitempty"`
+	Stripe           string `json:"stripe,omitempty"`
+	StandardWebhooks string `json:"standard,omitempty"`
+	Slack            string `json:"slack,omitempty"`
+	Shopify          string `json:"shopify,omitempty"`
+	Twilio           string `json:"twilio,omitempty"`
+}
+
+// ForwardTarget is a URL every new capture is re-sent to.
+type ForwardTarget struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+	// Headers removed before sending; nil means the default hop-by-hop set
+	StripHeaders []string `json:"strip_headers,omitempty"`
+	MaxAttempts  int      `json:"max_attempts,omitempty"`
+}
+
+// TransformStep is one edit applied to a forwarded copy of a capture.
+type TransformStep struct {
+	Type       string   `json:"type"` // set_header, remove_header, rename_header, jq, template or mask
+	Header     string   `json:"header,omitempty"`
+	Value      string   `json:"value,omitempty"`
+	To         string   `json:"to,omitempty"`
+	Expression string   `json:"expression,omitempty"`
+	Template   string   `json:"template,omitempty"`
+	Paths      []string `json:"paths,omitempty"`
+	Mask       string   `json:"mask,omitempty"`
+}
+
+// SinkConfig is a feed every new capture is published to.
+type SinkConfig struct {
+	ID   string `json:"id"`
+	Type string `json:"type"` // redis_stream, nats, file or stdout
+	// Redis (redis://) or NATS (nats://) server; empty means the inspector's own
+	URL     string `json:"url,omitempty"`
+	Stream  string `json:"stream,omitempty"`
+	MaxLen  int64  `json:"max_len,omitempty"`
+	Subject string `json:"subject,omitempty"`
+	Path    string `json:"path,omitempty"`
+}
+
+// DeliveryAttempt records one outbound request made on behalf of a capture.
+type DeliveryAttempt struct {
+	Kind            string              `json:"kind"`
+	TargetID        string              `json:"target_id,omitempty"`
+	URL             string              `json:"url"`
+	Attempt         int                 `json:"attempt"`
+	StatusCode      int                 `json:"status_code,omitempty"`
+	LatencyMs       int64               `json:"latency_ms"`
+	ResponseHeaders map[string][]string `json:"response_headers,omitempty"`
+	ResponseBody    string              `json:"response_body,omitempty"`
+	Error           string              `json:"error,omitempty"`
+	Timestamp       time.Time           `json:"timestamp"`
+}
+
+// ReplayBatch is the progress of a bulk replay.
+type ReplayBatch struct {
+	ID         string     `json:"id"`
+	URL        string     `json:"url"`
+	State      string     `json:"state"`
+	Total      int        `json:"total"`
+	Completed  int        `json:"completed"`
+	Succeeded  int        `json:"succeeded"`
+	Failed     int        `json:"failed"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// ReplayResult is one line of a bulk replay report.
+type ReplayResult struct {
+	CaptureID         string    `json:"capture_id"`
+	OriginalTimestamp time.Time `json:"original_timestamp"`
+	SentAt            time.Time `json:"sent_at"`
+	StatusCode        int       `json:"status_code,omitempty"`
+	LatencyMs         int64     `json:"latency_ms"`
+	Error             string    `json:"error,omitempty"`
+}
+
+// Simulation is a provider delivery made to an external URL, with every attempt made so far.
+type Simulation struct {
+	ID            string            `json:"id"`
+	URL           string            `json:"url"`
+	Method        string            `json:"method"`
+	Headers       map[string]string `json:"headers,omitempty"`
+	Body          string            `json:"body"`
+	Sign          string            `json:"sign,omitempty"`
+	TimeoutMs     int64             `json:"timeout_ms"`
+	ScheduleMs    []int64           `json:"schedule_ms"`
+	State         string            `json:"state"`
+	Attempts      []DeliveryAttempt `json:"attempts"`
+	NextAttemptAt *time.Time        `json:"next_attempt_at,omitempty"`
+	CreatedAt     time.Time         `json:"created_at"`
+}
+
+// StoredSimulation keeps the secret a simulation's retries are signed with, which is never returned.
+type StoredSimulation struct {
+	Simulation
+	Secret string `json:"secret,omitempty"`
+}
+
+// Baseline is a named set of captures frozen as the expected shape of one provider event.
+type Baseline struct {
+	Name       string    `json:"name"`
+	Provider   string    `json:"provider,omitempty"`
+	EventType  string    `json:"event_type,omitempty"`
+	CaptureIDs []string  `json:"capture_ids"`
+	CreatedAt  time.Time `json:"created_at"`
+	// Fields by JSON pointer, with * standing for any array index
+	Fields map[string]FieldShape `json:"fields"`
+}
+
+// FieldShape is what a baseline learned about one field.
+type FieldShape struct {
+	Types []string `json:"types"` // object, array, string, number, boolean or null
+	// Present in every baseline capture that had its parent, so drift reports its absence
+	Required bool `json:"required,omitempty"`
+	// String values seen, kept only when few values repeat across captures
+	Enum []string `json:"enum,omitempty"`
+}
+
+// Drift is one way a capture departs from a baseline.
+type Drift struct {
+	Baseline string `json:"baseline,omitempty"`
+	Kind     string `json:"kind"` // added_field, removed_field, type_changed or new_enum_value
+	Path     string `json:"path"`
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+}
+
+// Raw renders the capture as an HTTP/1.1 request, headers sorted by name. The target is
+// the sub-path and query the sender used, relative to the ingest URL.
+func (p WebhookPayload) Raw() string {
+	var b strings.Builder
+	target := p.Path
+	if target == "" {
+		target = "/"
+	}
+	if p.Query != "" {
+		target += "?" + p.Query
+	}
+	fmt.Fprintf(&b, "%s %s HTTP/1.1\r\n", p.Method, target)
+
+	names := make([]string, 0, len(p.Headers))
+	for name := range p.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range p.Headers[name] {
+			fmt.Fprintf(&b, "%s: %s\r\n", name, value)
+		}
+	}
+	b.WriteString("\r\n")
+	b.WriteString(p.Body)
+	return b.String()
 }
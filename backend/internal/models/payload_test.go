@@ -32,3 +32,20 @@ func TestWebhookPayload_MarshalUnmarshal(t *testing.T) {
 		t.Errorf("Decoded struct does not match original")
 	}
 }
+
+func TestWebhookPayload_Raw(t *testing.T) {
+	p := WebhookPayload{
+		Method:  "POST",
+		Path:    "/stripe",
+		Query:   "a=1",
+		Headers: map[string][]string{"X-B": {"2", "3"}, "Content-Type": {"application/json"}},
+		Body:    `{"ok":true}`,
+	}
+	want := "POST /stripe?a=1 HTTP/1.1\r\nContent-Type: application/json\r\nX-B: 2\r\nX-B: 3\r\n\r\n{\"ok\":true}"
+	if got := p.Raw(); got != want {
+		t.Errorf("Raw() = %q, want %q", got, want)
+	}
+	if got := (WebhookPayload{Method: "GET"}).Raw(); got != "GET / HTTP/1.1\r\n\r\n" {
+		t.Errorf("Raw() without path = %q", got)
+	}
+}
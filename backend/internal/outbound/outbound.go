@@ -0,0 +1,115 @@
+// Package outbound sends requests to URLs chosen by users: forwards, replays,
+// simulations, fixture sends and rule notifications. Its client refuses to connect to
+// loopback, private, link-local and unspecified addresses, checking the address actually
+// dialled so DNS answers and redirects can't point it back inside the network.
+package outbound
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"webhook-inspector/internal/config"
+)
+
+// ErrForbiddenAddress is returned when a request would reach an internal address
+var ErrForbiddenAddress = errors.New("loopback, private and link-local addresses are not allowed")
+
+// AllowPrivate lets requests reach internal addresses, for self-hosted setups
+// forwarding inside their own network and for tests
+var AllowPrivate = config.OutboundAllowPrivate
+
+// Ranges refused besides what netip classifies as loopback, private, link-local,
+// multicast or unspecified
+var forbiddenPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // "this network"
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT, also used for cloud metadata
+	netip.MustParsePrefix("192.0.0.0/24"),  // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"), // benchmarking
+}
+
+// Client is shared by every outbound request. It doesn't follow redirects: a 3xx is
+// reported as the target's answer.
+var Client = &http.Client{
+	Transport: newTransport(func(addr netip.AddrPort) bool { return AllowPrivate || Allowed(addr.Addr()) }),
+	Timeout:   config.ForwardTimeout,
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// WithTimeout returns Client with another overall timeout, sharing its transport
+func WithTimeout(d time.Duration) *http.Client {
+	c := *Client
+	c.Timeout = d
+	return &c
+}
+
+// Allowed reports whether an address may be dialled
+func Allowed(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsValid() || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, prefix := range forbiddenPrefixes {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckURL validates a target when it is saved: an absolute http(s) URL whose host
+// isn't a literal internal address. Hostnames are checked again when dialled.
+func CheckURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("url must be an absolute http(s) URL")
+	}
+	if AllowPrivate {
+		return nil
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("url host %s: %w", host, ErrForbiddenAddress)
+	}
+	if ip, err := netip.ParseAddr(host); err == nil && !Allowed(ip) {
+		return fmt.Errorf("url host %s: %w", host, ErrForbiddenAddress)
+	}
+	return nil
+}
+
+// A transport whose every connection, redirects included, is checked by permit once
+// the name has been resolved. Proxies are ignored since they would be dialled instead.
+func newTransport(permit func(netip.AddrPort) bool) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addr, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return fmt.Errorf("outbound: unexpected address %q: %w", address, err)
+			}
+			if !permit(addr) {
+				return fmt.Errorf("outbound: refusing to connect to %s: %w", addr.Addr(), ErrForbiddenAddress)
+			}
+			return nil
+		},
+	}
+	return &http.Transport{
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+}
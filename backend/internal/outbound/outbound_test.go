@@ -0,0 +1,86 @@
+package outbound
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAllowed(t *testing.T) {
+	for _, ip := range []string{"127.0.0.1", "10.1.2.3", "172.16.0.1", "192.168.1.1", "169.254.169.254",
+		"0.0.0.0", "100.100.100.200", "::1", "fe80::1", "fd00::1", "::", "::ffff:127.0.0.1"} {
+		if Allowed(netip.MustParseAddr(ip)) {
+			t.Errorf("%s allowed", ip)
+		}
+	}
+	for _, ip := range []string{"93.184.216.34", "2606:4700::1111"} {
+		if !Allowed(netip.MustParseAddr(ip)) {
+			t.Errorf("%s refused", ip)
+		}
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	for _, raw := range []string{"ftp://example.com", "/relative", "http://localhost:8080/x", "http://127.0.0.1/",
+		"http://[::1]/", "http://169.254.169.254/latest/meta-data/", "http://10.0.0.5:9000"} {
+		if err := CheckURL(raw); err == nil {
+			t.Errorf("%s accepted", raw)
+		}
+	}
+	if err := CheckURL("https://hooks.example.com/in"); err != nil {
+		t.Errorf("public URL refused: %v", err)
+	}
+}
+
+func TestClientRefusesInternalTargets(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("internal"))
+	}))
+	defer srv.Close()
+	port := srv.URL[strings.LastIndex(srv.URL, ":"):]
+
+	// The name resolves to loopback, so it is only caught once dialled
+	for _, target := range []string{srv.URL, "http://localhost" + port, "http://169.254.169.254/latest/meta-data/"} {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+		resp, err := Client.Do(req)
+		cancel()
+		if err == nil {
+			resp.Body.Close()
+			t.Errorf("%s: expected refusal, got %d", target, resp.StatusCode)
+			continue
+		}
+		if !errors.Is(err, ErrForbiddenAddress) {
+			t.Errorf("%s: err = %v", target, err)
+		}
+	}
+}
+
+func TestRedirectsAreChecked(t *testing.T) {
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("internal"))
+	}))
+	defer internal.Close()
+	public := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, internal.URL, http.StatusFound)
+	}))
+	defer public.Close()
+
+	// Pretend only the first server is public, and follow redirects unlike Client
+	publicAddr := netip.MustParseAddrPort(strings.TrimPrefix(public.URL, "http://"))
+	client := &http.Client{Transport: newTransport(func(addr netip.AddrPort) bool { return addr == publicAddr })}
+
+	resp, err := client.Get(public.URL)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatalf("expected the redirect to be refused, got %d", resp.StatusCode)
+	}
+	if !errors.Is(err, ErrForbiddenAddress) {
+		t.Errorf("err = %v", err)
+	}
+}
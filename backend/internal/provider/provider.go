@@ -0,0 +1,89 @@
+// Package provider recognises which service sent a webhook and which event it carries.
+package provider
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Known providers
+const (
+	GitHub           = "github"
+	Stripe           = "stripe"
+	Slack            = "slack"
+	Shopify          = "shopify"
+	Twilio           = "twilio"
+	StandardWebhooks = "standard"
+)
+
+// Detect returns the provider and event type of a request; either may be empty when unknown
+func Detect(headers http.Header, body []byte) (provider, event string) {
+	switch {
+	case headers.Get("X-GitHub-Event") != "":
+		return GitHub, headers.Get("X-GitHub-Event")
+	case headers.Get("X-Shopify-Topic") != "":
+		return Shopify, headers.Get("X-Shopify-Topic")
+	case headers.Get("Stripe-Signature") != "" || strings.HasPrefix(headers.Get("User-Agent"), "Stripe/"):
+		return Stripe, stringField(decode(body), "type")
+	case headers.Get("X-Slack-Signature") != "" || strings.HasPrefix(headers.Get("User-Agent"), "Slackbot"):
+		return Slack, slackEvent(body)
+	case headers.Get("X-Twilio-Signature") != "" || strings.HasPrefix(headers.Get("User-Agent"), "TwilioProxy"):
+		return Twilio, twilioEvent(body)
+	case headers.Get("Webhook-Id") != "" && headers.Get("Webhook-Signature") != "":
+		return StandardWebhooks, stringField(decode(body), "type")
+	}
+
+	// Unknown sender; many services still name the event in the body
+	doc := decode(body)
+	if event := stringField(doc, "type"); event != "" {
+		return "", event
+	}
+	return "", stringField(doc, "event")
+}
+
+func slackEvent(body []byte) string {
+	doc := decode(body)
+	if doc == nil {
+		// Slash commands and interactivity are form-encoded
+		form, _ := url.ParseQuery(string(body))
+		if form.Has("command") {
+			return "slash_command"
+		}
+		if form.Has("payload") {
+			return "interactive"
+		}
+		return ""
+	}
+	if inner, ok := doc["event"].(map[string]interface{}); ok && stringField(doc, "type") == "event_callback" {
+		return stringField(inner, "type")
+	}
+	return stringField(doc, "type")
+}
+
+func twilioEvent(body []byte) string {
+	form, _ := url.ParseQuery(string(body))
+	switch {
+	case form.Has("MessageStatus"):
+		return "message.status"
+	case form.Has("SmsSid") || form.Has("MessageSid"):
+		return "sms.received"
+	case form.Has("CallStatus"):
+		return "call." + form.Get("CallStatus")
+	}
+	return ""
+}
+
+func decode(body []byte) map[string]interface{} {
+	var doc map[string]interface{}
+	if json.Unmarshal(body, &doc) != nil {
+		return nil
+	}
+	return doc
+}
+
+func stringField(doc map[string]interface{}, key string) string {
+	s, _ := doc[key].(string)
+	return s
+}
@@ -0,0 +1,47 @@
+package provider
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"webhook-inspector/internal/fixtures"
+)
+
+func TestDetectCatalogFixtures(t *testing.T) {
+	for _, f := range fixtures.List() {
+		rendered, err := f.Render(time.Now())
+		if err != nil {
+			t.Fatalf("%s: %v", f.Name, err)
+		}
+		provider, event := Detect(rendered.Headers, []byte(rendered.Body))
+		if provider != f.Provider || event != f.Event {
+			t.Errorf("%s: got (%q, %q), want (%q, %q)", f.Name, provider, event, f.Provider, f.Event)
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name     string
+		headers  http.Header
+		body     string
+		provider string
+		event    string
+	}{
+		{"standard webhooks", http.Header{"Webhook-Id": {"msg_1"}, "Webhook-Signature": {"v1,abc"}}, `{"type":"invoice.paid"}`, StandardWebhooks, "invoice.paid"},
+		{"slack slash command", http.Header{"X-Slack-Signature": {"v0=abc"}}, "command=%2Fdeploy&text=prod", Slack, "slash_command"},
+		{"twilio status callback", http.Header{"X-Twilio-Signature": {"abc"}}, "MessageSid=SM1&MessageStatus=delivered", Twilio, "message.status"},
+		{"unknown with type", http.Header{}, `{"type":"order.paid"}`, "", "order.paid"},
+		{"unknown with event", http.Header{}, `{"event":"deploy"}`, "", "deploy"},
+		{"nothing to go on", http.Header{}, `[1,2]`, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, event := Detect(tt.headers, []byte(tt.body))
+			if provider != tt.provider || event != tt.event {
+				t.Errorf("got (%q, %q), want (%q, %q)", provider, event, tt.provider, tt.event)
+			}
+		})
+	}
+}
@@ -0,0 +1,441 @@
+// Package query filters and paginates a token's captures.
+package query
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"webhook-inspector/internal/config"
+	"webhook-inspector/internal/models"
+	"webhook-inspector/internal/search"
+)
+
+// Sort orders
+const (
+	OrderAsc  = "asc"
+	OrderDesc = "desc"
+)
+
+// DefaultLimit is the page size when none is requested
+const DefaultLimit = 50
+
+// ErrInvalidCursor is returned for cursors that weren't issued for the same query
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Filter selects captures; empty fields match everything
+type Filter struct {
+	Since    *time.Time `json:"since,omitempty"`
+	Until    *time.Time `json:"until,omitempty"`
+	Method   string     `json:"method,omitempty"`
+	Header   string     `json:"header,omitempty"` // "Name" to require a header, "Name:value" to match its value
+	Provider string     `json:"provider,omitempty"`
+	Tag      string     `json:"tag,omitempty"`
+	Path     string     `json:"path,omitempty"`   // sub-path prefix, matched by whole segments
+	Body     string     `json:"body,omitempty"`   // case-insensitive substring
+	Search   string     `json:"search,omitempty"` // full-text and JSON-path query, see package search
+	Pinned   *bool      `json:"pinned,omitempty"`
+	Read     *bool      `json:"read,omitempty"`
+
+	compiled *search.Query
+}
+
+// Compile parses the search expression; a filter with a search matches nothing until compiled
+func (f *Filter) Compile() error {
+	f.compiled = nil
+	if f.Search == "" {
+		return nil
+	}
+	compiled, err := search.Parse(f.Search)
+	if err != nil {
+		return fmt.Errorf("search: %w", err)
+	}
+	f.compiled = compiled
+	return nil
+}
+
+// IndexTerms returns the search index terms every match must have
+func (f Filter) IndexTerms() []string {
+	if f.compiled == nil {
+		return nil
+	}
+	return f.compiled.IndexTerms()
+}
+
+// Match reports whether a capture falls inside the filter
+func (f Filter) Match(p models.WebhookPayload) bool {
+	if !f.InRange(p.Timestamp) {
+		return false
+	}
+	if f.Method != "" && !strings.EqualFold(f.Method, p.Method) {
+		return false
+	}
+	if f.Header != "" && !matchHeader(f.Header, p.Headers) {
+		return false
+	}
+	if f.Provider != "" && f.Provider != p.Provider {
+		return false
+	}
+	if f.Tag != "" && !slices.Contains(p.Tags, f.Tag) {
+		return false
+	}
+	if f.Path != "" && !matchPath(f.Path, p.Path) {
+		return false
+	}
+	if f.Body != "" && !strings.Contains(strings.ToLower(p.Body), strings.ToLower(f.Body)) {
+		return false
+	}
+	if f.Search != "" && (f.compiled == nil || !f.compiled.Match(p)) {
+		return false
+	}
+	if f.Pinned != nil && *f.Pinned != p.Pinned {
+		return false
+	}
+	if f.Read != nil && *f.Read != p.Read {
+		return false
+	}
+	return true
+}
+
+// InRange reports whether t is inside the since/until bounds (both inclusive)
+func (f Filter) InRange(t time.Time) bool {
+	if f.Since != nil && t.Before(*f.Since) {
+		return false
+	}
+	if f.Until != nil && t.After(*f.Until) {
+		return false
+	}
+	return true
+}
+
+// TimeOnly reports whether the filter can be answered from capture times alone
+func (f Filter) TimeOnly() bool {
+	return f.Method == "" && f.Header == "" && f.Provider == "" && f.Tag == "" && f.Path == "" && f.Body == "" && f.Search == "" &&
+		f.Pinned == nil && f.Read == nil
+}
+
+func matchHeader(spec string, headers map[string][]string) bool {
+	name, value, hasValue := strings.Cut(spec, ":")
+	values := http.Header(headers).Values(strings.TrimSpace(name))
+	if !hasValue {
+		return len(values) > 0
+	}
+	return slices.Contains(values, strings.TrimSpace(value))
+}
+
+func matchPath(prefix, path string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// Query is a filtered page request
+type Query struct {
+	Filter
+	Limit  int
+	Order  string
+	Cursor string
+	// Only captures after this position, for fetching what arrived since a known capture
+	After *Entry
+}
+
+// Page is one page of captures plus cursors to its neighbours
+type Page struct {
+	Items      []models.WebhookPayload `json:"items"`
+	Total      *int                    `json:"total,omitempty"` // time-only queries only
+	NextCursor string                  `json:"next_cursor,omitempty"`
+	PrevCursor string                  `json:"prev_cursor,omitempty"`
+}
+
+// Parse reads a query from URL parameters
+func Parse(v url.Values) (Query, error) {
+	q := Query{
+		Filter: Filter{
+			Method:   v.Get("method"),
+			Header:   v.Get("header"),
+			Provider: v.Get("provider"),
+			Tag:      v.Get("tag"),
+			Path:     v.Get("path"),
+			Body:     v.Get("q"),
+			Search:   v.Get("search"),
+		},
+		Limit:  DefaultLimit,
+		Order:  OrderDesc,
+		Cursor: v.Get("cursor"),
+	}
+
+	if err := q.Compile(); err != nil {
+		return q, err
+	}
+
+	var err error
+	if q.Since, err = parseTime(v, "since"); err != nil {
+		return q, err
+	}
+	if q.Until, err = parseTime(v, "until"); err != nil {
+		return q, err
+	}
+	if q.Pinned, err = parseBool(v, "pinned"); err != nil {
+		return q, err
+	}
+	if q.Read, err = parseBool(v, "read"); err != nil {
+		return q, err
+	}
+
+	if raw := v.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > config.LogsMaxPageSize {
+			return q, fmt.Errorf("limit must be between 1 and %d", config.LogsMaxPageSize)
+		}
+		q.Limit = limit
+	}
+
+	switch order := v.Get("order"); order {
+	case "":
+	case OrderAsc, OrderDesc:
+		q.Order = order
+	default:
+		return q, fmt.Errorf("order must be %q or %q", OrderAsc, OrderDesc)
+	}
+	return q, nil
+}
+
+func parseTime(v url.Values, name string) (*time.Time, error) {
+	raw := v.Get(name)
+	if raw == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be an RFC 3339 timestamp", name)
+	}
+	return &t, nil
+}
+
+func parseBool(v url.Values, name string) (*bool, error) {
+	raw := v.Get(name)
+	if raw == "" {
+		return nil, nil
+	}
+	b, err := strconv.ParseBool(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be true or false", name)
+	}
+	return &b, nil
+}
+
+// Entry is a capture's position in a token's index
+type Entry struct {
+	ID   string
+	Time time.Time
+}
+
+// Captures are ordered by time, then ID, like Redis orders equal scores. Times are
+// compared at the index's microsecond precision so cursors round-trip exactly.
+func less(a, b Entry) bool {
+	if at, bt := a.Time.UnixMicro(), b.Time.UnixMicro(); at != bt {
+		return at < bt
+	}
+	return a.ID < b.ID
+}
+
+// SortEntries puts entries in ascending order
+func SortEntries(entries []Entry) {
+	sort.Slice(entries, func(i, j int) bool { return less(entries[i], entries[j]) })
+}
+
+// Before reports whether e comes before other in index order
+func (e Entry) Before(other Entry) bool {
+	return less(e, other)
+}
+
+// Index reads a token's index entries, ascending by time then ID, within a query's
+// since/until and after bounds. Stores implement it over their own index so a page
+// reads only as far as it needs.
+type Index interface {
+	// Count returns how many entries are within the bounds
+	Count() (int, error)
+	// Scan returns up to n entries within the bounds that come strictly after from,
+	// walking down when desc is set; nil from starts at the first entry
+	Scan(from *Entry, desc bool, n int) ([]Entry, error)
+}
+
+// Entries is an Index over entries already in ascending order, for stores that keep
+// every entry at hand
+func Entries(entries []Entry, q Query) Index {
+	var bounded sliceIndex
+	for _, e := range entries {
+		if q.InRange(e.Time) && (q.After == nil || less(*q.After, e)) {
+			bounded = append(bounded, e)
+		}
+	}
+	return bounded
+}
+
+type sliceIndex []Entry
+
+func (s sliceIndex) Count() (int, error) {
+	return len(s), nil
+}
+
+func (s sliceIndex) Scan(from *Entry, desc bool, n int) ([]Entry, error) {
+	if !desc {
+		i := sort.Search(len(s), func(i int) bool { return from == nil || less(*from, s[i]) })
+		return slices.Clone(s[i:min(i+n, len(s))]), nil
+	}
+	end := len(s)
+	if from != nil {
+		end = sort.Search(len(s), func(i int) bool { return !less(s[i], *from) })
+	}
+	out := slices.Clone(s[max(0, end-n):end])
+	slices.Reverse(out)
+	return out, nil
+}
+
+// How many index entries a filtered page loads at a time while looking for matches
+const scanChunk = 100
+
+// Run answers q from a token's index. Loading payloads is left to the store: for
+// time-only filters just the page is loaded, otherwise captures are loaded a chunk at a
+// time from the cursor until the page is full. Counting matches would mean loading the
+// whole range, so only time-only pages carry a total. load returns payloads in the
+// order asked for, omitting any that no longer exist.
+func Run(index Index, q Query, load func(ids []string) ([]models.WebhookPayload, error)) (*Page, error) {
+	var from *Entry
+	forward := true
+	if q.Cursor != "" {
+		c, err := decodeCursor(q.Cursor, q.Order)
+		if err != nil {
+			return nil, err
+		}
+		from = &Entry{ID: c.ID, Time: time.UnixMicro(c.Time)}
+		forward = c.Dir == "next"
+	}
+	// A previous page is found by walking back from the cursor against the order
+	desc := (q.Order == OrderDesc) == forward
+
+	var (
+		window []Entry
+		items  []models.WebhookPayload
+		more   bool
+		total  *int
+	)
+	if q.TimeOnly() {
+		entries, err := index.Scan(from, desc, q.Limit+1)
+		if err != nil {
+			return nil, err
+		}
+		more = len(entries) > q.Limit
+		window = entries[:min(q.Limit, len(entries))]
+		if items, err = load(ids(window)); err != nil {
+			return nil, err
+		}
+		n, err := index.Count()
+		if err != nil {
+			return nil, err
+		}
+		// Captures the index still listed but that expired don't count
+		n -= len(window) - len(items)
+		total = &n
+	} else {
+		var err error
+		if window, items, more, err = scanMatches(index, q, from, desc, load); err != nil {
+			return nil, err
+		}
+	}
+	if !forward {
+		slices.Reverse(window)
+		slices.Reverse(items)
+	}
+
+	page := &Page{Items: nonNil(items), Total: total}
+	if len(window) == 0 {
+		return page, nil
+	}
+	hasNext, hasPrev := more, from != nil
+	if !forward {
+		hasNext, hasPrev = from != nil, more
+	}
+	if hasNext {
+		last := window[len(window)-1]
+		page.NextCursor = encodeCursor(cursor{Order: q.Order, Dir: "next", Time: last.Time.UnixMicro(), ID: last.ID})
+	}
+	if hasPrev {
+		first := window[0]
+		page.PrevCursor = encodeCursor(cursor{Order: q.Order, Dir: "prev", Time: first.Time.UnixMicro(), ID: first.ID})
+	}
+	return page, nil
+}
+
+// Walk the index from the cursor, loading a chunk of captures at a time, until a page
+// of matches and one more are found
+func scanMatches(index Index, q Query, from *Entry, desc bool, load func(ids []string) ([]models.WebhookPayload, error)) (window []Entry, items []models.WebhookPayload, more bool, err error) {
+	for {
+		entries, err := index.Scan(from, desc, scanChunk)
+		if err != nil || len(entries) == 0 {
+			return window, items, false, err
+		}
+		captures, err := load(ids(entries))
+		if err != nil {
+			return nil, nil, false, err
+		}
+		for _, c := range captures {
+			if !q.Match(c) {
+				continue
+			}
+			if len(items) == q.Limit {
+				return window, items, true, nil
+			}
+			window = append(window, Entry{ID: c.ID, Time: c.Timestamp})
+			items = append(items, c)
+		}
+		if len(entries) < scanChunk {
+			return window, items, false, nil
+		}
+		from = &entries[len(entries)-1]
+	}
+}
+
+// Position of a page boundary; Dir is "next" (entries after Pos) or "prev" (entries before it)
+type cursor struct {
+	Order string `json:"o"`
+	Dir   string `json:"d"`
+	Time  int64  `json:"t"`
+	ID    string `json:"i"`
+}
+
+func encodeCursor(c cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(raw, order string) (cursor, error) {
+	var c cursor
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil || json.Unmarshal(data, &c) != nil || (c.Dir != "next" && c.Dir != "prev") || c.Order != order {
+		return c, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+func ids(entries []Entry) []string {
+	out := make([]string, len(entries))
+	for i, e := range entries {
+		out[i] = e.ID
+	}
+	return out
+}
+
+func nonNil(items []models.WebhookPayload) []models.WebhookPayload {
+	if items == nil {
+		return []models.WebhookPayload{}
+	}
+	return items
+}
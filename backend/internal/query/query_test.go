@@ -0,0 +1,219 @@
+package query
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"webhook-inspector/internal/models"
+)
+
+func entries(n int) ([]Entry, map[string]models.WebhookPayload) {
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	var list []Entry
+	byID := map[string]models.WebhookPayload{}
+	for i := 0; i < n; i++ {
+		id := fmt.Sprintf("c%02d", i)
+		// Pairs share a timestamp so ties are broken by ID
+		at := base.Add(time.Duration(i/2) * time.Second)
+		list = append(list, Entry{ID: id, Time: at})
+		byID[id] = models.WebhookPayload{ID: id, Method: "POST", Timestamp: at, Body: fmt.Sprintf(`{"n":%d}`, i)}
+	}
+	return list, byID
+}
+
+func run(t *testing.T, list []Entry, byID map[string]models.WebhookPayload, q Query) *Page {
+	t.Helper()
+	page, err := Run(Entries(list, q), q, func(ids []string) ([]models.WebhookPayload, error) {
+		var out []models.WebhookPayload
+		for _, id := range ids {
+			if p, ok := byID[id]; ok {
+				out = append(out, p)
+			}
+		}
+		return out, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return page
+}
+
+func total(page *Page) int {
+	if page.Total == nil {
+		return -1
+	}
+	return *page.Total
+}
+
+func TestRunWalksBothWays(t *testing.T) {
+	list, byID := entries(7)
+
+	for _, order := range []string{OrderAsc, OrderDesc} {
+		var forward []string
+		q := Query{Limit: 3, Order: order}
+		page := run(t, list, byID, q)
+		for {
+			for _, item := range page.Items {
+				forward = append(forward, item.ID)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			q.Cursor = page.NextCursor
+			page = run(t, list, byID, q)
+		}
+		if len(forward) != 7 {
+			t.Fatalf("%s: walked %v", order, forward)
+		}
+		if order == OrderAsc && (forward[0] != "c00" || forward[6] != "c06") {
+			t.Errorf("asc order = %v", forward)
+		}
+		if order == OrderDesc && (forward[0] != "c06" || forward[6] != "c00") {
+			t.Errorf("desc order = %v", forward)
+		}
+
+		// The last page holds one item; going back returns the full page before it
+		q.Cursor = page.PrevCursor
+		back := run(t, list, byID, q)
+		if len(back.Items) != 3 || back.Items[0].ID != forward[3] || back.NextCursor == "" || back.PrevCursor == "" {
+			t.Errorf("%s: prev page = %+v", order, back)
+		}
+	}
+}
+
+func TestRunFiltersBeforePaging(t *testing.T) {
+	list, byID := entries(10)
+	page := run(t, list, byID, Query{Filter: Filter{Body: `"n":1`}, Limit: 1, Order: OrderAsc})
+	if page.Total != nil || len(page.Items) != 1 || page.Items[0].ID != "c01" || page.NextCursor != "" {
+		t.Errorf("page = %+v", page)
+	}
+}
+
+func TestRunFilteredLoadsOnlyWhatThePageNeeds(t *testing.T) {
+	list, byID := entries(3 * scanChunk)
+	// Bodies ending in 5: c05, c15, ... c295
+	q := Query{Filter: Filter{Body: `5}`}, Limit: 4, Order: OrderDesc}
+	loaded := 0
+	load := func(ids []string) ([]models.WebhookPayload, error) {
+		loaded += len(ids)
+		out := make([]models.WebhookPayload, len(ids))
+		for i, id := range ids {
+			out[i] = byID[id]
+		}
+		return out, nil
+	}
+
+	page, err := Run(Entries(list, q), q, load)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Items) != 4 || page.Items[0].ID != "c295" || page.Items[3].ID != "c265" || page.NextCursor == "" || page.PrevCursor != "" {
+		t.Fatalf("first page = %+v", page)
+	}
+	if loaded != scanChunk {
+		t.Errorf("first page loaded %d captures, want %d", loaded, scanChunk)
+	}
+
+	var walked []string
+	for page.NextCursor != "" {
+		q.Cursor = page.NextCursor
+		if page, err = Run(Entries(list, q), q, load); err != nil {
+			t.Fatal(err)
+		}
+		for _, item := range page.Items {
+			walked = append(walked, item.ID)
+		}
+	}
+	if len(walked) != 26 || walked[len(walked)-1] != "c05" {
+		t.Fatalf("walked %v", walked)
+	}
+
+	q.Cursor = page.PrevCursor
+	if page, err = Run(Entries(list, q), q, load); err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Items) != 4 || page.Items[0].ID != "c55" || page.Items[3].ID != "c25" || page.NextCursor == "" || page.PrevCursor == "" {
+		t.Errorf("prev page = %+v", page)
+	}
+}
+
+func TestRunSkipsExpired(t *testing.T) {
+	list, byID := entries(5)
+	// Still indexed, but the capture is gone
+	delete(byID, "c01")
+	page := run(t, list, byID, Query{Limit: 3, Order: OrderAsc})
+	if total(page) != 4 || len(page.Items) != 2 || page.Items[1].ID != "c02" {
+		t.Errorf("page = %+v", page)
+	}
+}
+
+func TestRunAfter(t *testing.T) {
+	list, byID := entries(6)
+	// c02 and c03 share a timestamp; only c03 onwards come after c02
+	page := run(t, list, byID, Query{Limit: 2, Order: OrderAsc, After: &list[2]})
+	if total(page) != 3 || page.Items[0].ID != "c03" || page.NextCursor == "" || page.PrevCursor != "" {
+		t.Errorf("page = %+v", page)
+	}
+}
+
+func TestCursorBoundToOrder(t *testing.T) {
+	list, byID := entries(4)
+	page := run(t, list, byID, Query{Limit: 2, Order: OrderAsc})
+	q := Query{Limit: 2, Order: OrderDesc, Cursor: page.NextCursor}
+	_, err := Run(Entries(list, q), q, nil)
+	if err != ErrInvalidCursor {
+		t.Errorf("err = %v, want ErrInvalidCursor", err)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	p := models.WebhookPayload{
+		Method:  "POST",
+		Path:    "/stripe/events",
+		Headers: map[string][]string{"X-Github-Event": {"push"}},
+		Tags:    []string{"billing"},
+		Pinned:  true,
+	}
+	yes, no := true, false
+	tests := []struct {
+		filter Filter
+		want   bool
+	}{
+		{Filter{Header: "x-github-event"}, true},
+		{Filter{Header: "X-GitHub-Event: push"}, true},
+		{Filter{Header: "X-GitHub-Event:pull_request"}, false},
+		{Filter{Path: "/stripe"}, true},
+		{Filter{Path: "/stripe/"}, true},
+		{Filter{Path: "/strip"}, false},
+		{Filter{Tag: "billing", Method: "post"}, true},
+		{Filter{Tag: "other"}, false},
+		{Filter{Search: "billing"}, false}, // not compiled
+		{Filter{Pinned: &yes, Read: &no}, true},
+		{Filter{Read: &yes}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.filter.Match(p); got != tt.want {
+			t.Errorf("%+v: got %v, want %v", tt.filter, got, tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	q, err := Parse(url.Values{"limit": {"10"}, "order": {"asc"}, "since": {"2026-01-01T00:00:00Z"}, "q": {"cus_123"}})
+	if err != nil || q.Limit != 10 || q.Order != OrderAsc || q.Since == nil || q.Body != "cus_123" {
+		t.Errorf("Parse = %+v, %v", q, err)
+	}
+	if q, _ := Parse(url.Values{}); q.Limit != DefaultLimit || q.Order != OrderDesc {
+		t.Errorf("defaults = %+v", q)
+	}
+	if q, err := Parse(url.Values{"read": {"false"}}); err != nil || q.Read == nil || *q.Read || q.TimeOnly() {
+		t.Errorf("read = %+v, %v", q, err)
+	}
+	for _, bad := range []url.Values{{"limit": {"-1"}}, {"order": {"up"}}, {"until": {"tomorrow"}}, {"search": {"$.a >"}}, {"pinned": {"maybe"}}} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("expected %v to be rejected", bad)
+		}
+	}
+}
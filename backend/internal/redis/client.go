@@ -33,3 +33,8 @@ func getRedisAddr() string {
 	}
 	return "localhost:6379"
 }
+
+// Enabled reports whether InitRedis has connected; the in-memory store runs without Redis
+func Enabled() bool {
+	return Client != nil
+}
@@ -0,0 +1,156 @@
+package relay
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"webhook-inspector/internal/forward"
+	"webhook-inspector/internal/models"
+
+	"github.com/gorilla/websocket"
+)
+
+// Client holds a relay connection open and replays each capture against a local URL
+type Client struct {
+	Server     string // Base URL of the inspector, e.g. https://inspector.example.com
+	Credential string // Bearer credential of a bin, or empty to use Token
+	Token      string // webhook_token cookie value
+	Target     string // Local receiver, e.g. http://localhost:3000/webhooks
+	Since      time.Time
+	HTTPClient *http.Client
+
+	// IDs already replayed, so catch-up after a reconnect never sends one twice
+	seen map[string]bool
+}
+
+// Run connects and relays until ctx is cancelled, reconnecting with backoff on failure
+func (c *Client) Run(ctx context.Context) error {
+	if c.HTTPClient == nil {
+		c.HTTPClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	c.seen = make(map[string]bool)
+
+	backoff := time.Second
+	for {
+		start := time.Now()
+		err := c.session(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		// A connection that stayed up for a while resets the backoff
+		if time.Since(start) > time.Minute {
+			backoff = time.Second
+		}
+		log.Printf("relay: disconnected (%v), reconnecting in %s", err, backoff)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, 30*time.Second)
+	}
+}
+
+func (c *Client) session(ctx context.Context) error {
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	for {
+		var msg Message
+		if err := conn.ReadJSON(&msg); err != nil {
+			return err
+		}
+
+		switch msg.Type {
+		case TypeSynced:
+			log.Printf("relay: connected, forwarding to %s", c.Target)
+		case TypeCapture:
+			if msg.Capture == nil || c.seen[msg.Capture.ID] {
+				continue
+			}
+			result := c.replay(ctx, *msg.Capture)
+			if len(c.seen) > 10000 {
+				// Catch-up only resends captures after Since, so old IDs can go
+				c.seen = make(map[string]bool)
+			}
+			c.seen[msg.Capture.ID] = true
+			if msg.Capture.Timestamp.After(c.Since) {
+				c.Since = msg.Capture.Timestamp
+			}
+
+			if err := conn.WriteJSON(Message{Type: TypeResult, CaptureID: msg.Capture.ID, Result: &result}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (c *Client) dial(ctx context.Context) (*websocket.Conn, error) {
+	u, err := url.Parse(strings.TrimRight(c.Server, "/") + "/relay")
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "https":
+		u.Scheme = "wss"
+	default:
+		u.Scheme = "ws"
+	}
+	if !c.Since.IsZero() {
+		u.RawQuery = url.Values{"since": {c.Since.Format(time.RFC3339Nano)}}.Encode()
+	}
+
+	header := http.Header{}
+	if c.Credential != "" {
+		header.Set("Authorization", "Bearer "+c.Credential)
+	} else {
+		header.Set("Cookie", "webhook_token="+c.Token)
+	}
+
+	conn, resp, err := websocket.DefaultDialer.DialContext(ctx, u.String(), header)
+	if err != nil {
+		if resp != nil {
+			return nil, fmt.Errorf("%w (HTTP %d)", err, resp.StatusCode)
+		}
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (c *Client) replay(ctx context.Context, capture models.WebhookPayload) models.DeliveryAttempt {
+	attempt := models.DeliveryAttempt{
+		Kind:      "relay",
+		URL:       c.Target,
+		Attempt:   1,
+		Timestamp: time.Now().UTC(),
+	}
+
+	req, err := forward.NewRequest(ctx, capture, c.Target, nil)
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+
+	attempt = forward.Send(c.HTTPClient, req, attempt)
+	if attempt.Error != "" {
+		log.Printf("relay: %s %s failed: %s", capture.Method, capture.ID, attempt.Error)
+	} else {
+		log.Printf("relay: %s %s -> %d (%dms)", capture.Method, capture.ID, attempt.StatusCode, attempt.LatencyMs)
+	}
+	return attempt
+}
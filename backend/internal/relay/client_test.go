@@ -0,0 +1,78 @@
+package relay
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"webhook-inspector/internal/models"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestClient_ReplaysCaptureAndReportsResult(t *testing.T) {
+	received := make(chan string, 1)
+	local := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r.Method + " " + string(body)
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("ok"))
+	}))
+	defer local.Close()
+
+	results := make(chan Message, 1)
+	inspector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer whk_test" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		capture := models.WebhookPayload{
+			ID:        "abc123",
+			Method:    "POST",
+			Headers:   map[string][]string{"Content-Type": {"application/json"}},
+			Body:      `{"foo":"bar"}`,
+			Timestamp: time.Now().UTC(),
+		}
+		conn.WriteJSON(Message{Type: TypeSynced})
+		conn.WriteJSON(Message{Type: TypeCapture, Capture: &capture})
+
+		var msg Message
+		if err := conn.ReadJSON(&msg); err == nil {
+			results <- msg
+		}
+	}))
+	defer inspector.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	client := &Client{Server: inspector.URL, Credential: "whk_test", Target: local.URL}
+	go client.Run(ctx)
+
+	select {
+	case got := <-received:
+		if got != `POST {"foo":"bar"}` {
+			t.Errorf("unexpected replayed request: %s", got)
+		}
+	case <-ctx.Done():
+		t.Fatal("capture was never replayed")
+	}
+
+	select {
+	case msg := <-results:
+		if msg.Type != TypeResult || msg.CaptureID != "abc123" || msg.Result == nil || msg.Result.StatusCode != http.StatusAccepted {
+			t.Errorf("unexpected result message: %+v", msg)
+		}
+	case <-ctx.Done():
+		t.Fatal("result was never reported")
+	}
+}
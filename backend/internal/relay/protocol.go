@@ -0,0 +1,24 @@
+package relay
+
+import (
+	"webhook-inspector/internal/models"
+)
+
+// Message types exchanged over the relay WebSocket
+const (
+	// Server → client: a capture to replay locally
+	TypeCapture = "capture"
+	// Server → client: catch-up finished, everything after this is live
+	TypeSynced = "synced"
+	// Client → server: the local receiver's answer for a capture
+	TypeResult = "result"
+)
+
+// Message is the JSON envelope for every frame on the relay connection
+type Message struct {
+	Type      string                  `json:"type"`
+	Capture   *models.WebhookPayload  `json:"capture,omitempty"`
+	Replayed  bool                    `json:"replayed,omitempty"`
+	CaptureID string                  `json:"capture_id,omitempty"`
+	Result    *models.DeliveryAttempt `json:"result,omitempty"`
+}
@@ -0,0 +1,358 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"webhook-inspector/internal/config"
+	"webhook-inspector/internal/forward"
+	"webhook-inspector/internal/models"
+	"webhook-inspector/internal/query"
+	"webhook-inspector/internal/redis"
+	"webhook-inspector/internal/store"
+
+	"github.com/google/uuid"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// Batch states
+const (
+	StateRunning   = "running"
+	StateCompleted = "completed"
+	StateCancelled = "cancelled"
+)
+
+// ErrBatchNotFound is returned for unknown or expired batch IDs
+var ErrBatchNotFound = errors.New("batch not found")
+
+// ErrTooManyBatches is returned by Start when the token already has
+// config.ReplayMaxBatchesPerToken batches running
+var ErrTooManyBatches = errors.New("too many running replays")
+
+// A running batch renews its lease and checks for a cancel flag every pollInterval;
+// batches whose instance stops renewing drop out of the running count after batchLease
+const (
+	pollInterval = time.Second
+	batchLease   = 30 * time.Second
+)
+
+// BatchRequest starts a background replay of many captures against one target
+type BatchRequest struct {
+	Options
+	IDs            []string      `json:"ids,omitempty"`
+	Filter         *query.Filter `json:"filter,omitempty"`
+	Concurrency    int           `json:"concurrency,omitempty"`
+	RatePerSecond  float64       `json:"rate_per_second,omitempty"`
+	PreserveTiming bool          `json:"preserve_timing,omitempty"`
+}
+
+// Validate checks the request and applies defaults
+func (b *BatchRequest) Validate() error {
+	if err := b.Options.Validate(); err != nil {
+		return err
+	}
+	if b.Filter != nil {
+		if err := b.Filter.Compile(); err != nil {
+			return err
+		}
+	}
+	if b.Concurrency == 0 {
+		b.Concurrency = 1
+	}
+	if b.Concurrency < 1 || b.Concurrency > config.ReplayMaxConcurrency {
+		return fmt.Errorf("concurrency must be between 1 and %d", config.ReplayMaxConcurrency)
+	}
+	if b.RatePerSecond < 0 || b.RatePerSecond > config.ReplayMaxRate {
+		return fmt.Errorf("rate_per_second must be between 0 (unlimited) and %g", config.ReplayMaxRate)
+	}
+	return nil
+}
+
+// Batch is the progress of a bulk replay
+type Batch = models.ReplayBatch
+
+// Result is one line of a batch report
+type Result = models.ReplayResult
+
+// Set by Cancel and watched by the instance running the batch
+func cancelKey(token, id string) string {
+	return fmt.Sprintf("replayjob:%s:%s:cancel", token, id)
+}
+
+// Batch IDs scored by when their lease runs out, shared by every instance
+func runningKey(token string) string {
+	return "replayjobs_running:" + token
+}
+
+// Start launches a batch over captures (already in send order) and returns immediately
+func Start(s store.Store, token string, req BatchRequest, captures []models.WebhookPayload, secrets models.SigningSecrets) (*Batch, error) {
+	ctx := context.Background()
+	batch := &Batch{
+		ID:        uuid.New().String(),
+		URL:       req.URL,
+		State:     StateRunning,
+		Total:     len(captures),
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := claim(ctx, token, batch.ID); err != nil {
+		return nil, err
+	}
+	if err := s.SaveReplay(ctx, token, *batch, config.WebhookDataTTL); err != nil {
+		release(token, batch.ID)
+		return nil, err
+	}
+
+	snapshot := *batch
+	go run(s, token, batch, req, captures, secrets)
+	return &snapshot, nil
+}
+
+// Cancel flags a running batch to stop, whichever instance is running it; it reports
+// false when the batch isn't running
+func Cancel(ctx context.Context, s store.Store, token, id string) (bool, error) {
+	batch, err := Get(ctx, s, token, id)
+	if err == ErrBatchNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if batch.State != StateRunning {
+		return false, nil
+	}
+
+	// A batch whose lease ran out was lost with its instance
+	if err := redis.Client.ZScore(ctx, runningKey(token), id).Err(); err == goredis.Nil {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, redis.Client.Set(ctx, cancelKey(token, id), "1", batchLease).Err()
+}
+
+// Count the batch against the token's limit; adding before counting means two racing
+// starts can both be refused, but never both accepted over the limit
+func claim(ctx context.Context, token, id string) error {
+	now := time.Now()
+	pipe := redis.Client.TxPipeline()
+	pipe.ZRemRangeByScore(ctx, runningKey(token), "-inf", strconv.FormatInt(now.UnixMilli(), 10))
+	pipe.ZAdd(ctx, runningKey(token), goredis.Z{Score: float64(now.Add(batchLease).UnixMilli()), Member: id})
+	count := pipe.ZCard(ctx, runningKey(token))
+	pipe.Expire(ctx, runningKey(token), batchLease)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+	if count.Val() > int64(config.ReplayMaxBatchesPerToken) {
+		release(token, id)
+		return ErrTooManyBatches
+	}
+	return nil
+}
+
+// Renew the batch's lease and report whether it has been asked to stop
+func renew(ctx context.Context, token, id string) (bool, error) {
+	pipe := redis.Client.TxPipeline()
+	pipe.ZAdd(ctx, runningKey(token), goredis.Z{Score: float64(time.Now().Add(batchLease).UnixMilli()), Member: id})
+	pipe.Expire(ctx, runningKey(token), batchLease)
+	cancelled := pipe.Exists(ctx, cancelKey(token, id))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, err
+	}
+	return cancelled.Val() > 0, nil
+}
+
+func release(token, id string) {
+	ctx := context.Background()
+	pipe := redis.Client.TxPipeline()
+	pipe.ZRem(ctx, runningKey(token), id)
+	pipe.Del(ctx, cancelKey(token, id))
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("replay: failed to release batch %s: %v", id, err)
+	}
+}
+
+// Get returns the current progress of a batch
+func Get(ctx context.Context, s store.Store, token, id string) (*Batch, error) {
+	batch, err := s.GetReplay(ctx, token, id)
+	if err == store.ErrNotFound {
+		return nil, ErrBatchNotFound
+	}
+	return batch, err
+}
+
+// Report returns the per-capture results recorded so far
+func Report(ctx context.Context, s store.Store, token, id string) ([]Result, error) {
+	return s.ReplayResults(ctx, token, id)
+}
+
+func run(s store.Store, token string, batch *Batch, req BatchRequest, captures []models.WebhookPayload, secrets models.SigningSecrets) {
+	defer release(token, batch.ID)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go watch(ctx, cancel, token, batch.ID)
+
+	dispatch(ctx, req, captures,
+		func(ctx context.Context, capture models.WebhookPayload) Result {
+			return sendOne(ctx, s, token, capture, req.Options, secrets)
+		},
+		func(result Result) {
+			batch.Completed++
+			if result.Error == "" && result.StatusCode >= 200 && result.StatusCode < 300 {
+				batch.Succeeded++
+			} else {
+				batch.Failed++
+			}
+			// A purged batch stops without writing its records back
+			err := s.UpdateReplay(context.Background(), token, *batch, config.WebhookDataTTL)
+			if err == store.ErrNotFound {
+				cancel()
+				return
+			}
+			if err != nil {
+				log.Printf("replay: failed to save progress for batch %s: %v", batch.ID, err)
+			}
+			if err := s.AppendReplayResult(context.Background(), token, batch.ID, result, config.WebhookDataTTL); err != nil {
+				log.Printf("replay: failed to record result for batch %s: %v", batch.ID, err)
+			}
+		})
+
+	batch.State = StateCompleted
+	if ctx.Err() != nil {
+		batch.State = StateCancelled
+	}
+	finished := time.Now().UTC()
+	batch.FinishedAt = &finished
+	if err := s.UpdateReplay(context.Background(), token, *batch, config.WebhookDataTTL); err != nil && err != store.ErrNotFound {
+		log.Printf("replay: failed to save batch %s: %v", batch.ID, err)
+	}
+}
+
+// Purge stops a token's running batches and deletes every batch with its report
+func Purge(ctx context.Context, s store.Store, token string) error {
+	running, err := redis.Client.ZRange(ctx, runningKey(token), 0, -1).Result()
+	if err != nil {
+		return err
+	}
+	if len(running) > 0 {
+		pipe := redis.Client.Pipeline()
+		for _, id := range running {
+			pipe.Set(ctx, cancelKey(token, id), "1", batchLease)
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			return err
+		}
+	}
+	return s.DeleteReplays(ctx, token)
+}
+
+// Keep the batch's lease alive and cancel it once Cancel flags it, until ctx is done
+func watch(ctx context.Context, cancel context.CancelFunc, token, id string) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		cancelled, err := renew(ctx, token, id)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("replay: failed to renew batch %s: %v", id, err)
+			}
+			continue
+		}
+		if cancelled {
+			cancel()
+			return
+		}
+	}
+}
+
+// Send captures in order, spaced by their original timing and the rate limit, with at
+// most req.Concurrency in flight, until ctx is done. done is called with each result
+// one at a time; dispatch returns once every send has finished.
+func dispatch(ctx context.Context, req BatchRequest, captures []models.WebhookPayload, send func(context.Context, models.WebhookPayload) Result, done func(Result)) {
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, req.Concurrency)
+	)
+
+	var tick <-chan time.Time
+	if req.RatePerSecond > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / req.RatePerSecond))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	start := time.Now()
+loop:
+	for _, capture := range captures {
+		if req.PreserveTiming {
+			due := start.Add(capture.Timestamp.Sub(captures[0].Timestamp))
+			select {
+			case <-ctx.Done():
+				break loop
+			case <-time.After(time.Until(due)):
+			}
+		}
+		if tick != nil {
+			select {
+			case <-ctx.Done():
+				break loop
+			case <-tick:
+			}
+		}
+		select {
+		case <-ctx.Done():
+			break loop
+		case sem <- struct{}{}:
+		}
+
+		wg.Add(1)
+		go func(capture models.WebhookPayload) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			result := send(ctx, capture)
+
+			mu.Lock()
+			defer mu.Unlock()
+			done(result)
+		}(capture)
+	}
+	wg.Wait()
+}
+
+func sendOne(ctx context.Context, s store.Store, token string, capture models.WebhookPayload, opts Options, secrets models.SigningSecrets) Result {
+	result := Result{
+		CaptureID:         capture.ID,
+		OriginalTimestamp: capture.Timestamp,
+		SentAt:            time.Now().UTC(),
+	}
+
+	edited, err := Prepare(capture, opts, secrets, time.Now())
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	attempt := Send(ctx, edited, opts.URL)
+	if err := forward.RecordAttempt(context.Background(), s, token, capture.ID, attempt); err != nil {
+		log.Printf("replay: failed to record attempt for capture %s: %v", capture.ID, err)
+	}
+
+	result.StatusCode = attempt.StatusCode
+	result.LatencyMs = attempt.LatencyMs
+	result.Error = attempt.Error
+	return result
+}
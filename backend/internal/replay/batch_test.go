@@ -0,0 +1,191 @@
+package replay
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"webhook-inspector/internal/config"
+	"webhook-inspector/internal/models"
+	"webhook-inspector/internal/redis"
+	"webhook-inspector/internal/store"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+func captures(n int, gap time.Duration) []models.WebhookPayload {
+	start := time.Now()
+	out := make([]models.WebhookPayload, n)
+	for i := range out {
+		out[i] = models.WebhookPayload{ID: fmt.Sprintf("c%d", i), Timestamp: start.Add(time.Duration(i) * gap)}
+	}
+	return out
+}
+
+// Records when each capture was sent, in order
+type recorder struct {
+	mu      sync.Mutex
+	ids     []string
+	sentAt  []time.Duration
+	start   time.Time
+	delay   time.Duration
+	active  int
+	peak    int
+	results int
+}
+
+func newRecorder(delay time.Duration) *recorder {
+	return &recorder{start: time.Now(), delay: delay}
+}
+
+func (r *recorder) send(ctx context.Context, capture models.WebhookPayload) Result {
+	r.mu.Lock()
+	r.ids = append(r.ids, capture.ID)
+	r.sentAt = append(r.sentAt, time.Since(r.start))
+	r.active++
+	r.peak = max(r.peak, r.active)
+	r.mu.Unlock()
+
+	time.Sleep(r.delay)
+
+	r.mu.Lock()
+	r.active--
+	r.mu.Unlock()
+	return Result{CaptureID: capture.ID, StatusCode: 200}
+}
+
+func (r *recorder) done(Result) {
+	r.results++
+}
+
+func TestDispatchOrder(t *testing.T) {
+	rec := newRecorder(0)
+	dispatch(context.Background(), BatchRequest{Concurrency: 1}, captures(5, time.Second), rec.send, rec.done)
+
+	if fmt.Sprint(rec.ids) != "[c0 c1 c2 c3 c4]" {
+		t.Errorf("expected captures in original order, got %v", rec.ids)
+	}
+	if rec.results != 5 {
+		t.Errorf("expected 5 results, got %d", rec.results)
+	}
+}
+
+func TestDispatchRateLimit(t *testing.T) {
+	rec := newRecorder(0)
+	dispatch(context.Background(), BatchRequest{Concurrency: 4, RatePerSecond: 20}, captures(5, 0), rec.send, rec.done)
+
+	// One send per 50ms tick
+	for i := 1; i < len(rec.sentAt); i++ {
+		if gap := rec.sentAt[i] - rec.sentAt[i-1]; gap < 40*time.Millisecond {
+			t.Errorf("sends %d and %d were %s apart, expected ~50ms", i-1, i, gap)
+		}
+	}
+}
+
+func TestDispatchConcurrency(t *testing.T) {
+	rec := newRecorder(30 * time.Millisecond)
+	dispatch(context.Background(), BatchRequest{Concurrency: 3}, captures(9, 0), rec.send, rec.done)
+
+	if rec.peak != 3 {
+		t.Errorf("expected 3 sends in flight at most, got %d", rec.peak)
+	}
+	if rec.results != 9 {
+		t.Errorf("expected 9 results, got %d", rec.results)
+	}
+}
+
+func TestDispatchPreserveTiming(t *testing.T) {
+	rec := newRecorder(0)
+	dispatch(context.Background(), BatchRequest{Concurrency: 1, PreserveTiming: true}, captures(3, 100*time.Millisecond), rec.send, rec.done)
+
+	for i, at := range rec.sentAt {
+		if want := time.Duration(i) * 100 * time.Millisecond; at < want-10*time.Millisecond || at > want+80*time.Millisecond {
+			t.Errorf("capture %d sent at %s, expected ~%s", i, at, want)
+		}
+	}
+}
+
+func TestDispatchCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	rec := newRecorder(0)
+	done := func(result Result) {
+		rec.done(result)
+		if rec.results == 2 {
+			cancel()
+		}
+	}
+	dispatch(ctx, BatchRequest{Concurrency: 1, RatePerSecond: 20}, captures(10, 0), rec.send, done)
+
+	if rec.results < 2 || rec.results > 3 {
+		t.Errorf("expected dispatch to stop right after cancel, got %d results", rec.results)
+	}
+}
+
+func TestBatchRedis(t *testing.T) {
+	addr := os.Getenv("REDIS_TEST_ADDR")
+	if addr == "" {
+		t.Skip("REDIS_TEST_ADDR not set")
+	}
+	ctx := context.Background()
+	redis.Client = goredis.NewClient(&goredis.Options{Addr: addr, DB: 15})
+	t.Cleanup(func() {
+		redis.Client.Close()
+		redis.Client = nil
+	})
+	if err := redis.Client.FlushDB(ctx).Err(); err != nil {
+		t.Fatal(err)
+	}
+	s := store.NewRedis(redis.Client)
+
+	limit := config.ReplayMaxBatchesPerToken
+	config.ReplayMaxBatchesPerToken = 1
+	t.Cleanup(func() { config.ReplayMaxBatchesPerToken = limit })
+
+	// Spread out so the batch is still running when cancelled
+	req := BatchRequest{Options: Options{URL: "http://example.invalid/in"}, Concurrency: 1, PreserveTiming: true}
+	slow := captures(3, time.Hour)
+
+	first, err := Start(s, "batch", req, slow, models.SigningSecrets{})
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if _, err := Start(s, "batch", req, slow, models.SigningSecrets{}); err != ErrTooManyBatches {
+		t.Fatalf("expected a second batch to be refused, got %v", err)
+	}
+
+	if ok, err := Cancel(ctx, s, "batch", first.ID); !ok || err != nil {
+		t.Fatalf("expected the running batch to be cancelled, got %v %v", ok, err)
+	}
+	deadline := time.Now().Add(5 * pollInterval)
+	for {
+		batch, err := Get(ctx, s, "batch", first.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if batch.State == StateCancelled {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the batch to stop, still %s", batch.State)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	if ok, _ := Cancel(ctx, s, "batch", first.ID); ok {
+		t.Error("expected cancelling a finished batch to report false")
+	}
+	// The finished batch releases its slot just after saving its state
+	for {
+		_, err := Start(s, "batch", req, captures(1, 0), models.SigningSecrets{})
+		if err == nil {
+			break
+		}
+		if err != ErrTooManyBatches || time.Now().After(deadline) {
+			t.Fatalf("expected a batch to start once the first finished, got %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,87 @@
+package replay
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"webhook-inspector/internal/forward"
+	"webhook-inspector/internal/jsonpatch"
+	"webhook-inspector/internal/models"
+	"webhook-inspector/internal/outbound"
+	"webhook-inspector/internal/signing"
+
+	"github.com/google/uuid"
+)
+
+// Options describes where a stored capture is sent and how it is edited first
+type Options struct {
+	URL           string                `json:"url"`
+	SetHeaders    map[string]string     `json:"set_headers,omitempty"`
+	RemoveHeaders []string              `json:"remove_headers,omitempty"`
+	Patch         []jsonpatch.Operation `json:"patch,omitempty"`
+	// Re-sign with a fresh timestamp using the endpoint's secret for this scheme
+	Sign string `json:"sign,omitempty"`
+}
+
+// Validate checks the options before any capture is touched
+func (o Options) Validate() error {
+	if err := outbound.CheckURL(o.URL); err != nil {
+		return err
+	}
+	if o.Sign != "" && !signing.Valid(o.Sign) {
+		return fmt.Errorf("sign must be one of %v", signing.Schemes)
+	}
+	return nil
+}
+
+// Prepare returns a copy of the capture with the edits and signature applied
+func Prepare(payload models.WebhookPayload, opts Options, secrets models.SigningSecrets, now time.Time) (models.WebhookPayload, error) {
+	edited := payload
+	edited.Headers = make(map[string][]string, len(payload.Headers))
+	for name, values := range payload.Headers {
+		edited.Headers[http.CanonicalHeaderKey(name)] = append([]string(nil), values...)
+	}
+	header := http.Header(edited.Headers)
+
+	if len(opts.Patch) > 0 {
+		patched, err := jsonpatch.Apply([]byte(payload.Body), opts.Patch)
+		if err != nil {
+			return edited, fmt.Errorf("patch: %w", err)
+		}
+		edited.Body = string(patched)
+	}
+
+	for _, name := range opts.RemoveHeaders {
+		header.Del(name)
+	}
+	for name, value := range opts.SetHeaders {
+		header.Set(name, value)
+	}
+
+	if opts.Sign != "" {
+		msg := signing.Message{ID: "msg_" + uuid.New().String(), Timestamp: now, Body: []byte(edited.Body), URL: opts.URL}
+		if err := signing.Sign(opts.Sign, signing.SecretFor(secrets, opts.Sign), header, msg); err != nil {
+			return edited, err
+		}
+	}
+	return edited, nil
+}
+
+// Send delivers a prepared capture and reports the target's answer
+func Send(ctx context.Context, payload models.WebhookPayload, target string) models.DeliveryAttempt {
+	attempt := models.DeliveryAttempt{
+		Kind:      "replay",
+		URL:       target,
+		Attempt:   1,
+		Timestamp: time.Now().UTC(),
+	}
+
+	req, err := forward.NewRequest(ctx, payload, target, nil)
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	return forward.Send(outbound.Client, req, attempt)
+}
@@ -0,0 +1,103 @@
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"webhook-inspector/internal/jsonpatch"
+	"webhook-inspector/internal/models"
+	"webhook-inspector/internal/outbound"
+)
+
+func capture() models.WebhookPayload {
+	return models.WebhookPayload{
+		ID:      "c1",
+		Method:  "POST",
+		Headers: map[string][]string{"content-type": {"application/json"}, "X-Trace": {"a", "b"}, "X-Drop": {"1"}},
+		Body:    `{"amount":100,"currency":"usd"}`,
+	}
+}
+
+func TestPrepareHeaders(t *testing.T) {
+	original := capture()
+	opts := Options{
+		URL:           "https://example.com/in",
+		SetHeaders:    map[string]string{"x-trace": "c", "X-New": "yes"},
+		RemoveHeaders: []string{"x-drop"},
+	}
+	got, err := Prepare(original, opts, models.SigningSecrets{}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header := http.Header(got.Headers)
+	if v := header.Values("X-Trace"); len(v) != 1 || v[0] != "c" {
+		t.Errorf("X-Trace = %v", v)
+	}
+	if header.Get("X-New") != "yes" || header.Get("Content-Type") != "application/json" {
+		t.Errorf("headers = %v", got.Headers)
+	}
+	if _, ok := got.Headers["X-Drop"]; ok {
+		t.Errorf("X-Drop survived: %v", got.Headers)
+	}
+	if len(original.Headers["X-Trace"]) != 2 || original.Headers["X-Drop"] == nil {
+		t.Errorf("capture was modified: %v", original.Headers)
+	}
+}
+
+func TestPreparePatch(t *testing.T) {
+	opts := Options{URL: "https://example.com/in", Patch: []jsonpatch.Operation{
+		{Op: "replace", Path: "/amount", Value: json.RawMessage(`250`)},
+		{Op: "remove", Path: "/currency"},
+	}}
+	got, err := Prepare(capture(), opts, models.SigningSecrets{}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Body != `{"amount":250}` {
+		t.Errorf("body = %s", got.Body)
+	}
+
+	opts.Patch = []jsonpatch.Operation{{Op: "remove", Path: "/missing"}}
+	if _, err := Prepare(capture(), opts, models.SigningSecrets{}, time.Now()); err == nil || !strings.HasPrefix(err.Error(), "patch:") {
+		t.Errorf("bad patch: err = %v", err)
+	}
+}
+
+func TestPrepareSign(t *testing.T) {
+	opts := Options{URL: "https://example.com/in", Sign: "github"}
+	got, err := Prepare(capture(), opts, models.SigningSecrets{GitHub: "s3cret"}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(http.Header(got.Headers).Get("X-Hub-Signature-256"), "sha256=") {
+		t.Errorf("headers = %v", got.Headers)
+	}
+	if _, err := Prepare(capture(), opts, models.SigningSecrets{}, time.Now()); err == nil {
+		t.Error("expected signing without a secret to fail")
+	}
+}
+
+func TestInternalTargetsRefused(t *testing.T) {
+	for _, target := range []string{"http://127.0.0.1:8080/", "http://localhost/", "http://169.254.169.254/latest/meta-data/", "http://192.168.0.10/"} {
+		if err := (Options{URL: target}).Validate(); err == nil {
+			t.Errorf("Validate(%s) accepted", target)
+		}
+	}
+
+	// A name that only resolves to loopback is refused when dialled
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("internal server was reached")
+	}))
+	defer srv.Close()
+	target := strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)
+	attempt := Send(context.Background(), capture(), target)
+	if attempt.StatusCode != 0 || !strings.Contains(attempt.Error, outbound.ErrForbiddenAddress.Error()) {
+		t.Errorf("attempt = %+v", attempt)
+	}
+}
@@ -0,0 +1,453 @@
+// Package rules evaluates per-endpoint rules against new captures.
+package rules
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"webhook-inspector/internal/config"
+	"webhook-inspector/internal/forward"
+	"webhook-inspector/internal/jsonpatch"
+	"webhook-inspector/internal/models"
+	"webhook-inspector/internal/outbound"
+	"webhook-inspector/internal/usertmpl"
+
+	"github.com/google/uuid"
+)
+
+// Condition fields
+const (
+	FieldMethod    = "method"
+	FieldHeader    = "header"
+	FieldProvider  = "provider"
+	FieldEventType = "event_type"
+	FieldJSON      = "json"
+)
+
+// Condition operators; eq is the default
+const (
+	OpEq       = "eq"
+	OpNeq      = "neq"
+	OpContains = "contains"
+	OpPrefix   = "prefix"
+	OpSuffix   = "suffix"
+	OpRegex    = "regex"
+	OpExists   = "exists"
+	OpMissing  = "missing"
+	OpGt       = "gt"
+	OpLt       = "lt"
+)
+
+// Action types
+const (
+	ActionTag     = "tag"
+	ActionForward = "forward"
+	ActionNotify  = "notify"
+	ActionPin     = "pin"
+	ActionRespond = "respond"
+)
+
+const defaultNotifyText = `Webhook {{if .EventType}}{{.EventType}} {{end}}received{{if .Provider}} from {{.Provider}}{{end}} ({{.Method}}, capture {{.ID}})`
+
+// Headers a respond action may not set on the inspector's own responses; Location and
+// Refresh would turn the inspector into an open redirect
+var forbiddenResponseHeaders = map[string]bool{
+	"Set-Cookie": true, "Content-Length": true, "Transfer-Encoding": true, "Connection": true,
+	"Content-Security-Policy": true, "X-Content-Type-Options": true, "X-Ratelimit-Remaining": true,
+	"Location": true, "Refresh": true,
+}
+
+// Caps the regexes kept compiled; the cache starts over once it is full
+const maxCompiledRegexes = 10000
+
+// Regexes are compiled when rules are validated so Evaluate doesn't compile them for
+// every capture. Rules saved before a restart are compiled on first use.
+var compiledRegexes = struct {
+	sync.RWMutex
+	m map[string]*regexp.Regexp
+}{m: map[string]*regexp.Regexp{}}
+
+// Dispatch is an action that runs after the capture has been stored
+type Dispatch struct {
+	RuleID string
+	Action models.RuleAction
+}
+
+// Outcome collects what the ingest path has to do for the rules that fired
+type Outcome struct {
+	Fired []models.RuleFiring
+	Tags  []string
+	Pin   bool
+	// First respond action of the fired rules, if any
+	Response      *models.RuleAction
+	Forwards      []Dispatch
+	Notifications []Dispatch
+}
+
+// Validate checks rules before they are saved and fills in generated IDs.
+// URLs are checked by the caller together with the other outbound targets.
+func Validate(rules []models.Rule) error {
+	if len(rules) > config.RulesMaxPerToken {
+		return fmt.Errorf("at most %d rules are allowed", config.RulesMaxPerToken)
+	}
+
+	for i := range rules {
+		rule := &rules[i]
+		if rule.ID == "" {
+			rule.ID = uuid.New().String()
+		}
+		if rule.Match != "" && rule.Match != "all" && rule.Match != "any" {
+			return fmt.Errorf("rule %d: match must be \"all\" or \"any\"", i)
+		}
+		if len(rule.Actions) == 0 {
+			return fmt.Errorf("rule %d: at least one action is required", i)
+		}
+		for j, cond := range rule.Conditions {
+			if err := validateCondition(cond); err != nil {
+				return fmt.Errorf("rule %d condition %d: %w", i, j, err)
+			}
+		}
+		for j, action := range rule.Actions {
+			if err := validateAction(action); err != nil {
+				return fmt.Errorf("rule %d action %d: %w", i, j, err)
+			}
+		}
+	}
+	return nil
+}
+
+func validateCondition(cond models.RuleCondition) error {
+	switch cond.Field {
+	case FieldMethod, FieldProvider, FieldEventType:
+	case FieldHeader:
+		if cond.Name == "" {
+			return fmt.Errorf("header conditions need a name")
+		}
+	case FieldJSON:
+		if _, err := jsonpatch.ParsePointer(cond.Path); err != nil || cond.Path == "" {
+			return fmt.Errorf("json conditions need a JSON pointer path")
+		}
+	default:
+		return fmt.Errorf("unknown field %q", cond.Field)
+	}
+
+	switch cond.Op {
+	case "", OpEq, OpNeq, OpContains, OpPrefix, OpSuffix, OpExists, OpMissing:
+	case OpRegex:
+		if _, err := compileRegex(cond.Value); err != nil {
+			return fmt.Errorf("invalid regex: %w", err)
+		}
+	case OpGt, OpLt:
+		if _, err := strconv.ParseFloat(cond.Value, 64); err != nil {
+			return fmt.Errorf("%s needs a numeric value", cond.Op)
+		}
+	default:
+		return fmt.Errorf("unknown op %q", cond.Op)
+	}
+	return nil
+}
+
+func validateAction(action models.RuleAction) error {
+	switch action.Type {
+	case ActionTag:
+		if strings.TrimSpace(action.Tag) == "" {
+			return fmt.Errorf("tag actions need a tag")
+		}
+	case ActionForward:
+		if action.URL == "" {
+			return fmt.Errorf("forward actions need a url")
+		}
+		if err := outbound.CheckURL(action.URL); err != nil {
+			return err
+		}
+	case ActionNotify:
+		if action.URL == "" {
+			return fmt.Errorf("notify actions need a url")
+		}
+		if err := outbound.CheckURL(action.URL); err != nil {
+			return err
+		}
+		if action.Text != "" {
+			if _, err := usertmpl.Parse("notify", action.Text, nil); err != nil {
+				return fmt.Errorf("invalid text template: %w", err)
+			}
+		}
+	case ActionPin:
+	case ActionRespond:
+		if action.Status != 0 && (action.Status < 200 || action.Status > 599) {
+			return fmt.Errorf("status must be between 200 and 599")
+		}
+		if action.Status >= 300 && action.Status < 400 {
+			return fmt.Errorf("redirect statuses cannot be returned")
+		}
+		for name := range action.Headers {
+			if forbiddenResponseHeaders[http.CanonicalHeaderKey(name)] {
+				return fmt.Errorf("header %s cannot be set", name)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown action %q", action.Type)
+	}
+	return nil
+}
+
+// Evaluate runs every enabled rule against a capture, in order
+func Evaluate(rules []models.Rule, p models.WebhookPayload) Outcome {
+	var out Outcome
+	if len(rules) == 0 {
+		return out
+	}
+
+	doc := decodeBody(p)
+	for _, rule := range rules {
+		if rule.Disabled || !matches(rule, p, doc) {
+			continue
+		}
+
+		firing := models.RuleFiring{RuleID: rule.ID, Name: rule.Name}
+		for _, action := range rule.Actions {
+			firing.Actions = append(firing.Actions, action.Type)
+			switch action.Type {
+			case ActionTag:
+				out.Tags = appendUnique(out.Tags, strings.TrimSpace(action.Tag))
+			case ActionPin:
+				out.Pin = true
+			case ActionRespond:
+				if out.Response == nil {
+					action := action
+					out.Response = &action
+				}
+			case ActionForward:
+				out.Forwards = append(out.Forwards, Dispatch{RuleID: rule.ID, Action: action})
+			case ActionNotify:
+				out.Notifications = append(out.Notifications, Dispatch{RuleID: rule.ID, Action: action})
+			}
+		}
+		out.Fired = append(out.Fired, firing)
+
+		if rule.Stop {
+			break
+		}
+	}
+	return out
+}
+
+func matches(rule models.Rule, p models.WebhookPayload, doc interface{}) bool {
+	matchAny := rule.Match == "any"
+	for _, cond := range rule.Conditions {
+		ok := test(cond, p, doc)
+		if matchAny && ok {
+			return true
+		}
+		if !matchAny && !ok {
+			return false
+		}
+	}
+	// No conditions matches everything
+	return !matchAny || len(rule.Conditions) == 0
+}
+
+func test(cond models.RuleCondition, p models.WebhookPayload, doc interface{}) bool {
+	var (
+		value  string
+		exists bool
+	)
+	switch cond.Field {
+	case FieldMethod:
+		value, exists = p.Method, true
+	case FieldProvider:
+		value, exists = p.Provider, p.Provider != ""
+	case FieldEventType:
+		value, exists = p.EventType, p.EventType != ""
+	case FieldHeader:
+		values := http.Header(p.Headers).Values(cond.Name)
+		if len(values) > 0 {
+			value, exists = values[0], true
+		}
+	case FieldJSON:
+		if v, err := lookup(doc, cond.Path); err == nil {
+			value, exists = stringify(v), true
+		}
+	}
+
+	switch cond.Op {
+	case OpExists:
+		return exists
+	case OpMissing:
+		return !exists
+	case OpNeq:
+		return value != cond.Value
+	}
+	if !exists {
+		return false
+	}
+
+	switch cond.Op {
+	case "", OpEq:
+		return value == cond.Value
+	case OpContains:
+		return strings.Contains(value, cond.Value)
+	case OpPrefix:
+		return strings.HasPrefix(value, cond.Value)
+	case OpSuffix:
+		return strings.HasSuffix(value, cond.Value)
+	case OpRegex:
+		re, err := compileRegex(cond.Value)
+		return err == nil && re.MatchString(value)
+	case OpGt, OpLt:
+		have, err1 := strconv.ParseFloat(value, 64)
+		want, err2 := strconv.ParseFloat(cond.Value, 64)
+		if err1 != nil || err2 != nil {
+			return false
+		}
+		if cond.Op == OpGt {
+			return have > want
+		}
+		return have < want
+	}
+	return false
+}
+
+func compileRegex(pattern string) (*regexp.Regexp, error) {
+	compiledRegexes.RLock()
+	re, ok := compiledRegexes.m[pattern]
+	compiledRegexes.RUnlock()
+	if ok {
+		return re, nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	compiledRegexes.Lock()
+	if len(compiledRegexes.m) >= maxCompiledRegexes {
+		compiledRegexes.m = map[string]*regexp.Regexp{}
+	}
+	compiledRegexes.m[pattern] = re
+	compiledRegexes.Unlock()
+	return re, nil
+}
+
+// JSON bodies are decoded as-is; form bodies become an object of their first values
+func decodeBody(p models.WebhookPayload) interface{} {
+	decoder := json.NewDecoder(strings.NewReader(p.Body))
+	decoder.UseNumber()
+
+	var doc interface{}
+	if err := decoder.Decode(&doc); err == nil {
+		return doc
+	}
+
+	form, err := url.ParseQuery(p.Body)
+	if err != nil {
+		return nil
+	}
+	fields := make(map[string]interface{}, len(form))
+	for k := range form {
+		fields[k] = form.Get(k)
+	}
+	return fields
+}
+
+func lookup(doc interface{}, pointer string) (interface{}, error) {
+	tokens, err := jsonpatch.ParsePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+
+	current := doc
+	for _, tok := range tokens {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			v, ok := node[tok]
+			if !ok {
+				return nil, fmt.Errorf("path not found")
+			}
+			current = v
+		case []interface{}:
+			i, err := strconv.Atoi(tok)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, fmt.Errorf("path not found")
+			}
+			current = node[i]
+		default:
+			return nil, fmt.Errorf("path not found")
+		}
+	}
+	return current, nil
+}
+
+// Scalars compare by their plain text, everything else by its JSON
+func stringify(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case json.Number:
+		return t.String()
+	case nil:
+		return "null"
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, existing := range list {
+		if existing == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
+
+// Notify posts a chat message about a capture; the body works for both Slack and Discord webhooks
+func Notify(ctx context.Context, p models.WebhookPayload, d Dispatch) models.DeliveryAttempt {
+	attempt := models.DeliveryAttempt{
+		Kind:      "notify",
+		TargetID:  "rule:" + d.RuleID,
+		URL:       d.Action.URL,
+		Attempt:   1,
+		Timestamp: time.Now().UTC(),
+	}
+
+	text, err := renderText(ctx, d.Action.Text, p)
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	body, err := json.Marshal(map[string]string{"text": text, "content": text})
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.Action.URL, bytes.NewReader(body))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return forward.Send(outbound.Client, req, attempt)
+}
+
+// Bounded like transform templates, by usertmpl's deadline and output cap
+func renderText(ctx context.Context, text string, p models.WebhookPayload) (string, error) {
+	if text == "" {
+		text = defaultNotifyText
+	}
+	tmpl, err := usertmpl.Parse("notify", text, nil)
+	if err != nil {
+		return "", err
+	}
+	return usertmpl.Execute(ctx, tmpl, p)
+}
@@ -0,0 +1,183 @@
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"webhook-inspector/internal/models"
+	"webhook-inspector/internal/outbound"
+)
+
+func stripeFailure() models.WebhookPayload {
+	return models.WebhookPayload{
+		ID:        "c1",
+		Method:    "POST",
+		Headers:   map[string][]string{"Content-Type": {"application/json"}, "Stripe-Signature": {"t=1,v1=abc"}},
+		Body:      `{"type":"payment_intent.payment_failed","data":{"object":{"amount":2500,"livemode":false,"last_payment_error":{"code":"card_declined"}}}}`,
+		Provider:  "stripe",
+		EventType: "payment_intent.payment_failed",
+	}
+}
+
+func TestEvaluateConditions(t *testing.T) {
+	tests := []struct {
+		name  string
+		conds []models.RuleCondition
+		match string
+		want  bool
+	}{
+		{"event type", []models.RuleCondition{{Field: FieldEventType, Value: "payment_intent.payment_failed"}}, "", true},
+		{"provider and method", []models.RuleCondition{{Field: FieldProvider, Value: "stripe"}, {Field: FieldMethod, Value: "POST"}}, "", true},
+		{"all fails on one", []models.RuleCondition{{Field: FieldProvider, Value: "stripe"}, {Field: FieldMethod, Value: "PUT"}}, "", false},
+		{"any passes on one", []models.RuleCondition{{Field: FieldProvider, Value: "github"}, {Field: FieldMethod, Value: "POST"}}, "any", true},
+		{"header prefix", []models.RuleCondition{{Field: FieldHeader, Name: "stripe-signature", Op: OpPrefix, Value: "t="}}, "", true},
+		{"header missing", []models.RuleCondition{{Field: FieldHeader, Name: "X-GitHub-Event", Op: OpMissing}}, "", true},
+		{"json string", []models.RuleCondition{{Field: FieldJSON, Path: "/data/object/last_payment_error/code", Value: "card_declined"}}, "", true},
+		{"json number gt", []models.RuleCondition{{Field: FieldJSON, Path: "/data/object/amount", Op: OpGt, Value: "1000"}}, "", true},
+		{"json number lt", []models.RuleCondition{{Field: FieldJSON, Path: "/data/object/amount", Op: OpLt, Value: "1000"}}, "", false},
+		{"json bool", []models.RuleCondition{{Field: FieldJSON, Path: "/data/object/livemode", Value: "false"}}, "", true},
+		{"json missing path", []models.RuleCondition{{Field: FieldJSON, Path: "/data/nope", Value: "x"}}, "", false},
+		{"json exists", []models.RuleCondition{{Field: FieldJSON, Path: "/data/object", Op: OpExists}}, "", true},
+		{"regex", []models.RuleCondition{{Field: FieldEventType, Op: OpRegex, Value: `^payment_intent\.(payment_failed|canceled)$`}}, "", true},
+		{"neq", []models.RuleCondition{{Field: FieldEventType, Op: OpNeq, Value: "payment_intent.succeeded"}}, "", true},
+		{"no conditions", nil, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := models.Rule{ID: "r", Match: tt.match, Conditions: tt.conds, Actions: []models.RuleAction{{Type: ActionPin}}}
+			out := Evaluate([]models.Rule{rule}, stripeFailure())
+			if got := len(out.Fired) == 1; got != tt.want {
+				t.Errorf("fired = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateFormBody(t *testing.T) {
+	p := models.WebhookPayload{Method: "POST", Body: "From=%2B15551234567&Body=STOP"}
+	rule := models.Rule{
+		ID:         "r",
+		Conditions: []models.RuleCondition{{Field: FieldJSON, Path: "/Body", Value: "STOP"}},
+		Actions:    []models.RuleAction{{Type: ActionTag, Tag: "opt-out"}},
+	}
+	if out := Evaluate([]models.Rule{rule}, p); !reflect.DeepEqual(out.Tags, []string{"opt-out"}) {
+		t.Errorf("tags = %v", out.Tags)
+	}
+}
+
+func TestEvaluateOutcome(t *testing.T) {
+	failed := []models.RuleCondition{{Field: FieldEventType, Value: "payment_intent.payment_failed"}}
+	rules := []models.Rule{
+		{ID: "disabled", Disabled: true, Actions: []models.RuleAction{{Type: ActionTag, Tag: "never"}}},
+		{ID: "r1", Name: "payment failed", Conditions: failed, Actions: []models.RuleAction{
+			{Type: ActionTag, Tag: "payments"},
+			{Type: ActionTag, Tag: "failed"},
+			{Type: ActionNotify, URL: "https://hooks.slack.com/services/x"},
+			{Type: ActionRespond, Status: 202, Body: "first"},
+		}},
+		{ID: "r2", Conditions: failed, Stop: true, Actions: []models.RuleAction{
+			{Type: ActionTag, Tag: "payments"},
+			{Type: ActionForward, URL: "https://billing.example.com/hooks"},
+			{Type: ActionPin},
+			{Type: ActionRespond, Status: 500, Body: "second"},
+		}},
+		{ID: "after-stop", Actions: []models.RuleAction{{Type: ActionTag, Tag: "never"}}},
+	}
+
+	out := Evaluate(rules, stripeFailure())
+
+	if !reflect.DeepEqual(out.Tags, []string{"payments", "failed"}) {
+		t.Errorf("tags = %v", out.Tags)
+	}
+	if !out.Pin {
+		t.Error("expected pin")
+	}
+	if out.Response == nil || out.Response.Body != "first" {
+		t.Errorf("expected the first respond action to win, got %+v", out.Response)
+	}
+	if len(out.Forwards) != 1 || out.Forwards[0].RuleID != "r2" || len(out.Notifications) != 1 || out.Notifications[0].RuleID != "r1" {
+		t.Errorf("unexpected dispatches: %+v %+v", out.Forwards, out.Notifications)
+	}
+	want := []models.RuleFiring{
+		{RuleID: "r1", Name: "payment failed", Actions: []string{"tag", "tag", "notify", "respond"}},
+		{RuleID: "r2", Actions: []string{"tag", "forward", "pin", "respond"}},
+	}
+	if !reflect.DeepEqual(out.Fired, want) {
+		t.Errorf("fired = %+v, want %+v", out.Fired, want)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	bad := []models.Rule{
+		{Actions: nil},
+		{Match: "some", Actions: []models.RuleAction{{Type: ActionPin}}},
+		{Conditions: []models.RuleCondition{{Field: "body"}}, Actions: []models.RuleAction{{Type: ActionPin}}},
+		{Conditions: []models.RuleCondition{{Field: FieldHeader}}, Actions: []models.RuleAction{{Type: ActionPin}}},
+		{Conditions: []models.RuleCondition{{Field: FieldJSON, Path: "type"}}, Actions: []models.RuleAction{{Type: ActionPin}}},
+		{Conditions: []models.RuleCondition{{Field: FieldMethod, Op: OpRegex, Value: "("}}, Actions: []models.RuleAction{{Type: ActionPin}}},
+		{Conditions: []models.RuleCondition{{Field: FieldMethod, Op: OpGt, Value: "x"}}, Actions: []models.RuleAction{{Type: ActionPin}}},
+		{Actions: []models.RuleAction{{Type: ActionTag}}},
+		{Actions: []models.RuleAction{{Type: ActionNotify}}},
+		{Actions: []models.RuleAction{{Type: ActionRespond, Status: 99}}},
+		{Actions: []models.RuleAction{{Type: ActionRespond, Headers: map[string]string{"set-cookie": "a=b"}}}},
+		{Actions: []models.RuleAction{{Type: ActionRespond, Status: 302}}},
+		{Actions: []models.RuleAction{{Type: ActionRespond, Headers: map[string]string{"location": "https://evil.example"}}}},
+		{Actions: []models.RuleAction{{Type: "delete"}}},
+	}
+	for i, rule := range bad {
+		if err := Validate([]models.Rule{rule}); err == nil {
+			t.Errorf("case %d: expected %+v to be rejected", i, rule)
+		}
+	}
+
+	good := []models.Rule{{
+		Conditions: []models.RuleCondition{{Field: FieldJSON, Path: "/type", Op: OpPrefix, Value: "payment_intent."}},
+		Actions:    []models.RuleAction{{Type: ActionRespond, Status: 202, Headers: map[string]string{"X-Handled": "yes"}}},
+	}}
+	if err := Validate(good); err != nil {
+		t.Fatal(err)
+	}
+	if good[0].ID == "" {
+		t.Error("expected a generated ID")
+	}
+}
+
+func TestNotify(t *testing.T) {
+	outbound.AllowPrivate = true
+	t.Cleanup(func() { outbound.AllowPrivate = false })
+
+	var got map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		json.Unmarshal(data, &got)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	attempt := Notify(context.Background(), stripeFailure(), Dispatch{RuleID: "r1", Action: models.RuleAction{Type: ActionNotify, URL: srv.URL}})
+	if attempt.Error != "" || attempt.StatusCode != http.StatusNoContent || attempt.Kind != "notify" || attempt.TargetID != "rule:r1" {
+		t.Fatalf("unexpected attempt: %+v", attempt)
+	}
+	want := "Webhook payment_intent.payment_failed received from stripe (POST, capture c1)"
+	if got["text"] != want || got["content"] != want {
+		t.Errorf("message = %v, want %q", got, want)
+	}
+
+	attempt = Notify(context.Background(), stripeFailure(), Dispatch{RuleID: "r1", Action: models.RuleAction{URL: srv.URL, Text: "{{.Provider | printf \"%q\"}} alert"}})
+	if attempt.Error != "" || !strings.HasPrefix(got["text"], `"stripe" alert`) {
+		t.Errorf("custom text = %q (%s)", got["text"], attempt.Error)
+	}
+
+	got = nil
+	attempt = Notify(context.Background(), stripeFailure(), Dispatch{RuleID: "r1", Action: models.RuleAction{URL: srv.URL, Text: "{{range 100000000000}}{{end}}"}})
+	if attempt.Error == "" || got != nil {
+		t.Errorf("expected a runaway template to be stopped before sending, got %+v", attempt)
+	}
+}
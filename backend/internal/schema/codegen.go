@@ -0,0 +1,449 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Format is an output format for an inferred schema
+type Format struct {
+	Name        string
+	ContentType string
+	Extension   string
+	render      func(s *Schema) ([]byte, error)
+}
+
+var formats = map[string]Format{
+	"json-schema": {"json-schema", "application/schema+json", "schema.json", renderJSONSchema},
+	"go":          {"go", "text/x-go; charset=utf-8", "go", renderGo},
+	"typescript":  {"typescript", "text/x-typescript; charset=utf-8", "ts", renderTypeScript},
+	"python":      {"python", "text/x-python; charset=utf-8", "py", renderPython},
+}
+
+// Names lists the supported formats
+func Names() []string {
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Lookup returns the format with the given name
+func Lookup(name string) (Format, bool) {
+	f, ok := formats[name]
+	return f, ok
+}
+
+// Render writes the schema, or the types generated from it, in this format
+func (f Format) Render(s *Schema) ([]byte, error) {
+	return f.render(s)
+}
+
+func renderJSONSchema(s *Schema) ([]byte, error) {
+	data, err := json.MarshalIndent(s, "", "  ")
+	return append(data, '\n'), err
+}
+
+// Language-neutral type reference
+type ref struct {
+	kind  string // string, date-time, integer, number, boolean, any, map, struct, array or union
+	name  string // struct name
+	elem  *ref   // array element
+	union []ref
+}
+
+type fieldDef struct {
+	key      string
+	typ      ref
+	required bool
+	nullable bool
+}
+
+type typeDef struct {
+	name   string
+	fields []fieldDef
+}
+
+// Types to generate, the root first, with unique names
+type model struct {
+	root  ref
+	defs  []typeDef
+	names map[string]bool
+}
+
+func build(s *Schema) *model {
+	m := &model{names: make(map[string]bool)}
+	m.root = m.resolve(s, s.Title)
+	return m
+}
+
+func (m *model) unique(name string) string {
+	candidate := name
+	for i := 2; m.names[candidate]; i++ {
+		candidate = fmt.Sprintf("%s%d", name, i)
+	}
+	m.names[candidate] = true
+	return candidate
+}
+
+func (m *model) resolve(s *Schema, name string) ref {
+	var kinds []ref
+	for _, t := range s.Type {
+		switch t {
+		case "null":
+		case "object":
+			if len(s.Properties) == 0 {
+				kinds = append(kinds, ref{kind: "map"})
+				continue
+			}
+			kinds = append(kinds, m.object(s, name))
+		case "array":
+			elem := m.resolve(s.Items, itemName(name))
+			kinds = append(kinds, ref{kind: "array", elem: &elem})
+		case "string":
+			if s.Format == "date-time" {
+				kinds = append(kinds, ref{kind: "date-time"})
+			} else {
+				kinds = append(kinds, ref{kind: "string"})
+			}
+		default:
+			kinds = append(kinds, ref{kind: t})
+		}
+	}
+	switch len(kinds) {
+	case 0:
+		return ref{kind: "any"}
+	case 1:
+		return kinds[0]
+	default:
+		return ref{kind: "union", union: kinds}
+	}
+}
+
+func (m *model) object(s *Schema, name string) ref {
+	def := typeDef{name: m.unique(name)}
+	index := len(m.defs)
+	m.defs = append(m.defs, def)
+
+	keys := make([]string, 0, len(s.Properties))
+	for k := range s.Properties {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	required := make(map[string]bool, len(s.Required))
+	for _, k := range s.Required {
+		required[k] = true
+	}
+	for _, k := range keys {
+		prop := s.Properties[k]
+		def.fields = append(def.fields, fieldDef{
+			key:      k,
+			typ:      m.resolve(prop, def.name+pascal(k)),
+			required: required[k],
+			nullable: prop.Type.Has("null"),
+		})
+	}
+	m.defs[index] = def
+	return ref{kind: "struct", name: def.name}
+}
+
+// Name for the items of an array type: Items -> Item, Entries -> Entry
+func itemName(name string) string {
+	switch {
+	case strings.HasSuffix(name, "ies"):
+		return strings.TrimSuffix(name, "ies") + "y"
+	case strings.HasSuffix(name, "s") && !strings.HasSuffix(name, "ss"):
+		return strings.TrimSuffix(name, "s")
+	default:
+		return name + "Item"
+	}
+}
+
+var initialisms = map[string]bool{
+	"api": true, "html": true, "http": true, "id": true, "ip": true, "json": true, "sku": true,
+	"uri": true, "url": true, "uuid": true,
+}
+
+// PascalCase from any key: created_at -> CreatedAt, html_url -> HTMLURL, x-id -> XID
+func pascal(s string) string {
+	var words []string
+	var word []rune
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, string(word))
+			word = nil
+		}
+	}
+	prev := rune(0)
+	for _, r := range s {
+		switch {
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			flush()
+		case unicode.IsUpper(r) && unicode.IsLower(prev):
+			flush()
+			word = append(word, r)
+		default:
+			word = append(word, r)
+		}
+		prev = r
+	}
+	flush()
+
+	var b strings.Builder
+	for _, w := range words {
+		lower := strings.ToLower(w)
+		if initialisms[lower] {
+			b.WriteString(strings.ToUpper(w))
+			continue
+		}
+		runes := []rune(w)
+		b.WriteRune(unicode.ToUpper(runes[0]))
+		b.WriteString(string(runes[1:]))
+	}
+	name := b.String()
+	if name != "" && unicode.IsDigit([]rune(name)[0]) {
+		name = "N" + name
+	}
+	return name
+}
+
+func renderGo(s *Schema) ([]byte, error) {
+	m := build(s)
+	var b strings.Builder
+	usesTime := false
+	var goType func(r ref) string
+	goType = func(r ref) string {
+		switch r.kind {
+		case "string":
+			return "string"
+		case "date-time":
+			usesTime = true
+			return "time.Time"
+		case "integer":
+			return "int64"
+		case "number":
+			return "float64"
+		case "boolean":
+			return "bool"
+		case "map":
+			return "map[string]interface{}"
+		case "struct":
+			return r.name
+		case "array":
+			return "[]" + goType(*r.elem)
+		default:
+			return "interface{}"
+		}
+	}
+
+	var body strings.Builder
+	if m.root.kind != "struct" {
+		fmt.Fprintf(&body, "type %s %s\n\n", s.Title, goType(m.root))
+	}
+	for _, def := range m.defs {
+		fmt.Fprintf(&body, "type %s struct {\n", def.name)
+		used := make(map[string]bool)
+		for _, f := range def.fields {
+			name := pascal(f.key)
+			if name == "" {
+				name = "Field"
+			}
+			for i := 2; used[name]; i++ {
+				name = fmt.Sprintf("%s%d", pascal(f.key), i)
+			}
+			used[name] = true
+
+			typ := goType(f.typ)
+			switch f.typ.kind {
+			case "any", "map", "array", "union":
+			default:
+				if !f.required || f.nullable {
+					typ = "*" + typ
+				}
+			}
+			tag := f.key
+			if !f.required {
+				tag += ",omitempty"
+			}
+			fmt.Fprintf(&body, "\t%s %s `json:%q`\n", name, typ, tag)
+		}
+		body.WriteString("}\n\n")
+	}
+
+	fmt.Fprintf(&b, "// %s.\n\npackage webhooks\n\n", s.Description)
+	if usesTime {
+		b.WriteString("import \"time\"\n\n")
+	}
+	b.WriteString(body.String())
+	return format.Source([]byte(b.String()))
+}
+
+var tsIdentifier = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+func renderTypeScript(s *Schema) ([]byte, error) {
+	m := build(s)
+	var tsType func(r ref) string
+	tsType = func(r ref) string {
+		switch r.kind {
+		case "string", "date-time":
+			return "string"
+		case "integer", "number":
+			return "number"
+		case "boolean":
+			return "boolean"
+		case "map":
+			return "Record<string, unknown>"
+		case "struct":
+			return r.name
+		case "array":
+			elem := tsType(*r.elem)
+			if r.elem.kind == "union" {
+				elem = "(" + elem + ")"
+			}
+			return elem + "[]"
+		case "union":
+			parts := make([]string, len(r.union))
+			for i, u := range r.union {
+				parts[i] = tsType(u)
+			}
+			return strings.Join(parts, " | ")
+		default:
+			return "unknown"
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "// %s.\n\n", s.Description)
+	if m.root.kind != "struct" {
+		fmt.Fprintf(&b, "export type %s = %s;\n\n", s.Title, tsType(m.root))
+	}
+	for i, def := range m.defs {
+		fmt.Fprintf(&b, "export interface %s {\n", def.name)
+		for _, f := range def.fields {
+			key := f.key
+			if !tsIdentifier.MatchString(key) {
+				key = fmt.Sprintf("%q", key)
+			}
+			if !f.required {
+				key += "?"
+			}
+			typ := tsType(f.typ)
+			if f.nullable {
+				typ += " | null"
+			}
+			fmt.Fprintf(&b, "  %s: %s;\n", key, typ)
+		}
+		b.WriteString("}\n")
+		if i < len(m.defs)-1 {
+			b.WriteString("\n")
+		}
+	}
+	return []byte(b.String()), nil
+}
+
+var pyIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+var pyKeywords = map[string]bool{
+	"False": true, "None": true, "True": true, "and": true, "as": true, "assert": true,
+	"async": true, "await": true, "break": true, "class": true, "continue": true,
+	"def": true, "del": true, "elif": true, "else": true, "except": true, "finally": true,
+	"for": true, "from": true, "global": true, "if": true, "import": true, "in": true,
+	"is": true, "lambda": true, "nonlocal": true, "not": true, "or": true, "pass": true,
+	"raise": true, "return": true, "try": true, "while": true, "with": true, "yield": true,
+}
+
+var pyInvalid = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+func renderPython(s *Schema) ([]byte, error) {
+	m := build(s)
+	typing := make(map[string]bool)
+	var pyType func(r ref) string
+	pyType = func(r ref) string {
+		switch r.kind {
+		case "string", "date-time":
+			return "str"
+		case "integer":
+			return "int"
+		case "number":
+			return "float"
+		case "boolean":
+			return "bool"
+		case "map":
+			typing["Dict"], typing["Any"] = true, true
+			return "Dict[str, Any]"
+		case "struct":
+			return r.name
+		case "array":
+			typing["List"] = true
+			return "List[" + pyType(*r.elem) + "]"
+		case "union":
+			parts := make([]string, len(r.union))
+			for i, u := range r.union {
+				parts[i] = pyType(u)
+			}
+			typing["Union"] = true
+			return "Union[" + strings.Join(parts, ", ") + "]"
+		default:
+			typing["Any"] = true
+			return "Any"
+		}
+	}
+
+	var body strings.Builder
+	if m.root.kind != "struct" {
+		fmt.Fprintf(&body, "\n\n%s = %s\n", s.Title, pyType(m.root))
+	}
+	for _, def := range m.defs {
+		fmt.Fprintf(&body, "\n\n@dataclass\nclass %s:\n", def.name)
+		// Fields with defaults must come after those without
+		fields := append([]fieldDef(nil), def.fields...)
+		sort.SliceStable(fields, func(i, j int) bool { return fields[i].required && !fields[j].required })
+		used := make(map[string]bool)
+		for _, f := range fields {
+			name, comment := f.key, ""
+			if !pyIdentifier.MatchString(name) || pyKeywords[name] {
+				name = strings.Trim(pyInvalid.ReplaceAllString(name, "_"), "_")
+				if name == "" || unicode.IsDigit(rune(name[0])) || pyKeywords[name] {
+					name = "field_" + name
+				}
+				comment = fmt.Sprintf("  # JSON key %q", f.key)
+			}
+			for base, i := name, 2; used[name]; i++ {
+				name = fmt.Sprintf("%s_%d", base, i)
+			}
+			used[name] = true
+
+			typ := pyType(f.typ)
+			if f.nullable || !f.required {
+				typing["Optional"] = true
+				typ = "Optional[" + typ + "]"
+			}
+			if f.required {
+				fmt.Fprintf(&body, "    %s: %s%s\n", name, typ, comment)
+			} else {
+				fmt.Fprintf(&body, "    %s: %s = None%s\n", name, typ, comment)
+			}
+		}
+	}
+
+	imports := make([]string, 0, len(typing))
+	for name := range typing {
+		imports = append(imports, name)
+	}
+	sort.Strings(imports)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s.\n\nfrom __future__ import annotations\n\nfrom dataclasses import dataclass\n", s.Description)
+	if len(imports) > 0 {
+		fmt.Fprintf(&b, "from typing import %s\n", strings.Join(imports, ", "))
+	}
+	b.WriteString(body.String())
+	return []byte(b.String()), nil
+}
@@ -0,0 +1,199 @@
+// Package schema infers a JSON Schema from captured payloads of one event type and
+// generates Go structs, TypeScript interfaces and Python dataclasses from it.
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"webhook-inspector/internal/models"
+)
+
+// Draft the inferred schemas declare
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema is the subset of JSON Schema that inference produces
+type Schema struct {
+	Schema      string             `json:"$schema,omitempty"`
+	Title       string             `json:"title,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Type        Types              `json:"type,omitempty"`
+	Format      string             `json:"format,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+}
+
+// Types is a JSON Schema type list, written as a plain string when it has one entry
+type Types []string
+
+func (t Types) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+func (t *Types) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*t = Types{one}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(t))
+}
+
+// Has reports whether the list includes a type
+func (t Types) Has(name string) bool {
+	for _, v := range t {
+		if v == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Infer merges the bodies of captures into one schema: a property is required when it
+// appeared in every object at its position, and a field seen with several types gets
+// them all. Bodies that are neither JSON nor a form are skipped; the second result is
+// how many were used.
+func Infer(title string, captures []models.WebhookPayload) (*Schema, int) {
+	root := &node{}
+	samples := 0
+	for _, c := range captures {
+		if doc, ok := decode(c); ok {
+			root.add(doc)
+			samples++
+		}
+	}
+
+	s := root.schema()
+	s.Schema = Draft
+	s.Title = title
+	s.Description = fmt.Sprintf("Inferred from %d captured payloads", samples)
+	return s, samples
+}
+
+// TypeName names the type generated for a provider event, e.g. StripeChargeSucceeded
+func TypeName(provider, eventType string) string {
+	if name := pascal(provider + " " + eventType); name != "" {
+		return name
+	}
+	return "Webhook"
+}
+
+// Everything seen at one position across all samples
+type node struct {
+	types     map[string]bool
+	strings   int
+	dateTimes int
+	objects   int
+	count     int
+	props     map[string]*node
+	items     *node
+}
+
+func (n *node) add(v interface{}) {
+	if n.types == nil {
+		n.types = make(map[string]bool)
+	}
+	n.count++
+	switch v := v.(type) {
+	case map[string]interface{}:
+		n.types["object"] = true
+		n.objects++
+		if n.props == nil {
+			n.props = make(map[string]*node)
+		}
+		for k, child := range v {
+			if n.props[k] == nil {
+				n.props[k] = &node{}
+			}
+			n.props[k].add(child)
+		}
+	case []interface{}:
+		n.types["array"] = true
+		if n.items == nil {
+			n.items = &node{}
+		}
+		for _, item := range v {
+			n.items.add(item)
+		}
+	case string:
+		n.types["string"] = true
+		n.strings++
+		if _, err := time.Parse(time.RFC3339, v); err == nil {
+			n.dateTimes++
+		}
+	case json.Number:
+		if strings.ContainsAny(v.String(), ".eE") {
+			n.types["number"] = true
+		} else {
+			n.types["integer"] = true
+		}
+	case bool:
+		n.types["boolean"] = true
+	case nil:
+		n.types["null"] = true
+	}
+}
+
+func (n *node) schema() *Schema {
+	s := &Schema{}
+	for t := range n.types {
+		// Integers are numbers too, so a field that was both is just a number
+		if t == "integer" && n.types["number"] {
+			continue
+		}
+		s.Type = append(s.Type, t)
+	}
+	sort.Strings(s.Type)
+
+	if n.strings > 0 && n.dateTimes == n.strings {
+		s.Format = "date-time"
+	}
+	if n.types["object"] {
+		s.Properties = make(map[string]*Schema, len(n.props))
+		for k, child := range n.props {
+			s.Properties[k] = child.schema()
+			if child.count == n.objects {
+				s.Required = append(s.Required, k)
+			}
+		}
+		sort.Strings(s.Required)
+	}
+	if n.types["array"] {
+		s.Items = n.items.schema()
+	}
+	return s
+}
+
+// Decode a JSON body, or a form body as a map of first values like rules do. Anything
+// parses as a form, so only bodies sent as one are treated that way.
+func decode(p models.WebhookPayload) (interface{}, bool) {
+	dec := json.NewDecoder(strings.NewReader(p.Body))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err == nil {
+		return doc, true
+	}
+
+	mediaType, _, _ := strings.Cut(http.Header(p.Headers).Get("Content-Type"), ";")
+	if !strings.EqualFold(strings.TrimSpace(mediaType), "application/x-www-form-urlencoded") {
+		return nil, false
+	}
+	form, err := url.ParseQuery(p.Body)
+	if err != nil || len(form) == 0 {
+		return nil, false
+	}
+	fields := make(map[string]interface{}, len(form))
+	for k := range form {
+		fields[k] = form.Get(k)
+	}
+	return fields, true
+}
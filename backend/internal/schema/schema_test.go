@@ -0,0 +1,143 @@
+package schema
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"webhook-inspector/internal/models"
+)
+
+func samples(bodies ...string) []models.WebhookPayload {
+	var captures []models.WebhookPayload
+	for _, b := range bodies {
+		captures = append(captures, models.WebhookPayload{Body: b})
+	}
+	return captures
+}
+
+var charges = samples(
+	`{"id":"ch_1","amount":100,"created":"2026-01-01T00:00:00Z","customer":{"id":"c","email":null},"items":[{"sku":"x","qty":1}],"ratio":1,"x-id":1}`,
+	`{"id":"ch_2","amount":250,"created":"2026-01-02T00:00:00Z","customer":{"id":"d","email":"a@b"},"items":[],"ratio":1.5,"mixed":"s"}`,
+	`{"id":"ch_3","amount":300,"created":"2026-01-03T00:00:00Z","customer":{"id":"e"},"items":[{"sku":"y"}],"mixed":2}`,
+	"plain=text",
+)
+
+func TestInfer(t *testing.T) {
+	s, n := Infer("Charge", charges)
+	if n != 3 || s.Title != "Charge" || s.Schema != Draft {
+		t.Fatalf("Infer = %+v, %d", s, n)
+	}
+	if strings.Join(s.Required, ",") != "amount,created,customer,id,items" {
+		t.Errorf("required = %v", s.Required)
+	}
+	props := s.Properties
+	if props["created"].Format != "date-time" || props["ratio"].Type[0] != "number" || props["amount"].Type[0] != "integer" {
+		t.Errorf("created/ratio/amount = %+v %+v %+v", props["created"], props["ratio"], props["amount"])
+	}
+	if email := props["customer"].Properties["email"]; strings.Join(email.Type, ",") != "null,string" {
+		t.Errorf("email = %+v", email)
+	}
+	if items := props["items"].Items; strings.Join(items.Required, ",") != "sku" {
+		t.Errorf("items = %+v", items)
+	}
+
+	data, _ := json.Marshal(props["mixed"])
+	if string(data) != `{"type":["integer","string"]}` {
+		t.Errorf("mixed = %s", data)
+	}
+	var back Schema
+	if err := json.Unmarshal([]byte(`{"type":"string"}`), &back); err != nil || back.Type[0] != "string" {
+		t.Errorf("unmarshal = %+v, %v", back, err)
+	}
+}
+
+func TestInferForm(t *testing.T) {
+	form := models.WebhookPayload{
+		Headers: map[string][]string{"Content-Type": {"application/x-www-form-urlencoded; charset=utf-8"}},
+		Body:    "From=%2B1555&Body=hi",
+	}
+	s, n := Infer("Sms", []models.WebhookPayload{form})
+	if n != 1 || strings.Join(s.Required, ",") != "Body,From" || s.Properties["From"].Type[0] != "string" {
+		t.Errorf("Infer = %+v, %d", s, n)
+	}
+}
+
+func TestRender(t *testing.T) {
+	s, _ := Infer(TypeName("stripe", "charge.succeeded"), charges)
+	render := func(name string) string {
+		f, ok := Lookup(name)
+		if !ok {
+			t.Fatalf("no format %q", name)
+		}
+		out, err := f.Render(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(out)
+	}
+
+	goCode := render("go")
+	for _, want := range []string{
+		"package webhooks",
+		`import "time"`,
+		"type StripeChargeSucceeded struct",
+		"Created  time.Time                     `json:\"created\"`",
+		"Items    []StripeChargeSucceededItem   `json:\"items\"`",
+		"XID      *int64                        `json:\"x-id,omitempty\"`",
+		"Email *string `json:\"email,omitempty\"`",
+	} {
+		if !strings.Contains(goCode, want) {
+			t.Errorf("Go output missing %q:\n%s", want, goCode)
+		}
+	}
+
+	ts := render("typescript")
+	for _, want := range []string{
+		"export interface StripeChargeSucceeded {",
+		"  items: StripeChargeSucceededItem[];",
+		`  "x-id"?: number;`,
+		"  email?: string | null;",
+		"  mixed?: number | string;",
+	} {
+		if !strings.Contains(ts, want) {
+			t.Errorf("TypeScript output missing %q:\n%s", want, ts)
+		}
+	}
+
+	py := render("python")
+	for _, want := range []string{
+		"from typing import List, Optional, Union\n",
+		"class StripeChargeSucceededCustomer:\n    id: str\n    email: Optional[str] = None\n",
+		`    x_id: Optional[int] = None  # JSON key "x-id"`,
+	} {
+		if !strings.Contains(py, want) {
+			t.Errorf("Python output missing %q:\n%s", want, py)
+		}
+	}
+}
+
+func TestNames(t *testing.T) {
+	for in, want := range map[string]string{
+		"github push":             "GithubPush",
+		"stripe charge.succeeded": "StripeChargeSucceeded",
+		"html_url":                "HTMLURL",
+		"createdAt":               "CreatedAt",
+		"3ds":                     "N3ds",
+	} {
+		if got := pascal(in); got != want {
+			t.Errorf("pascal(%q) = %q, want %q", in, got, want)
+		}
+	}
+	if TypeName("", "") != "Webhook" {
+		t.Error("unclassified events need a type name")
+	}
+
+	// A top-level array becomes an alias of its items
+	s, _ := Infer("Events", samples(`[{"id":1}]`))
+	f, _ := Lookup("go")
+	out, _ := f.Render(s)
+	if !strings.Contains(string(out), "type Events []Event\n") {
+		t.Errorf("array root:\n%s", out)
+	}
+}
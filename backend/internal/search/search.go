@@ -0,0 +1,551 @@
+// Package search implements full-text and JSON-path queries over captures and the
+// index terms that let stores answer them without reading every payload.
+package search
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"webhook-inspector/internal/config"
+	"webhook-inspector/internal/models"
+)
+
+// Comparison operators for path predicates; OpExists is a bare path
+const (
+	OpExists    = "exists"
+	OpNotExists = "!exists"
+	OpEq        = "=="
+	OpNeq       = "!="
+	OpGt        = ">"
+	OpGte       = ">="
+	OpLt        = "<"
+	OpLte       = "<="
+)
+
+// PartialTerm marks captures with more terms than SEARCH_MAX_TERMS; stores always
+// treat them as candidates because their index entries are incomplete
+const PartialTerm = "x:partial"
+
+// Longest string value and token that get indexed
+const (
+	maxValueLen = 128
+	maxTokenLen = 64
+)
+
+// Query is a parsed search: every clause must match
+type Query struct {
+	Text  []string // words and phrases, lowercased
+	Paths []Predicate
+}
+
+// Predicate tests the value at a JSON path in the body
+type Predicate struct {
+	Path  []string // object keys, array indexes, or "*" for any element
+	Op    string
+	Value interface{} // string, float64, bool or nil
+}
+
+// Parse reads a query such as:
+//
+//	refund "card declined" $.data.object.customer == "cus_123" $.amount > 1000 $.livemode !$.test
+//
+// Bare words and quoted phrases are matched against the body and headers; $.path alone
+// requires the path to exist and !$.path requires it to be missing.
+func Parse(input string) (*Query, error) {
+	q := &Query{}
+	l := &lexer{input: input}
+	for {
+		l.skipSpace()
+		if l.done() {
+			break
+		}
+
+		switch c := l.peek(); {
+		case c == '$' || (c == '!' && strings.HasPrefix(l.rest(), "!$")):
+			p, err := l.predicate()
+			if err != nil {
+				return nil, err
+			}
+			q.Paths = append(q.Paths, p)
+		case c == '"':
+			phrase, err := l.quoted()
+			if err != nil {
+				return nil, err
+			}
+			if phrase = strings.ToLower(strings.TrimSpace(phrase)); phrase != "" {
+				q.Text = append(q.Text, phrase)
+			}
+		default:
+			q.Text = append(q.Text, strings.ToLower(l.word()))
+		}
+	}
+	if len(q.Text) == 0 && len(q.Paths) == 0 {
+		return nil, fmt.Errorf("empty search")
+	}
+	return q, nil
+}
+
+type lexer struct {
+	input string
+	pos   int
+}
+
+func (l *lexer) done() bool   { return l.pos >= len(l.input) }
+func (l *lexer) peek() byte   { return l.input[l.pos] }
+func (l *lexer) rest() string { return l.input[l.pos:] }
+
+func (l *lexer) skipSpace() {
+	for !l.done() && (l.peek() == ' ' || l.peek() == '\t' || l.peek() == '\n') {
+		l.pos++
+	}
+}
+
+// Read up to the next whitespace
+func (l *lexer) word() string {
+	start := l.pos
+	for !l.done() && l.peek() != ' ' && l.peek() != '\t' && l.peek() != '\n' {
+		l.pos++
+	}
+	return l.input[start:l.pos]
+}
+
+// Read a JSON string literal
+func (l *lexer) quoted() (string, error) {
+	start := l.pos
+	l.pos++
+	for !l.done() {
+		switch l.peek() {
+		case '\\':
+			l.pos += 2
+			continue
+		case '"':
+			l.pos++
+			var s string
+			if err := json.Unmarshal([]byte(l.input[start:l.pos]), &s); err != nil {
+				return "", fmt.Errorf("invalid string %s", l.input[start:l.pos])
+			}
+			return s, nil
+		}
+		l.pos++
+	}
+	return "", fmt.Errorf("unterminated string at %d", start)
+}
+
+func (l *lexer) predicate() (Predicate, error) {
+	var p Predicate
+	negated := l.peek() == '!'
+	if negated {
+		l.pos++
+	}
+
+	start := l.pos
+	for !l.done() && !strings.ContainsRune(" \t\n=!<>", rune(l.peek())) {
+		if l.peek() == '[' {
+			// Keys in brackets may contain spaces and operators
+			end := strings.IndexByte(l.input[l.pos:], ']')
+			if end < 0 {
+				return p, fmt.Errorf("unterminated [ in %s", l.input[start:])
+			}
+			l.pos += end
+		}
+		l.pos++
+	}
+	path, err := parsePath(l.input[start:l.pos])
+	if err != nil {
+		return p, err
+	}
+	p.Path = path
+
+	l.skipSpace()
+	op := ""
+	for _, candidate := range []string{OpEq, OpNeq, OpGte, OpLte, OpGt, OpLt} {
+		if strings.HasPrefix(l.rest(), candidate) {
+			op = candidate
+			break
+		}
+	}
+	if op == "" {
+		p.Op = OpExists
+		if negated {
+			p.Op = OpNotExists
+		}
+		return p, nil
+	}
+	if negated {
+		return p, fmt.Errorf("! only applies to a bare path")
+	}
+	p.Op = op
+	l.pos += len(op)
+	l.skipSpace()
+	if l.done() {
+		return p, fmt.Errorf("missing value after %s", op)
+	}
+
+	if l.peek() == '"' {
+		s, err := l.quoted()
+		if err != nil {
+			return p, err
+		}
+		p.Value = s
+	} else {
+		raw := l.word()
+		switch raw {
+		case "true", "false":
+			p.Value = raw == "true"
+		case "null":
+			p.Value = nil
+		default:
+			n, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				return p, fmt.Errorf("value %q must be quoted, a number, true, false or null", raw)
+			}
+			p.Value = n
+		}
+	}
+
+	if _, isNumber := p.Value.(float64); !isNumber && op != OpEq && op != OpNeq {
+		return p, fmt.Errorf("%s needs a number", op)
+	}
+	return p, nil
+}
+
+// Parse $.a.b[0]["c d"][*] into segments
+func parsePath(raw string) ([]string, error) {
+	if !strings.HasPrefix(raw, "$") {
+		return nil, fmt.Errorf("path %q must start with $", raw)
+	}
+	var segments []string
+	rest := raw[1:]
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			key := rest[1 : end+1]
+			if key == "" {
+				return nil, fmt.Errorf("empty key in path %q", raw)
+			}
+			segments = append(segments, key)
+			rest = rest[end+1:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated [ in path %q", raw)
+			}
+			inner := rest[1:end]
+			if strings.HasPrefix(inner, `"`) {
+				var key string
+				if err := json.Unmarshal([]byte(inner), &key); err != nil {
+					return nil, fmt.Errorf("invalid key %s in path %q", inner, raw)
+				}
+				segments = append(segments, key)
+			} else if _, err := strconv.Atoi(inner); err == nil || inner == "*" {
+				segments = append(segments, inner)
+			} else {
+				return nil, fmt.Errorf("invalid index [%s] in path %q", inner, raw)
+			}
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("unexpected %q in path %q", rest[0], raw)
+		}
+	}
+	return segments, nil
+}
+
+// Match reports whether a capture satisfies every clause
+func (q *Query) Match(p models.WebhookPayload) bool {
+	if len(q.Text) > 0 {
+		tokens := tokenSet(p)
+		haystack := strings.ToLower(p.Body)
+		for name, values := range p.Headers {
+			haystack += "\n" + strings.ToLower(name) + ": " + strings.ToLower(strings.Join(values, ", "))
+		}
+		for _, text := range q.Text {
+			for _, token := range tokenize(text) {
+				if !tokens[token] {
+					return false
+				}
+			}
+			if strings.ContainsRune(text, ' ') && !strings.Contains(haystack, text) {
+				return false
+			}
+		}
+	}
+
+	if len(q.Paths) > 0 {
+		doc := decode(p.Body)
+		for _, pred := range q.Paths {
+			if !pred.match(doc) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (p Predicate) match(doc interface{}) bool {
+	values := resolve(doc, p.Path)
+	switch p.Op {
+	case OpExists:
+		return len(values) > 0
+	case OpNotExists:
+		return len(values) == 0
+	}
+	for _, v := range values {
+		if p.compare(v) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p Predicate) compare(v interface{}) bool {
+	switch want := p.Value.(type) {
+	case float64:
+		got, ok := number(v)
+		if !ok {
+			return p.Op == OpNeq
+		}
+		switch p.Op {
+		case OpEq:
+			return got == want
+		case OpNeq:
+			return got != want
+		case OpGt:
+			return got > want
+		case OpGte:
+			return got >= want
+		case OpLt:
+			return got < want
+		case OpLte:
+			return got <= want
+		}
+	default:
+		equal := canonical(v) == canonical(want)
+		if p.Op == OpNeq {
+			return !equal
+		}
+		return equal
+	}
+	return false
+}
+
+// Every value the path reaches; wildcards fan out over array elements
+func resolve(doc interface{}, path []string) []interface{} {
+	current := []interface{}{doc}
+	for _, segment := range path {
+		var next []interface{}
+		for _, node := range current {
+			switch n := node.(type) {
+			case map[string]interface{}:
+				if v, ok := n[segment]; ok {
+					next = append(next, v)
+				}
+			case []interface{}:
+				if segment == "*" {
+					next = append(next, n...)
+				} else if i, err := strconv.Atoi(segment); err == nil && i >= 0 && i < len(n) {
+					next = append(next, n[i])
+				}
+			}
+		}
+		current = next
+	}
+	if doc == nil {
+		return nil
+	}
+	return current
+}
+
+func number(v interface{}) (float64, bool) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return 0, false
+	}
+	f, err := n.Float64()
+	return f, err == nil
+}
+
+// Canonical text of a scalar so equal values index and compare the same way
+func canonical(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return "s" + t
+	case json.Number:
+		if f, err := t.Float64(); err == nil {
+			return "n" + strconv.FormatFloat(f, 'g', -1, 64)
+		}
+		return "n" + t.String()
+	case float64:
+		return "n" + strconv.FormatFloat(t, 'g', -1, 64)
+	case bool:
+		return "b" + strconv.FormatBool(t)
+	case nil:
+		return "null"
+	}
+	return "" // objects and arrays never equal a scalar
+}
+
+// Decode a JSON body, or a form body as a map of first values like rules do
+func decode(body string) interface{} {
+	dec := json.NewDecoder(strings.NewReader(body))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err == nil {
+		return doc
+	}
+
+	form, err := url.ParseQuery(body)
+	if err != nil || len(form) == 0 {
+		return nil
+	}
+	fields := make(map[string]interface{}, len(form))
+	for k := range form {
+		fields[k] = form.Get(k)
+	}
+	return fields
+}
+
+func tokenize(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+	tokens := fields[:0]
+	for _, f := range fields {
+		if len(f) <= maxTokenLen {
+			tokens = append(tokens, f)
+		}
+	}
+	return tokens
+}
+
+func tokenSet(p models.WebhookPayload) map[string]bool {
+	set := make(map[string]bool)
+	for _, t := range tokenize(p.Body) {
+		set[t] = true
+	}
+	for name, values := range p.Headers {
+		for _, t := range tokenize(name + " " + strings.Join(values, " ")) {
+			set[t] = true
+		}
+	}
+	return set
+}
+
+// Terms returns the index terms of a capture: "t:" for words, "e:" for every JSON
+// pointer present and "v:" for pointer=value pairs of scalars. Past SEARCH_MAX_TERMS
+// the list is cut short and ends with PartialTerm.
+func Terms(p models.WebhookPayload) []string {
+	seen := make(map[string]bool)
+	var terms []string
+	add := func(term string) bool {
+		if seen[term] {
+			return true
+		}
+		if len(terms) >= config.SearchMaxTerms {
+			return false
+		}
+		seen[term] = true
+		terms = append(terms, term)
+		return true
+	}
+
+	complete := true
+	for token := range tokenSet(p) {
+		if !add("t:" + token) {
+			complete = false
+			break
+		}
+	}
+	if complete {
+		complete = walk(decode(p.Body), "", add)
+	}
+	if !complete {
+		terms = append(terms, PartialTerm)
+	}
+	return terms
+}
+
+// Add terms for every node under doc; returns false once add refuses more
+func walk(node interface{}, pointer string, add func(string) bool) bool {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		for k, v := range n {
+			child := pointer + "/" + escape(k)
+			if !add("e:"+child) || !walk(v, child, add) {
+				return false
+			}
+		}
+	case []interface{}:
+		for i, v := range n {
+			child := pointer + "/" + strconv.Itoa(i)
+			if !add("e:"+child) || !walk(v, child, add) {
+				return false
+			}
+		}
+	case string:
+		if len(n) <= maxValueLen {
+			return add("v:" + pointer + "=" + canonical(n))
+		}
+	case nil:
+		if pointer != "" {
+			return add("v:" + pointer + "=" + canonical(n))
+		}
+	default:
+		return add("v:" + pointer + "=" + canonical(n))
+	}
+	return true
+}
+
+// JSON pointer escaping (RFC 6901)
+func escape(key string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+}
+
+// IndexTerms returns terms every match is guaranteed to have, for narrowing candidates
+func (q *Query) IndexTerms() []string {
+	var terms []string
+	for _, text := range q.Text {
+		for _, token := range tokenize(text) {
+			terms = append(terms, "t:"+token)
+		}
+	}
+
+	for _, pred := range q.Paths {
+		if pred.Op == OpNotExists || pred.Op == OpNeq {
+			continue
+		}
+		// Only the part of the path before a wildcard can be indexed
+		var pointer bytes.Buffer
+		concrete := true
+		for _, segment := range pred.Path {
+			if segment == "*" {
+				concrete = false
+				break
+			}
+			pointer.WriteString("/" + escape(segment))
+		}
+		if pointer.Len() == 0 {
+			continue
+		}
+
+		value := canonical(pred.Value)
+		longString := false
+		if s, ok := pred.Value.(string); ok && len(s) > maxValueLen {
+			longString = true
+		}
+		if concrete && pred.Op == OpEq && !longString {
+			terms = append(terms, "v:"+pointer.String()+"="+value)
+		} else {
+			terms = append(terms, "e:"+pointer.String())
+		}
+	}
+	return terms
+}
@@ -0,0 +1,127 @@
+package search
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"webhook-inspector/internal/models"
+)
+
+var charge = models.WebhookPayload{
+	Headers: map[string][]string{"Stripe-Signature": {"t=1,v1=abc"}, "User-Agent": {"Stripe/1.0"}},
+	Body: `{"type":"charge.failed","livemode":false,"data":{"object":{"customer":"cus_123","amount":2500,` +
+		`"outcome":{"reason":"card declined"},"lines":[{"id":"li_1","qty":1},{"id":"li_2","qty":3}],"odd key":"x"}}}`,
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{`cus_123`, true},
+		{`CHARGE failed`, true},
+		{`refund`, false},
+		{`"card declined"`, true},
+		{`"declined card"`, false},
+		{`stripe-signature`, true},
+		{`$.data.object.customer == "cus_123"`, true},
+		{`$.data.object.customer=="cus_999"`, false},
+		{`$.data.object.customer != "cus_999"`, true},
+		{`$.data.object.amount > 1000`, true},
+		{`$.data.object.amount >= 2500 $.data.object.amount <= 2500`, true},
+		{`$.data.object.amount < 1000`, false},
+		{`$.data.object.amount == 2500.0`, true},
+		{`$.livemode == false`, true},
+		{`$.data.object.outcome`, true},
+		{`$.data.object.refund`, false},
+		{`!$.data.object.refund`, true},
+		{`!$.livemode`, false},
+		{`$.data.object.lines[1].id == "li_2"`, true},
+		{`$.data.object.lines[*].qty > 2`, true},
+		{`$.data.object.lines[*].qty > 5`, false},
+		{`$.data.object["odd key"] == "x"`, true},
+		{`cus_123 $.type == "charge.failed" !$.data.object.refund`, true},
+	}
+	for _, tt := range tests {
+		q, err := Parse(tt.query)
+		if err != nil {
+			t.Errorf("%s: %v", tt.query, err)
+			continue
+		}
+		if got := q.Match(charge); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestMatchFormBody(t *testing.T) {
+	q, _ := Parse(`$.status == "paid"`)
+	if !q.Match(models.WebhookPayload{Body: "status=paid&id=1"}) {
+		t.Error("expected form fields to be addressable by path")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, bad := range []string{
+		``,
+		`"unterminated`,
+		`$.a >`,
+		`$.a > "text"`,
+		`$.a == bare`,
+		`!$.a == 1`,
+		`$.a[x]`,
+		`$a`,
+	} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+// Every match must hold the terms the query asks the index for
+func TestIndexTermsCoverMatches(t *testing.T) {
+	terms := Terms(charge)
+	for _, query := range []string{
+		`cus_123 "card declined"`,
+		`$.data.object.customer == "cus_123"`,
+		`$.data.object.amount == 2500.0`,
+		`$.data.object.amount > 1000`,
+		`$.livemode == false`,
+		`$.data.object.lines[*].qty > 2`,
+		`$.data.object["odd key"] == "x"`,
+	} {
+		q, _ := Parse(query)
+		wanted := q.IndexTerms()
+		if len(wanted) == 0 {
+			t.Errorf("%s: expected index terms", query)
+		}
+		for _, term := range wanted {
+			if !slices.Contains(terms, term) {
+				t.Errorf("%s: capture lacks index term %q", query, term)
+			}
+		}
+	}
+
+	q, _ := Parse(`!$.refund $.type != "x"`)
+	if terms := q.IndexTerms(); len(terms) != 0 {
+		t.Errorf("negations can't be answered from the index, got %v", terms)
+	}
+}
+
+func TestTermsPartial(t *testing.T) {
+	var words []string
+	for i := 0; i < 3000; i++ {
+		words = append(words, fmt.Sprintf("w%d", i))
+	}
+	big := models.WebhookPayload{Body: strings.Join(words, " ")}
+
+	terms := Terms(big)
+	if terms[len(terms)-1] != PartialTerm {
+		t.Errorf("expected an oversized capture to be marked partial, got %d terms", len(terms))
+	}
+	if slices.Contains(Terms(charge), PartialTerm) {
+		t.Error("small capture marked partial")
+	}
+}
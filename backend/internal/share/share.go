@@ -0,0 +1,157 @@
+// Package share describes the read-only links handed out for one capture or a filtered
+// view of a token's captures. Links are signed so they can't be forged or extended,
+// optionally password-protected, and redact chosen headers from what they show.
+package share
+
+import (
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"webhook-inspector/internal/models"
+	"webhook-inspector/internal/query"
+)
+
+// Redacted replaces the values of redacted headers
+const Redacted = "[redacted]"
+
+// DefaultRedactHeaders are redacted when a link doesn't choose its own
+var DefaultRedactHeaders = []string{"Authorization", "Proxy-Authorization", "X-Api-Key"}
+
+// Dropped from every shared capture whatever the link says, since it can carry the
+// inspector's own webhook_token cookie
+var alwaysDropped = []string{"Cookie"}
+
+const (
+	passwordIterations = 100_000
+	passwordScheme     = "pbkdf2-sha256"
+)
+
+// Link is one share; exactly one of CaptureID and Filter is set
+type Link struct {
+	ID        string        `json:"id"`
+	Token     string        `json:"token"`
+	CaptureID string        `json:"capture_id,omitempty"`
+	Filter    *query.Filter `json:"filter,omitempty"`
+	Redact    []string      `json:"redact_headers"`
+	// Salted hash of the password, empty when the link isn't protected
+	Password  string    `json:"password,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// NewID returns a random link ID
+func NewID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// Sign returns the signature a link's URL carries. It covers the expiry, so a link
+// can't be made to outlive what it was issued for.
+func Sign(key []byte, l Link) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(l.ID + "." + strconv.FormatInt(l.ExpiresAt.Unix(), 10)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether sig was issued for l with key
+func Verify(key []byte, l Link, sig string) bool {
+	return hmac.Equal([]byte(Sign(key, l)), []byte(sig))
+}
+
+// HashPassword returns a salted hash of password to store on a link
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	sum, err := pbkdf2.Key(sha256.New, password, salt, passwordIterations, sha256.Size)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s$%d$%s$%s", passwordScheme, passwordIterations,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(sum)), nil
+}
+
+// CheckPassword reports whether password matches a hash from HashPassword
+func CheckPassword(hash, password string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != passwordScheme {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations < 1 {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return false
+	}
+	got, err := pbkdf2.Key(sha256.New, password, salt, iterations, len(want))
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(got, want) == 1
+}
+
+// Redact returns a copy of p for a link's viewers: Cookie is dropped and the named
+// headers, request and response alike, keep their names but lose their values
+func Redact(p models.WebhookPayload, headers []string) models.WebhookPayload {
+	redacted := make(map[string]bool, len(headers))
+	for _, name := range headers {
+		redacted[http.CanonicalHeaderKey(name)] = true
+	}
+	dropped := make(map[string]bool, len(alwaysDropped))
+	for _, name := range alwaysDropped {
+		dropped[name] = true
+	}
+
+	out := p
+	out.Headers = make(map[string][]string, len(p.Headers))
+	for name, values := range p.Headers {
+		key := http.CanonicalHeaderKey(name)
+		switch {
+		case dropped[key]:
+		case redacted[key]:
+			out.Headers[name] = redactedValues(len(values))
+		default:
+			out.Headers[name] = append([]string(nil), values...)
+		}
+	}
+
+	if p.Response != nil {
+		resp := *p.Response
+		resp.Headers = make(map[string]string, len(p.Response.Headers))
+		for name, value := range p.Response.Headers {
+			if redacted[http.CanonicalHeaderKey(name)] {
+				value = Redacted
+			}
+			resp.Headers[name] = value
+		}
+		out.Response = &resp
+	}
+	return out
+}
+
+func redactedValues(n int) []string {
+	values := make([]string, n)
+	for i := range values {
+		values[i] = Redacted
+	}
+	return values
+}
@@ -0,0 +1,74 @@
+package share
+
+import (
+	"testing"
+	"time"
+
+	"webhook-inspector/internal/models"
+)
+
+func TestSignVerify(t *testing.T) {
+	key := []byte("secret")
+	l := Link{ID: "abc", ExpiresAt: time.Unix(1700000000, 0)}
+	sig := Sign(key, l)
+
+	if !Verify(key, l, sig) {
+		t.Error("expected the issued signature to verify")
+	}
+	if Verify([]byte("other"), l, sig) {
+		t.Error("expected a different key to fail")
+	}
+	extended := l
+	extended.ExpiresAt = l.ExpiresAt.Add(time.Hour)
+	if Verify(key, extended, sig) {
+		t.Error("expected a later expiry to fail")
+	}
+}
+
+func TestPassword(t *testing.T) {
+	hash, err := HashPassword("hunter2")
+	if err != nil {
+		t.Fatalf("HashPassword failed: %v", err)
+	}
+	if !CheckPassword(hash, "hunter2") {
+		t.Error("expected the right password to match")
+	}
+	if CheckPassword(hash, "hunter3") || CheckPassword("garbage", "hunter2") {
+		t.Error("expected wrong passwords and bad hashes to fail")
+	}
+	if again, _ := HashPassword("hunter2"); again == hash {
+		t.Error("expected hashes to be salted")
+	}
+}
+
+func TestRedact(t *testing.T) {
+	p := models.WebhookPayload{
+		Headers: map[string][]string{
+			"Authorization": {"Bearer abc"},
+			"Cookie":        {"webhook_token=tok"},
+			"X-Signature":   {"sig1", "sig2"},
+			"Content-Type":  {"application/json"},
+		},
+		Response: &models.CaptureResponse{Status: 200, Headers: map[string]string{"x-signature": "s"}},
+	}
+	got := Redact(p, []string{"authorization", "X-Signature"})
+
+	if _, ok := got.Headers["Cookie"]; ok {
+		t.Error("expected Cookie to be dropped")
+	}
+	if v := got.Headers["Authorization"]; len(v) != 1 || v[0] != Redacted {
+		t.Errorf("Authorization = %v", v)
+	}
+	if v := got.Headers["X-Signature"]; len(v) != 2 || v[1] != Redacted {
+		t.Errorf("X-Signature = %v", v)
+	}
+	if v := got.Headers["Content-Type"]; len(v) != 1 || v[0] != "application/json" {
+		t.Errorf("Content-Type = %v", v)
+	}
+	if got.Response.Headers["x-signature"] != Redacted {
+		t.Errorf("response headers = %v", got.Response.Headers)
+	}
+	if p.Headers["Authorization"][0] != "Bearer abc" || p.Response.Headers["x-signature"] != "s" {
+		t.Error("expected the original capture to be left alone")
+	}
+}
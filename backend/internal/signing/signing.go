@@ -0,0 +1,160 @@
+package signing
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"webhook-inspector/internal/models"
+)
+
+// Supported signature schemes
+const (
+	GitHub           = "github"
+	Stripe           = "stripe"
+	StandardWebhooks = "standard"
+	Slack            = "slack"
+	Shopify          = "shopify"
+	Twilio           = "twilio"
+)
+
+// Schemes lists every supported scheme name
+var Schemes = []string{GitHub, Stripe, StandardWebhooks, Slack, Shopify, Twilio}
+
+// Message is what gets signed; ID is only used by Standard Webhooks and URL only by Twilio
+type Message struct {
+	ID        string
+	Timestamp time.Time
+	Body      []byte
+	URL       string
+}
+
+// Sign sets the headers a provider would send for msg, replacing any existing signature
+func Sign(scheme, secret string, header http.Header, msg Message) error {
+	if secret == "" {
+		return fmt.Errorf("no %s signing secret configured", scheme)
+	}
+
+	switch scheme {
+	case GitHub:
+		header.Set("X-Hub-Signature-256", "sha256="+hexHMAC(sha256.New, []byte(secret), msg.Body))
+		header.Set("X-Hub-Signature", "sha1="+hexHMAC(sha1.New, []byte(secret), msg.Body))
+	case Stripe:
+		ts := strconv.FormatInt(msg.Timestamp.Unix(), 10)
+		signed := append([]byte(ts+"."), msg.Body...)
+		header.Set("Stripe-Signature", "t="+ts+",v1="+hexHMAC(sha256.New, []byte(secret), signed))
+	case StandardWebhooks:
+		key, err := standardKey(secret)
+		if err != nil {
+			return err
+		}
+		ts := strconv.FormatInt(msg.Timestamp.Unix(), 10)
+		signed := append([]byte(msg.ID+"."+ts+"."), msg.Body...)
+		mac := hmac.New(sha256.New, key)
+		mac.Write(signed)
+		header.Set("Webhook-Id", msg.ID)
+		header.Set("Webhook-Timestamp", ts)
+		header.Set("Webhook-Signature", "v1,"+base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+	case Slack:
+		ts := strconv.FormatInt(msg.Timestamp.Unix(), 10)
+		signed := append([]byte("v0:"+ts+":"), msg.Body...)
+		header.Set("X-Slack-Request-Timestamp", ts)
+		header.Set("X-Slack-Signature", "v0="+hexHMAC(sha256.New, []byte(secret), signed))
+	case Shopify:
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(msg.Body)
+		header.Set("X-Shopify-Hmac-Sha256", base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+	case Twilio:
+		signed, err := twilioPayload(msg)
+		if err != nil {
+			return err
+		}
+		mac := hmac.New(sha1.New, []byte(secret))
+		mac.Write([]byte(signed))
+		header.Set("X-Twilio-Signature", base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+	default:
+		return fmt.Errorf("unknown signing scheme %q", scheme)
+	}
+	return nil
+}
+
+// SecretFor picks the endpoint secret matching scheme
+func SecretFor(secrets models.SigningSecrets, scheme string) string {
+	switch scheme {
+	case GitHub:
+		return secrets.GitHub
+	case Stripe:
+		return secrets.Stripe
+	case StandardWebhooks:
+		return secrets.StandardWebhooks
+	case Slack:
+		return secrets.Slack
+	case Shopify:
+		return secrets.Shopify
+	case Twilio:
+		return secrets.Twilio
+	}
+	return ""
+}
+
+// Valid reports whether scheme names a supported scheme
+func Valid(scheme string) bool {
+	for _, s := range Schemes {
+		if s == scheme {
+			return true
+		}
+	}
+	return false
+}
+
+// Standard Webhooks secrets are base64, usually prefixed with "whsec_"
+func standardKey(secret string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(secret, "whsec_"))
+	if err != nil {
+		return nil, fmt.Errorf("standard webhooks secret must be base64: %w", err)
+	}
+	return key, nil
+}
+
+// Twilio signs the full URL followed by every form parameter, sorted, as name+value
+func twilioPayload(msg Message) (string, error) {
+	if msg.URL == "" {
+		return "", fmt.Errorf("twilio signatures need the destination URL")
+	}
+	params, err := url.ParseQuery(string(msg.Body))
+	if err != nil {
+		return "", fmt.Errorf("twilio signatures need a form-encoded body: %w", err)
+	}
+
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString(msg.URL)
+	for _, name := range names {
+		for _, value := range params[name] {
+			b.WriteString(name)
+			b.WriteString(value)
+		}
+	}
+	return b.String(), nil
+}
+
+func hexHMAC(h func() hash.Hash, key, data []byte) string {
+	mac := hmac.New(h, key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
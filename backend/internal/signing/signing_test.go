@@ -0,0 +1,68 @@
+package signing
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSign_GitHub(t *testing.T) {
+	header := http.Header{}
+	err := Sign(GitHub, "It's a Secret to Everybody", header, Message{Body: []byte("Hello, World!")})
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+
+	want := "sha256=757107ea0eb2509fc211221cce984b8a37570b6d7586c22c46f4379c8b043e17"
+	if got := header.Get("X-Hub-Signature-256"); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestSign_StandardWebhooks(t *testing.T) {
+	header := http.Header{}
+	err := Sign(StandardWebhooks, "whsec_MfKQ9r8GKYqrTwjUPD8ILPZIo2LaLaSw", header, Message{
+		ID:        "msg_p5jXN8AQM9LWM0D4loKWxJek",
+		Timestamp: time.Unix(1614265330, 0),
+		Body:      []byte(`{"test": 2432232314}`),
+	})
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+
+	want := "v1,g0hM9SsE+OTPJTGt/tmIKtSyZlE3uFJELVlNIOLJ1OE="
+	if got := header.Get("Webhook-Signature"); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestSign_StripeAndErrors(t *testing.T) {
+	header := http.Header{}
+	if err := Sign(Stripe, "whsec_test", header, Message{Timestamp: time.Unix(1700000000, 0), Body: []byte("{}")}); err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	if got := header.Get("Stripe-Signature"); len(got) < 16 || got[:13] != "t=1700000000," {
+		t.Errorf("unexpected Stripe-Signature %q", got)
+	}
+
+	if err := Sign(GitHub, "", header, Message{}); err == nil {
+		t.Error("expected an empty secret to fail")
+	}
+	if err := Sign("bogus", "secret", header, Message{}); err == nil {
+		t.Error("expected an unknown scheme to fail")
+	}
+}
+
+func TestSign_Slack(t *testing.T) {
+	body := "token=xyzz0WbapA4vBCDEFasx0q6G&team_id=T1DC2JH3J&team_domain=testteamnow&channel_id=G8PSS9T3V&channel_name=foobar&user_id=U2CERLKJA&user_name=roadrunner&command=%2Fwebhook-collect&text=&response_url=https%3A%2F%2Fhooks.slack.com%2Fcommands%2FT1DC2JH3J%2F397700885554%2F96rGlfmibIGlgcZRskXaIFfN&trigger_id=398738663015.47445629121.803a0bc887a14d10d2c447fce8b6703c"
+	header := http.Header{}
+	err := Sign(Slack, "8f742231b10e8888abcd99yyyzzz85a5", header, Message{Timestamp: time.Unix(1531420618, 0), Body: []byte(body)})
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+
+	want := "v0=a2114d57b48eac39b9ad189dd8316235a7b4a8d21a10bd27519666489c69b503"
+	if got := header.Get("X-Slack-Signature"); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
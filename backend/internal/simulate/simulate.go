@@ -0,0 +1,360 @@
+package simulate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"webhook-inspector/internal/forward"
+	"webhook-inspector/internal/models"
+	"webhook-inspector/internal/outbound"
+	"webhook-inspector/internal/redis"
+	"webhook-inspector/internal/signing"
+	"webhook-inspector/internal/store"
+
+	"github.com/google/uuid"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// Simulation states
+const (
+	StatePending   = "pending"
+	StateDelivered = "delivered"
+	StateFailed    = "failed"
+)
+
+const (
+	dueKey         = "simulate:due"
+	defaultTimeout = 10 * time.Second
+	maxTimeout     = 30 * time.Second
+)
+
+// ErrNotFound is returned for unknown or expired simulations
+var ErrNotFound = errors.New("simulation not found")
+
+// RequestError reports a simulation request that can't be made as given
+type RequestError struct {
+	Err error
+}
+
+func (e *RequestError) Error() string { return e.Err.Error() }
+
+func (e *RequestError) Unwrap() error { return e.Err }
+
+// Delays between attempts after a non-2xx response, as each provider documents them.
+// GitHub never retries automatically and Twilio only retries failed connections;
+// Stripe backs off over roughly three days; Standard Webhooks follows the Svix
+// schedule it was derived from; Slack retries almost at once, then after one and five
+// minutes; Shopify makes eight retries with exponential backoff over about four hours.
+// A scheme missing here needs an explicit retry_schedule.
+var ProviderSchedules = map[string][]time.Duration{
+	signing.GitHub:           nil,
+	signing.Twilio:           nil,
+	signing.Stripe:           {time.Hour, 2 * time.Hour, 4 * time.Hour, 8 * time.Hour, 16 * time.Hour, 24 * time.Hour, 24 * time.Hour},
+	signing.StandardWebhooks: {5 * time.Second, 5 * time.Minute, 30 * time.Minute, 2 * time.Hour, 5 * time.Hour, 10 * time.Hour, 10 * time.Hour},
+	signing.Slack:            {time.Second, time.Minute, 5 * time.Minute},
+	signing.Shopify:          {time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute, 16 * time.Minute, 32 * time.Minute, 64 * time.Minute, 128 * time.Minute},
+}
+
+// Request defines an event and how the server should deliver it
+type Request struct {
+	URL     string            `json:"url"`
+	Method  string            `json:"method,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body"`
+	Sign    string            `json:"sign,omitempty"`
+	// Overrides the endpoint's secret for Sign
+	Secret  string `json:"secret,omitempty"`
+	Timeout string `json:"timeout,omitempty"`
+	// Custom delays between attempts; defaults to the provider schedule of Sign
+	RetrySchedule []string `json:"retry_schedule,omitempty"`
+	NoRetry       bool     `json:"no_retry,omitempty"`
+	// Multiplies every delay, e.g. 0.001 to walk a multi-day schedule in minutes
+	TimeScale float64 `json:"time_scale,omitempty"`
+}
+
+// Simulation is a delivery in progress or finished, with every attempt made so far
+type Simulation = models.Simulation
+
+// A simulation with its token and the secret its retries are signed with
+type record struct {
+	models.StoredSimulation
+	Token string
+}
+
+// Create validates the request, makes the first attempt and schedules any retries
+func Create(ctx context.Context, s store.Store, token string, req Request, secrets models.SigningSecrets) (*Simulation, error) {
+	rec, err := newRecord(token, req, secrets)
+	if err != nil {
+		return nil, &RequestError{Err: err}
+	}
+
+	attempt(ctx, rec)
+	if err := s.CreateSimulation(ctx, token, rec.StoredSimulation, retention(rec)); err != nil {
+		return nil, err
+	}
+	schedule(ctx, rec)
+	return &rec.Simulation, nil
+}
+
+func newRecord(token string, req Request, secrets models.SigningSecrets) (*record, error) {
+	err := outbound.CheckURL(req.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	method := strings.ToUpper(req.Method)
+	if method == "" {
+		method = http.MethodPost
+	}
+
+	secret := req.Secret
+	if req.Sign != "" {
+		if !signing.Valid(req.Sign) {
+			return nil, fmt.Errorf("sign must be one of %v", signing.Schemes)
+		}
+		if secret == "" {
+			secret = signing.SecretFor(secrets, req.Sign)
+		}
+		if secret == "" {
+			return nil, fmt.Errorf("no %s secret given or configured on the endpoint", req.Sign)
+		}
+	}
+
+	timeout := defaultTimeout
+	if req.Timeout != "" {
+		timeout, err = time.ParseDuration(req.Timeout)
+		if err != nil || timeout <= 0 || timeout > maxTimeout {
+			return nil, fmt.Errorf("timeout must be a duration up to %s", maxTimeout)
+		}
+	}
+
+	delays, err := buildSchedule(req)
+	if err != nil {
+		return nil, err
+	}
+
+	rec := &record{
+		StoredSimulation: models.StoredSimulation{
+			Simulation: Simulation{
+				ID:        uuid.New().String(),
+				URL:       req.URL,
+				Method:    method,
+				Headers:   req.Headers,
+				Body:      req.Body,
+				Sign:      req.Sign,
+				TimeoutMs: timeout.Milliseconds(),
+				State:     StatePending,
+				Attempts:  []models.DeliveryAttempt{},
+				CreatedAt: time.Now().UTC(),
+			},
+			Secret: secret,
+		},
+		Token: token,
+	}
+	for _, d := range delays {
+		rec.ScheduleMs = append(rec.ScheduleMs, d.Milliseconds())
+	}
+	return rec, nil
+}
+
+func buildSchedule(req Request) ([]time.Duration, error) {
+	if req.NoRetry {
+		return nil, nil
+	}
+
+	schedule, known := ProviderSchedules[req.Sign]
+	if !known && req.Sign != "" && len(req.RetrySchedule) == 0 {
+		return nil, fmt.Errorf("%s has no documented retry schedule; give retry_schedule or no_retry", req.Sign)
+	}
+	if len(req.RetrySchedule) > 0 {
+		if len(req.RetrySchedule) > 10 {
+			return nil, fmt.Errorf("retry_schedule allows at most 10 retries")
+		}
+		schedule = nil
+		for _, raw := range req.RetrySchedule {
+			d, err := time.ParseDuration(raw)
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("invalid retry delay %q", raw)
+			}
+			schedule = append(schedule, d)
+		}
+	}
+
+	if req.TimeScale < 0 || req.TimeScale > 1 {
+		return nil, fmt.Errorf("time_scale must be between 0 and 1")
+	}
+	if req.TimeScale > 0 {
+		scaled := make([]time.Duration, len(schedule))
+		for i, d := range schedule {
+			scaled[i] = max(time.Duration(float64(d)*req.TimeScale), time.Second)
+		}
+		schedule = scaled
+	}
+	return schedule, nil
+}
+
+// Make the next attempt and work out whether, and when, another one follows
+func attempt(ctx context.Context, rec *record) {
+	result := deliver(ctx, rec)
+	rec.Attempts = append(rec.Attempts, result)
+	rec.NextAttemptAt = nil
+
+	if result.Error == "" && result.StatusCode >= 200 && result.StatusCode < 300 {
+		rec.State = StateDelivered
+		return
+	}
+
+	retries := len(rec.Attempts) - 1
+	if retries >= len(rec.ScheduleMs) {
+		rec.State = StateFailed
+		return
+	}
+
+	due := time.Now().Add(time.Duration(rec.ScheduleMs[retries]) * time.Millisecond).UTC()
+	rec.NextAttemptAt = &due
+}
+
+// Queue the next retry of a saved simulation
+func schedule(ctx context.Context, rec *record) {
+	if rec.NextAttemptAt == nil {
+		return
+	}
+	member := rec.Token + "|" + rec.ID
+	if err := redis.Client.ZAdd(ctx, dueKey, goredis.Z{Score: float64(rec.NextAttemptAt.UnixMilli()), Member: member}).Err(); err != nil {
+		log.Printf("simulate: failed to schedule retry for %s: %v", rec.ID, err)
+	}
+}
+
+func deliver(ctx context.Context, rec *record) models.DeliveryAttempt {
+	attempt := models.DeliveryAttempt{
+		Kind:      "simulate",
+		URL:       rec.URL,
+		Attempt:   len(rec.Attempts) + 1,
+		Timestamp: time.Now().UTC(),
+	}
+
+	header := http.Header{}
+	for name, value := range rec.Headers {
+		header.Set(name, value)
+	}
+	if header.Get("Content-Type") == "" {
+		header.Set("Content-Type", "application/json")
+	}
+	if rec.Sign != "" {
+		// Providers sign every attempt with a fresh timestamp but keep the message ID
+		msg := signing.Message{ID: "msg_" + rec.ID, Timestamp: time.Now(), Body: []byte(rec.Body), URL: rec.URL}
+		if err := signing.Sign(rec.Sign, rec.Secret, header, msg); err != nil {
+			attempt.Error = err.Error()
+			return attempt
+		}
+	}
+
+	payload := models.WebhookPayload{Method: rec.Method, Headers: header, Body: rec.Body}
+	req, err := forward.NewRequest(ctx, payload, rec.URL, []string{})
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+
+	return forward.Send(outbound.WithTimeout(time.Duration(rec.TimeoutMs)*time.Millisecond), req, attempt)
+}
+
+// Run performs scheduled retries, reading and saving simulations in s, until ctx is cancelled
+func Run(ctx context.Context, s store.Store) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		due, err := redis.Client.ZRangeByScore(ctx, dueKey, &goredis.ZRangeBy{
+			Min: "-inf",
+			Max: strconv.FormatInt(time.Now().UnixMilli(), 10),
+		}).Result()
+		if err != nil {
+			log.Printf("simulate: failed to read schedule: %v", err)
+			continue
+		}
+
+		for _, member := range due {
+			if removed, err := redis.Client.ZRem(ctx, dueKey, member).Result(); err != nil || removed == 0 {
+				continue
+			}
+			token, id, _ := strings.Cut(member, "|")
+			rec, err := load(ctx, s, token, id)
+			if err != nil {
+				// Deleted or expired along with its token
+				continue
+			}
+			attempt(ctx, rec)
+			if err := s.SaveSimulation(ctx, token, rec.StoredSimulation, retention(rec)); err != nil {
+				log.Printf("simulate: failed to save %s: %v", id, err)
+				continue
+			}
+			schedule(ctx, rec)
+		}
+	}
+}
+
+// Get returns one simulation with all of its attempts
+func Get(ctx context.Context, s store.Store, token, id string) (*Simulation, error) {
+	rec, err := load(ctx, s, token, id)
+	if err != nil {
+		return nil, err
+	}
+	return &rec.Simulation, nil
+}
+
+// List returns the most recent simulations, newest first
+func List(ctx context.Context, s store.Store, token string) ([]Simulation, error) {
+	stored, err := s.ListSimulations(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	sims := make([]Simulation, 0, len(stored))
+	for _, sim := range stored {
+		sims = append(sims, sim.Simulation)
+	}
+	return sims, nil
+}
+
+// Pending counts the simulations for a token that still have a retry to come
+func Pending(ctx context.Context, s store.Store, token string) (int, error) {
+	return s.PendingSimulations(ctx, token)
+}
+
+// Purge removes every simulation for a token; pending retries are skipped once their record is gone
+func Purge(ctx context.Context, s store.Store, token string) error {
+	return s.DeleteSimulations(ctx, token)
+}
+
+func load(ctx context.Context, s store.Store, token, id string) (*record, error) {
+	sim, err := s.GetSimulation(ctx, token, id)
+	if err == store.ErrNotFound {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &record{StoredSimulation: *sim, Token: token}, nil
+}
+
+// Keep a simulation for a day past its last possible retry
+func retention(rec *record) time.Duration {
+	ttl := 24 * time.Hour
+	for _, ms := range rec.ScheduleMs {
+		ttl += time.Duration(ms) * time.Millisecond
+	}
+	return ttl
+}
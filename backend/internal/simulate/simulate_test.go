@@ -0,0 +1,106 @@
+package simulate
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"webhook-inspector/internal/models"
+	"webhook-inspector/internal/outbound"
+	"webhook-inspector/internal/signing"
+	"webhook-inspector/internal/store"
+)
+
+func TestBuildSchedule(t *testing.T) {
+	got, err := buildSchedule(Request{Sign: signing.StandardWebhooks})
+	if err != nil || len(got) != len(ProviderSchedules[signing.StandardWebhooks]) {
+		t.Fatalf("expected the Standard Webhooks schedule, got %v (%v)", got, err)
+	}
+
+	got, _ = buildSchedule(Request{Sign: signing.Stripe, TimeScale: 0.001})
+	if got[0] != 3600*time.Millisecond {
+		t.Errorf("expected scaled first delay of 3.6s, got %s", got[0])
+	}
+
+	got, _ = buildSchedule(Request{Sign: signing.GitHub})
+	if len(got) != 0 {
+		t.Errorf("expected GitHub to never retry, got %v", got)
+	}
+
+	if _, err := buildSchedule(Request{RetrySchedule: []string{"soon"}}); err == nil {
+		t.Error("expected an invalid delay to fail")
+	}
+
+	for _, scheme := range signing.Schemes {
+		if _, ok := ProviderSchedules[scheme]; !ok {
+			t.Errorf("expected a retry schedule for %s", scheme)
+		}
+	}
+	if _, err := buildSchedule(Request{Sign: "acme"}); err == nil {
+		t.Error("expected a scheme without a schedule to need retry_schedule")
+	}
+	if got, err := buildSchedule(Request{Sign: "acme", RetrySchedule: []string{"1m"}}); err != nil || len(got) != 1 {
+		t.Errorf("expected an explicit schedule to be used, got %v (%v)", got, err)
+	}
+}
+
+func TestCreate_ReportsInvalidRequests(t *testing.T) {
+	_, err := Create(context.Background(), store.NewMemory(), "abc123", Request{URL: "http://example.com", Sign: "acme"}, models.SigningSecrets{})
+	var invalid *RequestError
+	if !errors.As(err, &invalid) {
+		t.Errorf("expected a RequestError, got %v", err)
+	}
+}
+
+func TestNewRecord_RequiresSecretForSigning(t *testing.T) {
+	if _, err := newRecord("abc123", Request{URL: "http://example.com", Sign: signing.GitHub}, models.SigningSecrets{}); err == nil {
+		t.Error("expected signing without a secret to fail")
+	}
+
+	rec, err := newRecord("abc123", Request{URL: "http://example.com", Sign: signing.GitHub}, models.SigningSecrets{GitHub: "s3cret"})
+	if err != nil {
+		t.Fatalf("newRecord failed: %v", err)
+	}
+	if rec.Secret != "s3cret" || rec.Method != "POST" {
+		t.Errorf("expected endpoint secret and default method, got %q %q", rec.Secret, rec.Method)
+	}
+}
+
+func TestDeliver_SignsTwilioWithURL(t *testing.T) {
+	outbound.AllowPrivate = true
+	t.Cleanup(func() { outbound.AllowPrivate = false })
+
+	got := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got <- r.Header.Get("X-Twilio-Signature")
+	}))
+	defer srv.Close()
+
+	url := srv.URL + "/sms?x=1"
+	rec, err := newRecord("abc123", Request{
+		URL:     url,
+		Sign:    signing.Twilio,
+		Headers: map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+		Body:    "To=%2B15550001111&Body=hi&From=%2B15552223333",
+	}, models.SigningSecrets{Twilio: "s3cret"})
+	if err != nil {
+		t.Fatalf("newRecord failed: %v", err)
+	}
+
+	result := deliver(context.Background(), rec)
+	if result.Error != "" || result.StatusCode != http.StatusOK {
+		t.Fatalf("expected a delivered attempt, got %+v", result)
+	}
+
+	mac := hmac.New(sha1.New, []byte("s3cret"))
+	mac.Write([]byte(url + "Bodyhi" + "From+15552223333" + "To+15550001111"))
+	if want := base64.StdEncoding.EncodeToString(mac.Sum(nil)); <-got != want {
+		t.Errorf("expected the signature over the target URL, want %s", want)
+	}
+}
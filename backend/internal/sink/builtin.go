@@ -0,0 +1,208 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"webhook-inspector/internal/config"
+	"webhook-inspector/internal/redis"
+
+	"github.com/nats-io/nats.go"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+var (
+	connMu       sync.Mutex
+	redisClients = map[string]*goredis.Client{}
+	natsConns    = map[string]*nats.Conn{}
+	fileLocks    = map[string]*sync.Mutex{}
+)
+
+// Close drops the cached connections to external Redis and NATS servers
+func Close() {
+	connMu.Lock()
+	defer connMu.Unlock()
+
+	for url, client := range redisClients {
+		client.Close()
+		delete(redisClients, url)
+	}
+	for url, conn := range natsConns {
+		conn.Close()
+		delete(natsConns, url)
+	}
+}
+
+type redisStream struct {
+	url    string
+	stream string
+	maxLen int64
+	// Stream lives on the inspector's Redis and expires with the token's data
+	owned bool
+}
+
+func (s *redisStream) Publish(ctx context.Context, msg Message) error {
+	client, err := redisClient(s.url)
+	if err != nil {
+		return err
+	}
+	capture, err := json.Marshal(msg.Capture)
+	if err != nil {
+		return err
+	}
+
+	pipe := client.Pipeline()
+	pipe.XAdd(ctx, &goredis.XAddArgs{
+		Stream: s.stream,
+		MaxLen: s.maxLen,
+		Approx: true,
+		Values: map[string]interface{}{
+			"token":     msg.Token,
+			"id":        msg.Capture.ID,
+			"method":    msg.Capture.Method,
+			"timestamp": msg.Capture.Timestamp.Format(time.RFC3339Nano),
+			"capture":   capture,
+		},
+	})
+	if s.owned {
+		pipe.Expire(ctx, s.stream, config.WebhookDataTTL)
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func redisClient(url string) (*goredis.Client, error) {
+	if url == "" {
+		return redis.Client, nil
+	}
+
+	connMu.Lock()
+	defer connMu.Unlock()
+
+	if client, ok := redisClients[url]; ok {
+		return client, nil
+	}
+	opts, err := goredis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	client := goredis.NewClient(opts)
+	redisClients[url] = client
+	return client, nil
+}
+
+type natsSink struct {
+	url     string
+	subject string
+}
+
+func (s *natsSink) Publish(ctx context.Context, msg Message) error {
+	conn, err := natsConn(s.url)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if err := conn.Publish(s.subject, data); err != nil {
+		return err
+	}
+	// Core NATS has no acks; a flush at least confirms the server has the message
+	return conn.FlushWithContext(ctx)
+}
+
+func natsConn(url string) (*nats.Conn, error) {
+	connMu.Lock()
+	defer connMu.Unlock()
+
+	if conn, ok := natsConns[url]; ok && !conn.IsClosed() {
+		return conn, nil
+	}
+	conn, err := nats.Connect(url, nats.Name("webhook-inspector"), nats.Timeout(5*time.Second))
+	if err != nil {
+		return nil, fmt.Errorf("connect to %s: %w", url, err)
+	}
+	natsConns[url] = conn
+	return conn, nil
+}
+
+// Returned once a capped file sink is full
+var errFileFull = errors.New("file sink is full")
+
+type fileSink struct {
+	path     string
+	maxBytes int64 // 0 for no limit
+}
+
+func (s *fileSink) Publish(ctx context.Context, msg Message) error {
+	line, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	lock := fileLock(s.path)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if s.maxBytes > 0 {
+		info, err := file.Stat()
+		if err == nil && info.Size()+int64(len(line))+1 > s.maxBytes {
+			err = fmt.Errorf("%w at %d bytes", errFileFull, s.maxBytes)
+		}
+		if err != nil {
+			file.Close()
+			return err
+		}
+	}
+	_, err = file.Write(append(line, '\n'))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func fileLock(path string) *sync.Mutex {
+	connMu.Lock()
+	defer connMu.Unlock()
+
+	lock, ok := fileLocks[path]
+	if !ok {
+		lock = &sync.Mutex{}
+		fileLocks[path] = lock
+	}
+	return lock
+}
+
+var (
+	stdoutMu sync.Mutex
+	stdout   io.Writer = os.Stdout
+)
+
+type stdoutSink struct{}
+
+func (stdoutSink) Publish(ctx context.Context, msg Message) error {
+	line, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	stdoutMu.Lock()
+	defer stdoutMu.Unlock()
+	_, err = stdout.Write(append(line, '\n'))
+	return err
+}
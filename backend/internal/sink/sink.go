@@ -0,0 +1,387 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"webhook-inspector/internal/config"
+	"webhook-inspector/internal/models"
+	"webhook-inspector/internal/redis"
+	"webhook-inspector/internal/store"
+
+	"github.com/google/uuid"
+)
+
+// Sink types
+const (
+	TypeRedisStream = "redis_stream"
+	TypeNATS        = "nats"
+	TypeFile        = "file"
+	TypeStdout      = "stdout"
+)
+
+// Where a sink was configured
+const (
+	ScopeEndpoint = "endpoint"
+	ScopeInstance = "instance"
+)
+
+const (
+	queueKey      = "sink:queue"
+	processingKey = "sink:processing"
+	retryKey      = "sink:retry"
+	defaultMaxLen = 10000
+	maxMaxLen     = 100000
+)
+
+// Sink publishes captures to an external feed
+type Sink interface {
+	Publish(ctx context.Context, msg Message) error
+}
+
+// Message is the envelope every sink receives
+type Message struct {
+	Token   string                `json:"token"`
+	Capture models.WebhookPayload `json:"capture"`
+}
+
+// Job is one capture waiting to be published to one sink
+type Job struct {
+	ID        string                `json:"id"`
+	Token     string                `json:"token"`
+	Scope     string                `json:"scope"`
+	Sink      models.SinkConfig     `json:"sink"`
+	Payload   models.WebhookPayload `json:"payload"`
+	Attempt   int                   `json:"attempt"`
+	LastError string                `json:"last_error,omitempty"`
+}
+
+// Stats are the delivery counters of one sink
+type Stats struct {
+	models.SinkConfig
+	Scope     string `json:"scope"`
+	Delivered int64  `json:"delivered"`
+	Failures  int64  `json:"failures"`
+	Dropped   int64  `json:"dropped"`
+	LastError string `json:"last_error,omitempty"`
+}
+
+var (
+	instanceOnce  sync.Once
+	instanceSinks []models.SinkConfig
+	instanceErr   error
+)
+
+// Instance returns the instance-wide sinks parsed from SINKS
+func Instance() ([]models.SinkConfig, error) {
+	instanceOnce.Do(func() {
+		instanceSinks, instanceErr = ParseInstance(config.Sinks)
+	})
+	return instanceSinks, instanceErr
+}
+
+// ParseInstance decodes and validates a JSON array of instance-wide sinks
+func ParseInstance(raw string) ([]models.SinkConfig, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+
+	var sinks []models.SinkConfig
+	if err := json.Unmarshal([]byte(raw), &sinks); err != nil {
+		return nil, fmt.Errorf("SINKS: %w", err)
+	}
+	for i := range sinks {
+		if sinks[i].ID == "" {
+			sinks[i].ID = fmt.Sprintf("%s-%d", sinks[i].Type, i)
+		}
+		if err := Validate(&sinks[i], ScopeInstance); err != nil {
+			return nil, fmt.Errorf("SINKS[%d]: %w", i, err)
+		}
+	}
+	return sinks, nil
+}
+
+// Validate checks a sink definition and fills in defaults. Endpoint sinks are
+// user-supplied, so they may not choose a server, write to stdout or write outside
+// their token's directory under SINK_FILE_DIR.
+func Validate(cfg *models.SinkConfig, scope string) error {
+	if cfg.ID == "" {
+		cfg.ID = uuid.New().String()
+	}
+	if cfg.MaxLen < 0 || (scope == ScopeEndpoint && cfg.MaxLen > maxMaxLen) {
+		return fmt.Errorf("max_len must be between 0 and %d", maxMaxLen)
+	}
+
+	// Endpoint sinks only use the server's own connections, so they can't reach internal
+	// services or other tokens' keys
+	if scope == ScopeEndpoint && cfg.URL != "" {
+		return fmt.Errorf("url can only be set on instance-wide sinks")
+	}
+
+	switch cfg.Type {
+	case TypeRedisStream:
+		if cfg.Stream == "" {
+			return fmt.Errorf("redis_stream sinks need a stream")
+		}
+		if cfg.URL != "" {
+			if u, err := url.Parse(cfg.URL); err != nil || (u.Scheme != "redis" && u.Scheme != "rediss") {
+				return fmt.Errorf("url must be a redis:// or rediss:// URL")
+			}
+		}
+	case TypeNATS:
+		if cfg.Subject == "" {
+			return fmt.Errorf("nats sinks need a subject")
+		}
+		if cfg.URL == "" && config.NATSURL == "" {
+			if scope == ScopeEndpoint {
+				return fmt.Errorf("nats sinks are disabled on this instance (NATS_URL is not set)")
+			}
+			return fmt.Errorf("nats sinks need a url when NATS_URL is not set")
+		}
+		if cfg.URL != "" {
+			if u, err := url.Parse(cfg.URL); err != nil || (u.Scheme != "nats" && u.Scheme != "tls") {
+				return fmt.Errorf("url must be a nats:// or tls:// URL")
+			}
+		}
+	case TypeFile:
+		if cfg.Path == "" {
+			return fmt.Errorf("file sinks need a path")
+		}
+		if scope == ScopeEndpoint {
+			if config.SinkFileDir == "" {
+				return fmt.Errorf("file sinks are disabled on this instance")
+			}
+			if filepath.Base(cfg.Path) != cfg.Path || cfg.Path == "." || cfg.Path == ".." {
+				return fmt.Errorf("path must be a plain file name")
+			}
+		}
+	case TypeStdout:
+		if scope == ScopeEndpoint {
+			return fmt.Errorf("stdout sinks can only be configured instance-wide")
+		}
+	default:
+		return fmt.Errorf("unknown sink type %q", cfg.Type)
+	}
+	return nil
+}
+
+// New builds the sink described by cfg; "{token}" in a stream, subject or path
+// is replaced with the capture's token. Endpoint sinks are kept inside the token's
+// namespace: stream:{token}:, webhooks.{token}. and SINK_FILE_DIR/{token}/.
+func New(cfg models.SinkConfig, token, scope string) (Sink, error) {
+	expand := func(s string) string { return strings.ReplaceAll(s, "{token}", token) }
+
+	switch cfg.Type {
+	case TypeRedisStream:
+		stream := expand(cfg.Stream)
+		owned := scope == ScopeEndpoint
+		if owned {
+			// Keep user streams on the inspector's Redis inside the token's namespace
+			stream = streamKey(token, cfg.Stream)
+		}
+		maxLen := cfg.MaxLen
+		if maxLen == 0 {
+			maxLen = defaultMaxLen
+		}
+		return &redisStream{url: cfg.URL, stream: stream, maxLen: maxLen, owned: owned}, nil
+	case TypeNATS:
+		server := cfg.URL
+		if server == "" {
+			server = config.NATSURL
+		}
+		subject := expand(cfg.Subject)
+		if scope == ScopeEndpoint {
+			subject = natsSubject(token, cfg.Subject)
+		}
+		return &natsSink{url: server, subject: subject}, nil
+	case TypeFile:
+		if scope == ScopeEndpoint {
+			dir, err := fileDir(token)
+			if err != nil {
+				return nil, err
+			}
+			path, err := inFileDir(filepath.Join(dir, expand(cfg.Path)))
+			if err != nil {
+				return nil, err
+			}
+			return &fileSink{path: path, maxBytes: config.SinkFileMaxBytes}, nil
+		}
+		return &fileSink{path: expand(cfg.Path)}, nil
+	case TypeStdout:
+		return stdoutSink{}, nil
+	}
+	return nil, fmt.Errorf("unknown sink type %q", cfg.Type)
+}
+
+func streamKey(token, name string) string {
+	return fmt.Sprintf("stream:%s:%s", token, name)
+}
+
+func natsSubject(token, subject string) string {
+	return "webhooks." + token + "." + strings.ReplaceAll(subject, "{token}", token)
+}
+
+// Directory holding a token's endpoint file sinks. Tokens are UUIDs; anything else that
+// could name a path outside SINK_FILE_DIR is refused.
+func fileDir(token string) (string, error) {
+	if token == "" || strings.ContainsAny(token, `/\`) || strings.Contains(token, "..") {
+		return "", fmt.Errorf("invalid token %q for a file sink", token)
+	}
+	return inFileDir(filepath.Join(config.SinkFileDir, token))
+}
+
+// Clean path, making sure it still lies below SINK_FILE_DIR
+func inFileDir(path string) (string, error) {
+	root, err := filepath.Abs(config.SinkFileDir)
+	if err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("file sink path %q is outside SINK_FILE_DIR", path)
+	}
+	return filepath.Clean(path), nil
+}
+
+func statsKey(token, scope string) string {
+	if scope == ScopeInstance {
+		return "sinkstats:instance"
+	}
+	return "sinkstats:" + token
+}
+
+// Enqueue schedules publication of a stored capture to the token's sinks and the instance-wide ones
+func Enqueue(ctx context.Context, s store.Store, token string, payload models.WebhookPayload) error {
+	cfg, err := s.LoadConfig(ctx, token)
+	if err != nil {
+		return fmt.Errorf("load endpoint config: %w", err)
+	}
+	global, _ := Instance()
+	if len(cfg.Sinks) == 0 && len(global) == 0 {
+		return nil
+	}
+
+	var jobs []interface{}
+	add := func(sink models.SinkConfig, scope string) error {
+		data, err := json.Marshal(Job{ID: uuid.New().String(), Token: token, Scope: scope, Sink: sink, Payload: payload})
+		if err != nil {
+			return err
+		}
+		jobs = append(jobs, data)
+		return nil
+	}
+	for _, sc := range cfg.Sinks {
+		if err := add(sc, ScopeEndpoint); err != nil {
+			return err
+		}
+	}
+	for _, sc := range global {
+		if err := add(sc, ScopeInstance); err != nil {
+			return err
+		}
+	}
+	return redis.Client.LPush(ctx, queueKey, jobs...).Err()
+}
+
+// List returns the token's sinks and the instance-wide ones with their counters.
+// Instance sinks only expose their ID and type since their URLs may hold credentials.
+func List(ctx context.Context, s store.Store, token string) ([]Stats, error) {
+	cfg, err := s.LoadConfig(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	global, _ := Instance()
+
+	stats := make([]Stats, 0, len(cfg.Sinks)+len(global))
+	for _, sc := range cfg.Sinks {
+		stats = append(stats, Stats{SinkConfig: sc, Scope: ScopeEndpoint})
+	}
+	for _, sc := range global {
+		stats = append(stats, Stats{SinkConfig: models.SinkConfig{ID: sc.ID, Type: sc.Type}, Scope: ScopeInstance})
+	}
+
+	counters := map[string]map[string]string{}
+	for _, scope := range []string{ScopeEndpoint, ScopeInstance} {
+		values, err := redis.Client.HGetAll(ctx, statsKey(token, scope)).Result()
+		if err != nil {
+			return nil, err
+		}
+		counters[scope] = values
+	}
+
+	for i := range stats {
+		values := counters[stats[i].Scope]
+		id := stats[i].ID
+		fmt.Sscan(values[id+":delivered"], &stats[i].Delivered)
+		fmt.Sscan(values[id+":failures"], &stats[i].Failures)
+		fmt.Sscan(values[id+":dropped"], &stats[i].Dropped)
+		stats[i].LastError = values[id+":last_error"]
+	}
+	return stats, nil
+}
+
+// Purge removes the token's sink counters, its files and the streams its configured sinks
+// keep on the inspector's Redis. Streams of sinks removed earlier expire on their own.
+func Purge(ctx context.Context, s store.Store, token string) error {
+	var dir string
+	if config.SinkFileDir != "" {
+		var err error
+		if dir, err = fileDir(token); err != nil {
+			return err
+		}
+	}
+
+	cfg, err := s.LoadConfig(ctx, token)
+	if err != nil {
+		return fmt.Errorf("load endpoint config: %w", err)
+	}
+
+	keys := []string{statsKey(token, ScopeEndpoint)}
+	for _, sc := range cfg.Sinks {
+		if sc.Type == TypeRedisStream {
+			keys = append(keys, streamKey(token, sc.Stream))
+		}
+	}
+	if err := redis.Client.Del(ctx, keys...).Err(); err != nil {
+		return err
+	}
+	if dir != "" {
+		return os.RemoveAll(dir)
+	}
+	return nil
+}
+
+// Update the counters of a sink after an attempt
+func record(ctx context.Context, job Job, err error, dropped bool) {
+	key := statsKey(job.Token, job.Scope)
+	id := job.Sink.ID
+
+	pipe := redis.Client.Pipeline()
+	if err == nil {
+		pipe.HIncrBy(ctx, key, id+":delivered", 1)
+	} else {
+		pipe.HIncrBy(ctx, key, id+":failures", 1)
+		pipe.HSet(ctx, key, id+":last_error", fmt.Sprintf("%s: %v", time.Now().UTC().Format(time.RFC3339), err))
+		if dropped {
+			pipe.HIncrBy(ctx, key, id+":dropped", 1)
+		}
+	}
+	if job.Scope == ScopeEndpoint {
+		pipe.Expire(ctx, key, config.WebhookDataTTL)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("sink: failed to update counters for sink %s: %v", id, err)
+	}
+}
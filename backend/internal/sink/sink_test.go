@@ -0,0 +1,223 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"webhook-inspector/internal/config"
+	"webhook-inspector/internal/models"
+	"webhook-inspector/internal/store"
+)
+
+func TestValidate(t *testing.T) {
+	config.SinkFileDir = ""
+	config.NATSURL = ""
+
+	tests := []struct {
+		name  string
+		cfg   models.SinkConfig
+		scope string
+		err   string
+	}{
+		{"redis stream", models.SinkConfig{Type: TypeRedisStream, Stream: "captures"}, ScopeEndpoint, ""},
+		{"redis stream without name", models.SinkConfig{Type: TypeRedisStream}, ScopeEndpoint, "need a stream"},
+		{"redis stream bad url", models.SinkConfig{Type: TypeRedisStream, Stream: "s", URL: "http://x"}, ScopeInstance, "redis://"},
+		{"endpoint redis url", models.SinkConfig{Type: TypeRedisStream, Stream: "s", URL: "redis://127.0.0.1:6379"}, ScopeEndpoint, "instance-wide"},
+		{"nats", models.SinkConfig{Type: TypeNATS, Subject: "hooks", URL: "nats://localhost:4222"}, ScopeInstance, ""},
+		{"endpoint nats url", models.SinkConfig{Type: TypeNATS, Subject: "hooks", URL: "nats://10.0.0.1:4222"}, ScopeEndpoint, "instance-wide"},
+		{"nats without server", models.SinkConfig{Type: TypeNATS, Subject: "hooks"}, ScopeEndpoint, "NATS_URL"},
+		{"endpoint file disabled", models.SinkConfig{Type: TypeFile, Path: "out.ndjson"}, ScopeEndpoint, "disabled"},
+		{"instance file", models.SinkConfig{Type: TypeFile, Path: "/var/log/captures.ndjson"}, ScopeInstance, ""},
+		{"endpoint stdout", models.SinkConfig{Type: TypeStdout}, ScopeEndpoint, "instance-wide"},
+		{"instance stdout", models.SinkConfig{Type: TypeStdout}, ScopeInstance, ""},
+		{"unknown", models.SinkConfig{Type: "kafka"}, ScopeInstance, "unknown sink type"},
+		{"huge max_len", models.SinkConfig{Type: TypeRedisStream, Stream: "s", MaxLen: 1 << 30}, ScopeEndpoint, "max_len"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(&tt.cfg, tt.scope)
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if tt.cfg.ID == "" {
+					t.Error("expected a generated ID")
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("expected error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestValidateEndpointFilePath(t *testing.T) {
+	config.SinkFileDir = t.TempDir()
+	defer func() { config.SinkFileDir = "" }()
+
+	for _, path := range []string{"../escape.ndjson", "/etc/passwd", "a/b.ndjson", ".."} {
+		cfg := models.SinkConfig{Type: TypeFile, Path: path}
+		if err := Validate(&cfg, ScopeEndpoint); err == nil {
+			t.Errorf("expected %q to be rejected", path)
+		}
+	}
+
+	cfg := models.SinkConfig{Type: TypeFile, Path: "{token}.ndjson"}
+	if err := Validate(&cfg, ScopeEndpoint); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestParseInstance(t *testing.T) {
+	sinks, err := ParseInstance(`[{"type":"stdout"},{"type":"file","path":"/tmp/hooks.ndjson"}]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sinks) != 2 || sinks[0].ID != "stdout-0" || sinks[1].ID != "file-1" {
+		t.Fatalf("unexpected sinks: %+v", sinks)
+	}
+
+	if _, err := ParseInstance(`[{"type":"nats"}]`); err == nil {
+		t.Error("expected invalid sink to be rejected")
+	}
+	if sinks, err := ParseInstance(""); err != nil || sinks != nil {
+		t.Errorf("expected no sinks, got %v, %v", sinks, err)
+	}
+}
+
+func TestFileSinkAppendsNDJSON(t *testing.T) {
+	config.SinkFileDir = t.TempDir()
+	defer func() { config.SinkFileDir = "" }()
+
+	s, err := New(models.SinkConfig{Type: TypeFile, Path: "{token}.ndjson"}, "tok", ScopeEndpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"a", "b"} {
+		msg := Message{Token: "tok", Capture: models.WebhookPayload{ID: id, Method: "POST"}}
+		if err := s.Publish(context.Background(), msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(config.SinkFileDir, "tok", "tok.ndjson"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d: %s", len(lines), data)
+	}
+	var msg Message
+	if err := json.Unmarshal([]byte(lines[1]), &msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Token != "tok" || msg.Capture.ID != "b" {
+		t.Errorf("unexpected message: %+v", msg)
+	}
+}
+
+func TestStdoutSink(t *testing.T) {
+	var buf bytes.Buffer
+	stdout = &buf
+	defer func() { stdout = os.Stdout }()
+
+	s, err := New(models.SinkConfig{Type: TypeStdout}, "tok", ScopeInstance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Publish(context.Background(), Message{Token: "tok", Capture: models.WebhookPayload{ID: "x"}}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), `{"token":"tok","capture":{"id":"x"`) || !strings.HasSuffix(buf.String(), "\n") {
+		t.Errorf("unexpected output: %q", buf.String())
+	}
+}
+
+func TestNewRedisStreamNamespacesEndpointStreams(t *testing.T) {
+	s, err := New(models.SinkConfig{Type: TypeRedisStream, Stream: "captures"}, "tok", ScopeEndpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs := s.(*redisStream)
+	if rs.stream != "stream:tok:captures" || !rs.owned || rs.maxLen != defaultMaxLen {
+		t.Errorf("unexpected stream sink: %+v", rs)
+	}
+
+	s, _ = New(models.SinkConfig{Type: TypeRedisStream, Stream: "hooks.{token}", URL: "redis://other:6379"}, "tok", ScopeInstance)
+	if rs := s.(*redisStream); rs.stream != "hooks.tok" || rs.owned {
+		t.Errorf("unexpected external stream sink: %+v", rs)
+	}
+}
+
+func TestNewNATSNamespacesEndpointSubjects(t *testing.T) {
+	s, _ := New(models.SinkConfig{Type: TypeNATS, Subject: "captures"}, "tok", ScopeEndpoint)
+	if ns := s.(*natsSink); ns.subject != "webhooks.tok.captures" {
+		t.Errorf("endpoint subject = %s", ns.subject)
+	}
+	s, _ = New(models.SinkConfig{Type: TypeNATS, Subject: "hooks.{token}"}, "tok", ScopeInstance)
+	if ns := s.(*natsSink); ns.subject != "hooks.tok" {
+		t.Errorf("instance subject = %s", ns.subject)
+	}
+}
+
+func TestEndpointFileSinksAreSeparatedAndCapped(t *testing.T) {
+	config.SinkFileDir = t.TempDir()
+	config.SinkFileMaxBytes = 300
+	defer func() { config.SinkFileDir, config.SinkFileMaxBytes = "", 100<<20 }()
+
+	msg := Message{Capture: models.WebhookPayload{ID: "x", Method: "POST", Body: strings.Repeat("a", 100)}}
+	for _, token := range []string{"one", "two"} {
+		s, _ := New(models.SinkConfig{Type: TypeFile, Path: "out.ndjson"}, token, ScopeEndpoint)
+		msg.Token = token
+		if err := s.Publish(context.Background(), msg); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Publish(context.Background(), msg); !errors.Is(err, errFileFull) {
+			t.Errorf("%s: second publish err = %v", token, err)
+		}
+	}
+	for _, token := range []string{"one", "two"} {
+		data, err := os.ReadFile(filepath.Join(config.SinkFileDir, token, "out.ndjson"))
+		if err != nil || strings.Count(string(data), "\n") != 1 || !strings.Contains(string(data), `"token":"`+token+`"`) {
+			t.Errorf("%s file = %q, %v", token, data, err)
+		}
+	}
+}
+
+func TestFileSinksStayInsideSinkFileDir(t *testing.T) {
+	root := t.TempDir()
+	config.SinkFileDir = filepath.Join(root, "sinks")
+	defer func() { config.SinkFileDir = "" }()
+
+	outside := filepath.Join(root, "outside")
+	if err := os.Mkdir(outside, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, token := range []string{"../outside", "..", `..\outside`, "a/b", ""} {
+		if _, err := fileDir(token); err == nil {
+			t.Errorf("fileDir(%q) should be refused", token)
+		}
+		if _, err := New(models.SinkConfig{Type: TypeFile, Path: "out.ndjson"}, token, ScopeEndpoint); err == nil {
+			t.Errorf("New with token %q should be refused", token)
+		}
+	}
+	if _, err := New(models.SinkConfig{Type: TypeFile, Path: "{token}"}, "..", ScopeEndpoint); err == nil {
+		t.Error("a path expanding to .. should be refused")
+	}
+	if err := Purge(context.Background(), store.NewMemory(), "../outside"); err == nil {
+		t.Error("Purge should refuse a traversal token")
+	}
+	if _, err := os.Stat(outside); err != nil {
+		t.Errorf("directory outside SINK_FILE_DIR was touched: %v", err)
+	}
+}
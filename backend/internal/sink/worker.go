@@ -0,0 +1,164 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strconv"
+	"time"
+
+	"webhook-inspector/internal/config"
+	"webhook-inspector/internal/forward"
+	"webhook-inspector/internal/redis"
+	"webhook-inspector/internal/store"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+const publishTimeout = 10 * time.Second
+
+// Run starts the sink workers, reading endpoint settings from s, and the retry scheduler;
+// it blocks until ctx is cancelled
+func Run(ctx context.Context, s store.Store) {
+	if _, err := Instance(); err != nil {
+		log.Printf("sink: instance-wide sinks disabled: %v", err)
+	}
+	defer Close()
+
+	recoverInFlight(ctx)
+	for i := 0; i < config.SinkWorkers; i++ {
+		go work(ctx, s)
+	}
+	scheduleRetries(ctx)
+}
+
+// Jobs left in the processing list by a crashed worker go back on the queue.
+// With several instances this may publish a capture twice, which at-least-once allows.
+func recoverInFlight(ctx context.Context) {
+	for {
+		err := redis.Client.LMove(ctx, processingKey, queueKey, "RIGHT", "RIGHT").Err()
+		if err == goredis.Nil {
+			return
+		}
+		if err != nil {
+			log.Printf("sink: failed to recover in-flight jobs: %v", err)
+			return
+		}
+	}
+}
+
+func work(ctx context.Context, s store.Store) {
+	for ctx.Err() == nil {
+		// The job stays in the processing list until it is published or rescheduled
+		data, err := redis.Client.BLMove(ctx, queueKey, processingKey, "RIGHT", "LEFT", 5*time.Second).Result()
+		if err == goredis.Nil {
+			continue
+		}
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("sink: failed to pop job: %v", err)
+				time.Sleep(time.Second)
+			}
+			continue
+		}
+
+		var job Job
+		if err := json.Unmarshal([]byte(data), &job); err != nil {
+			log.Printf("sink: dropping malformed job: %v", err)
+		} else {
+			process(ctx, s, job)
+		}
+
+		if err := redis.Client.LRem(context.Background(), processingKey, 1, data).Err(); err != nil {
+			log.Printf("sink: failed to ack job: %v", err)
+		}
+	}
+}
+
+// Move retries whose backoff has elapsed back onto the queue
+func scheduleRetries(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		due, err := redis.Client.ZRangeByScore(ctx, retryKey, &goredis.ZRangeBy{
+			Min: "-inf",
+			Max: strconv.FormatInt(time.Now().UnixMilli(), 10),
+		}).Result()
+		if err != nil {
+			log.Printf("sink: failed to read retry schedule: %v", err)
+			continue
+		}
+
+		for _, data := range due {
+			// ZREM acts as the claim when several instances share the schedule
+			if removed, err := redis.Client.ZRem(ctx, retryKey, data).Result(); err != nil || removed == 0 {
+				continue
+			}
+			if err := redis.Client.LPush(ctx, queueKey, data).Err(); err != nil {
+				log.Printf("sink: failed to requeue retry: %v", err)
+			}
+		}
+	}
+}
+
+func process(ctx context.Context, s store.Store, job Job) {
+	if job.Scope == ScopeEndpoint && !stillConfigured(ctx, s, job) {
+		return
+	}
+
+	sink, err := New(job.Sink, job.Token, job.Scope)
+	if err != nil {
+		log.Printf("sink: dropping job for sink %s: %v", job.Sink.ID, err)
+		return
+	}
+
+	publishCtx, cancel := context.WithTimeout(ctx, publishTimeout)
+	err = sink.Publish(publishCtx, Message{Token: job.Token, Capture: job.Payload})
+	cancel()
+
+	job.Attempt++
+	if err == nil {
+		record(ctx, job, nil, false)
+		return
+	}
+
+	dropped := job.Attempt >= config.SinkMaxAttempts
+	record(ctx, job, err, dropped)
+	if dropped {
+		log.Printf("sink: dropping capture %s for %s sink %s after %d attempts: %v", job.Payload.ID, job.Sink.Type, job.Sink.ID, job.Attempt, err)
+		return
+	}
+
+	job.LastError = err.Error()
+	data, err := json.Marshal(job)
+	if err != nil {
+		log.Printf("sink: failed to marshal job %s: %v", job.ID, err)
+		return
+	}
+	due := time.Now().Add(forward.Backoff(job.Attempt))
+	if err := redis.Client.ZAdd(ctx, retryKey, goredis.Z{Score: float64(due.UnixMilli()), Member: data}).Err(); err != nil {
+		log.Printf("sink: failed to schedule retry for job %s: %v", job.ID, err)
+	}
+}
+
+// Endpoint sinks that were removed (or whose token was reset) stop receiving queued captures
+func stillConfigured(ctx context.Context, s store.Store, job Job) bool {
+	cfg, err := s.LoadConfig(ctx, job.Token)
+	if err != nil {
+		// Can't tell; publishing anyway keeps the at-least-once promise
+		return true
+	}
+	for _, configured := range cfg.Sinks {
+		if configured.ID == job.Sink.ID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,870 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"webhook-inspector/internal/models"
+	"webhook-inspector/internal/query"
+	"webhook-inspector/internal/share"
+)
+
+// Memory keeps everything in process, for tests and single-instance deployments without Redis
+type Memory struct {
+	mu  sync.Mutex
+	now func() time.Time
+
+	captures    map[string]map[string]entry // token -> capture ID -> JSON payload
+	owners      map[string]entry
+	bins        map[string]entry // JSON bin
+	binCreds    map[string]entry
+	credentials map[string]entry
+	binExpiry   map[string]time.Time
+	sessions    map[string]entry
+	userTokens  map[string]string
+	usage       map[string]entry
+	configs     map[string]entry            // JSON config
+	baselines   map[string]map[string]entry // token -> name -> JSON baseline
+	shares      map[string]entry            // link ID -> JSON link
+	replays     map[string]map[string]entry // token -> replay ID -> JSON batch
+	results     map[string]map[string]*resultLog
+	simulations map[string]map[string]entry // token -> simulation ID -> JSON simulation
+	simHistory  map[string][]string         // token -> simulation IDs, newest first
+}
+
+// A replay's report, kept as JSON lines
+type resultLog struct {
+	lines   []string
+	expires time.Time
+}
+
+// Records are kept serialised, like in Redis, so callers can't mutate stored data
+type entry struct {
+	value   string
+	count   int64
+	expires time.Time // zero never expires
+}
+
+func (e entry) live(now time.Time) bool {
+	return e.expires.IsZero() || now.Before(e.expires)
+}
+
+// NewMemory returns an empty in-memory store
+func NewMemory() *Memory {
+	return &Memory{
+		now:         time.Now,
+		captures:    make(map[string]map[string]entry),
+		owners:      make(map[string]entry),
+		bins:        make(map[string]entry),
+		binCreds:    make(map[string]entry),
+		credentials: make(map[string]entry),
+		binExpiry:   make(map[string]time.Time),
+		sessions:    make(map[string]entry),
+		userTokens:  make(map[string]string),
+		usage:       make(map[string]entry),
+		configs:     make(map[string]entry),
+		baselines:   make(map[string]map[string]entry),
+		shares:      make(map[string]entry),
+		replays:     make(map[string]map[string]entry),
+		results:     make(map[string]map[string]*resultLog),
+		simulations: make(map[string]map[string]entry),
+		simHistory:  make(map[string][]string),
+	}
+}
+
+func (m *Memory) expiry(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return m.now().Add(ttl)
+}
+
+// Look up a live entry, dropping it if it has expired; callers hold m.mu
+func (m *Memory) lookup(records map[string]entry, key string) (entry, bool) {
+	e, ok := records[key]
+	if !ok {
+		return entry{}, false
+	}
+	if !e.live(m.now()) {
+		delete(records, key)
+		return entry{}, false
+	}
+	return e, true
+}
+
+// Sweep drops every expired entry; lookups only drop the ones they come across
+func (m *Memory) Sweep() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	for _, records := range []map[string]entry{m.owners, m.bins, m.binCreds, m.credentials, m.sessions, m.usage, m.configs, m.shares} {
+		sweep(records, now)
+	}
+	for _, byToken := range []map[string]map[string]entry{m.captures, m.baselines, m.replays, m.simulations} {
+		for token, records := range byToken {
+			if sweep(records, now); len(records) == 0 {
+				delete(byToken, token)
+			}
+		}
+	}
+	for token, logs := range m.results {
+		for id, l := range logs {
+			if !l.expires.IsZero() && !now.Before(l.expires) {
+				delete(logs, id)
+			}
+		}
+		if len(logs) == 0 {
+			delete(m.results, token)
+		}
+	}
+	for token := range m.simHistory {
+		if _, ok := m.simulations[token]; !ok {
+			delete(m.simHistory, token)
+		}
+	}
+}
+
+// RunSweeper calls Sweep every interval until ctx is cancelled
+func (m *Memory) RunSweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.Sweep()
+		}
+	}
+}
+
+func sweep(records map[string]entry, now time.Time) {
+	for key, e := range records {
+		if !e.live(now) {
+			delete(records, key)
+		}
+	}
+}
+
+func (m *Memory) SaveCapture(_ context.Context, token string, payload models.WebhookPayload, ttl time.Duration) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.captures[token] == nil {
+		m.captures[token] = make(map[string]entry)
+	}
+	m.captures[token][payload.ID] = entry{value: string(data), expires: m.expiry(ttl)}
+	return nil
+}
+
+func (m *Memory) GetCapture(_ context.Context, token, id string) (*models.WebhookPayload, error) {
+	m.mu.Lock()
+	e, ok := m.lookup(m.captures[token], id)
+	m.mu.Unlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	var payload models.WebhookPayload
+	if err := json.Unmarshal([]byte(e.value), &payload); err != nil {
+		return nil, err
+	}
+	return &payload, nil
+}
+
+func (m *Memory) CaptureTTL(_ context.Context, token, id string) (time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.lookup(m.captures[token], id)
+	if !ok {
+		return 0, ErrNotFound
+	}
+	if e.expires.IsZero() {
+		return 0, nil
+	}
+	return e.expires.Sub(m.now()), nil
+}
+
+func (m *Memory) ListCaptures(_ context.Context, token string) ([]models.WebhookPayload, error) {
+	m.mu.Lock()
+	var raw []string
+	for id := range m.captures[token] {
+		if e, ok := m.lookup(m.captures[token], id); ok {
+			raw = append(raw, e.value)
+		}
+	}
+	if len(m.captures[token]) == 0 {
+		delete(m.captures, token)
+	}
+	m.mu.Unlock()
+
+	var captures []models.WebhookPayload
+	for _, data := range raw {
+		var parsed models.WebhookPayload
+		if err := json.Unmarshal([]byte(data), &parsed); err != nil {
+			continue
+		}
+		captures = append(captures, parsed)
+	}
+
+	// Same order as the Redis index: microsecond score, then ID
+	sort.Slice(captures, func(i, j int) bool {
+		a, b := captures[i].Timestamp.UnixMicro(), captures[j].Timestamp.UnixMicro()
+		if a != b {
+			return a < b
+		}
+		return captures[i].ID < captures[j].ID
+	})
+	return captures, nil
+}
+
+func (m *Memory) QueryCaptures(ctx context.Context, token string, q query.Query) (*query.Page, error) {
+	captures, err := m.ListCaptures(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]models.WebhookPayload, len(captures))
+	entries := make([]query.Entry, len(captures))
+	for i, c := range captures {
+		byID[c.ID] = c
+		entries[i] = query.Entry{ID: c.ID, Time: time.UnixMicro(c.Timestamp.UnixMicro())}
+	}
+	query.SortEntries(entries)
+
+	return query.Run(query.Entries(entries, q), q, func(ids []string) ([]models.WebhookPayload, error) {
+		loaded := make([]models.WebhookPayload, 0, len(ids))
+		for _, id := range ids {
+			loaded = append(loaded, byID[id])
+		}
+		return loaded, nil
+	})
+}
+
+func (m *Memory) CaptureIDs(ctx context.Context, token string) ([]string, error) {
+	captures, err := m.ListCaptures(ctx, token)
+	ids := make([]string, len(captures))
+	for i, c := range captures {
+		ids[i] = c.ID
+	}
+	return ids, err
+}
+
+func (m *Memory) CountCaptures(ctx context.Context, token string) (int, error) {
+	ids, err := m.CaptureIDs(ctx, token)
+	return len(ids), err
+}
+
+func (m *Memory) CaptureCounts(ctx context.Context, token string) (Counts, error) {
+	captures, err := m.ListCaptures(ctx, token)
+	if err != nil {
+		return Counts{}, err
+	}
+	counts := Counts{Total: len(captures)}
+	for _, c := range captures {
+		if c.Pinned {
+			counts.Pinned++
+		}
+		if !c.Read {
+			counts.Unread++
+		}
+	}
+	return counts, nil
+}
+
+func (m *Memory) OldestUnpinned(ctx context.Context, token string, keep int) ([]string, error) {
+	captures, err := m.ListCaptures(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	var unpinned []string
+	for _, c := range captures {
+		if !c.Pinned {
+			unpinned = append(unpinned, c.ID)
+		}
+	}
+	if len(unpinned) <= keep {
+		return nil, nil
+	}
+	return unpinned[:len(unpinned)-keep], nil
+}
+
+func (m *Memory) DeleteCapture(_ context.Context, token, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.captures[token], id)
+	return nil
+}
+
+func (m *Memory) DeleteCaptureIDs(_ context.Context, token string, ids []string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	deleted := 0
+	for _, id := range ids {
+		if _, ok := m.lookup(m.captures[token], id); ok {
+			delete(m.captures[token], id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (m *Memory) UpdateCaptures(_ context.Context, token string, ids []string, edit func(*models.WebhookPayload) bool) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	changed := 0
+	for _, id := range ids {
+		e, ok := m.lookup(m.captures[token], id)
+		if !ok {
+			continue
+		}
+		var payload models.WebhookPayload
+		if err := json.Unmarshal([]byte(e.value), &payload); err != nil || !edit(&payload) {
+			continue
+		}
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return changed, err
+		}
+		e.value = string(data)
+		m.captures[token][id] = e
+		changed++
+	}
+	return changed, nil
+}
+
+func (m *Memory) DeleteCaptures(_ context.Context, token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.captures, token)
+	return nil
+}
+
+func (m *Memory) TokenOwner(_ context.Context, token string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, _ := m.lookup(m.owners, token)
+	return e.value, nil
+}
+
+func (m *Memory) SetTokenOwner(_ context.Context, token, owner string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.owners[token] = entry{value: owner, expires: m.expiry(ttl)}
+	return nil
+}
+
+func (m *Memory) SaveBin(_ context.Context, bin models.Bin, credential string) error {
+	data, err := json.Marshal(bin)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bins[bin.Token] = entry{value: string(data), expires: bin.ExpiresAt}
+	m.binCreds[bin.Token] = entry{value: credential, expires: bin.ExpiresAt}
+	m.credentials[credential] = entry{value: bin.Token, expires: bin.ExpiresAt}
+	if bin.Owner != "" {
+		m.owners[bin.Token] = entry{value: bin.Owner, expires: bin.ExpiresAt}
+	}
+	m.binExpiry[bin.Token] = bin.ExpiresAt
+	return nil
+}
+
+func (m *Memory) GetBin(_ context.Context, token string) (*models.Bin, error) {
+	m.mu.Lock()
+	e, ok := m.lookup(m.bins, token)
+	m.mu.Unlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	var bin models.Bin
+	if err := json.Unmarshal([]byte(e.value), &bin); err != nil {
+		return nil, err
+	}
+	return &bin, nil
+}
+
+func (m *Memory) DeleteBin(_ context.Context, token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// Expired credentials must still be removed, so skip lookup here
+	if e, ok := m.binCreds[token]; ok {
+		delete(m.credentials, e.value)
+	}
+	delete(m.bins, token)
+	delete(m.binCreds, token)
+	delete(m.owners, token)
+	delete(m.binExpiry, token)
+	return nil
+}
+
+func (m *Memory) ClaimExpiredBins(_ context.Context, now time.Time) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var claimed []string
+	for token, expires := range m.binExpiry {
+		if !expires.After(now) {
+			claimed = append(claimed, token)
+			delete(m.binExpiry, token)
+		}
+	}
+	sort.Strings(claimed)
+	return claimed, nil
+}
+
+func (m *Memory) CredentialToken(_ context.Context, credential string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.lookup(m.credentials, credential)
+	if !ok {
+		return "", ErrNotFound
+	}
+	return e.value, nil
+}
+
+func (m *Memory) CreateSession(_ context.Context, session, login string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions[session] = entry{value: login, expires: m.expiry(ttl)}
+	return nil
+}
+
+func (m *Memory) SessionUser(_ context.Context, session string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.lookup(m.sessions, session)
+	if !ok {
+		return "", ErrNotFound
+	}
+	return e.value, nil
+}
+
+func (m *Memory) DeleteSession(_ context.Context, session string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, session)
+	return nil
+}
+
+func (m *Memory) UserToken(_ context.Context, login string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	token, ok := m.userTokens[login]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return token, nil
+}
+
+func (m *Memory) SetUserToken(_ context.Context, login, token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.userTokens[login] = token
+	return nil
+}
+
+func (m *Memory) IncrementUsage(_ context.Context, token string, ttl time.Duration) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, _ := m.lookup(m.usage, token)
+	e.count++
+	e.expires = m.expiry(ttl)
+	m.usage[token] = e
+	return e.count, nil
+}
+
+func (m *Memory) Usage(_ context.Context, token string) (int64, time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.lookup(m.usage, token)
+	if !ok {
+		return 0, 0, nil
+	}
+	if e.expires.IsZero() {
+		return e.count, 0, nil
+	}
+	return e.count, e.expires.Sub(m.now()), nil
+}
+
+func (m *Memory) ResetUsage(_ context.Context, token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.usage, token)
+	return nil
+}
+
+func (m *Memory) LoadConfig(_ context.Context, token string) (models.EndpointConfig, error) {
+	var cfg models.EndpointConfig
+
+	m.mu.Lock()
+	e, ok := m.lookup(m.configs, token)
+	m.mu.Unlock()
+	if !ok {
+		return cfg, nil
+	}
+
+	err := json.Unmarshal([]byte(e.value), &cfg)
+	return cfg, err
+}
+
+func (m *Memory) SaveConfig(_ context.Context, token string, cfg models.EndpointConfig, ttl time.Duration) error {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.configs[token] = entry{value: string(data), expires: m.expiry(ttl)}
+	return nil
+}
+
+func (m *Memory) DeleteConfig(_ context.Context, token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.configs, token)
+	return nil
+}
+
+func (m *Memory) SaveBaseline(_ context.Context, token string, b models.Baseline, ttl time.Duration) error {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.baselines[token] == nil {
+		m.baselines[token] = make(map[string]entry)
+	}
+	m.baselines[token][b.Name] = entry{value: string(data), expires: m.expiry(ttl)}
+	return nil
+}
+
+func (m *Memory) GetBaseline(_ context.Context, token, name string) (*models.Baseline, error) {
+	m.mu.Lock()
+	e, ok := m.lookup(m.baselines[token], name)
+	m.mu.Unlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	var b models.Baseline
+	if err := json.Unmarshal([]byte(e.value), &b); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+func (m *Memory) ListBaselines(ctx context.Context, token string) ([]models.Baseline, error) {
+	m.mu.Lock()
+	names := make([]string, 0, len(m.baselines[token]))
+	for name := range m.baselines[token] {
+		names = append(names, name)
+	}
+	m.mu.Unlock()
+	sort.Strings(names)
+
+	baselines := make([]models.Baseline, 0, len(names))
+	for _, name := range names {
+		b, err := m.GetBaseline(ctx, token, name)
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		baselines = append(baselines, *b)
+	}
+	return baselines, nil
+}
+
+func (m *Memory) DeleteBaseline(_ context.Context, token, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.baselines[token], name)
+	return nil
+}
+
+func (m *Memory) DeleteBaselines(_ context.Context, token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.baselines, token)
+	return nil
+}
+
+func (m *Memory) SaveShare(_ context.Context, l share.Link) error {
+	data, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.shares[l.ID] = entry{value: string(data), expires: l.ExpiresAt}
+	return nil
+}
+
+func (m *Memory) GetShare(_ context.Context, id string) (*share.Link, error) {
+	m.mu.Lock()
+	e, ok := m.lookup(m.shares, id)
+	m.mu.Unlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	var l share.Link
+	if err := json.Unmarshal([]byte(e.value), &l); err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+func (m *Memory) ListShares(ctx context.Context, token string) ([]share.Link, error) {
+	m.mu.Lock()
+	ids := make([]string, 0, len(m.shares))
+	for id := range m.shares {
+		ids = append(ids, id)
+	}
+	m.mu.Unlock()
+
+	var links []share.Link
+	for _, id := range ids {
+		l, err := m.GetShare(ctx, id)
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if l.Token == token {
+			links = append(links, *l)
+		}
+	}
+	sort.Slice(links, func(i, j int) bool { return links[i].CreatedAt.Before(links[j].CreatedAt) })
+	return links, nil
+}
+
+func (m *Memory) DeleteShare(ctx context.Context, token, id string) error {
+	l, err := m.GetShare(ctx, id)
+	if err != nil {
+		return err
+	}
+	if l.Token != token {
+		return ErrNotFound
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.shares, id)
+	return nil
+}
+
+func (m *Memory) DeleteShares(ctx context.Context, token string) error {
+	links, err := m.ListShares(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, l := range links {
+		delete(m.shares, l.ID)
+	}
+	return nil
+}
+
+func (m *Memory) SaveReplay(_ context.Context, token string, b models.ReplayBatch, ttl time.Duration) error {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.replays[token] == nil {
+		m.replays[token] = make(map[string]entry)
+	}
+	m.replays[token][b.ID] = entry{value: string(data), expires: m.expiry(ttl)}
+	return nil
+}
+
+func (m *Memory) UpdateReplay(_ context.Context, token string, b models.ReplayBatch, ttl time.Duration) error {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.lookup(m.replays[token], b.ID); !ok {
+		return ErrNotFound
+	}
+	m.replays[token][b.ID] = entry{value: string(data), expires: m.expiry(ttl)}
+	return nil
+}
+
+func (m *Memory) GetReplay(_ context.Context, token, id string) (*models.ReplayBatch, error) {
+	m.mu.Lock()
+	e, ok := m.lookup(m.replays[token], id)
+	m.mu.Unlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	var b models.ReplayBatch
+	if err := json.Unmarshal([]byte(e.value), &b); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+func (m *Memory) AppendReplayResult(_ context.Context, token, id string, r models.ReplayResult, ttl time.Duration) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.results[token] == nil {
+		m.results[token] = make(map[string]*resultLog)
+	}
+	l := m.results[token][id]
+	if l == nil || (!l.expires.IsZero() && !m.now().Before(l.expires)) {
+		l = &resultLog{}
+		m.results[token][id] = l
+	}
+	l.lines = append(l.lines, string(data))
+	l.expires = m.expiry(ttl)
+	return nil
+}
+
+func (m *Memory) ReplayResults(_ context.Context, token, id string) ([]models.ReplayResult, error) {
+	m.mu.Lock()
+	var lines []string
+	if l := m.results[token][id]; l != nil && (l.expires.IsZero() || m.now().Before(l.expires)) {
+		lines = l.lines
+	}
+	m.mu.Unlock()
+
+	results := make([]models.ReplayResult, 0, len(lines))
+	for _, line := range lines {
+		var r models.ReplayResult
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			continue
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+func (m *Memory) DeleteReplays(_ context.Context, token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.replays, token)
+	delete(m.results, token)
+	return nil
+}
+
+func (m *Memory) CreateSimulation(ctx context.Context, token string, sim models.StoredSimulation, ttl time.Duration) error {
+	if err := m.SaveSimulation(ctx, token, sim, ttl); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	history := append([]string{sim.ID}, m.simHistory[token]...)
+	m.simHistory[token] = history[:min(len(history), SimulationHistory)]
+	return nil
+}
+
+func (m *Memory) SaveSimulation(_ context.Context, token string, sim models.StoredSimulation, ttl time.Duration) error {
+	data, err := json.Marshal(sim)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.simulations[token] == nil {
+		m.simulations[token] = make(map[string]entry)
+	}
+	m.simulations[token][sim.ID] = entry{value: string(data), expires: m.expiry(ttl)}
+	return nil
+}
+
+func (m *Memory) GetSimulation(_ context.Context, token, id string) (*models.StoredSimulation, error) {
+	m.mu.Lock()
+	e, ok := m.lookup(m.simulations[token], id)
+	m.mu.Unlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	var sim models.StoredSimulation
+	if err := json.Unmarshal([]byte(e.value), &sim); err != nil {
+		return nil, err
+	}
+	return &sim, nil
+}
+
+func (m *Memory) ListSimulations(ctx context.Context, token string) ([]models.StoredSimulation, error) {
+	m.mu.Lock()
+	ids := append([]string(nil), m.simHistory[token]...)
+	m.mu.Unlock()
+
+	var sims []models.StoredSimulation
+	for _, id := range ids {
+		sim, err := m.GetSimulation(ctx, token, id)
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		sims = append(sims, *sim)
+	}
+	return sims, nil
+}
+
+func (m *Memory) PendingSimulations(_ context.Context, token string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	pending := 0
+	for id := range m.simulations[token] {
+		e, ok := m.lookup(m.simulations[token], id)
+		if !ok {
+			continue
+		}
+		var sim models.StoredSimulation
+		if err := json.Unmarshal([]byte(e.value), &sim); err != nil {
+			return 0, err
+		}
+		if sim.NextAttemptAt != nil {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+func (m *Memory) DeleteSimulations(_ context.Context, token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.simulations, token)
+	delete(m.simHistory, token)
+	return nil
+}
@@ -0,0 +1,1231 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"webhook-inspector/internal/models"
+	"webhook-inspector/internal/query"
+	"webhook-inspector/internal/search"
+	"webhook-inspector/internal/share"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// Sorted set of bin tokens scored by their expiry (unix seconds)
+const binExpiryKey = "bins:expiry"
+
+// Set once every capture stored before the per-token index existed has been indexed
+const captureIndexMigratedKey = "migrations:capture_index"
+
+// Set once every indexed capture has been added to the expiry, unpinned and unread indexes
+const captureFlagsMigratedKey = "migrations:capture_flags"
+
+// Keys per MGET/DEL batch
+const batchSize = 500
+
+// Adds a capture to its token's index, and to the expiry, unpinned and unread indexes
+// as its expiry and flags say. Each index lives as long as its longest-lived capture:
+// it is made persistent for captures without expiry and its TTL is only ever extended,
+// so an abandoned token's indexes disappear with its last capture.
+//
+// KEYS[1] index, KEYS[2] expiry index, KEYS[3] unpinned index, KEYS[4] unread set;
+// ARGV[1] score, ARGV[2] capture ID, ARGV[3] capture TTL in ms (0 = none),
+// ARGV[4] "1" if pinned, ARGV[5] "1" if read
+var indexCaptureScript = goredis.NewScript(`
+local id = ARGV[2]
+local ttl = tonumber(ARGV[3])
+local function add(cmd, key, ...)
+	local current = redis.call('PTTL', key)
+	redis.call(cmd, key, ...)
+	if ttl == 0 then
+		redis.call('PERSIST', key)
+	elseif current == -2 or (current >= 0 and current < ttl) then
+		redis.call('PEXPIRE', key, ttl)
+	end
+end
+add('ZADD', KEYS[1], ARGV[1], id)
+if ttl == 0 then
+	redis.call('ZREM', KEYS[2], id)
+else
+	local now = redis.call('TIME')
+	add('ZADD', KEYS[2], tonumber(now[1]) * 1000 + math.floor(tonumber(now[2]) / 1000) + ttl, id)
+end
+if ARGV[4] == '1' then
+	redis.call('ZREM', KEYS[3], id)
+else
+	add('ZADD', KEYS[3], ARGV[1], id)
+end
+if ARGV[5] == '1' then
+	redis.call('SREM', KEYS[4], id)
+else
+	add('SADD', KEYS[4], id)
+end
+return 1
+`)
+
+// Drops captures whose expiry has passed from a token's indexes.
+//
+// KEYS[1] expiry index, KEYS[2] index, KEYS[3] unpinned index, KEYS[4] unread set
+var pruneExpiredScript = goredis.NewScript(`
+local now = redis.call('TIME')
+local ids = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', tonumber(now[1]) * 1000 + math.floor(tonumber(now[2]) / 1000))
+for i = 1, #ids, 500 do
+	local batch = {unpack(ids, i, math.min(i + 499, #ids))}
+	redis.call('ZREM', KEYS[1], unpack(batch))
+	redis.call('ZREM', KEYS[2], unpack(batch))
+	redis.call('ZREM', KEYS[3], unpack(batch))
+	redis.call('SREM', KEYS[4], unpack(batch))
+end
+return #ids
+`)
+
+// Redis keeps everything in Redis under the keys the inspector has always used
+type Redis struct {
+	client *goredis.Client
+}
+
+// NewRedis returns a store backed by client
+func NewRedis(client *goredis.Client) *Redis {
+	return &Redis{client: client}
+}
+
+func captureKey(token, id string) string {
+	return fmt.Sprintf("hooks:%s:%s", token, id)
+}
+
+// Sorted set of a token's capture IDs scored by capture time (unix microseconds)
+func captureIndexKey(token string) string {
+	return "capture_index:" + token
+}
+
+// Sorted set of a token's expiring capture IDs scored by expiry (unix milliseconds)
+func captureExpiryKey(token string) string {
+	return "capture_expiry:" + token
+}
+
+// Sorted set of a token's unpinned capture IDs scored like the capture index
+func captureUnpinnedKey(token string) string {
+	return "capture_unpinned:" + token
+}
+
+// Set of a token's unread capture IDs
+func captureUnreadKey(token string) string {
+	return "capture_unread:" + token
+}
+
+// The capture index and the indexes derived from it, in the order the scripts take them
+func indexKeys(token string) []string {
+	return []string{captureIndexKey(token), captureExpiryKey(token), captureUnpinnedKey(token), captureUnreadKey(token)}
+}
+
+func ownerKey(token string) string {
+	return "token:" + token + ":owner"
+}
+
+func binKey(token string) string {
+	return "token:" + token + ":bin"
+}
+
+func binCredentialKey(token string) string {
+	return "token:" + token + ":bearer"
+}
+
+func credentialKey(credential string) string {
+	return "bearer:" + credential
+}
+
+func sessionKey(session string) string {
+	return "user:" + session
+}
+
+func userTokenKey(login string) string {
+	return "user:" + login + ":webhook_token"
+}
+
+func usageKey(token string) string {
+	return "rate_limit:" + token
+}
+
+func configKey(token string) string {
+	return "token:" + token + ":config"
+}
+
+// Hash of baseline name to JSON baseline
+func baselinesKey(token string) string {
+	return "token:" + token + ":baselines"
+}
+
+func shareKey(id string) string {
+	return "share:" + id
+}
+
+// Set of a token's link IDs, living as long as its longest-lived link
+func sharesKey(token string) string {
+	return "token:" + token + ":shares"
+}
+
+func replayKey(token, id string) string {
+	return fmt.Sprintf("replayjob:%s:%s", token, id)
+}
+
+// List of a replay's JSON results
+func replayResultsKey(token, id string) string {
+	return replayKey(token, id) + ":results"
+}
+
+// Set of every replay ID a token has stored, living as long as its longest-lived replay
+func replaysKey(token string) string {
+	return "replayjobs:" + token
+}
+
+func simulationKey(token, id string) string {
+	return fmt.Sprintf("simulation:%s:%s", token, id)
+}
+
+// List of a token's newest simulation IDs, newest first
+func simulationsKey(token string) string {
+	return "simulations:" + token
+}
+
+// Set of a token's simulation IDs with a retry still to come, which may have left the history
+func pendingSimulationsKey(token string) string {
+	return "simulations_pending:" + token
+}
+
+// Set of every simulation ID a token has stored, so deleting reaches those past the history
+func allSimulationsKey(token string) string {
+	return "simulations_all:" + token
+}
+
+// Get a string value, mapping a missing key to ErrNotFound
+func (s *Redis) get(ctx context.Context, key string) (string, error) {
+	value, err := s.client.Get(ctx, key).Result()
+	if err == goredis.Nil {
+		return "", ErrNotFound
+	}
+	return value, err
+}
+
+func (s *Redis) SaveCapture(ctx context.Context, token string, payload models.WebhookPayload, ttl time.Duration) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	// The capture, its index entries and its search terms are written in one transaction
+	pipe := s.client.TxPipeline()
+	pipe.Set(ctx, captureKey(token, payload.ID), data, ttl)
+	indexCaptureScript.Eval(ctx, pipe, indexKeys(token), indexArgs(payload, ttl)...)
+	indexTerms(ctx, pipe, token, payload, ttl)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (s *Redis) index(ctx context.Context, token string, payload models.WebhookPayload, ttl time.Duration) error {
+	return indexCaptureScript.Run(ctx, s.client, indexKeys(token), indexArgs(payload, ttl)...).Err()
+}
+
+func indexArgs(payload models.WebhookPayload, ttl time.Duration) []interface{} {
+	return []interface{}{payload.Timestamp.UnixMicro(), payload.ID, ttl.Milliseconds(), flag(payload.Pinned), flag(payload.Read)}
+}
+
+func flag(set bool) string {
+	if set {
+		return "1"
+	}
+	return "0"
+}
+
+// Drop expired captures from the indexes so they can be counted without loading captures
+func (s *Redis) pruneExpired(ctx context.Context, token string) error {
+	return pruneExpiredScript.Run(ctx, s.client, []string{captureExpiryKey(token), captureIndexKey(token), captureUnpinnedKey(token), captureUnreadKey(token)}).Err()
+}
+
+func (s *Redis) OldestUnpinned(ctx context.Context, token string, keep int) ([]string, error) {
+	if err := s.pruneExpired(ctx, token); err != nil {
+		return nil, err
+	}
+	unpinned, err := s.client.ZCard(ctx, captureUnpinnedKey(token)).Result()
+	if err != nil || unpinned <= int64(keep) {
+		return nil, err
+	}
+	return s.client.ZRange(ctx, captureUnpinnedKey(token), 0, unpinned-int64(keep)-1).Result()
+}
+
+// Remove capture IDs from every index in a transaction
+func unindex(ctx context.Context, pipe goredis.Pipeliner, token string, ids []interface{}) {
+	pipe.ZRem(ctx, captureIndexKey(token), ids...)
+	pipe.ZRem(ctx, captureExpiryKey(token), ids...)
+	pipe.ZRem(ctx, captureUnpinnedKey(token), ids...)
+	pipe.SRem(ctx, captureUnreadKey(token), ids...)
+}
+
+func (s *Redis) CaptureCounts(ctx context.Context, token string) (Counts, error) {
+	if err := s.pruneExpired(ctx, token); err != nil {
+		return Counts{}, err
+	}
+	pipe := s.client.Pipeline()
+	total := pipe.ZCard(ctx, captureIndexKey(token))
+	unpinned := pipe.ZCard(ctx, captureUnpinnedKey(token))
+	unread := pipe.SCard(ctx, captureUnreadKey(token))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return Counts{}, err
+	}
+	return Counts{
+		Total:  int(total.Val()),
+		Pinned: int(total.Val() - unpinned.Val()),
+		Unread: int(unread.Val()),
+	}, nil
+}
+
+func (s *Redis) GetCapture(ctx context.Context, token, id string) (*models.WebhookPayload, error) {
+	data, err := s.get(ctx, captureKey(token, id))
+	if err != nil {
+		return nil, err
+	}
+
+	var payload models.WebhookPayload
+	if err := json.Unmarshal([]byte(data), &payload); err != nil {
+		return nil, err
+	}
+	return &payload, nil
+}
+
+func (s *Redis) CaptureTTL(ctx context.Context, token, id string) (time.Duration, error) {
+	ttl, err := s.client.PTTL(ctx, captureKey(token, id)).Result()
+	if err != nil {
+		return 0, err
+	}
+	// PTTL answers -2 for a missing key and -1 for one without expiry
+	if ttl == -2 {
+		return 0, ErrNotFound
+	}
+	return max(ttl, 0), nil
+}
+
+func (s *Redis) ListCaptures(ctx context.Context, token string) ([]models.WebhookPayload, error) {
+	ids, err := s.CaptureIDs(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	return s.fetch(ctx, token, ids)
+}
+
+func (s *Redis) QueryCaptures(ctx context.Context, token string, q query.Query) (*query.Page, error) {
+	if err := s.pruneExpired(ctx, token); err != nil {
+		return nil, err
+	}
+	index := &redisIndex{ctx: ctx, client: s.client, key: captureIndexKey(token), min: math.MinInt64, max: math.MaxInt64, after: q.After}
+	if q.Since != nil {
+		index.min = q.Since.UnixMicro()
+	}
+	if q.After != nil {
+		index.min = max(index.min, q.After.Time.UnixMicro())
+	}
+	if q.Until != nil {
+		index.max = q.Until.UnixMicro()
+	}
+
+	candidates, err := s.searchCandidates(ctx, token, q.Filter, index)
+	if err != nil {
+		return nil, err
+	}
+	return query.Run(candidates, q, func(ids []string) ([]models.WebhookPayload, error) {
+		return s.fetch(ctx, token, ids)
+	})
+}
+
+// A token's capture index between two scores (inclusive) and after one entry, read
+// with ZRANGEBYSCORE ... LIMIT so a page costs only the entries it walks
+type redisIndex struct {
+	ctx      context.Context
+	client   *goredis.Client
+	key      string
+	min, max int64
+	after    *query.Entry
+}
+
+func (ix *redisIndex) Count() (int, error) {
+	n, err := ix.client.ZCount(ix.ctx, ix.key, score(ix.min, "-inf"), score(ix.max, "+inf")).Result()
+	if err != nil || ix.after == nil {
+		return int(n), err
+	}
+	// Entries sharing the after entry's score but not after it are inside the score range
+	at := strconv.FormatInt(ix.after.Time.UnixMicro(), 10)
+	tied, err := ix.client.ZRangeByScore(ix.ctx, ix.key, &goredis.ZRangeBy{Min: at, Max: at}).Result()
+	if err != nil {
+		return 0, err
+	}
+	for _, id := range tied {
+		if id <= ix.after.ID {
+			n--
+		}
+	}
+	return int(n), nil
+}
+
+func (ix *redisIndex) Scan(from *query.Entry, desc bool, n int) ([]query.Entry, error) {
+	lo, hi := ix.min, ix.max
+	if from != nil && desc {
+		hi = min(hi, from.Time.UnixMicro())
+	} else if from != nil {
+		lo = max(lo, from.Time.UnixMicro())
+	}
+
+	var entries []query.Entry
+	// Entries tied on score with from or after come back too and are skipped, so keep
+	// reading until n are found or the range runs out
+	for offset := int64(0); len(entries) < n; {
+		by := &goredis.ZRangeBy{Min: score(lo, "-inf"), Max: score(hi, "+inf"), Offset: offset, Count: int64(n - len(entries))}
+		var members []goredis.Z
+		var err error
+		if desc {
+			members, err = ix.client.ZRevRangeByScoreWithScores(ix.ctx, ix.key, by).Result()
+		} else {
+			members, err = ix.client.ZRangeByScoreWithScores(ix.ctx, ix.key, by).Result()
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range members {
+			e := query.Entry{ID: m.Member.(string), Time: time.UnixMicro(int64(m.Score))}
+			if ix.after != nil && !ix.after.Before(e) {
+				continue
+			}
+			if from != nil && (desc && !e.Before(*from) || !desc && !from.Before(e)) {
+				continue
+			}
+			entries = append(entries, e)
+		}
+		if int64(len(members)) < by.Count {
+			break
+		}
+		offset += int64(len(members))
+	}
+	return entries, nil
+}
+
+// A ZRANGEBYSCORE bound, or the given infinity for an open end
+func score(v int64, open string) string {
+	if v == math.MinInt64 || v == math.MaxInt64 {
+		return open
+	}
+	return strconv.FormatInt(v, 10)
+}
+
+// Load captures by ID in index order, pruning index entries whose capture has expired
+func (s *Redis) fetch(ctx context.Context, token string, ids []string) ([]models.WebhookPayload, error) {
+	captures := make([]models.WebhookPayload, 0, len(ids))
+	var expired []interface{}
+
+	for start := 0; start < len(ids); start += batchSize {
+		batch := ids[start:min(start+batchSize, len(ids))]
+		keys := make([]string, len(batch))
+		for i, id := range batch {
+			keys[i] = captureKey(token, id)
+		}
+
+		values, err := s.client.MGet(ctx, keys...).Result()
+		if err != nil {
+			return nil, err
+		}
+		for i, value := range values {
+			data, ok := value.(string)
+			if !ok {
+				expired = append(expired, batch[i])
+				continue
+			}
+
+			var parsed models.WebhookPayload
+			if err := json.Unmarshal([]byte(data), &parsed); err != nil {
+				continue // skip invalid entries
+			}
+			captures = append(captures, parsed)
+		}
+	}
+
+	if len(expired) > 0 {
+		pipe := s.client.TxPipeline()
+		unindex(ctx, pipe, token, expired)
+		if _, err := pipe.Exec(ctx); err != nil {
+			return nil, fmt.Errorf("prune capture index: %w", err)
+		}
+	}
+	return captures, nil
+}
+
+func (s *Redis) CaptureIDs(ctx context.Context, token string) ([]string, error) {
+	return s.client.ZRange(ctx, captureIndexKey(token), 0, -1).Result()
+}
+
+func (s *Redis) CountCaptures(ctx context.Context, token string) (int, error) {
+	ids, err := s.CaptureIDs(ctx, token)
+	if err != nil {
+		return 0, err
+	}
+
+	// The index outlives expired captures until they are pruned, so check each one
+	var expired []interface{}
+	for start := 0; start < len(ids); start += batchSize {
+		batch := ids[start:min(start+batchSize, len(ids))]
+		pipe := s.client.Pipeline()
+		exists := make([]*goredis.IntCmd, len(batch))
+		for i, id := range batch {
+			exists[i] = pipe.Exists(ctx, captureKey(token, id))
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			return 0, err
+		}
+		for i, cmd := range exists {
+			if cmd.Val() == 0 {
+				expired = append(expired, batch[i])
+			}
+		}
+	}
+
+	if len(expired) > 0 {
+		pipe := s.client.TxPipeline()
+		unindex(ctx, pipe, token, expired)
+		if _, err := pipe.Exec(ctx); err != nil {
+			return 0, fmt.Errorf("prune capture index: %w", err)
+		}
+	}
+	return len(ids) - len(expired), nil
+}
+
+func (s *Redis) DeleteCapture(ctx context.Context, token, id string) error {
+	payload, err := s.GetCapture(ctx, token, id)
+	if err != nil && err != ErrNotFound {
+		return err
+	}
+	if payload != nil {
+		if err := s.unindexTerms(ctx, token, *payload); err != nil {
+			return err
+		}
+	}
+
+	pipe := s.client.TxPipeline()
+	pipe.Del(ctx, captureKey(token, id))
+	unindex(ctx, pipe, token, []interface{}{id})
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (s *Redis) DeleteCaptureIDs(ctx context.Context, token string, ids []string) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	// Expired captures have nothing left to unindex
+	captures, err := s.fetch(ctx, token, ids)
+	if err != nil {
+		return 0, err
+	}
+
+	keys := make([]string, len(ids))
+	members := make([]interface{}, len(ids))
+	for i, id := range ids {
+		keys[i] = captureKey(token, id)
+		members[i] = id
+	}
+	pipe := s.client.TxPipeline()
+	deleted := pipe.Del(ctx, keys...)
+	unindex(ctx, pipe, token, members)
+	for _, c := range captures {
+		for _, term := range search.Terms(c) {
+			pipe.SRem(ctx, searchTermKey(token, term), c.ID)
+		}
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return int(deleted.Val()), nil
+}
+
+// Attempts at an optimistic UpdateCaptures transaction before giving up
+const maxUpdateAttempts = 5
+
+func (s *Redis) UpdateCaptures(ctx context.Context, token string, ids []string, edit func(*models.WebhookPayload) bool) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = captureKey(token, id)
+	}
+
+	changed := 0
+	update := func(tx *goredis.Tx) error {
+		values, err := tx.MGet(ctx, keys...).Result()
+		if err != nil {
+			return err
+		}
+		type update struct {
+			key     string
+			payload models.WebhookPayload
+			data    []byte
+			ttl     *goredis.DurationCmd
+		}
+		var edited []update
+		for i, value := range values {
+			data, ok := value.(string)
+			if !ok {
+				continue // expired
+			}
+			u := update{key: keys[i]}
+			if err := json.Unmarshal([]byte(data), &u.payload); err != nil || !edit(&u.payload) {
+				continue
+			}
+			if u.data, err = json.Marshal(u.payload); err != nil {
+				return err
+			}
+			edited = append(edited, u)
+		}
+		changed = len(edited)
+		if changed == 0 {
+			return nil
+		}
+
+		// The indexes need each capture's remaining TTL, which the watch keeps stable
+		pipe := tx.Pipeline()
+		for i := range edited {
+			edited[i].ttl = pipe.PTTL(ctx, edited[i].key)
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+			for _, u := range edited {
+				if u.ttl.Val() == -2 {
+					continue // expired since it was read
+				}
+				pipe.SetArgs(ctx, u.key, u.data, goredis.SetArgs{KeepTTL: true})
+				indexCaptureScript.Eval(ctx, pipe, indexKeys(token), indexArgs(u.payload, max(u.ttl.Val(), 0))...)
+			}
+			return nil
+		})
+		return err
+	}
+
+	// Retry when another writer touched one of the captures mid-way
+	for attempt := 0; attempt < maxUpdateAttempts; attempt++ {
+		err := s.client.Watch(ctx, update, keys...)
+		if err != goredis.TxFailedErr {
+			return changed, err
+		}
+	}
+	return 0, goredis.TxFailedErr
+}
+
+func (s *Redis) DeleteCaptures(ctx context.Context, token string) error {
+	ids, err := s.CaptureIDs(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	for start := 0; start < len(ids); start += batchSize {
+		batch := ids[start:min(start+batchSize, len(ids))]
+		keys := make([]string, len(batch))
+		for i, id := range batch {
+			keys[i] = captureKey(token, id)
+		}
+		if err := s.client.Del(ctx, keys...).Err(); err != nil {
+			return err
+		}
+	}
+	if err := s.deleteTerms(ctx, token); err != nil {
+		return err
+	}
+	return s.client.Del(ctx, indexKeys(token)...).Err()
+}
+
+// IndexExistingCaptures adds captures stored before the per-token index existed to it.
+// It walks the keyspace with SCAN once, then records that the migration is done.
+func (s *Redis) IndexExistingCaptures(ctx context.Context) error {
+	if done, err := s.client.Exists(ctx, captureIndexMigratedKey).Result(); err != nil || done > 0 {
+		return err
+	}
+
+	indexed := 0
+	iter := s.client.Scan(ctx, 0, captureKey("*", "*"), 1000).Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+		parts := strings.Split(key, ":")
+		if len(parts) != 3 {
+			continue
+		}
+
+		data, err := s.client.Get(ctx, key).Result()
+		if err == goredis.Nil {
+			continue // expired while scanning
+		}
+		if err != nil {
+			return err
+		}
+		ttl, err := s.client.PTTL(ctx, key).Result()
+		if err != nil {
+			return err
+		}
+
+		var payload models.WebhookPayload
+		if err := json.Unmarshal([]byte(data), &payload); err != nil {
+			continue
+		}
+		payload.ID = parts[2]
+		if err := s.index(ctx, parts[1], payload, max(ttl, 0)); err != nil {
+			return err
+		}
+		indexed++
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+
+	log.Printf("Indexed %d existing captures", indexed)
+	return s.client.Set(ctx, captureIndexMigratedKey, time.Now().UTC().Format(time.RFC3339), 0).Err()
+}
+
+// IndexExistingCaptureFlags adds captures indexed before the expiry, unpinned and unread
+// indexes existed to them. It walks the capture indexes with SCAN once, then records
+// that it is done.
+func (s *Redis) IndexExistingCaptureFlags(ctx context.Context) error {
+	if done, err := s.client.Exists(ctx, captureFlagsMigratedKey).Result(); err != nil || done > 0 {
+		return err
+	}
+
+	indexed := 0
+	prefix := captureIndexKey("")
+	iter := s.client.Scan(ctx, 0, captureIndexKey("*"), 1000).Iterator()
+	for iter.Next(ctx) {
+		token := iter.Val()[len(prefix):]
+		captures, err := s.ListCaptures(ctx, token)
+		if err != nil {
+			return err
+		}
+		for _, payload := range captures {
+			ttl, err := s.client.PTTL(ctx, captureKey(token, payload.ID)).Result()
+			if err != nil {
+				return err
+			}
+			if ttl == -2 {
+				continue // expired while indexing
+			}
+			if err := s.index(ctx, token, payload, max(ttl, 0)); err != nil {
+				return err
+			}
+			indexed++
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+
+	log.Printf("Indexed the flags of %d existing captures", indexed)
+	return s.client.Set(ctx, captureFlagsMigratedKey, time.Now().UTC().Format(time.RFC3339), 0).Err()
+}
+
+func (s *Redis) TokenOwner(ctx context.Context, token string) (string, error) {
+	owner, err := s.get(ctx, ownerKey(token))
+	if err == ErrNotFound {
+		return "", nil
+	}
+	return owner, err
+}
+
+func (s *Redis) SetTokenOwner(ctx context.Context, token, owner string, ttl time.Duration) error {
+	return s.client.Set(ctx, ownerKey(token), owner, ttl).Err()
+}
+
+func (s *Redis) SaveBin(ctx context.Context, bin models.Bin, credential string) error {
+	data, err := json.Marshal(bin)
+	if err != nil {
+		return err
+	}
+
+	ttl := time.Until(bin.ExpiresAt)
+	pipe := s.client.TxPipeline()
+	pipe.Set(ctx, binKey(bin.Token), data, ttl)
+	pipe.Set(ctx, binCredentialKey(bin.Token), credential, ttl)
+	pipe.Set(ctx, credentialKey(credential), bin.Token, ttl)
+	if bin.Owner != "" {
+		pipe.Set(ctx, ownerKey(bin.Token), bin.Owner, ttl)
+	}
+	pipe.ZAdd(ctx, binExpiryKey, goredis.Z{Score: float64(bin.ExpiresAt.Unix()), Member: bin.Token})
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (s *Redis) GetBin(ctx context.Context, token string) (*models.Bin, error) {
+	data, err := s.get(ctx, binKey(token))
+	if err != nil {
+		return nil, err
+	}
+
+	var bin models.Bin
+	if err := json.Unmarshal([]byte(data), &bin); err != nil {
+		return nil, err
+	}
+	return &bin, nil
+}
+
+func (s *Redis) DeleteBin(ctx context.Context, token string) error {
+	credential, err := s.get(ctx, binCredentialKey(token))
+	if err != nil && err != ErrNotFound {
+		return fmt.Errorf("get credential: %w", err)
+	}
+
+	keys := []string{binKey(token), binCredentialKey(token), ownerKey(token)}
+	if credential != "" {
+		keys = append(keys, credentialKey(credential))
+	}
+
+	pipe := s.client.TxPipeline()
+	pipe.Del(ctx, keys...)
+	pipe.ZRem(ctx, binExpiryKey, token)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (s *Redis) ClaimExpiredBins(ctx context.Context, now time.Time) ([]string, error) {
+	tokens, err := s.client.ZRangeByScore(ctx, binExpiryKey, &goredis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(now.Unix(), 10),
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var claimed []string
+	for _, token := range tokens {
+		// Only the instance that removes the entry gets the bin, so sweeps can run concurrently
+		if removed, err := s.client.ZRem(ctx, binExpiryKey, token).Result(); err == nil && removed > 0 {
+			claimed = append(claimed, token)
+		}
+	}
+	return claimed, nil
+}
+
+func (s *Redis) CredentialToken(ctx context.Context, credential string) (string, error) {
+	return s.get(ctx, credentialKey(credential))
+}
+
+func (s *Redis) CreateSession(ctx context.Context, session, login string, ttl time.Duration) error {
+	return s.client.Set(ctx, sessionKey(session), login, ttl).Err()
+}
+
+func (s *Redis) SessionUser(ctx context.Context, session string) (string, error) {
+	return s.get(ctx, sessionKey(session))
+}
+
+func (s *Redis) DeleteSession(ctx context.Context, session string) error {
+	return s.client.Del(ctx, sessionKey(session)).Err()
+}
+
+func (s *Redis) UserToken(ctx context.Context, login string) (string, error) {
+	return s.get(ctx, userTokenKey(login))
+}
+
+func (s *Redis) SetUserToken(ctx context.Context, login, token string) error {
+	return s.client.Set(ctx, userTokenKey(login), token, 0).Err()
+}
+
+func (s *Redis) IncrementUsage(ctx context.Context, token string, ttl time.Duration) (int64, error) {
+	pipe := s.client.Pipeline()
+	incr := pipe.Incr(ctx, usageKey(token))
+	// Always set TTL to ensure it doesn't get lost
+	pipe.Expire(ctx, usageKey(token), ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
+
+func (s *Redis) Usage(ctx context.Context, token string) (int64, time.Duration, error) {
+	count, err := s.client.Get(ctx, usageKey(token)).Int64()
+	if err == goredis.Nil {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	ttl, err := s.client.TTL(ctx, usageKey(token)).Result()
+	if err != nil {
+		return 0, 0, err
+	}
+	return count, max(ttl, 0), nil
+}
+
+func (s *Redis) ResetUsage(ctx context.Context, token string) error {
+	return s.client.Del(ctx, usageKey(token)).Err()
+}
+
+func (s *Redis) LoadConfig(ctx context.Context, token string) (models.EndpointConfig, error) {
+	var cfg models.EndpointConfig
+
+	data, err := s.get(ctx, configKey(token))
+	if err == ErrNotFound {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+
+	err = json.Unmarshal([]byte(data), &cfg)
+	return cfg, err
+}
+
+func (s *Redis) SaveConfig(ctx context.Context, token string, cfg models.EndpointConfig, ttl time.Duration) error {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, configKey(token), data, ttl).Err()
+}
+
+func (s *Redis) DeleteConfig(ctx context.Context, token string) error {
+	return s.client.Del(ctx, configKey(token)).Err()
+}
+
+func (s *Redis) SaveBaseline(ctx context.Context, token string, b models.Baseline, ttl time.Duration) error {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return err
+	}
+	// The hash shares one expiry, refreshed by each save
+	pipe := s.client.TxPipeline()
+	pipe.HSet(ctx, baselinesKey(token), b.Name, data)
+	if ttl > 0 {
+		pipe.Expire(ctx, baselinesKey(token), ttl)
+	} else {
+		pipe.Persist(ctx, baselinesKey(token))
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (s *Redis) GetBaseline(ctx context.Context, token, name string) (*models.Baseline, error) {
+	data, err := s.client.HGet(ctx, baselinesKey(token), name).Result()
+	if err == goredis.Nil {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var b models.Baseline
+	if err := json.Unmarshal([]byte(data), &b); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+func (s *Redis) ListBaselines(ctx context.Context, token string) ([]models.Baseline, error) {
+	values, err := s.client.HGetAll(ctx, baselinesKey(token)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	baselines := make([]models.Baseline, 0, len(values))
+	for _, data := range values {
+		var b models.Baseline
+		if err := json.Unmarshal([]byte(data), &b); err != nil {
+			return nil, err
+		}
+		baselines = append(baselines, b)
+	}
+	sort.Slice(baselines, func(i, j int) bool { return baselines[i].Name < baselines[j].Name })
+	return baselines, nil
+}
+
+func (s *Redis) DeleteBaseline(ctx context.Context, token, name string) error {
+	return s.client.HDel(ctx, baselinesKey(token), name).Err()
+}
+
+func (s *Redis) DeleteBaselines(ctx context.Context, token string) error {
+	return s.client.Del(ctx, baselinesKey(token)).Err()
+}
+
+func (s *Redis) SaveShare(ctx context.Context, l share.Link) error {
+	data, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+
+	ttl := time.Until(l.ExpiresAt)
+	pipe := s.client.TxPipeline()
+	pipe.Set(ctx, shareKey(l.ID), data, ttl)
+	pipe.SAdd(ctx, sharesKey(l.Token), l.ID)
+	pipe.ExpireNX(ctx, sharesKey(l.Token), ttl)
+	pipe.ExpireGT(ctx, sharesKey(l.Token), ttl)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (s *Redis) GetShare(ctx context.Context, id string) (*share.Link, error) {
+	data, err := s.get(ctx, shareKey(id))
+	if err != nil {
+		return nil, err
+	}
+
+	var l share.Link
+	if err := json.Unmarshal([]byte(data), &l); err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+func (s *Redis) ListShares(ctx context.Context, token string) ([]share.Link, error) {
+	ids, err := s.client.SMembers(ctx, sharesKey(token)).Result()
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = shareKey(id)
+	}
+	values, err := s.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var links []share.Link
+	var expired []interface{}
+	for i, v := range values {
+		data, ok := v.(string)
+		if !ok {
+			expired = append(expired, ids[i])
+			continue
+		}
+		var l share.Link
+		if err := json.Unmarshal([]byte(data), &l); err != nil {
+			return nil, err
+		}
+		links = append(links, l)
+	}
+	if len(expired) > 0 {
+		if err := s.client.SRem(ctx, sharesKey(token), expired...).Err(); err != nil {
+			log.Printf("ListShares: failed to drop expired links of token %s: %v", token, err)
+		}
+	}
+	sort.Slice(links, func(i, j int) bool { return links[i].CreatedAt.Before(links[j].CreatedAt) })
+	return links, nil
+}
+
+func (s *Redis) DeleteShare(ctx context.Context, token, id string) error {
+	l, err := s.GetShare(ctx, id)
+	if err != nil {
+		return err
+	}
+	if l.Token != token {
+		return ErrNotFound
+	}
+
+	pipe := s.client.TxPipeline()
+	pipe.Del(ctx, shareKey(id))
+	pipe.SRem(ctx, sharesKey(token), id)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (s *Redis) DeleteShares(ctx context.Context, token string) error {
+	ids, err := s.client.SMembers(ctx, sharesKey(token)).Result()
+	if err != nil {
+		return err
+	}
+
+	keys := []string{sharesKey(token)}
+	for _, id := range ids {
+		keys = append(keys, shareKey(id))
+	}
+	return s.client.Del(ctx, keys...).Err()
+}
+
+func (s *Redis) SaveReplay(ctx context.Context, token string, b models.ReplayBatch, ttl time.Duration) error {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return err
+	}
+	pipe := s.client.TxPipeline()
+	pipe.Set(ctx, replayKey(token, b.ID), data, ttl)
+	pipe.SAdd(ctx, replaysKey(token), b.ID)
+	pipe.ExpireNX(ctx, replaysKey(token), ttl)
+	pipe.ExpireGT(ctx, replaysKey(token), ttl)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (s *Redis) UpdateReplay(ctx context.Context, token string, b models.ReplayBatch, ttl time.Duration) error {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return err
+	}
+	updated, err := s.client.SetXX(ctx, replayKey(token, b.ID), data, ttl).Result()
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *Redis) GetReplay(ctx context.Context, token, id string) (*models.ReplayBatch, error) {
+	data, err := s.get(ctx, replayKey(token, id))
+	if err != nil {
+		return nil, err
+	}
+
+	var b models.ReplayBatch
+	if err := json.Unmarshal([]byte(data), &b); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+func (s *Redis) AppendReplayResult(ctx context.Context, token, id string, r models.ReplayResult, ttl time.Duration) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	pipe := s.client.TxPipeline()
+	pipe.RPush(ctx, replayResultsKey(token, id), data)
+	pipe.Expire(ctx, replayResultsKey(token, id), ttl)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (s *Redis) ReplayResults(ctx context.Context, token, id string) ([]models.ReplayResult, error) {
+	values, err := s.client.LRange(ctx, replayResultsKey(token, id), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]models.ReplayResult, 0, len(values))
+	for _, v := range values {
+		var r models.ReplayResult
+		if err := json.Unmarshal([]byte(v), &r); err != nil {
+			continue
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+func (s *Redis) DeleteReplays(ctx context.Context, token string) error {
+	ids, err := s.client.SMembers(ctx, replaysKey(token)).Result()
+	if err != nil {
+		return err
+	}
+
+	keys := []string{replaysKey(token)}
+	for _, id := range ids {
+		keys = append(keys, replayKey(token, id), replayResultsKey(token, id))
+	}
+	return s.client.Del(ctx, keys...).Err()
+}
+
+func (s *Redis) CreateSimulation(ctx context.Context, token string, sim models.StoredSimulation, ttl time.Duration) error {
+	return s.saveSimulation(ctx, token, sim, ttl, true)
+}
+
+func (s *Redis) SaveSimulation(ctx context.Context, token string, sim models.StoredSimulation, ttl time.Duration) error {
+	return s.saveSimulation(ctx, token, sim, ttl, false)
+}
+
+func (s *Redis) saveSimulation(ctx context.Context, token string, sim models.StoredSimulation, ttl time.Duration, created bool) error {
+	data, err := json.Marshal(sim)
+	if err != nil {
+		return err
+	}
+
+	// The history and pending set live as long as their longest-lived simulation
+	pipe := s.client.TxPipeline()
+	pipe.Set(ctx, simulationKey(token, sim.ID), data, ttl)
+	if created {
+		pipe.LPush(ctx, simulationsKey(token), sim.ID)
+		pipe.LTrim(ctx, simulationsKey(token), 0, SimulationHistory-1)
+		pipe.ExpireNX(ctx, simulationsKey(token), ttl)
+		pipe.ExpireGT(ctx, simulationsKey(token), ttl)
+		pipe.SAdd(ctx, allSimulationsKey(token), sim.ID)
+		pipe.ExpireNX(ctx, allSimulationsKey(token), ttl)
+		pipe.ExpireGT(ctx, allSimulationsKey(token), ttl)
+	}
+	if sim.NextAttemptAt != nil {
+		pipe.SAdd(ctx, pendingSimulationsKey(token), sim.ID)
+		pipe.ExpireNX(ctx, pendingSimulationsKey(token), ttl)
+		pipe.ExpireGT(ctx, pendingSimulationsKey(token), ttl)
+	} else {
+		pipe.SRem(ctx, pendingSimulationsKey(token), sim.ID)
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (s *Redis) GetSimulation(ctx context.Context, token, id string) (*models.StoredSimulation, error) {
+	data, err := s.get(ctx, simulationKey(token, id))
+	if err != nil {
+		return nil, err
+	}
+
+	var sim models.StoredSimulation
+	if err := json.Unmarshal([]byte(data), &sim); err != nil {
+		return nil, err
+	}
+	return &sim, nil
+}
+
+func (s *Redis) ListSimulations(ctx context.Context, token string) ([]models.StoredSimulation, error) {
+	ids, err := s.client.LRange(ctx, simulationsKey(token), 0, -1).Result()
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = simulationKey(token, id)
+	}
+	values, err := s.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	sims := make([]models.StoredSimulation, 0, len(values))
+	for _, v := range values {
+		data, ok := v.(string)
+		if !ok {
+			continue
+		}
+		var sim models.StoredSimulation
+		if err := json.Unmarshal([]byte(data), &sim); err != nil {
+			return nil, err
+		}
+		sims = append(sims, sim)
+	}
+	return sims, nil
+}
+
+func (s *Redis) PendingSimulations(ctx context.Context, token string) (int, error) {
+	n, err := s.client.SCard(ctx, pendingSimulationsKey(token)).Result()
+	return int(n), err
+}
+
+func (s *Redis) DeleteSimulations(ctx context.Context, token string) error {
+	ids, err := s.client.SMembers(ctx, allSimulationsKey(token)).Result()
+	if err != nil {
+		return err
+	}
+
+	keys := []string{simulationsKey(token), pendingSimulationsKey(token), allSimulationsKey(token)}
+	for _, id := range ids {
+		keys = append(keys, simulationKey(token, id))
+	}
+	return s.client.Del(ctx, keys...).Err()
+}
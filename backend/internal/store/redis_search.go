@@ -0,0 +1,189 @@
+package store
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"webhook-inspector/internal/models"
+	"webhook-inspector/internal/query"
+	"webhook-inspector/internal/search"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// Set once every capture indexed before search terms existed has been given its terms
+const searchIndexMigratedKey = "migrations:search_index"
+
+// Adds a capture ID to the set of every search term it contains, and the terms to the
+// token's term registry. Like the capture index, each set keeps the TTL of its
+// longest-lived member.
+//
+// KEYS[1] registry, KEYS[2..] term sets; ARGV[1] capture ID, ARGV[2] TTL in ms (0 = none),
+// ARGV[3..] terms in KEYS order
+var indexTermsScript = goredis.NewScript(`
+local ttl = tonumber(ARGV[2])
+local function add(key, member)
+	local current = redis.call('PTTL', key)
+	redis.call('SADD', key, member)
+	if ttl == 0 then
+		redis.call('PERSIST', key)
+	elseif current == -2 or (current >= 0 and current < ttl) then
+		redis.call('PEXPIRE', key, ttl)
+	end
+end
+for i = 2, #KEYS do
+	add(KEYS[i], ARGV[1])
+	add(KEYS[1], ARGV[i + 1])
+end
+return 1
+`)
+
+// Set of capture IDs containing a search term
+func searchTermKey(token, term string) string {
+	return "search:" + token + ":" + term
+}
+
+// Set of every term indexed for a token, so a purge can find the term sets
+func searchTermsKey(token string) string {
+	return "search_terms:" + token
+}
+
+// Queue the indexing of a capture's terms on pipe, usually the transaction saving it
+func indexTerms(ctx context.Context, pipe goredis.Pipeliner, token string, payload models.WebhookPayload, ttl time.Duration) {
+	terms := search.Terms(payload)
+	if len(terms) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(terms)+1)
+	args := make([]interface{}, 0, len(terms)+2)
+	keys = append(keys, searchTermsKey(token))
+	args = append(args, payload.ID, ttl.Milliseconds())
+	for _, term := range terms {
+		keys = append(keys, searchTermKey(token, term))
+		args = append(args, term)
+	}
+	indexTermsScript.Eval(ctx, pipe, keys, args...)
+}
+
+func (s *Redis) unindexTerms(ctx context.Context, token string, payload models.WebhookPayload) error {
+	pipe := s.client.Pipeline()
+	for _, term := range search.Terms(payload) {
+		pipe.SRem(ctx, searchTermKey(token, term), payload.ID)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Narrow the index to captures holding every term the search requires. Captures with
+// a partial index are always kept; the filter itself checks them.
+func (s *Redis) searchCandidates(ctx context.Context, token string, f query.Filter, index query.Index) (query.Index, error) {
+	terms := f.IndexTerms()
+	if len(terms) == 0 {
+		return index, nil
+	}
+
+	keys := make([]string, len(terms))
+	for i, term := range terms {
+		keys[i] = searchTermKey(token, term)
+	}
+	pipe := s.client.Pipeline()
+	matched := pipe.SInter(ctx, keys...)
+	partial := pipe.SMembers(ctx, searchTermKey(token, search.PartialTerm))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	candidates := make(map[string]bool)
+	for _, id := range matched.Val() {
+		candidates[id] = true
+	}
+	for _, id := range partial.Val() {
+		candidates[id] = true
+	}
+	return candidateIndex{Index: index, candidates: candidates}, nil
+}
+
+// An index that skips entries outside a candidate set
+type candidateIndex struct {
+	query.Index
+	candidates map[string]bool
+}
+
+func (c candidateIndex) Scan(from *query.Entry, desc bool, n int) ([]query.Entry, error) {
+	var entries []query.Entry
+	for len(entries) < n {
+		batch, err := c.Index.Scan(from, desc, n)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range batch {
+			if c.candidates[e.ID] && len(entries) < n {
+				entries = append(entries, e)
+			}
+		}
+		if len(batch) < n {
+			break
+		}
+		from = &batch[len(batch)-1]
+	}
+	return entries, nil
+}
+
+func (s *Redis) deleteTerms(ctx context.Context, token string) error {
+	terms, err := s.client.SMembers(ctx, searchTermsKey(token)).Result()
+	if err != nil {
+		return err
+	}
+	for start := 0; start < len(terms); start += batchSize {
+		batch := terms[start:min(start+batchSize, len(terms))]
+		keys := make([]string, len(batch))
+		for i, term := range batch {
+			keys[i] = searchTermKey(token, term)
+		}
+		if err := s.client.Del(ctx, keys...).Err(); err != nil {
+			return err
+		}
+	}
+	return s.client.Del(ctx, searchTermsKey(token)).Err()
+}
+
+// IndexExistingSearchTerms gives captures indexed before search existed their search
+// terms. It walks the capture indexes with SCAN once, then records that it is done.
+func (s *Redis) IndexExistingSearchTerms(ctx context.Context) error {
+	if done, err := s.client.Exists(ctx, searchIndexMigratedKey).Result(); err != nil || done > 0 {
+		return err
+	}
+
+	indexed := 0
+	prefix := captureIndexKey("")
+	iter := s.client.Scan(ctx, 0, captureIndexKey("*"), 1000).Iterator()
+	for iter.Next(ctx) {
+		token := iter.Val()[len(prefix):]
+		captures, err := s.ListCaptures(ctx, token)
+		if err != nil {
+			return err
+		}
+		for _, payload := range captures {
+			ttl, err := s.client.PTTL(ctx, captureKey(token, payload.ID)).Result()
+			if err != nil {
+				return err
+			}
+			if ttl == -2 {
+				continue // expired while indexing
+			}
+			pipe := s.client.Pipeline()
+			indexTerms(ctx, pipe, token, payload, max(ttl, 0))
+			if _, err := pipe.Exec(ctx); err != nil {
+				return err
+			}
+			indexed++
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+
+	log.Printf("Indexed search terms for %d existing captures", indexed)
+	return s.client.Set(ctx, searchIndexMigratedKey, time.Now().UTC().Format(time.RFC3339), 0).Err()
+}
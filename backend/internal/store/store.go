@@ -0,0 +1,169 @@
+// Package store defines the persistence used by the HTTP handlers, with Redis and in-memory implementations.
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"webhook-inspector/internal/models"
+	"webhook-inspector/internal/query"
+	"webhook-inspector/internal/share"
+)
+
+// ErrNotFound is returned for records that don't exist or have expired
+var ErrNotFound = errors.New("not found")
+
+// A zero ttl stores a record without expiry throughout this package.
+
+// Captures holds the webhook payloads received by each token
+type Captures interface {
+	SaveCapture(ctx context.Context, token string, payload models.WebhookPayload, ttl time.Duration) error
+	GetCapture(ctx context.Context, token, id string) (*models.WebhookPayload, error)
+	// CaptureTTL returns how long a capture has left, 0 if it never expires
+	CaptureTTL(ctx context.Context, token, id string) (time.Duration, error)
+	// ListCaptures returns every live capture of a token, oldest first
+	ListCaptures(ctx context.Context, token string) ([]models.WebhookPayload, error)
+	// QueryCaptures returns one filtered page of a token's captures
+	QueryCaptures(ctx context.Context, token string, q query.Query) (*query.Page, error)
+	// CaptureIDs returns the IDs of a token's captures, oldest first; some may have just expired
+	CaptureIDs(ctx context.Context, token string) ([]string, error)
+	// CountCaptures returns how many live captures a token has
+	CountCaptures(ctx context.Context, token string) (int, error)
+	// CaptureCounts counts a token's live captures by triage state without loading them
+	CaptureCounts(ctx context.Context, token string) (Counts, error)
+	// OldestUnpinned returns the IDs of a token's live unpinned captures beyond the
+	// newest keep, oldest first, without loading them
+	OldestUnpinned(ctx context.Context, token string, keep int) ([]string, error)
+	DeleteCapture(ctx context.Context, token, id string) error
+	// DeleteCaptureIDs removes the named captures and their index entries in one
+	// transaction, returning how many existed
+	DeleteCaptureIDs(ctx context.Context, token string, ids []string) (int, error)
+	// UpdateCaptures runs edit on each named capture and saves the ones it reports as
+	// changed in one transaction, keeping their expiry; it returns how many changed
+	UpdateCaptures(ctx context.Context, token string, ids []string, edit func(*models.WebhookPayload) bool) (int, error)
+	DeleteCaptures(ctx context.Context, token string) error
+}
+
+// Counts are a token's live captures by triage state
+type Counts struct {
+	Total  int
+	Pinned int
+	Unread int
+}
+
+// Tokens tracks who owns a webhook token and the bins created through the API
+type Tokens interface {
+	// TokenOwner returns the GitHub login owning a token, or "" for anonymous tokens
+	TokenOwner(ctx context.Context, token string) (string, error)
+	SetTokenOwner(ctx context.Context, token, owner string, ttl time.Duration) error
+	// SaveBin stores a bin, its owner and its bearer credential until the bin expires
+	SaveBin(ctx context.Context, bin models.Bin, credential string) error
+	GetBin(ctx context.Context, token string) (*models.Bin, error)
+	// DeleteBin removes the bin's metadata, owner and credential
+	DeleteBin(ctx context.Context, token string) error
+	// ClaimExpiredBins removes and returns bins that expired before now; concurrent
+	// callers never get the same token
+	ClaimExpiredBins(ctx context.Context, now time.Time) ([]string, error)
+	// CredentialToken resolves a bearer credential to its token
+	CredentialToken(ctx context.Context, credential string) (string, error)
+}
+
+// Sessions maps login session IDs to GitHub logins
+type Sessions interface {
+	CreateSession(ctx context.Context, session, login string, ttl time.Duration) error
+	SessionUser(ctx context.Context, session string) (string, error)
+	DeleteSession(ctx context.Context, session string) error
+}
+
+// Users links GitHub logins to their permanent webhook token
+type Users interface {
+	UserToken(ctx context.Context, login string) (string, error)
+	SetUserToken(ctx context.Context, login, token string) error
+}
+
+// RateLimits counts the requests ingested per token
+type RateLimits interface {
+	// IncrementUsage bumps the counter and (re)sets its expiry, returning the new count
+	IncrementUsage(ctx context.Context, token string, ttl time.Duration) (int64, error)
+	// Usage returns the current count and how long until it resets
+	Usage(ctx context.Context, token string) (int64, time.Duration, error)
+	ResetUsage(ctx context.Context, token string) error
+}
+
+// Configs holds the per-endpoint settings
+type Configs interface {
+	// LoadConfig returns an empty config when none was saved
+	LoadConfig(ctx context.Context, token string) (models.EndpointConfig, error)
+	SaveConfig(ctx context.Context, token string, cfg models.EndpointConfig, ttl time.Duration) error
+	DeleteConfig(ctx context.Context, token string) error
+}
+
+// Baselines holds the golden recordings frozen for each token
+type Baselines interface {
+	SaveBaseline(ctx context.Context, token string, b models.Baseline, ttl time.Duration) error
+	GetBaseline(ctx context.Context, token, name string) (*models.Baseline, error)
+	// ListBaselines returns a token's baselines sorted by name
+	ListBaselines(ctx context.Context, token string) ([]models.Baseline, error)
+	DeleteBaseline(ctx context.Context, token, name string) error
+	DeleteBaselines(ctx context.Context, token string) error
+}
+
+// Shares holds the read-only links handed out for each token, each kept until it expires
+type Shares interface {
+	SaveShare(ctx context.Context, l share.Link) error
+	// GetShare resolves a link by ID alone, for viewers who don't hold the token
+	GetShare(ctx context.Context, id string) (*share.Link, error)
+	// ListShares returns a token's live links, oldest first
+	ListShares(ctx context.Context, token string) ([]share.Link, error)
+	// DeleteShare returns ErrNotFound unless the link belongs to token
+	DeleteShare(ctx context.Context, token, id string) error
+	DeleteShares(ctx context.Context, token string) error
+}
+
+// Replays holds the progress and per-capture report of each bulk replay
+type Replays interface {
+	SaveReplay(ctx context.Context, token string, b models.ReplayBatch, ttl time.Duration) error
+	// UpdateReplay saves a replay only while it still exists, returning ErrNotFound once
+	// it has been deleted
+	UpdateReplay(ctx context.Context, token string, b models.ReplayBatch, ttl time.Duration) error
+	GetReplay(ctx context.Context, token, id string) (*models.ReplayBatch, error)
+	// AppendReplayResult adds a line to a replay's report, which expires after ttl
+	AppendReplayResult(ctx context.Context, token, id string, r models.ReplayResult, ttl time.Duration) error
+	// ReplayResults returns a replay's report in the order its lines were added
+	ReplayResults(ctx context.Context, token, id string) ([]models.ReplayResult, error)
+	// DeleteReplays removes every replay of a token with its report
+	DeleteReplays(ctx context.Context, token string) error
+}
+
+// SimulationHistory is how many of a token's simulations ListSimulations returns
+const SimulationHistory = 50
+
+// Simulations holds the deliveries simulated for each token
+type Simulations interface {
+	// CreateSimulation stores a new simulation at the head of the token's history
+	CreateSimulation(ctx context.Context, token string, sim models.StoredSimulation, ttl time.Duration) error
+	// SaveSimulation updates a simulation created earlier
+	SaveSimulation(ctx context.Context, token string, sim models.StoredSimulation, ttl time.Duration) error
+	GetSimulation(ctx context.Context, token, id string) (*models.StoredSimulation, error)
+	// ListSimulations returns the token's most recent simulations, newest first
+	ListSimulations(ctx context.Context, token string) ([]models.StoredSimulation, error)
+	// PendingSimulations counts the token's simulations with a retry still to come,
+	// including ones too old to be listed
+	PendingSimulations(ctx context.Context, token string) (int, error)
+	DeleteSimulations(ctx context.Context, token string) error
+}
+
+// Store is everything the HTTP handlers and delivery workers persist
+type Store interface {
+	Captures
+	Tokens
+	Sessions
+	Users
+	RateLimits
+	Configs
+	Baselines
+	Shares
+	Replays
+	Simulations
+}
@@ -0,0 +1,593 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"webhook-inspector/internal/models"
+	"webhook-inspector/internal/query"
+	"webhook-inspector/internal/share"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// Behaviour every Store implementation must share
+func testStore(t *testing.T, s Store) {
+	ctx := context.Background()
+
+	t.Run("captures", func(t *testing.T) {
+		base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+		for i, id := range []string{"b", "a", "c"} {
+			p := models.WebhookPayload{ID: id, Method: "POST", Body: "{}", Timestamp: base.Add(time.Duration(2-i) * time.Minute)}
+			if err := s.SaveCapture(ctx, "tok", p, time.Hour); err != nil {
+				t.Fatal(err)
+			}
+		}
+		s.SaveCapture(ctx, "other", models.WebhookPayload{ID: "x"}, 0)
+
+		list, err := s.ListCaptures(ctx, "tok")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list) != 3 || list[0].ID != "c" || list[2].ID != "b" {
+			t.Fatalf("expected oldest first, got %+v", list)
+		}
+		if ids, err := s.CaptureIDs(ctx, "tok"); err != nil || len(ids) != 3 || ids[0] != "c" {
+			t.Errorf("CaptureIDs = %v, %v", ids, err)
+		}
+		if n, err := s.CountCaptures(ctx, "tok"); err != nil || n != 3 {
+			t.Errorf("CountCaptures = %d, %v", n, err)
+		}
+		if ttl, err := s.CaptureTTL(ctx, "tok", "a"); err != nil || ttl <= 0 || ttl > time.Hour {
+			t.Errorf("CaptureTTL = %s, %v", ttl, err)
+		}
+		if ttl, err := s.CaptureTTL(ctx, "other", "x"); err != nil || ttl != 0 {
+			t.Errorf("CaptureTTL without expiry = %s, %v", ttl, err)
+		}
+		if _, err := s.CaptureTTL(ctx, "tok", "x"); err != ErrNotFound {
+			t.Errorf("CaptureTTL of a missing capture = %v", err)
+		}
+
+		if _, err := s.GetCapture(ctx, "tok", "x"); err != ErrNotFound {
+			t.Errorf("captures must be scoped to their token, got %v", err)
+		}
+		if err := s.DeleteCapture(ctx, "tok", "a"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.GetCapture(ctx, "tok", "a"); err != ErrNotFound {
+			t.Errorf("expected deleted capture to be gone, got %v", err)
+		}
+		if err := s.DeleteCaptures(ctx, "tok"); err != nil {
+			t.Fatal(err)
+		}
+		if list, _ := s.ListCaptures(ctx, "tok"); len(list) != 0 {
+			t.Errorf("expected no captures after purge, got %d", len(list))
+		}
+		if p, err := s.GetCapture(ctx, "other", "x"); err != nil || p.ID != "x" {
+			t.Errorf("purge touched another token: %v", err)
+		}
+	})
+
+	t.Run("query", func(t *testing.T) {
+		base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+		for i := 0; i < 9; i++ {
+			// Pairs share a timestamp so ties are broken by ID
+			p := models.WebhookPayload{ID: fmt.Sprintf("q%d", i), Method: "POST", Timestamp: base.Add(time.Duration(i/2) * time.Second)}
+			if i%3 == 0 {
+				p.Method = "GET"
+			}
+			s.SaveCapture(ctx, "query", p, time.Hour)
+		}
+		walk := func(q query.Query) (ids []string, totals []int) {
+			for {
+				page, err := s.QueryCaptures(ctx, "query", q)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, c := range page.Items {
+					ids = append(ids, c.ID)
+				}
+				if page.Total != nil {
+					totals = append(totals, *page.Total)
+				}
+				if page.NextCursor == "" {
+					return ids, totals
+				}
+				q.Cursor = page.NextCursor
+			}
+		}
+
+		if ids, totals := walk(query.Query{Limit: 2, Order: query.OrderDesc}); fmt.Sprint(ids) != "[q8 q7 q6 q5 q4 q3 q2 q1 q0]" || fmt.Sprint(totals) != "[9 9 9 9 9]" {
+			t.Errorf("desc walk = %v, totals %v", ids, totals)
+		}
+		since := base.Add(time.Second)
+		after := query.Entry{ID: "q2", Time: base.Add(time.Second)}
+		if ids, totals := walk(query.Query{Filter: query.Filter{Since: &since}, Limit: 4, Order: query.OrderAsc, After: &after}); fmt.Sprint(ids) != "[q3 q4 q5 q6 q7 q8]" || fmt.Sprint(totals) != "[6 6]" {
+			t.Errorf("after walk = %v, totals %v", ids, totals)
+		}
+		if ids, totals := walk(query.Query{Filter: query.Filter{Method: "GET"}, Limit: 1, Order: query.OrderAsc}); fmt.Sprint(ids) != "[q0 q3 q6]" || totals != nil {
+			t.Errorf("filtered walk = %v, totals %v", ids, totals)
+		}
+	})
+
+	t.Run("bulk", func(t *testing.T) {
+		for _, id := range []string{"a", "b", "c"} {
+			s.SaveCapture(ctx, "bulk", models.WebhookPayload{ID: id, Body: "{}"}, time.Hour)
+		}
+		changed, err := s.UpdateCaptures(ctx, "bulk", []string{"a", "b", "missing"}, func(p *models.WebhookPayload) bool {
+			if p.ID == "b" {
+				return false
+			}
+			p.Read = true
+			return true
+		})
+		if err != nil || changed != 1 {
+			t.Fatalf("UpdateCaptures = %d, %v", changed, err)
+		}
+		if p, _ := s.GetCapture(ctx, "bulk", "a"); p == nil || !p.Read {
+			t.Errorf("edit not saved: %+v", p)
+		}
+		if ttl, _ := s.CaptureTTL(ctx, "bulk", "a"); ttl <= 0 {
+			t.Errorf("edit dropped the expiry: %s", ttl)
+		}
+
+		deleted, err := s.DeleteCaptureIDs(ctx, "bulk", []string{"a", "c", "missing"})
+		if err != nil || deleted != 2 {
+			t.Fatalf("DeleteCaptureIDs = %d, %v", deleted, err)
+		}
+		if ids, _ := s.CaptureIDs(ctx, "bulk"); len(ids) != 1 || ids[0] != "b" {
+			t.Errorf("index after delete = %v", ids)
+		}
+	})
+
+	t.Run("counts", func(t *testing.T) {
+		s.SaveCapture(ctx, "counts", models.WebhookPayload{ID: "a"}, time.Hour)
+		s.SaveCapture(ctx, "counts", models.WebhookPayload{ID: "b", Pinned: true}, 0)
+		s.SaveCapture(ctx, "counts", models.WebhookPayload{ID: "c", Read: true}, time.Hour)
+		if counts, err := s.CaptureCounts(ctx, "counts"); err != nil || counts != (Counts{Total: 3, Pinned: 1, Unread: 2}) {
+			t.Fatalf("CaptureCounts = %+v, %v", counts, err)
+		}
+
+		s.UpdateCaptures(ctx, "counts", []string{"a", "b"}, func(p *models.WebhookPayload) bool {
+			p.Pinned = !p.Pinned
+			p.Read = true
+			return true
+		})
+		s.SaveCapture(ctx, "counts", models.WebhookPayload{ID: "c", Pinned: true, Read: true}, 0)
+		if counts, _ := s.CaptureCounts(ctx, "counts"); counts != (Counts{Total: 3, Pinned: 2, Unread: 0}) {
+			t.Errorf("counts after edits = %+v", counts)
+		}
+
+		s.SaveCapture(ctx, "counts", models.WebhookPayload{ID: "d", Timestamp: time.Now()}, time.Hour)
+		if ids, err := s.OldestUnpinned(ctx, "counts", 0); err != nil || len(ids) != 2 || ids[0] != "b" || ids[1] != "d" {
+			t.Errorf("OldestUnpinned(0) = %v, %v", ids, err)
+		}
+		if ids, _ := s.OldestUnpinned(ctx, "counts", 1); len(ids) != 1 || ids[0] != "b" {
+			t.Errorf("OldestUnpinned(1) = %v", ids)
+		}
+		if ids, _ := s.OldestUnpinned(ctx, "counts", 2); len(ids) != 0 {
+			t.Errorf("OldestUnpinned(2) = %v", ids)
+		}
+		s.DeleteCapture(ctx, "counts", "d")
+
+		s.DeleteCaptureIDs(ctx, "counts", []string{"a"})
+		s.DeleteCapture(ctx, "counts", "c")
+		if counts, _ := s.CaptureCounts(ctx, "counts"); counts != (Counts{Total: 1, Pinned: 0, Unread: 0}) {
+			t.Errorf("counts after deletes = %+v", counts)
+		}
+		s.DeleteCaptures(ctx, "counts")
+		if counts, _ := s.CaptureCounts(ctx, "counts"); counts != (Counts{}) {
+			t.Errorf("counts after purge = %+v", counts)
+		}
+	})
+
+	t.Run("bins", func(t *testing.T) {
+		bin := models.Bin{Token: "bin1", Owner: "octocat", CreatedAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour)}
+		if err := s.SaveBin(ctx, bin, "whk_1"); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := s.GetBin(ctx, "bin1"); err != nil || got.Owner != "octocat" {
+			t.Fatalf("GetBin = %+v, %v", got, err)
+		}
+		if token, err := s.CredentialToken(ctx, "whk_1"); err != nil || token != "bin1" {
+			t.Errorf("CredentialToken = %q, %v", token, err)
+		}
+		if owner, _ := s.TokenOwner(ctx, "bin1"); owner != "octocat" {
+			t.Errorf("TokenOwner = %q", owner)
+		}
+
+		if claimed, _ := s.ClaimExpiredBins(ctx, time.Now()); len(claimed) != 0 {
+			t.Errorf("claimed a live bin: %v", claimed)
+		}
+		claimed, err := s.ClaimExpiredBins(ctx, time.Now().Add(2*time.Hour))
+		if err != nil || len(claimed) != 1 || claimed[0] != "bin1" {
+			t.Fatalf("ClaimExpiredBins = %v, %v", claimed, err)
+		}
+		if again, _ := s.ClaimExpiredBins(ctx, time.Now().Add(2*time.Hour)); len(again) != 0 {
+			t.Errorf("bin claimed twice: %v", again)
+		}
+
+		if err := s.DeleteBin(ctx, "bin1"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.GetBin(ctx, "bin1"); err != ErrNotFound {
+			t.Errorf("GetBin after delete: %v", err)
+		}
+		if _, err := s.CredentialToken(ctx, "whk_1"); err != ErrNotFound {
+			t.Errorf("credential survived the bin: %v", err)
+		}
+		if owner, err := s.TokenOwner(ctx, "bin1"); err != nil || owner != "" {
+			t.Errorf("owner survived the bin: %q, %v", owner, err)
+		}
+	})
+
+	t.Run("sessions and users", func(t *testing.T) {
+		if _, err := s.SessionUser(ctx, "sess"); err != ErrNotFound {
+			t.Errorf("unknown session: %v", err)
+		}
+		s.CreateSession(ctx, "sess", "octocat", time.Hour)
+		if login, err := s.SessionUser(ctx, "sess"); err != nil || login != "octocat" {
+			t.Errorf("SessionUser = %q, %v", login, err)
+		}
+		s.DeleteSession(ctx, "sess")
+		if _, err := s.SessionUser(ctx, "sess"); err != ErrNotFound {
+			t.Errorf("deleted session: %v", err)
+		}
+
+		if _, err := s.UserToken(ctx, "octocat"); err != ErrNotFound {
+			t.Errorf("unknown user: %v", err)
+		}
+		s.SetUserToken(ctx, "octocat", "tok")
+		if token, err := s.UserToken(ctx, "octocat"); err != nil || token != "tok" {
+			t.Errorf("UserToken = %q, %v", token, err)
+		}
+	})
+
+	t.Run("rate limits", func(t *testing.T) {
+		for i := 1; i <= 3; i++ {
+			if n, err := s.IncrementUsage(ctx, "tok", time.Hour); err != nil || n != int64(i) {
+				t.Fatalf("IncrementUsage = %d, %v", n, err)
+			}
+		}
+		count, ttl, err := s.Usage(ctx, "tok")
+		if err != nil || count != 3 || ttl <= 0 || ttl > time.Hour {
+			t.Errorf("Usage = %d, %s, %v", count, ttl, err)
+		}
+		s.ResetUsage(ctx, "tok")
+		if count, _, _ := s.Usage(ctx, "tok"); count != 0 {
+			t.Errorf("usage after reset = %d", count)
+		}
+	})
+
+	t.Run("configs", func(t *testing.T) {
+		if cfg, err := s.LoadConfig(ctx, "tok"); err != nil || len(cfg.Forwards) != 0 {
+			t.Errorf("missing config = %+v, %v", cfg, err)
+		}
+		cfg := models.EndpointConfig{Forwards: []models.ForwardTarget{{ID: "t1", URL: "https://example.com"}}}
+		s.SaveConfig(ctx, "tok", cfg, 0)
+		if got, _ := s.LoadConfig(ctx, "tok"); len(got.Forwards) != 1 || got.Forwards[0].URL != "https://example.com" {
+			t.Errorf("LoadConfig = %+v", got)
+		}
+		s.DeleteConfig(ctx, "tok")
+		if got, _ := s.LoadConfig(ctx, "tok"); len(got.Forwards) != 0 {
+			t.Errorf("config survived delete: %+v", got)
+		}
+	})
+
+	t.Run("baselines", func(t *testing.T) {
+		for _, name := range []string{"b", "a"} {
+			b := models.Baseline{Name: name, Provider: "github", Fields: map[string]models.FieldShape{"/ref": {Types: []string{"string"}}}}
+			if err := s.SaveBaseline(ctx, "tok", b, time.Hour); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if list, err := s.ListBaselines(ctx, "tok"); err != nil || len(list) != 2 || list[0].Name != "a" {
+			t.Errorf("ListBaselines = %+v, %v", list, err)
+		}
+		if b, err := s.GetBaseline(ctx, "tok", "b"); err != nil || b.Fields["/ref"].Types[0] != "string" {
+			t.Errorf("GetBaseline = %+v, %v", b, err)
+		}
+		s.DeleteBaseline(ctx, "tok", "b")
+		if _, err := s.GetBaseline(ctx, "tok", "b"); err != ErrNotFound {
+			t.Errorf("deleted baseline: err = %v", err)
+		}
+		s.DeleteBaselines(ctx, "tok")
+		if list, _ := s.ListBaselines(ctx, "tok"); len(list) != 0 {
+			t.Errorf("baselines survived delete: %+v", list)
+		}
+	})
+
+	t.Run("shares", func(t *testing.T) {
+		now := time.Now().UTC()
+		for i, id := range []string{"s2", "s1"} {
+			l := share.Link{ID: id, Token: "tok", CaptureID: "c", CreatedAt: now.Add(time.Duration(-i) * time.Minute), ExpiresAt: now.Add(time.Hour)}
+			if err := s.SaveShare(ctx, l); err != nil {
+				t.Fatal(err)
+			}
+		}
+		s.SaveShare(ctx, share.Link{ID: "s3", Token: "other", CreatedAt: now, ExpiresAt: now.Add(time.Hour)})
+
+		if list, err := s.ListShares(ctx, "tok"); err != nil || len(list) != 2 || list[0].ID != "s1" {
+			t.Errorf("ListShares = %+v, %v", list, err)
+		}
+		if l, err := s.GetShare(ctx, "s2"); err != nil || l.Token != "tok" || l.CaptureID != "c" {
+			t.Errorf("GetShare = %+v, %v", l, err)
+		}
+		if err := s.DeleteShare(ctx, "tok", "s3"); err != ErrNotFound {
+			t.Errorf("deleting another token's link: err = %v", err)
+		}
+		s.DeleteShare(ctx, "tok", "s2")
+		if _, err := s.GetShare(ctx, "s2"); err != ErrNotFound {
+			t.Errorf("deleted link: err = %v", err)
+		}
+		s.DeleteShares(ctx, "tok")
+		if list, _ := s.ListShares(ctx, "tok"); len(list) != 0 {
+			t.Errorf("links survived delete: %+v", list)
+		}
+		if _, err := s.GetShare(ctx, "s3"); err != nil {
+			t.Errorf("other token's link: err = %v", err)
+		}
+	})
+
+	t.Run("replays", func(t *testing.T) {
+		b := models.ReplayBatch{ID: "r1", URL: "http://example.com", State: "running", Total: 2}
+		if err := s.SaveReplay(ctx, "tok", b, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		b.Completed = 1
+		if err := s.UpdateReplay(ctx, "tok", b, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := s.GetReplay(ctx, "tok", "r1"); err != nil || got.Completed != 1 {
+			t.Errorf("GetReplay = %+v, %v", got, err)
+		}
+		if _, err := s.GetReplay(ctx, "other", "r1"); err != ErrNotFound {
+			t.Errorf("other token's replay: err = %v", err)
+		}
+
+		for _, id := range []string{"c1", "c2"} {
+			if err := s.AppendReplayResult(ctx, "tok", "r1", models.ReplayResult{CaptureID: id, StatusCode: 200}, time.Hour); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if results, err := s.ReplayResults(ctx, "tok", "r1"); err != nil || len(results) != 2 || results[0].CaptureID != "c1" {
+			t.Errorf("ReplayResults = %+v, %v", results, err)
+		}
+
+		s.SaveReplay(ctx, "other", b, time.Hour)
+		if err := s.DeleteReplays(ctx, "tok"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.GetReplay(ctx, "tok", "r1"); err != ErrNotFound {
+			t.Errorf("deleted replay: err = %v", err)
+		}
+		if results, _ := s.ReplayResults(ctx, "tok", "r1"); len(results) != 0 {
+			t.Errorf("deleted replay's report = %+v", results)
+		}
+		// A batch still running when its token is purged can't bring its record back
+		if err := s.UpdateReplay(ctx, "tok", b, time.Hour); err != ErrNotFound {
+			t.Errorf("UpdateReplay after delete: err = %v", err)
+		}
+		if _, err := s.GetReplay(ctx, "other", "r1"); err != nil {
+			t.Errorf("other token's replay: err = %v", err)
+		}
+	})
+
+	t.Run("simulations", func(t *testing.T) {
+		due := time.Now().Add(time.Minute)
+		for i := 0; i < SimulationHistory+1; i++ {
+			sim := models.StoredSimulation{Simulation: models.Simulation{ID: fmt.Sprintf("sim%d", i)}, Secret: "s3cret"}
+			if i == 0 {
+				sim.NextAttemptAt = &due
+			}
+			if err := s.CreateSimulation(ctx, "tok", sim, time.Hour); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		list, err := s.ListSimulations(ctx, "tok")
+		if err != nil || len(list) != SimulationHistory || list[0].ID != fmt.Sprintf("sim%d", SimulationHistory) {
+			t.Fatalf("ListSimulations = %d, %v", len(list), err)
+		}
+		if n, err := s.PendingSimulations(ctx, "tok"); err != nil || n != 1 {
+			t.Errorf("PendingSimulations counts ones past the history: %d, %v", n, err)
+		}
+
+		sim, err := s.GetSimulation(ctx, "tok", "sim0")
+		if err != nil || sim.Secret != "s3cret" {
+			t.Fatalf("GetSimulation = %+v, %v", sim, err)
+		}
+		sim.NextAttemptAt = nil
+		s.SaveSimulation(ctx, "tok", *sim, time.Hour)
+		if n, _ := s.PendingSimulations(ctx, "tok"); n != 0 {
+			t.Errorf("finished simulation still pending: %d", n)
+		}
+		if list, _ := s.ListSimulations(ctx, "tok"); len(list) != SimulationHistory {
+			t.Errorf("saving moved a simulation into the history: %d", len(list))
+		}
+
+		s.DeleteSimulations(ctx, "tok")
+		if list, _ := s.ListSimulations(ctx, "tok"); len(list) != 0 {
+			t.Errorf("simulations survived delete: %d", len(list))
+		}
+		if _, err := s.GetSimulation(ctx, "tok", "sim0"); err != ErrNotFound {
+			t.Errorf("simulation past the history survived delete: err = %v", err)
+		}
+	})
+}
+
+func TestMemory(t *testing.T) {
+	testStore(t, NewMemory())
+}
+
+// Runs against a scratch database when REDIS_TEST_ADDR is set; the database is flushed
+func TestRedis(t *testing.T) {
+	addr := os.Getenv("REDIS_TEST_ADDR")
+	if addr == "" {
+		t.Skip("REDIS_TEST_ADDR not set")
+	}
+	ctx := context.Background()
+	client := goredis.NewClient(&goredis.Options{Addr: addr, DB: 15})
+	defer client.Close()
+	if err := client.FlushDB(ctx).Err(); err != nil {
+		t.Fatal(err)
+	}
+	s := NewRedis(client)
+	testStore(t, s)
+
+	t.Run("index", func(t *testing.T) {
+		s.SaveCapture(ctx, "idx", models.WebhookPayload{ID: "short", Timestamp: time.Now()}, time.Hour)
+		if ttl := client.PTTL(ctx, captureIndexKey("idx")).Val(); ttl <= 0 {
+			t.Errorf("index should expire with its captures, ttl = %s", ttl)
+		}
+		s.SaveCapture(ctx, "idx", models.WebhookPayload{ID: "pinned", Timestamp: time.Now()}, 0)
+		if ttl := client.TTL(ctx, captureIndexKey("idx")).Val(); ttl != -1 {
+			t.Errorf("index of a pinned capture must persist, ttl = %s", ttl)
+		}
+
+		// An entry whose capture expired is pruned on the next listing
+		client.Del(ctx, captureKey("idx", "short"))
+		if list, _ := s.ListCaptures(ctx, "idx"); len(list) != 1 {
+			t.Errorf("ListCaptures = %+v", list)
+		}
+		if ids, _ := s.CaptureIDs(ctx, "idx"); len(ids) != 1 || ids[0] != "pinned" {
+			t.Errorf("expired entry not pruned: %v", ids)
+		}
+
+		// Expired captures leave the counts without being loaded
+		s.SaveCapture(ctx, "idx", models.WebhookPayload{ID: "brief", Timestamp: time.Now()}, 50*time.Millisecond)
+		time.Sleep(100 * time.Millisecond)
+		if counts, _ := s.CaptureCounts(ctx, "idx"); counts != (Counts{Total: 1, Pinned: 0, Unread: 1}) {
+			t.Errorf("counts after expiry = %+v", counts)
+		}
+		if page, err := s.QueryCaptures(ctx, "idx", query.Query{Limit: 10, Order: query.OrderAsc}); err != nil || page.Total == nil || *page.Total != 1 {
+			t.Errorf("QueryCaptures after expiry = %+v, %v", page, err)
+		}
+
+		// Captures from before the index are picked up once
+		client.Set(ctx, captureKey("old", "legacy"), `{"id":"legacy","timestamp":"2026-01-01T00:00:00Z"}`, time.Hour)
+		if err := s.IndexExistingCaptures(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if ids, _ := s.CaptureIDs(ctx, "old"); len(ids) != 1 {
+			t.Errorf("legacy capture not indexed: %v", ids)
+		}
+
+		// Captures indexed before the flag indexes are given their flags once
+		client.Del(ctx, captureUnreadKey("old"), captureUnpinnedKey("old"))
+		if err := s.IndexExistingCaptureFlags(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if counts, _ := s.CaptureCounts(ctx, "old"); counts != (Counts{Total: 1, Pinned: 0, Unread: 1}) {
+			t.Errorf("legacy capture flags not indexed: %+v", counts)
+		}
+	})
+
+	t.Run("search", func(t *testing.T) {
+		s.SaveCapture(ctx, "srch", models.WebhookPayload{ID: "a", Body: `{"customer":"cus_123"}`, Timestamp: time.Now()}, time.Hour)
+		s.SaveCapture(ctx, "srch", models.WebhookPayload{ID: "b", Body: `{"customer":"cus_456"}`, Timestamp: time.Now()}, time.Hour)
+
+		q := query.Query{Filter: query.Filter{Search: `$.customer == "cus_123"`}, Limit: 10, Order: query.OrderAsc}
+		q.Compile()
+		if page, err := s.QueryCaptures(ctx, "srch", q); err != nil || len(page.Items) != 1 || page.Items[0].ID != "a" {
+			t.Fatalf("QueryCaptures = %+v, %v", page, err)
+		}
+		if n := client.SCard(ctx, searchTermKey("srch", "t:cus_123")).Val(); n != 1 {
+			t.Errorf("term set holds %d captures", n)
+		}
+
+		s.DeleteCapture(ctx, "srch", "a")
+		if n := client.Exists(ctx, searchTermKey("srch", "t:cus_123")).Val(); n != 0 {
+			t.Error("deleted capture left in the term set")
+		}
+		s.DeleteCaptures(ctx, "srch")
+		if keys := client.Keys(ctx, "search*srch*").Val(); len(keys) != 0 {
+			t.Errorf("purge left search keys: %v", keys)
+		}
+	})
+}
+
+func TestMemoryExpiry(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+	now := time.Now()
+	m.now = func() time.Time { return now }
+
+	m.SaveCapture(ctx, "tok", models.WebhookPayload{ID: "short"}, time.Minute)
+	m.SaveCapture(ctx, "tok", models.WebhookPayload{ID: "forever"}, 0)
+	m.CreateSession(ctx, "sess", "octocat", time.Minute)
+
+	now = now.Add(2 * time.Minute)
+	if _, err := m.GetCapture(ctx, "tok", "short"); err != ErrNotFound {
+		t.Errorf("expected capture to expire, got %v", err)
+	}
+	if list, _ := m.ListCaptures(ctx, "tok"); len(list) != 1 || list[0].ID != "forever" {
+		t.Errorf("ListCaptures = %+v", list)
+	}
+	if _, err := m.SessionUser(ctx, "sess"); err != ErrNotFound {
+		t.Errorf("expected session to expire, got %v", err)
+	}
+}
+
+func TestMemorySweep(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+	now := time.Now()
+	m.now = func() time.Time { return now }
+
+	m.SaveCapture(ctx, "tok", models.WebhookPayload{ID: "short"}, time.Minute)
+	m.SaveCapture(ctx, "gone", models.WebhookPayload{ID: "short"}, time.Minute)
+	m.SaveCapture(ctx, "tok", models.WebhookPayload{ID: "forever"}, 0)
+	m.CreateSession(ctx, "sess", "octocat", time.Minute)
+	m.IncrementUsage(ctx, "tok", time.Hour)
+
+	now = now.Add(2 * time.Minute)
+	m.Sweep()
+	if _, ok := m.captures["gone"]; ok {
+		t.Error("expected a token without live captures to be dropped")
+	}
+	if _, ok := m.captures["tok"]["short"]; ok || len(m.captures["tok"]) != 1 {
+		t.Errorf("captures after sweep = %v", m.captures["tok"])
+	}
+	if _, ok := m.sessions["sess"]; ok {
+		t.Error("expected the expired session to be dropped")
+	}
+	if _, ok := m.usage["tok"]; !ok {
+		t.Error("expected live usage to be kept")
+	}
+}
+
+func TestMemoryOrdersLikeRedis(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+	at := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	// Same microsecond, so the index falls back to the ID
+	for _, p := range []models.WebhookPayload{
+		{ID: "b", Timestamp: at.Add(300 * time.Nanosecond)},
+		{ID: "c", Timestamp: at},
+		{ID: "a", Timestamp: at.Add(600 * time.Nanosecond)},
+		{ID: "0", Timestamp: at.Add(time.Microsecond)},
+	} {
+		m.SaveCapture(ctx, "tok", p, 0)
+	}
+	ids, _ := m.CaptureIDs(ctx, "tok")
+	if len(ids) != 4 || ids[0] != "a" || ids[1] != "b" || ids[2] != "c" || ids[3] != "0" {
+		t.Errorf("CaptureIDs = %v", ids)
+	}
+}
+
+func TestMemoryIsolatesCallers(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+	p := models.WebhookPayload{ID: "a", Headers: map[string][]string{"X": {"1"}}}
+	m.SaveCapture(ctx, "tok", p, 0)
+	p.Headers["X"][0] = "changed"
+
+	got, _ := m.GetCapture(ctx, "tok", "a")
+	if got.Headers["X"][0] != "1" {
+		t.Error("stored capture shares memory with the caller")
+	}
+}
@@ -0,0 +1,299 @@
+// Package transform rewrites forwarded copies of a capture: headers, the JSON body and masked fields.
+package transform
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"webhook-inspector/internal/jsonpatch"
+	"webhook-inspector/internal/models"
+	"webhook-inspector/internal/usertmpl"
+
+	"github.com/itchyny/gojq"
+)
+
+// Step types
+const (
+	SetHeader    = "set_header"
+	RemoveHeader = "remove_header"
+	RenameHeader = "rename_header"
+	JQ           = "jq"
+	Template     = "template"
+	Mask         = "mask"
+)
+
+const (
+	defaultMask = "***"
+	// Bounds runaway expressions such as `repeat(1)`
+	jqTimeout = time.Second
+)
+
+// TemplateData is what a template step sees as "."
+type TemplateData struct {
+	Method  string
+	Headers http.Header
+	// Parsed JSON body, or nil when the body is not JSON
+	Body interface{}
+	Raw  string
+}
+
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return usertmpl.Capped(string(data))
+	},
+}
+
+// Validate checks steps before they are saved, compiling expressions and templates
+func Validate(steps []models.TransformStep) error {
+	for i, step := range steps {
+		if err := validateStep(step); err != nil {
+			return fmt.Errorf("step %d (%s): %w", i, step.Type, err)
+		}
+	}
+	return nil
+}
+
+func validateStep(step models.TransformStep) error {
+	switch step.Type {
+	case SetHeader, RemoveHeader:
+		if step.Header == "" {
+			return fmt.Errorf("header is required")
+		}
+	case RenameHeader:
+		if step.Header == "" || step.To == "" {
+			return fmt.Errorf("header and to are required")
+		}
+	case JQ:
+		_, err := compileJQ(step.Expression)
+		return err
+	case Template:
+		_, err := parseTemplate(step.Template)
+		return err
+	case Mask:
+		if len(step.Paths) == 0 {
+			return fmt.Errorf("paths are required")
+		}
+		for _, p := range step.Paths {
+			if _, err := jsonpatch.ParsePointer(p); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unknown step type")
+	}
+	return nil
+}
+
+// Apply returns a copy of payload with the steps applied in order; the input is not modified
+func Apply(ctx context.Context, payload models.WebhookPayload, steps []models.TransformStep) (models.WebhookPayload, error) {
+	out := payload
+	out.Headers = http.Header(payload.Headers).Clone()
+	if out.Headers == nil {
+		out.Headers = map[string][]string{}
+	}
+
+	for i, step := range steps {
+		if err := applyStep(ctx, &out, step); err != nil {
+			return payload, fmt.Errorf("step %d (%s): %w", i, step.Type, err)
+		}
+	}
+	return out, nil
+}
+
+func applyStep(ctx context.Context, p *models.WebhookPayload, step models.TransformStep) error {
+	headers := http.Header(p.Headers)
+
+	switch step.Type {
+	case SetHeader:
+		headers.Set(step.Header, step.Value)
+	case RemoveHeader:
+		headers.Del(step.Header)
+	case RenameHeader:
+		values := headers.Values(step.Header)
+		if len(values) == 0 {
+			return nil
+		}
+		headers.Del(step.Header)
+		for _, v := range values {
+			headers.Add(step.To, v)
+		}
+	case JQ:
+		return applyJQ(ctx, p, step.Expression)
+	case Template:
+		return applyTemplate(ctx, p, step.Template)
+	case Mask:
+		return applyMask(p, step.Paths, step.Mask)
+	default:
+		return fmt.Errorf("unknown step type")
+	}
+	return nil
+}
+
+func compileJQ(expression string) (*gojq.Code, error) {
+	if strings.TrimSpace(expression) == "" {
+		return nil, fmt.Errorf("expression is required")
+	}
+	query, err := gojq.Parse(expression)
+	if err != nil {
+		return nil, err
+	}
+	return gojq.Compile(query)
+}
+
+// The expression must produce exactly one value, which becomes the new body
+func applyJQ(ctx context.Context, p *models.WebhookPayload, expression string) error {
+	code, err := compileJQ(expression)
+	if err != nil {
+		return err
+	}
+	body, err := decodeBody(p.Body)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, jqTimeout)
+	defer cancel()
+
+	iter := code.RunWithContext(ctx, body)
+	result, ok := iter.Next()
+	if !ok {
+		return fmt.Errorf("expression produced no output")
+	}
+	if err, isErr := result.(error); isErr {
+		return err
+	}
+	if extra, more := iter.Next(); more {
+		if err, isErr := extra.(error); isErr {
+			return err
+		}
+		return fmt.Errorf("expression produced more than one output")
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	p.Body = string(data)
+	return nil
+}
+
+func parseTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		return nil, fmt.Errorf("template is required")
+	}
+	return usertmpl.Parse("body", text, templateFuncs)
+}
+
+// Templates are bounded by usertmpl's deadline and output cap, as jq is by jqTimeout
+func applyTemplate(ctx context.Context, p *models.WebhookPayload, text string) error {
+	tmpl, err := parseTemplate(text)
+	if err != nil {
+		return err
+	}
+
+	data := TemplateData{Method: p.Method, Headers: http.Header(p.Headers), Raw: p.Body}
+	// Non-JSON bodies are still available as .Raw
+	data.Body, _ = decodeBody(p.Body)
+
+	body, err := usertmpl.Execute(ctx, tmpl, data)
+	if err != nil {
+		return err
+	}
+	p.Body = body
+	return nil
+}
+
+// Replace every value at the given pointers; a "*" token matches every key or index
+func applyMask(p *models.WebhookPayload, paths []string, replacement string) error {
+	if replacement == "" {
+		replacement = defaultMask
+	}
+	body, err := decodeBody(p.Body)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		tokens, err := jsonpatch.ParsePointer(path)
+		if err != nil {
+			return err
+		}
+		body = mask(body, tokens, replacement)
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	p.Body = string(data)
+	return nil
+}
+
+func mask(node interface{}, tokens []string, replacement string) interface{} {
+	if len(tokens) == 0 {
+		return replacement
+	}
+	tok, rest := tokens[0], tokens[1:]
+
+	switch n := node.(type) {
+	case map[string]interface{}:
+		for key, child := range n {
+			if tok == "*" || tok == key {
+				n[key] = mask(child, rest, replacement)
+			}
+		}
+	case []interface{}:
+		for i, child := range n {
+			if tok == "*" || tok == fmt.Sprint(i) {
+				n[i] = mask(child, rest, replacement)
+			}
+		}
+	}
+	// Missing paths are left alone so one mask list fits several event shapes
+	return node
+}
+
+func decodeBody(body string) (interface{}, error) {
+	decoder := json.NewDecoder(strings.NewReader(body))
+	decoder.UseNumber()
+
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil {
+		return nil, fmt.Errorf("body is not valid JSON: %w", err)
+	}
+	return normalizeNumbers(v), nil
+}
+
+// gojq works on float64/int values; json.Number keeps big IDs exact until then
+func normalizeNumbers(v interface{}) interface{} {
+	switch n := v.(type) {
+	case map[string]interface{}:
+		for k, child := range n {
+			n[k] = normalizeNumbers(child)
+		}
+	case []interface{}:
+		for i, child := range n {
+			n[i] = normalizeNumbers(child)
+		}
+	case json.Number:
+		if i, err := n.Int64(); err == nil {
+			return int(i)
+		}
+		if i, ok := new(big.Int).SetString(n.String(), 10); ok {
+			return i
+		}
+		f, _ := n.Float64()
+		return f
+	}
+	return v
+}
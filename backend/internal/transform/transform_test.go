@@ -0,0 +1,180 @@
+package transform
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"webhook-inspector/internal/models"
+)
+
+func capture() models.WebhookPayload {
+	return models.WebhookPayload{
+		ID:     "c1",
+		Method: "POST",
+		Headers: map[string][]string{
+			"Content-Type":     {"application/json"},
+			"Stripe-Signature": {"t=1,v1=abc"},
+			"X-Request-Id":     {"r1"},
+		},
+		Body: `{"id":"evt_1","type":"charge.succeeded","amount":12345678901234567890,"customer":{"email":"a@example.com","cards":[{"last4":"4242"},{"last4":"1881"}]}}`,
+	}
+}
+
+func decode(t *testing.T, body string) map[string]interface{} {
+	t.Helper()
+	var v map[string]interface{}
+	if err := json.Unmarshal([]byte(body), &v); err != nil {
+		t.Fatalf("invalid JSON %q: %v", body, err)
+	}
+	return v
+}
+
+func TestApplyHeaders(t *testing.T) {
+	in := capture()
+	out, err := Apply(context.Background(), in, []models.TransformStep{
+		{Type: SetHeader, Header: "x-env", Value: "staging"},
+		{Type: RemoveHeader, Header: "stripe-signature"},
+		{Type: RenameHeader, Header: "X-Request-Id", To: "X-Correlation-Id"},
+		{Type: RenameHeader, Header: "X-Missing", To: "X-Other"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string][]string{
+		"Content-Type":     {"application/json"},
+		"X-Env":            {"staging"},
+		"X-Correlation-Id": {"r1"},
+	}
+	if !reflect.DeepEqual(out.Headers, want) {
+		t.Errorf("headers = %v, want %v", out.Headers, want)
+	}
+	if _, ok := in.Headers["Stripe-Signature"]; !ok {
+		t.Error("input headers were modified")
+	}
+}
+
+func TestApplyJQ(t *testing.T) {
+	out, err := Apply(context.Background(), capture(), []models.TransformStep{
+		{Type: JQ, Expression: `{event: .type, payload: {id, amount}}`},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Large integers must survive the round trip exactly
+	if out.Body != `{"event":"charge.succeeded","payload":{"amount":12345678901234567890,"id":"evt_1"}}` {
+		t.Errorf("unexpected body: %s", out.Body)
+	}
+}
+
+func TestApplyJQErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		body string
+		err  string
+	}{
+		{"multiple outputs", `.customer.cards[]`, capture().Body, "more than one output"},
+		{"no output", `empty`, capture().Body, "no output"},
+		{"runtime error", `.type + 1`, capture().Body, "cannot add"},
+		{"not json", `.`, "a=b", "not valid JSON"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := capture()
+			p.Body = tt.body
+			_, err := Apply(context.Background(), p, []models.TransformStep{{Type: JQ, Expression: tt.expr}})
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("expected error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestApplyTemplate(t *testing.T) {
+	out, err := Apply(context.Background(), capture(), []models.TransformStep{
+		{Type: Template, Template: `{"kind":{{json .Body.type}},"email":{{json .Body.customer.email}},"via":"{{.Headers.Get "Content-Type"}}"}`},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := decode(t, out.Body)
+	if got["kind"] != "charge.succeeded" || got["email"] != "a@example.com" || got["via"] != "application/json" {
+		t.Errorf("unexpected body: %s", out.Body)
+	}
+}
+
+func TestApplyTemplateRunaway(t *testing.T) {
+	for _, text := range []string{
+		`{{range 100000000000}}{{end}}`,
+		`{{range 100000000000}}x{{end}}`,
+		`{{define "a"}}{{template "a" .}}{{template "a" .}}{{end}}{{template "a" .}}`,
+	} {
+		start := time.Now()
+		_, err := Apply(context.Background(), capture(), []models.TransformStep{{Type: Template, Template: text}})
+		if err == nil {
+			t.Errorf("expected %s to be stopped", text)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("%s ran for %s", text, elapsed)
+		}
+	}
+}
+
+func TestApplyMask(t *testing.T) {
+	out, err := Apply(context.Background(), capture(), []models.TransformStep{
+		{Type: Mask, Paths: []string{"/customer/email", "/customer/cards/*/last4", "/not/there"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"amount":12345678901234567890,"customer":{"cards":[{"last4":"***"},{"last4":"***"}],"email":"***"},"id":"evt_1","type":"charge.succeeded"}`
+	if out.Body != want {
+		t.Errorf("body = %s\nwant  %s", out.Body, want)
+	}
+}
+
+func TestApplyLeavesInputOnError(t *testing.T) {
+	in := capture()
+	out, err := Apply(context.Background(), in, []models.TransformStep{
+		{Type: SetHeader, Header: "X-Env", Value: "staging"},
+		{Type: JQ, Expression: `error("boom")`},
+	})
+	if err == nil || !strings.Contains(err.Error(), "step 1 (jq)") {
+		t.Fatalf("expected step error, got %v", err)
+	}
+	if !reflect.DeepEqual(out, in) {
+		t.Error("expected the original payload back on error")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	bad := [][]models.TransformStep{
+		{{Type: "upper"}},
+		{{Type: SetHeader}},
+		{{Type: RenameHeader, Header: "A"}},
+		{{Type: JQ, Expression: ".foo | "}},
+		{{Type: Template, Template: "{{.Body"}},
+		{{Type: Mask}},
+		{{Type: Mask, Paths: []string{"no-slash"}}},
+	}
+	for _, steps := range bad {
+		if err := Validate(steps); err == nil {
+			t.Errorf("expected %+v to be rejected", steps)
+		}
+	}
+
+	good := []models.TransformStep{
+		{Type: SetHeader, Header: "X-Env", Value: "staging"},
+		{Type: JQ, Expression: ".data.object"},
+		{Type: Template, Template: `{{json .Body}}`},
+		{Type: Mask, Paths: []string{"/card/number"}},
+	}
+	if err := Validate(good); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,136 @@
+// Package usertmpl runs text/templates written by users, such as transform steps and
+// rule notifications, within a deadline and an output cap. text/template can't be
+// cancelled, so every range iteration and template call is made to check the deadline,
+// and the builtins that build strings are replaced by capped ones.
+package usertmpl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+)
+
+const (
+	// Timeout matches the one jq transform steps get
+	Timeout = time.Second
+	// MaxOutput caps what a template writes and every string it builds along the way
+	MaxOutput = 1 << 20
+)
+
+var (
+	// ErrTimeout is returned when a template runs longer than Timeout
+	ErrTimeout = errors.New("template timed out")
+	// ErrTooLarge is returned when a template produces more than MaxOutput bytes
+	ErrTooLarge = fmt.Errorf("template output exceeds %d bytes", MaxOutput)
+)
+
+// Called at the start of every range iteration and template; Execute swaps in a
+// version bound to its deadline
+const tickFunc = "usertmplTick"
+
+var builtins = template.FuncMap{
+	tickFunc:   func() string { return "" },
+	"print":    func(args ...interface{}) (string, error) { return Capped(fmt.Sprint(args...)) },
+	"printf":   func(format string, args ...interface{}) (string, error) { return Capped(fmt.Sprintf(format, args...)) },
+	"println":  func(args ...interface{}) (string, error) { return Capped(fmt.Sprintln(args...)) },
+	"html":     func(args ...interface{}) (string, error) { return Capped(template.HTMLEscaper(args...)) },
+	"js":       func(args ...interface{}) (string, error) { return Capped(template.JSEscaper(args...)) },
+	"urlquery": func(args ...interface{}) (string, error) { return Capped(template.URLQueryEscaper(args...)) },
+}
+
+// Capped returns s, or ErrTooLarge when it is longer than MaxOutput. Functions that
+// build strings for templates should pass their results through it.
+func Capped(s string) (string, error) {
+	if len(s) > MaxOutput {
+		return "", ErrTooLarge
+	}
+	return s, nil
+}
+
+// Parse parses text as a template with funcs on top of the capped builtins.
+// Missing map keys render as the zero value.
+func Parse(name, text string, funcs template.FuncMap) (*template.Template, error) {
+	tmpl, err := template.New(name).Funcs(builtins).Funcs(funcs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	tick, err := template.New("tick").Funcs(builtins).Parse("{{" + tickFunc + "}}")
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil && t.Tree.Root != nil {
+			instrument(t.Tree.Root, tick.Tree.Root.Nodes[0])
+		}
+	}
+	return tmpl, nil
+}
+
+// Execute runs a template from Parse against data, stopping it with ErrTimeout once it
+// runs past Timeout or ctx is done, and with ErrTooLarge once it writes more than MaxOutput
+func Execute(ctx context.Context, tmpl *template.Template, data interface{}) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+
+	bound, err := tmpl.Clone()
+	if err != nil {
+		return "", err
+	}
+	bound.Funcs(template.FuncMap{tickFunc: func() (string, error) {
+		if ctx.Err() != nil {
+			return "", ErrTimeout
+		}
+		return "", nil
+	}})
+
+	var out cappedWriter
+	if err := bound.Execute(&out, data); err != nil {
+		// Report the limit rather than text/template's wrapping of it
+		for _, limit := range []error{ErrTimeout, ErrTooLarge} {
+			if errors.Is(err, limit) {
+				return "", limit
+			}
+		}
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// Put a tick first in list and in the body of every range below it
+func instrument(list *parse.ListNode, tick parse.Node) {
+	for _, node := range list.Nodes {
+		var branch *parse.BranchNode
+		switch n := node.(type) {
+		case *parse.IfNode:
+			branch = &n.BranchNode
+		case *parse.WithNode:
+			branch = &n.BranchNode
+		case *parse.RangeNode:
+			branch = &n.BranchNode
+		default:
+			continue
+		}
+		if branch.List != nil {
+			instrument(branch.List, tick)
+		}
+		if branch.ElseList != nil {
+			instrument(branch.ElseList, tick)
+		}
+	}
+	list.Nodes = append([]parse.Node{tick}, list.Nodes...)
+}
+
+type cappedWriter struct {
+	strings.Builder
+}
+
+func (w *cappedWriter) Write(p []byte) (int, error) {
+	if w.Len()+len(p) > MaxOutput {
+		return 0, ErrTooLarge
+	}
+	return w.Builder.Write(p)
+}
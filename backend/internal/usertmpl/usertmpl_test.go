@@ -0,0 +1,63 @@
+package usertmpl
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func run(t *testing.T, text string, data interface{}) (string, error) {
+	t.Helper()
+	tmpl, err := Parse("test", text, nil)
+	if err != nil {
+		t.Fatalf("Parse(%q) failed: %v", text, err)
+	}
+	return Execute(context.Background(), tmpl, data)
+}
+
+func TestExecute(t *testing.T) {
+	out, err := run(t, `{{range .}}{{if .}}{{printf "%s;" .}}{{else}}-{{end}}{{end}}{{with .}}{{len .}}{{end}}`, []string{"a", "", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "a;-b;3" {
+		t.Errorf("got %q", out)
+	}
+	if out, _ := run(t, `{{.missing}}`, map[string]string{}); out != "" {
+		t.Errorf("expected missing keys to render empty, got %q", out)
+	}
+}
+
+func TestExecuteTimeout(t *testing.T) {
+	for _, text := range []string{
+		`{{range 100000000000}}{{end}}`,
+		`{{range $i := 100000}}{{range 100000}}{{end}}{{end}}`,
+	} {
+		if _, err := run(t, text, nil); !errors.Is(err, ErrTimeout) {
+			t.Errorf("%s: expected ErrTimeout, got %v", text, err)
+		}
+	}
+	// Deep recursion hits text/template's own depth limit first
+	if _, err := run(t, `{{define "a"}}{{template "a"}}{{template "a"}}{{end}}{{template "a"}}`, nil); err == nil {
+		t.Error("expected runaway recursion to be stopped")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	tmpl, _ := Parse("test", `{{range 10}}{{end}}`, nil)
+	if _, err := Execute(ctx, tmpl, nil); !errors.Is(err, ErrTimeout) {
+		t.Errorf("expected a cancelled context to stop the template, got %v", err)
+	}
+}
+
+func TestExecuteTooLarge(t *testing.T) {
+	for _, text := range []string{
+		`{{range 100000000000}}xxxxxxxx{{end}}`,
+		`{{$s := "xx"}}{{range 64}}{{$s = printf "%s%s" $s $s}}{{end}}`,
+		`{{$s := "<>"}}{{range 64}}{{$s = print $s (html $s)}}{{end}}`,
+	} {
+		if _, err := run(t, text, nil); !errors.Is(err, ErrTooLarge) {
+			t.Errorf("%s: expected ErrTooLarge, got %v", text, err)
+		}
+	}
+}
@@ -1,34 +1,145 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"webhook-inspector/internal/config"
+	"webhook-inspector/internal/forward"
 	"webhook-inspector/internal/handlers"
 	"webhook-inspector/internal/redis"
+	"webhook-inspector/internal/simulate"
+	"webhook-inspector/internal/sink"
+	"webhook-inspector/internal/store"
 
 	"github.com/go-chi/chi/v5"
 )
 
 func main() {
-	redis.InitRedis()
 	log.Println("GITHUB_CLIENT_ID =", os.Getenv("GITHUB_CLIENT_ID"))
 
+	if config.StoreBackend == "memory" {
+		log.Println("Using the in-memory store; Redis-backed features are disabled")
+		memoryStore := store.NewMemory()
+		handlers.Store = memoryStore
+		go memoryStore.RunSweeper(context.Background(), config.MemorySweepInterval)
+	} else {
+		// Share links must verify on every instance and after restarts
+		if config.ShareSigningKey == "" {
+			log.Fatal("SHARE_SIGNING_KEY must be set when using the Redis store")
+		}
+		redis.InitRedis()
+		redisStore := store.NewRedis(redis.Client)
+		handlers.Store = redisStore
+
+		go func() {
+			if err := redisStore.IndexExistingCaptures(context.Background()); err != nil {
+				log.Printf("main: failed to index existing captures: %v", err)
+				return
+			}
+			if err := redisStore.IndexExistingSearchTerms(context.Background()); err != nil {
+				log.Printf("main: failed to index existing search terms: %v", err)
+			}
+			if err := redisStore.IndexExistingCaptureFlags(context.Background()); err != nil {
+				log.Printf("main: failed to index the flags of existing captures: %v", err)
+			}
+		}()
+
+		go forward.Run(context.Background(), redisStore)
+		go simulate.Run(context.Background(), redisStore)
+		go sink.Run(context.Background(), redisStore)
+	}
+	go handlers.RunBinJanitor(context.Background())
+
+	log.Println("Starting server on :8080")
+	if err := http.ListenAndServe(":8080", newRouter()); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Routes for the API, docs and dashboard; handlers.Store must be set first
+func newRouter() http.Handler {
 	r := chi.NewRouter()
 
 	// Webhooks
 	r.Route("/hooks", func(r chi.Router) {
 		r.Post("/", handlers.HandleWebhook)
 		r.Post("/{token}", handlers.HandleWebhook)
+		r.Post("/{token}/*", handlers.HandleWebhook)
 	})
 
 	// Token mgmt
 	r.Get("/create", handlers.CreateSession)
 	r.Get("/logs", handlers.GetWebhookLogs)
+	r.Get("/search", handlers.SearchWebhooks)
+	r.Get("/logs/export", handlers.ExportWebhooks)
+	r.Post("/logs/import", handlers.ImportWebhooks)
+	r.Get("/logs/diff", handlers.DiffWebhooks)
+	r.Post("/logs/bulk", handlers.BulkUpdateWebhooks)
 	r.Get("/status", handlers.GetTokenStatus)
 	r.Post("/reset", handlers.ResetToken)
+	r.Get("/logs/{id}", handlers.GetWebhook)
+	r.Patch("/logs/{id}", handlers.UpdateWebhook)
 	r.Delete("/logs/{id}", handlers.DeleteWebhook)
+	r.Get("/logs/{id}/snippet", handlers.GetSnippet)
+
+	// Per-endpoint settings
+	r.Get("/endpoint/config", handlers.GetEndpointConfig)
+	r.Put("/endpoint/config", handlers.PutEndpointConfig)
+	r.Post("/logs/{id}/transform", handlers.PreviewTransform)
+
+	// Golden baselines and drift
+	r.Get("/baselines", handlers.ListBaselines)
+	r.Post("/baselines", handlers.CreateBaseline)
+	r.Get("/baselines/{name}", handlers.GetBaseline)
+	r.Delete("/baselines/{name}", handlers.DeleteBaseline)
+	r.Get("/baselines/{name}/drift", handlers.GetDriftReport)
+
+	// Read-only share links; /shared/{id} needs only the signed link
+	r.Get("/shares", handlers.ListShares)
+	r.Post("/shares", handlers.CreateShare)
+	r.Delete("/shares/{id}", handlers.DeleteShare)
+	r.Get("/shared/{id}", handlers.GetSharedView)
+
+	// Inferred schemas and generated types
+	r.Get("/schemas", handlers.ListSchemas)
+	r.Get("/schemas/infer", handlers.InferSchema)
+
+	// Provider sample events
+	r.Get("/fixtures", handlers.ListFixtures)
+	r.Get("/fixtures/{name}", handlers.GetFixture)
+	r.Post("/fixtures/{name}/send", handlers.SendFixture)
+
+	// Features driven by the Redis delivery workers
+	r.Group(func(r chi.Router) {
+		r.Use(handlers.RequireRedis)
+
+		// Forwarding and replay
+		r.Get("/logs/{id}/deliveries", handlers.GetDeliveries)
+		r.Get("/endpoint/sinks", handlers.GetSinks)
+		r.Post("/logs/{id}/replay", handlers.ReplayWebhook)
+		r.Post("/replays", handlers.StartReplayBatch)
+		r.Get("/replays/{id}", handlers.GetReplayBatch)
+		r.Get("/replays/{id}/report", handlers.GetReplayReport)
+		r.Post("/replays/{id}/cancel", handlers.CancelReplayBatch)
+		r.Get("/deliveries/dead", handlers.GetDeadLetters)
+		r.Post("/deliveries/dead/{id}/retry", handlers.RetryDeadLetter)
+
+		// Outbound delivery simulator
+		r.Post("/simulations", handlers.CreateSimulation)
+		r.Get("/simulations", handlers.ListSimulations)
+		r.Get("/simulations/{id}", handlers.GetSimulation)
+
+		// CLI relay (WebSocket)
+		r.Get("/relay", handlers.RelayConnect)
+	})
+
+	// Ephemeral bins (bearer credential auth)
+	r.Post("/bins", handlers.CreateBin)
+	r.Get("/bins/{token}", handlers.GetBin)
+	r.Delete("/bins/{token}", handlers.DeleteBin)
 
 	// Auth
 	r.Get("/auth/github", handlers.GitHubLogin)
@@ -79,8 +190,5 @@ func main() {
 		http.ServeFile(w, r, "./frontend/dist/index.html")
 	})
 
-	log.Println("Starting server on :8080")
-	if err := http.ListenAndServe(":8080", r); err != nil {
-		log.Fatal(err)
-	}
+	return r
 }
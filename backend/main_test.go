@@ -0,0 +1,854 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"webhook-inspector/internal/config"
+	"webhook-inspector/internal/handlers"
+	"webhook-inspector/internal/models"
+	"webhook-inspector/internal/query"
+	"webhook-inspector/internal/store"
+)
+
+// Cookie tokens must be UUIDs like the ones the server issues
+const (
+	testToken  = "6f1c2a0e-8a8d-4c3b-9d5e-2f7a1b3c4d5e"
+	otherToken = "0b9e7d6c-5a4f-4e3d-8c2b-1a0f9e8d7c6b"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	handlers.Store = store.NewMemory()
+	srv := httptest.NewServer(newRouter())
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func do(t *testing.T, method, url, body string, mod func(*http.Request)) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mod != nil {
+		mod(req)
+	}
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func listLogs(t *testing.T, url string, mod func(*http.Request)) query.Page {
+	t.Helper()
+	var page query.Page
+	if err := json.NewDecoder(do(t, "GET", url, "", mod).Body).Decode(&page); err != nil {
+		t.Fatal(err)
+	}
+	return page
+}
+
+// The page's total, or -1 when it has none
+func total(page query.Page) int {
+	if page.Total == nil {
+		return -1
+	}
+	return *page.Total
+}
+
+func TestInMemoryAPI(t *testing.T) {
+	srv := newTestServer(t)
+
+	resp := do(t, "GET", srv.URL+"/create", "", nil)
+	var cookie *http.Cookie
+	for _, c := range resp.Cookies() {
+		if c.Name == "webhook_token" {
+			cookie = c
+		}
+	}
+	if cookie == nil {
+		t.Fatal("expected a webhook_token cookie")
+	}
+	withCookie := func(r *http.Request) { r.AddCookie(&http.Cookie{Name: cookie.Name, Value: cookie.Value}) }
+
+	for _, body := range []string{`{"n":1}`, `{"n":2}`} {
+		if resp := do(t, "POST", srv.URL+"/hooks/"+cookie.Value, body, withCookie); resp.StatusCode != http.StatusOK {
+			t.Fatalf("POST /hooks = %d", resp.StatusCode)
+		}
+	}
+	if resp := do(t, "POST", srv.URL+"/hooks/"+cookie.Value, "not json", withCookie); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("invalid body = %d", resp.StatusCode)
+	}
+
+	logs := listLogs(t, srv.URL+"/logs?order=asc", withCookie).Items
+	if len(logs) != 2 || logs[0].Body != `{"n":1}` {
+		t.Fatalf("logs = %+v", logs)
+	}
+
+	if resp := do(t, "DELETE", srv.URL+"/logs/"+logs[0].ID, "", withCookie); resp.StatusCode != http.StatusOK {
+		t.Fatalf("DELETE /logs/{id} = %d", resp.StatusCode)
+	}
+	if page := listLogs(t, srv.URL+"/logs", withCookie); total(page) != 1 {
+		t.Fatalf("expected one capture after delete, got %d", total(page))
+	}
+
+	var status map[string]interface{}
+	json.NewDecoder(do(t, "GET", srv.URL+"/status", "", withCookie).Body).Decode(&status)
+	if status["requests_used"] != float64(2) || status["privileged"] != false {
+		t.Errorf("status = %v", status)
+	}
+
+	if resp := do(t, "POST", srv.URL+"/reset", "", withCookie); resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST /reset = %d", resp.StatusCode)
+	}
+	if page := listLogs(t, srv.URL+"/logs", withCookie); total(page) != 0 || page.Items == nil {
+		t.Errorf("expected reset to purge captures, got %+v", page)
+	}
+
+	if resp := do(t, "GET", srv.URL+"/replays/x", "", withCookie); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Redis-only route = %d, want 503", resp.StatusCode)
+	}
+}
+
+func TestLogsPagination(t *testing.T) {
+	srv := newTestServer(t)
+	token := testToken
+	withCookie := func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "webhook_token", Value: token}) }
+
+	sends := []struct{ path, body, header string }{
+		{"", `{"type":"a"}`, ""},
+		{"/stripe/events", `{"type":"payment_intent.succeeded"}`, "Stripe-Signature"},
+		{"/stripe", `{"type":"b"}`, ""},
+		{"/github", `{"customer":"cus_123"}`, "X-GitHub-Event"},
+		{"/stripex", `{"type":"c"}`, ""},
+	}
+	for _, s := range sends {
+		resp := do(t, "POST", srv.URL+"/hooks/"+token+s.path+"?source=test", s.body, func(r *http.Request) {
+			withCookie(r)
+			if s.header != "" {
+				r.Header.Set(s.header, "push")
+			}
+		})
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("POST %s = %d", s.path, resp.StatusCode)
+		}
+	}
+
+	// Walk every page forward, then back again with the prev cursor
+	var seen []string
+	page := listLogs(t, srv.URL+"/logs?limit=2", withCookie)
+	first := page
+	for {
+		for _, item := range page.Items {
+			seen = append(seen, item.Body)
+		}
+		if total(page) != 5 {
+			t.Fatalf("total = %d", total(page))
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		page = listLogs(t, srv.URL+"/logs?limit=2&cursor="+page.NextCursor, withCookie)
+	}
+	if len(seen) != 5 || seen[0] != `{"type":"c"}` || seen[4] != `{"type":"a"}` {
+		t.Fatalf("expected newest first without gaps, got %v", seen)
+	}
+	if first.PrevCursor != "" {
+		t.Error("first page must not have a prev cursor")
+	}
+	back := listLogs(t, srv.URL+"/logs?limit=2&cursor="+page.PrevCursor, withCookie)
+	if len(back.Items) != 2 || back.Items[0].Body != `{"type":"b"}` {
+		t.Errorf("prev page = %+v", back.Items)
+	}
+
+	filters := map[string]int{
+		"path=/stripe":                    2,
+		"provider=stripe":                 1,
+		"header=X-GitHub-Event":           1,
+		"header=X-GitHub-Event:pull":      0,
+		"q=CUS_123":                       1,
+		"method=post":                     5,
+		"since=2000-01-01T00:00:00Z":      5,
+		"until=2000-01-01T00:00:00Z":      0,
+		"path=/stripe&q=payment_intent":   1,
+		"order=asc&limit=1&path=/stripex": 1,
+	}
+	for params, want := range filters {
+		if page := listLogs(t, srv.URL+"/logs?"+params, withCookie); len(page.Items) != want {
+			t.Errorf("%s: items %d, want %d", params, len(page.Items), want)
+		}
+	}
+
+	item := listLogs(t, srv.URL+"/logs?path=/github", withCookie).Items[0]
+	if item.Path != "/github" || item.Query != "source=test" {
+		t.Errorf("sub-path capture = %q %q", item.Path, item.Query)
+	}
+
+	for _, bad := range []string{"limit=0", "order=sideways", "since=yesterday", "cursor=nope"} {
+		if resp := do(t, "GET", srv.URL+"/logs?"+bad, "", withCookie); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s = %d, want 400", bad, resp.StatusCode)
+		}
+	}
+}
+
+func TestInMemoryBins(t *testing.T) {
+	srv := newTestServer(t)
+
+	var bin struct {
+		Token      string `json:"token"`
+		IngestURL  string `json:"ingest_url"`
+		Credential string `json:"credential"`
+	}
+	resp := do(t, "POST", srv.URL+"/bins", `{"ttl":"5m"}`, nil)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("POST /bins = %d", resp.StatusCode)
+	}
+	json.NewDecoder(resp.Body).Decode(&bin)
+	bearer := func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+bin.Credential) }
+
+	if resp := do(t, "POST", srv.URL+"/hooks/"+bin.Token, `{"ok":true}`, nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST to bin ingest URL = %d", resp.StatusCode)
+	}
+	// Senders' own bearer auth is captured, not checked
+	senderAuth := func(r *http.Request) { r.Header.Set("Authorization", "Bearer sender-secret") }
+	if resp := do(t, "POST", srv.URL+"/hooks/"+bin.Token, `{"ok":true}`, senderAuth); resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST with sender bearer auth = %d", resp.StatusCode)
+	}
+
+	if page := listLogs(t, srv.URL+"/logs", bearer); total(page) != 2 {
+		t.Fatalf("expected the bin captures, got %d", total(page))
+	}
+
+	if resp := do(t, "DELETE", srv.URL+"/bins/"+bin.Token, "", bearer); resp.StatusCode != http.StatusOK {
+		t.Fatalf("DELETE /bins = %d", resp.StatusCode)
+	}
+	if resp := do(t, "GET", srv.URL+"/logs", "", bearer); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("credential still valid after delete: %d", resp.StatusCode)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	srv := newTestServer(t)
+	withCookie := func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "webhook_token", Value: testToken}) }
+
+	for _, body := range []string{
+		`{"type":"charge.failed","data":{"object":{"customer":"cus_123","amount":2500}}}`,
+		`{"type":"charge.succeeded","data":{"object":{"customer":"cus_456","amount":500}}}`,
+		`{"type":"customer.created","data":{"object":{"id":"cus_123"}}}`,
+	} {
+		if resp := do(t, "POST", srv.URL+"/hooks/"+testToken, body, withCookie); resp.StatusCode != http.StatusOK {
+			t.Fatalf("POST /hooks = %d", resp.StatusCode)
+		}
+	}
+
+	searches := map[string]int{
+		`cus_123`:                                  2,
+		`charge`:                                   2,
+		`$.data.object.customer == "cus_123"`:      1,
+		`$.data.object.amount > 1000`:              1,
+		`$.data.object.amount`:                     2,
+		`!$.data.object.amount`:                    1,
+		`charge $.data.object.customer != "cus_1"`: 2,
+	}
+	for q, want := range searches {
+		page := listLogs(t, srv.URL+"/search?q="+url.QueryEscape(q), withCookie)
+		if len(page.Items) != want {
+			t.Errorf("%s: items %d, want %d", q, len(page.Items), want)
+		}
+	}
+
+	// /logs filters combine with the search
+	if page := listLogs(t, srv.URL+"/logs?limit=1&search="+url.QueryEscape("cus_123"), withCookie); page.Total != nil || len(page.Items) != 1 || page.NextCursor == "" {
+		t.Errorf("paged search = %+v", page)
+	}
+
+	for _, bad := range []string{"/search", "/search?q=" + url.QueryEscape(`$.a >`), "/logs?search=" + url.QueryEscape(`"open`)} {
+		if resp := do(t, "GET", srv.URL+bad, "", withCookie); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s = %d, want 400", bad, resp.StatusCode)
+		}
+	}
+}
+
+func TestCaptureDetailAndSync(t *testing.T) {
+	srv := newTestServer(t)
+	withCookie := func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "webhook_token", Value: testToken}) }
+	send := func(body string) {
+		t.Helper()
+		resp := do(t, "POST", srv.URL+"/hooks/"+testToken+"/stripe?x=1", body, func(r *http.Request) {
+			withCookie(r)
+			r.Header.Set("Content-Type", "application/json; charset=utf-8")
+		})
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("POST /hooks = %d", resp.StatusCode)
+		}
+	}
+	send(`{"n":1}`)
+	send(`{"n":2}`)
+	first := listLogs(t, srv.URL+"/logs?order=asc", withCookie).Items[0]
+
+	var detail struct {
+		ID       string `json:"id"`
+		Raw      string `json:"raw"`
+		Metadata struct {
+			ContentType string `json:"content_type"`
+			BodySize    int    `json:"body_size"`
+			BodyJSON    bool   `json:"body_json"`
+		} `json:"metadata"`
+	}
+	resp := do(t, "GET", srv.URL+"/logs/"+first.ID, "", withCookie)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /logs/{id} = %d", resp.StatusCode)
+	}
+	json.NewDecoder(resp.Body).Decode(&detail)
+	if detail.ID != first.ID || !strings.HasPrefix(detail.Raw, "POST /stripe?x=1 HTTP/1.1\r\n") || !strings.HasSuffix(detail.Raw, "\r\n\r\n{\"n\":1}") {
+		t.Errorf("raw = %q", detail.Raw)
+	}
+	if detail.Metadata.ContentType != "application/json" || detail.Metadata.BodySize != 7 || !detail.Metadata.BodyJSON {
+		t.Errorf("metadata = %+v", detail.Metadata)
+	}
+	if resp := do(t, "GET", srv.URL+"/logs/missing", "", withCookie); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown capture = %d", resp.StatusCode)
+	}
+
+	// Unchanged logs answer 304 until a new capture arrives
+	resp = do(t, "GET", srv.URL+"/logs", "", withCookie)
+	etag := resp.Header.Get("ETag")
+	ifNoneMatch := func(tag string) func(*http.Request) {
+		return func(r *http.Request) { withCookie(r); r.Header.Set("If-None-Match", tag) }
+	}
+	if etag == "" {
+		t.Fatal("expected an ETag on /logs")
+	}
+	if resp := do(t, "GET", srv.URL+"/logs", "", ifNoneMatch(`"other", W/`+etag)); resp.StatusCode != http.StatusNotModified {
+		t.Errorf("matching If-None-Match = %d, want 304", resp.StatusCode)
+	}
+	send(`{"n":3}`)
+	if resp := do(t, "GET", srv.URL+"/logs", "", ifNoneMatch(etag)); resp.StatusCode != http.StatusOK || resp.Header.Get("ETag") == etag {
+		t.Errorf("stale If-None-Match = %d", resp.StatusCode)
+	}
+
+	// Incremental sync returns only what came after the given capture, oldest first
+	page := listLogs(t, srv.URL+"/logs?after="+first.ID, withCookie)
+	if total(page) != 2 || page.Items[0].Body != `{"n":2}` || page.Items[1].Body != `{"n":3}` {
+		t.Errorf("after = %+v", page)
+	}
+	if page := listLogs(t, srv.URL+"/logs?after="+page.Items[1].ID, withCookie); total(page) != 0 {
+		t.Errorf("after newest = %+v", page)
+	}
+	if resp := do(t, "GET", srv.URL+"/logs?after="+first.ID+"&order=desc", "", withCookie); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("after with order=desc = %d, want 400", resp.StatusCode)
+	}
+	if resp := do(t, "GET", srv.URL+"/logs?after=missing", "", withCookie); resp.StatusCode != http.StatusGone {
+		t.Errorf("after unknown capture = %d, want 410", resp.StatusCode)
+	}
+}
+
+func TestExport(t *testing.T) {
+	srv := newTestServer(t)
+	withCookie := func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "webhook_token", Value: testToken}) }
+	for _, path := range []string{"/stripe", "/github", "/stripe"} {
+		if resp := do(t, "POST", srv.URL+"/hooks/"+testToken+path, `{"ok":true}`, withCookie); resp.StatusCode != http.StatusOK {
+			t.Fatalf("POST /hooks = %d", resp.StatusCode)
+		}
+	}
+
+	resp := do(t, "GET", srv.URL+"/logs/export?format=ndjson&path=/stripe", "", withCookie)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("export = %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	var lines []models.WebhookPayload
+	dec := json.NewDecoder(resp.Body)
+	for dec.More() {
+		var p models.WebhookPayload
+		if err := dec.Decode(&p); err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, p)
+	}
+	if len(lines) != 2 || lines[0].Response == nil || lines[0].Response.Body != "Webhook received" || lines[0].Timing == nil {
+		t.Errorf("exported = %+v", lines)
+	}
+
+	var har struct {
+		Log struct{ Entries []json.RawMessage }
+	}
+	resp = do(t, "GET", srv.URL+"/logs/export?format=har", "", withCookie)
+	if err := json.NewDecoder(resp.Body).Decode(&har); err != nil || len(har.Log.Entries) != 3 {
+		t.Errorf("har = %d entries, %v", len(har.Log.Entries), err)
+	}
+
+	if resp := do(t, "GET", srv.URL+"/logs/export?format=xml", "", withCookie); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("unknown format = %d, want 400", resp.StatusCode)
+	}
+}
+
+func TestImport(t *testing.T) {
+	srv := newTestServer(t)
+	withCookie := func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "webhook_token", Value: testToken}) }
+	ndjson := `{"id":"orig-1","method":"POST","timestamp":"2025-01-01T00:00:00Z","headers":{"X-Github-Event":["push"]},"body":"{\"n\":1}","pinned":true}
+{"id":"orig-2","method":"POST","timestamp":"2025-01-01T00:00:01Z","body":"{\"n\":2}"}
+`
+	resp := do(t, "POST", srv.URL+"/logs/import?format=ndjson", ndjson, withCookie)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("import = %d", resp.StatusCode)
+	}
+
+	logs := listLogs(t, srv.URL+"/logs?order=asc", withCookie).Items
+	if len(logs) != 2 || logs[0].ID == "orig-1" || logs[0].Imported == nil || logs[0].Imported.OriginalID != "orig-1" || logs[0].Pinned {
+		t.Fatalf("imported = %+v", logs)
+	}
+	if !logs[0].Timestamp.Equal(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)) || logs[0].Provider != "github" {
+		t.Errorf("original timestamp/provider lost: %+v", logs[0])
+	}
+
+	// Imports use the storage quota, not the ingest rate limit
+	var status map[string]interface{}
+	json.NewDecoder(do(t, "GET", srv.URL+"/status", "", withCookie).Body).Decode(&status)
+	if status["requests_used"] != float64(0) || status["captures_stored"] != float64(2) {
+		t.Errorf("status = %v", status)
+	}
+	defer func(quota int) { config.AnonymousStorageQuota = quota }(config.AnonymousStorageQuota)
+	config.AnonymousStorageQuota = 3
+	if resp := do(t, "POST", srv.URL+"/logs/import?format=ndjson", ndjson, withCookie); resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("over quota = %d, want 413", resp.StatusCode)
+	}
+
+	for _, bad := range []string{"?format=ndjson&x", "?format=har", "?format=xml"} {
+		if resp := do(t, "POST", srv.URL+"/logs/import"+bad, "not json", withCookie); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s = %d, want 400", bad, resp.StatusCode)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	srv := newTestServer(t)
+	withCookie := func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "webhook_token", Value: testToken}) }
+	for _, body := range []string{`{"id":"evt_1","amount":100,"created_at":1}`, `{"id":"evt_2","amount":250,"created_at":2}`} {
+		do(t, "POST", srv.URL+"/hooks/"+testToken, body, withCookie)
+	}
+	logs := listLogs(t, srv.URL+"/logs?order=asc", withCookie).Items
+	if len(logs) != 2 {
+		t.Fatalf("logs = %d", len(logs))
+	}
+
+	target := srv.URL + "/logs/diff?a=" + logs[0].ID + "&b=" + logs[1].ID
+	var res struct {
+		Equal bool
+		Body  struct {
+			Format  string
+			Changes []struct{ Op, Path string }
+		}
+	}
+	resp := do(t, "GET", target+"&ignore=/id,/*_at&ignore_headers=Content-Length", "", withCookie)
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("diff = %d, %v", resp.StatusCode, err)
+	}
+	if res.Equal || res.Body.Format != "json" || len(res.Body.Changes) != 1 || res.Body.Changes[0].Path != "/amount" {
+		t.Errorf("diff = %+v", res)
+	}
+
+	for query, want := range map[string]int{
+		"?a=" + logs[0].ID:                                       http.StatusBadRequest,
+		"?a=" + logs[0].ID + "&b=missing":                        http.StatusNotFound,
+		"?a=" + logs[0].ID + "&b=" + logs[1].ID + "&ignore=nope": http.StatusBadRequest,
+	} {
+		if resp := do(t, "GET", srv.URL+"/logs/diff"+query, "", withCookie); resp.StatusCode != want {
+			t.Errorf("%s = %d, want %d", query, resp.StatusCode, want)
+		}
+	}
+}
+
+func TestBaselines(t *testing.T) {
+	srv := newTestServer(t)
+	withCookie := func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "webhook_token", Value: testToken}) }
+	push := func(body string) {
+		resp := do(t, "POST", srv.URL+"/hooks/"+testToken, body, func(r *http.Request) {
+			withCookie(r)
+			r.Header.Set("X-GitHub-Event", "push")
+		})
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("POST /hooks = %d", resp.StatusCode)
+		}
+	}
+	push(`{"ref":"main","size":1}`)
+	push(`{"ref":"main","size":2}`)
+
+	create := `{"name":"pushes","filter":{"provider":"github"}}`
+	if resp := do(t, "POST", srv.URL+"/baselines", create, withCookie); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create = %d", resp.StatusCode)
+	}
+	if resp := do(t, "POST", srv.URL+"/baselines", create, withCookie); resp.StatusCode != http.StatusConflict {
+		t.Errorf("duplicate = %d, want 409", resp.StatusCode)
+	}
+	if resp := do(t, "POST", srv.URL+"/baselines", `{"name":"none","ids":["missing"]}`, withCookie); resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("empty selection = %d, want 422", resp.StatusCode)
+	}
+
+	// New captures of the same event are flagged as they arrive
+	push(`{"ref":"dev","size":"3"}`)
+	logs := listLogs(t, srv.URL+"/logs", withCookie).Items
+	if len(logs[0].Drift) != 2 || logs[0].Drift[0].Baseline != "pushes" || logs[0].Drift[0].Path != "/ref" || logs[1].Drift != nil {
+		t.Errorf("drift = %+v", logs[0].Drift)
+	}
+
+	var report struct {
+		Checked, Drifted int
+		Findings         []struct{ Kind, Path string }
+	}
+	resp := do(t, "GET", srv.URL+"/baselines/pushes/drift", "", withCookie)
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil || report.Checked != 1 || report.Drifted != 1 || len(report.Findings) != 2 {
+		t.Errorf("report = %+v, %v", report, err)
+	}
+
+	if resp := do(t, "DELETE", srv.URL+"/baselines/pushes", "", withCookie); resp.StatusCode != http.StatusOK {
+		t.Errorf("delete = %d", resp.StatusCode)
+	}
+	if resp := do(t, "GET", srv.URL+"/baselines/pushes/drift", "", withCookie); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("deleted baseline = %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestSchemas(t *testing.T) {
+	srv := newTestServer(t)
+	withCookie := func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "webhook_token", Value: testToken}) }
+	for _, body := range []string{`{"ref":"main","forced":false}`, `{"ref":"dev"}`} {
+		do(t, "POST", srv.URL+"/hooks/"+testToken, body, func(r *http.Request) {
+			withCookie(r)
+			r.Header.Set("X-GitHub-Event", "push")
+		})
+	}
+
+	var list []struct {
+		TypeName string `json:"type_name"`
+		Samples  int
+	}
+	json.NewDecoder(do(t, "GET", srv.URL+"/schemas", "", withCookie).Body).Decode(&list)
+	if len(list) != 1 || list[0].TypeName != "GithubPush" || list[0].Samples != 2 {
+		t.Fatalf("schemas = %+v", list)
+	}
+
+	resp := do(t, "GET", srv.URL+"/schemas/infer?provider=github&event_type=push&format=typescript", "", withCookie)
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "  forced?: boolean;") ||
+		resp.Header.Get("Content-Disposition") != `attachment; filename="GithubPush.ts"` {
+		t.Errorf("typescript = %d %s\n%s", resp.StatusCode, resp.Header.Get("Content-Disposition"), body)
+	}
+
+	var s struct{ Required []string }
+	resp = do(t, "GET", srv.URL+"/schemas/infer?provider=github&event_type=push", "", withCookie)
+	if err := json.NewDecoder(resp.Body).Decode(&s); err != nil || len(s.Required) != 1 || s.Required[0] != "ref" {
+		t.Errorf("json schema = %+v, %v", s, err)
+	}
+
+	for query, want := range map[string]int{
+		"?provider=stripe": http.StatusNotFound,
+		"?provider=github&event_type=push&format=rust": http.StatusBadRequest,
+	} {
+		if resp := do(t, "GET", srv.URL+"/schemas/infer"+query, "", withCookie); resp.StatusCode != want {
+			t.Errorf("%s = %d, want %d", query, resp.StatusCode, want)
+		}
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	srv := newTestServer(t)
+	withCookie := func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "webhook_token", Value: testToken}) }
+	do(t, "POST", srv.URL+"/hooks/"+testToken+"/gh?x=1", `{"ref":"main"}`, withCookie)
+	id := listLogs(t, srv.URL+"/logs", withCookie).Items[0].ID
+
+	resp := do(t, "GET", srv.URL+"/logs/"+id+"/snippet?lang=python", "", withCookie)
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), srv.URL+"/hooks/"+testToken+"/gh?x=1") || !strings.Contains(string(body), `b'{"ref":"main"}'`) {
+		t.Errorf("python = %d\n%s", resp.StatusCode, body)
+	}
+
+	do(t, "PUT", srv.URL+"/endpoint/config", `{"secrets":{"github":"s3cret"}}`, withCookie)
+	resp = do(t, "GET", srv.URL+"/logs/"+id+"/snippet?sign=github&url=https://example.test/in", "", withCookie)
+	body, _ = io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "'https://example.test/in'") || !strings.Contains(string(body), "X-Hub-Signature-256: sha256=") {
+		t.Errorf("signed curl = %d\n%s", resp.StatusCode, body)
+	}
+
+	for path, want := range map[string]int{
+		"/logs/" + id + "/snippet?lang=cobol":  http.StatusBadRequest,
+		"/logs/" + id + "/snippet?url=ftp://x": http.StatusBadRequest,
+		"/logs/" + id + "/snippet?sign=nope":   http.StatusBadRequest,
+		"/logs/missing/snippet":                http.StatusNotFound,
+	} {
+		if resp := do(t, "GET", srv.URL+path, "", withCookie); resp.StatusCode != want {
+			t.Errorf("GET %s = %d, want %d", path, resp.StatusCode, want)
+		}
+	}
+}
+
+func TestTriage(t *testing.T) {
+	srv := newTestServer(t)
+	withCookie := func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "webhook_token", Value: testToken}) }
+	for i := 0; i < 3; i++ {
+		do(t, "POST", srv.URL+"/hooks/"+testToken, `{"n":1}`, withCookie)
+	}
+	items := listLogs(t, srv.URL+"/logs", withCookie).Items
+	id := items[0].ID
+
+	var updated models.WebhookPayload
+	resp := do(t, "PATCH", srv.URL+"/logs/"+id, `{"tags":[" bug ","bug","triaged"],"note":"looks off","pinned":true,"read":true}`, withCookie)
+	if err := json.NewDecoder(resp.Body).Decode(&updated); err != nil || resp.StatusCode != http.StatusOK ||
+		len(updated.Tags) != 2 || updated.Note != "looks off" || !updated.Pinned || !updated.Read {
+		t.Fatalf("PATCH = %d %+v, %v", resp.StatusCode, updated, err)
+	}
+	ttl, _ := handlers.Store.CaptureTTL(context.Background(), testToken, id)
+	if ttl != 0 && ttl < config.PinnedCaptureTTL-time.Minute {
+		t.Errorf("pinned capture expires in %s", ttl)
+	}
+
+	// Fields left out stay as they were
+	do(t, "PATCH", srv.URL+"/logs/"+id, `{"read":false}`, withCookie)
+	if got := listLogs(t, srv.URL+"/logs?read=false&pinned=true", withCookie).Items; len(got) != 1 || got[0].Note != "looks off" {
+		t.Errorf("read=false&pinned=true = %+v", got)
+	}
+	do(t, "PATCH", srv.URL+"/logs/"+items[1].ID, `{"read":true}`, withCookie)
+
+	var status struct{ Unread, Pinned int }
+	json.NewDecoder(do(t, "GET", srv.URL+"/status", "", withCookie).Body).Decode(&status)
+	if status.Unread != 2 || status.Pinned != 1 {
+		t.Errorf("status = %+v", status)
+	}
+
+	for body, want := range map[string]int{
+		`{"tags":[""]}`:    http.StatusBadRequest,
+		`{"pinned":"yes"}`: http.StatusBadRequest,
+	} {
+		if resp := do(t, "PATCH", srv.URL+"/logs/"+id, body, withCookie); resp.StatusCode != want {
+			t.Errorf("PATCH %s = %d, want %d", body, resp.StatusCode, want)
+		}
+	}
+	if resp := do(t, "PATCH", srv.URL+"/logs/missing", `{"read":true}`, withCookie); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("PATCH missing = %d", resp.StatusCode)
+	}
+
+	quota := config.AnonymousPinQuota
+	config.AnonymousPinQuota = 1
+	t.Cleanup(func() { config.AnonymousPinQuota = quota })
+	if resp := do(t, "PATCH", srv.URL+"/logs/"+items[2].ID, `{"pinned":true}`, withCookie); resp.StatusCode != http.StatusConflict {
+		t.Errorf("pin over quota = %d", resp.StatusCode)
+	}
+}
+
+func TestRetention(t *testing.T) {
+	srv := newTestServer(t)
+	withCookie := func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "webhook_token", Value: testToken}) }
+
+	for body, want := range map[string]int{
+		`{"retention":{"ttl":"48h"}}`:                 http.StatusBadRequest,
+		`{"retention":{"ttl":"10s"}}`:                 http.StatusBadRequest,
+		`{"retention":{"max_captures":1000000}}`:      http.StatusBadRequest,
+		`{"retention":{"ttl":"1h","max_captures":2}}`: http.StatusOK,
+	} {
+		if resp := do(t, "PUT", srv.URL+"/endpoint/config", body, withCookie); resp.StatusCode != want {
+			t.Errorf("PUT %s = %d, want %d", body, resp.StatusCode, want)
+		}
+	}
+
+	do(t, "POST", srv.URL+"/hooks/"+testToken, `{"n":0}`, withCookie)
+	first := listLogs(t, srv.URL+"/logs", withCookie).Items[0].ID
+	do(t, "PATCH", srv.URL+"/logs/"+first, `{"pinned":true}`, withCookie)
+	for i := 1; i <= 3; i++ {
+		do(t, "POST", srv.URL+"/hooks/"+testToken, fmt.Sprintf(`{"n":%d}`, i), withCookie)
+	}
+
+	// The pinned capture doesn't count and the oldest unpinned one was evicted
+	items := listLogs(t, srv.URL+"/logs?order=asc", withCookie).Items
+	if len(items) != 3 || items[0].ID != first || items[1].Body != `{"n":2}` {
+		t.Fatalf("kept %+v", items)
+	}
+	ttl, _ := handlers.Store.CaptureTTL(context.Background(), testToken, items[2].ID)
+	if ttl <= 0 || ttl > time.Hour {
+		t.Errorf("capture expires in %s, want the endpoint's 1h", ttl)
+	}
+
+	var status struct {
+		Retention   string
+		MaxCaptures int `json:"max_captures"`
+	}
+	json.NewDecoder(do(t, "GET", srv.URL+"/status", "", withCookie).Body).Decode(&status)
+	if status.Retention != "1h0m0s" || status.MaxCaptures != 2 {
+		t.Errorf("status = %+v", status)
+	}
+}
+
+func TestBulk(t *testing.T) {
+	srv := newTestServer(t)
+	withCookie := func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "webhook_token", Value: testToken}) }
+	for _, event := range []string{"push", "push", "issues"} {
+		do(t, "POST", srv.URL+"/hooks/"+testToken, `{"ok":true}`, func(r *http.Request) {
+			withCookie(r)
+			r.Header.Set("X-GitHub-Event", event)
+		})
+	}
+	do(t, "POST", srv.URL+"/hooks/"+testToken, `{"ok":true}`, withCookie)
+
+	bulk := func(body string) (got struct{ Matched, Affected int }, status int) {
+		resp := do(t, "POST", srv.URL+"/logs/bulk", body, withCookie)
+		json.NewDecoder(resp.Body).Decode(&got)
+		return got, resp.StatusCode
+	}
+
+	if got, status := bulk(`{"action":"tag","tags":["ci"],"filter":{"header":"X-GitHub-Event:push"}}`); status != http.StatusOK || got.Matched != 2 || got.Affected != 2 {
+		t.Errorf("tag = %d %+v", status, got)
+	}
+	if got, _ := bulk(`{"action":"tag","tags":["ci"],"filter":{"provider":"github"}}`); got.Matched != 3 || got.Affected != 1 {
+		t.Errorf("tag again = %+v", got)
+	}
+	if got, _ := bulk(`{"action":"read","filter":{"tag":"ci"}}`); got.Affected != 3 {
+		t.Errorf("read = %+v", got)
+	}
+	if items := listLogs(t, srv.URL+"/logs?read=false", withCookie).Items; len(items) != 1 || len(items[0].Tags) != 0 {
+		t.Errorf("unread = %+v", items)
+	}
+
+	ids := listLogs(t, srv.URL+"/logs?header=X-GitHub-Event:issues", withCookie).Items
+	if got, _ := bulk(`{"action":"untag","tags":["ci"],"ids":["` + ids[0].ID + `"]}`); got.Affected != 1 {
+		t.Errorf("untag = %+v", got)
+	}
+	if got, _ := bulk(`{"action":"delete","filter":{"tag":"ci"}}`); got.Affected != 2 {
+		t.Errorf("delete = %+v", got)
+	}
+	if page := listLogs(t, srv.URL+"/logs", withCookie); total(page) != 2 {
+		t.Errorf("left %d captures", total(page))
+	}
+
+	for _, body := range []string{`{"action":"delete"}`, `{"action":"archive","filter":{}}`, `{"action":"tag","filter":{}}`, `{"action":"delete","filter":{"search":"$.a >"}}`} {
+		if _, status := bulk(body); status != http.StatusBadRequest {
+			t.Errorf("%s = %d", body, status)
+		}
+	}
+	if got, _ := bulk(`{"action":"delete","filter":{}}`); got.Affected != 2 {
+		t.Errorf("delete everything = %+v", got)
+	}
+}
+
+func TestShares(t *testing.T) {
+	srv := newTestServer(t)
+	withCookie := func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "webhook_token", Value: testToken}) }
+	for _, event := range []string{"push", "issues"} {
+		do(t, "POST", srv.URL+"/hooks/"+testToken, `{"ok":true}`, func(r *http.Request) {
+			withCookie(r)
+			r.Header.Set("X-Api-Key", "secret")
+			r.Header.Set("X-GitHub-Event", event)
+		})
+	}
+	issues := listLogs(t, srv.URL+"/logs?header=X-GitHub-Event:issues", withCookie).Items[0]
+
+	create := func(body string) (link struct{ ID, URL string }, status int) {
+		resp := do(t, "POST", srv.URL+"/shares", body, withCookie)
+		json.NewDecoder(resp.Body).Decode(&link)
+		return link, resp.StatusCode
+	}
+	view := func(url string, mod func(*http.Request)) (*http.Response, string) {
+		resp := do(t, "GET", url, "", mod)
+		body, _ := io.ReadAll(resp.Body)
+		return resp, string(body)
+	}
+
+	link, status := create(`{"capture_id":"` + issues.ID + `"}`)
+	if status != http.StatusCreated {
+		t.Fatalf("create = %d", status)
+	}
+	resp, body := view(link.URL, nil)
+	var shared models.WebhookPayload
+	json.Unmarshal([]byte(body), &shared)
+	if resp.StatusCode != http.StatusOK || shared.ID != issues.ID {
+		t.Fatalf("view = %d %s", resp.StatusCode, body)
+	}
+	if _, ok := shared.Headers["Cookie"]; ok || shared.Headers["X-Api-Key"][0] != "[redacted]" || strings.Contains(body, testToken) {
+		t.Errorf("shared headers = %v", shared.Headers)
+	}
+	if resp, _ := view(link.URL+"x", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("tampered signature = %d", resp.StatusCode)
+	}
+
+	filtered, _ := create(`{"filter":{"header":"X-GitHub-Event:push"},"redact_headers":[],"password":"hunter2"}`)
+	if resp, _ := view(filtered.URL, nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("no password = %d", resp.StatusCode)
+	}
+	withPassword := func(r *http.Request) { r.Header.Set("X-Share-Password", "hunter2") }
+	resp, body = view(filtered.URL+"&limit=10", withPassword)
+	var page query.Page
+	json.Unmarshal([]byte(body), &page)
+	if resp.StatusCode != http.StatusOK || len(page.Items) != 1 || page.Items[0].Headers["X-Api-Key"][0] != "secret" {
+		t.Errorf("filtered view = %d %s", resp.StatusCode, body)
+	}
+	// Wrong passwords lock the link out, even for the right one
+	for i := 0; i < config.SharePasswordMaxFailures; i++ {
+		if resp, _ := view(filtered.URL, func(r *http.Request) { r.Header.Set("X-Share-Password", "guess") }); resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("wrong password %d = %d", i, resp.StatusCode)
+		}
+	}
+	if resp, _ := view(filtered.URL, withPassword); resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("password after too many failures = %d", resp.StatusCode)
+	}
+	handlers.Store.ResetUsage(context.Background(), "share_password:link:"+filtered.ID)
+	if resp, _ := view(filtered.URL, withPassword); resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("expected the client IP to stay locked out, got %d", resp.StatusCode)
+	}
+	handlers.Store.ResetUsage(context.Background(), "share_password:ip:127.0.0.1")
+
+	if resp, body := view(filtered.URL+"&header=X-GitHub-Event:issues", withPassword); strings.Count(body, `"id":`) != 1 || strings.Contains(body, issues.ID) {
+		t.Errorf("widened filter = %d %s", resp.StatusCode, body)
+	}
+
+	for _, body := range []string{`{}`, `{"capture_id":"x","filter":{}}`, `{"filter":{},"ttl":"48h"}`, `{"filter":{},"redact_headers":["Bad Name"]}`} {
+		if _, status := create(body); status != http.StatusBadRequest {
+			t.Errorf("%s = %d", body, status)
+		}
+	}
+	if _, status := create(`{"capture_id":"missing"}`); status != http.StatusNotFound {
+		t.Errorf("missing capture = %d", status)
+	}
+
+	var links []struct{ ID string }
+	json.NewDecoder(do(t, "GET", srv.URL+"/shares", "", withCookie).Body).Decode(&links)
+	if len(links) != 2 || links[0].ID != link.ID {
+		t.Errorf("list = %+v", links)
+	}
+	if resp := do(t, "DELETE", srv.URL+"/shares/"+link.ID, "", withCookie); resp.StatusCode != http.StatusOK {
+		t.Errorf("revoke = %d", resp.StatusCode)
+	}
+	if resp, _ := view(link.URL, nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("revoked link = %d", resp.StatusCode)
+	}
+	if resp := do(t, "DELETE", srv.URL+"/shares/"+filtered.ID, "", func(r *http.Request) {
+		r.AddCookie(&http.Cookie{Name: "webhook_token", Value: otherToken})
+	}); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("revoking another token's link = %d", resp.StatusCode)
+	}
+}
+
+func TestInternalTargetsRefused(t *testing.T) {
+	srv := newTestServer(t)
+	withCookie := func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "webhook_token", Value: testToken}) }
+
+	for _, target := range []string{"http://127.0.0.1:6379", "http://localhost/admin", "http://169.254.169.254/latest/meta-data/", "http://10.0.0.2"} {
+		body := `{"forwards":[{"url":"` + target + `"}]}`
+		if resp := do(t, "PUT", srv.URL+"/endpoint/config", body, withCookie); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("forward to %s = %d", target, resp.StatusCode)
+		}
+		body = `{"url":"` + target + `"}`
+		if resp := do(t, "POST", srv.URL+"/fixtures/github.push/send", body, withCookie); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("fixture send to %s = %d", target, resp.StatusCode)
+		}
+	}
+	if resp := do(t, "PUT", srv.URL+"/endpoint/config", `{"forwards":[{"url":"https://staging.example.com/in"}]}`, withCookie); resp.StatusCode != http.StatusOK {
+		t.Errorf("public forward = %d", resp.StatusCode)
+	}
+}
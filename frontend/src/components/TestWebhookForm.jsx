@@ -1,24 +1,57 @@
 import { useState } from "react"
 
+// Twilio signs form parameters, so its deliveries are form-encoded rather than JSON
+const TWILIO_SAMPLE = "MessageSid=SM0000000000000000000000000000000&From=%2B15551234567&To=%2B15557654321&Body=hello!"
+
 export default function TestWebhookForm({ token, onSent }) {
   const [jsonBody, setJsonBody] = useState(`{ "event": "ping", "message": "hello!" }`)
+  const [destination, setDestination] = useState("self")
+  const [targetUrl, setTargetUrl] = useState("")
+  const [sign, setSign] = useState("")
   const [sending, setSending] = useState(false)
   const [error, setError] = useState(null)
+  const [simulation, setSimulation] = useState(null)
+
+  const sendToSelf = async () => {
+    const res = await fetch(`/hooks/${token}`, {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: jsonBody,
+    })
+
+    if (!res.ok) throw new Error(await res.text())
+    onSent() // let parent refresh logs
+  }
+
+  // Deliver to an external URL through the server, signed like a real provider
+  const sendToExternal = async () => {
+    const res = await fetch("/simulations", {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({
+        url: targetUrl,
+        body: jsonBody,
+        sign: sign || undefined,
+        headers: sign === "twilio" ? { "Content-Type": "application/x-www-form-urlencoded" } : undefined,
+      }),
+    })
+
+    if (!res.ok) throw new Error(await res.text())
+    setSimulation(await res.json())
+  }
 
   const handleSubmit = async (e) => {
     e.preventDefault()
     setSending(true)
     setError(null)
+    setSimulation(null)
 
     try {
-      const res = await fetch(`/hooks/${token}`, {
-        method: "POST",
-        headers: { "Content-Type": "application/json" },
-        body: jsonBody,
-      })
-
-      if (!res.ok) throw new Error(await res.text())
-      onSent() // let parent refresh logs
+      if (destination === "self") {
+        await sendToSelf()
+      } else {
+        await sendToExternal()
+      }
     } catch (err) {
       setError(err.message || "Failed to send")
     } finally {
@@ -30,6 +63,54 @@ export default function TestWebhookForm({ token, onSent }) {
     <div className="bg-white p-4 rounded border">
       <h3 className="text-lg font-semibold mb-2">Send Test Webhook</h3>
       <form onSubmit={handleSubmit}>
+        <div className="flex gap-4 mb-2 text-sm">
+          <label>
+            <input
+              type="radio"
+              checked={destination === "self"}
+              onChange={() => setDestination("self")}
+              className="mr-1"
+            />
+            This endpoint
+          </label>
+          <label>
+            <input
+              type="radio"
+              checked={destination === "external"}
+              onChange={() => setDestination("external")}
+              className="mr-1"
+            />
+            External URL
+          </label>
+        </div>
+        {destination === "external" && (
+          <div className="flex gap-2 mb-2">
+            <input
+              type="url"
+              required
+              placeholder="https://staging.example.com/webhooks"
+              value={targetUrl}
+              onChange={(e) => setTargetUrl(e.target.value)}
+              className="flex-1 border rounded p-2 text-sm"
+            />
+            <select
+              value={sign}
+              onChange={(e) => {
+                setSign(e.target.value)
+                if (e.target.value === "twilio") setJsonBody(TWILIO_SAMPLE)
+              }}
+              className="border rounded p-2 text-sm"
+            >
+              <option value="">Unsigned</option>
+              <option value="github">GitHub</option>
+              <option value="stripe">Stripe</option>
+              <option value="standard">Standard Webhooks</option>
+              <option value="slack">Slack</option>
+              <option value="shopify">Shopify</option>
+              <option value="twilio">Twilio</option>
+            </select>
+          </div>
+        )}
         <textarea
           rows={6}
           value={jsonBody}
@@ -37,6 +118,19 @@ export default function TestWebhookForm({ token, onSent }) {
           className="w-full font-mono border rounded p-2 text-sm"
         />
         {error && <p className="text-red-600 mt-2">{error}</p>}
+        {simulation && (
+          <div className="mt-2 text-sm">
+            <p>
+              Delivery <span className="font-mono">{simulation.id}</span>: {simulation.state}
+              {simulation.next_attempt_at && ` (next attempt ${new Date(simulation.next_attempt_at).toLocaleString()})`}
+            </p>
+            {simulation.attempts.map((a) => (
+              <p key={a.attempt} className="font-mono text-gray-600">
+                #{a.attempt} → {a.error || a.status_code} in {a.latency_ms}ms
+              </p>
+            ))}
+          </div>
+        )}
         <button
           type="submit"
           disabled={sending}
@@ -14,9 +14,9 @@ export default function Dashboard() {
 
 
 const refreshLogs = useCallback(() => {
-  fetch("/logs")
+  fetch("/logs?limit=100")
     .then((res) => res.json())
-    .then(setLogs)
+    .then((page) => setLogs(page.items))
     .catch(() => setLogs([]))
 }, [])
 